@@ -0,0 +1,54 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/apistats"
+)
+
+// accessLogMiddleware writes one line per API request to logger, tagged with
+// the request ID so a failing call reported by a user can be found again. The
+// request is also recorded in apistats for the aggregated request metrics.
+func accessLogMiddleware(logger *log.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !strings.HasPrefix(c.Request().URL.Path, "/api/") {
+				return next(c)
+			}
+
+			start := time.Now()
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+			}
+
+			user, _ := c.Get("user").(string)
+			if user == "" {
+				user = "-"
+			}
+
+			latency := time.Since(start)
+			logger.Printf("%s %s %s %s %s %d %s",
+				time.Now().Format(time.RFC3339),
+				c.Response().Header().Get(echo.HeaderXRequestID),
+				user,
+				c.Request().Method,
+				c.Path(),
+				c.Response().Status,
+				latency.Round(time.Microsecond),
+			)
+			apistats.Add(apistats.Record{
+				Time:    start,
+				Method:  c.Request().Method,
+				Route:   c.Path(),
+				User:    user,
+				Status:  c.Response().Status,
+				Latency: float64(latency.Microseconds()) / 1000,
+			})
+			return nil
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/shirou/gopsutil/disk"
+	"pkg.bijsven.nl/MiniMC/pkg"
+	"pkg.bijsven.nl/MiniMC/pkg/alerts"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+)
+
+func envFloat(name string, def float64) float64 {
+	if f, err := strconv.ParseFloat(os.Getenv(name), 64); err == nil {
+		return f
+	}
+	return def
+}
+
+func registerAlertRules() {
+	minTPS := envFloat("ALERT_MIN_TPS", 15)
+	maxMemory := envFloat("ALERT_MAX_MEMORY_PERCENT", 90)
+	minDiskMB := envFloat("ALERT_MIN_DISK_MB", 1024)
+
+	alerts.Register(alerts.Rule{
+		Name:     "low_tps",
+		Severity: "warning",
+		Check: func() (bool, float64, string) {
+			tps, ok := server.AverageTPS()
+			if !ok || !server.GetStatus() {
+				return false, tps, ""
+			}
+			return tps < minTPS, tps, fmt.Sprintf("TPS is %.1f (threshold %.1f)", tps, minTPS)
+		},
+	})
+
+	alerts.Register(alerts.Rule{
+		Name:     "memory_high",
+		Severity: "warning",
+		Check: func() (bool, float64, string) {
+			used, limit := pkg.CgroupMemory()
+			if limit == 0 {
+				return false, 0, ""
+			}
+			percent := float64(used) / float64(limit) * 100
+			return percent > maxMemory, percent, fmt.Sprintf("memory usage at %.1f%% of the container limit", percent)
+		},
+	})
+
+	alerts.Register(alerts.Rule{
+		Name:     "disk_low",
+		Severity: "critical",
+		Check: func() (bool, float64, string) {
+			usage, err := disk.Usage(MinecraftDir)
+			if err != nil {
+				return false, 0, ""
+			}
+			freeMB := float64(usage.Free) / 1024 / 1024
+			return freeMB < minDiskMB, freeMB, fmt.Sprintf("%.0f MB disk space left", freeMB)
+		},
+	})
+}
+
+func listAlerts(c echo.Context) error {
+	return c.JSON(http.StatusOK, alerts.List())
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/auth"
+	"pkg.bijsven.nl/MiniMC/pkg/security"
+)
+
+// authMiddleware accepts either a session cookie or BasicAuth credentials.
+// Unlike echo's BasicAuth middleware it never sends a WWW-Authenticate
+// challenge, so browsers don't pop up their own login prompt. The frontend
+// itself is served without authentication and logs in through
+// /api/auth/session.
+func authMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		path := c.Request().URL.Path
+		if !strings.HasPrefix(path, "/api/") {
+			return next(c)
+		}
+		if path == "/api/auth/session" && c.Request().Method == http.MethodPost {
+			return next(c)
+		}
+		if path == publicStatusPath && c.Request().Method == http.MethodGet {
+			return next(c)
+		}
+
+		if cookie, err := c.Cookie(auth.CookieName); err == nil {
+			if s := auth.Lookup(cookie.Value); s != nil {
+				c.Set("user", s.Username)
+				return next(c)
+			}
+		}
+
+		if token := c.QueryParam("share"); token != "" && c.Request().Method == http.MethodGet {
+			if scope, ok := shareScopes[path]; ok && sharesEnabled(scope) {
+				if s := auth.LookupShare(token, scope); s != nil {
+					c.Set("share", s)
+					return next(c)
+				}
+			}
+			security.Emit(security.Event{
+				Kind: security.InvalidShare,
+				IP:   c.RealIP(),
+				Path: path,
+			})
+		}
+
+		if username, password, ok := c.Request().BasicAuth(); ok {
+			if remaining := auth.Locked(c.RealIP()); remaining > 0 {
+				return lockedOut(c, username, remaining)
+			}
+			if auth.CheckCredentials(username, password) {
+				auth.ClearFailures(c.RealIP())
+				c.Set("user", username)
+				return next(c)
+			}
+			loginFailed(c, username)
+		}
+
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: "Login required",
+		})
+	}
+}
+
+// loginFailed records a failed login and locks the address out once it has
+// failed too often.
+func loginFailed(c echo.Context, username string) {
+	security.Emit(security.Event{
+		Kind: security.LoginFailed,
+		User: username,
+		IP:   c.RealIP(),
+		Path: c.Request().URL.Path,
+	})
+	if auth.RecordFailure(c.RealIP()) {
+		security.Emit(security.Event{
+			Kind:   security.Lockout,
+			User:   username,
+			IP:     c.RealIP(),
+			Detail: "too many failed logins",
+		})
+	}
+}
+
+func lockedOut(c echo.Context, username string, remaining time.Duration) error {
+	security.Emit(security.Event{
+		Kind: security.LockedLogin,
+		User: username,
+		IP:   c.RealIP(),
+		Path: c.Request().URL.Path,
+	})
+	c.Response().Header().Set("Retry-After", fmt.Sprint(int(remaining.Seconds())+1))
+	return c.JSON(http.StatusTooManyRequests, ErrorResponse{
+		Error:   "locked_out",
+		Message: "Too many failed logins, try again in " + remaining.Round(time.Second).String(),
+	})
+}
+
+func sessionTTL() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("SESSION_TTL")); err == nil && d > 0 {
+		return d
+	}
+	return 24 * time.Hour
+}
+
+func loginHandler(c echo.Context) error {
+	var request struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+
+	if remaining := auth.Locked(c.RealIP()); remaining > 0 {
+		return lockedOut(c, request.Username, remaining)
+	}
+
+	if !auth.CheckCredentials(request.Username, request.Password) {
+		loginFailed(c, request.Username)
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "invalid_credentials",
+			Message: "Invalid username or password",
+		})
+	}
+
+	auth.ClearFailures(c.RealIP())
+
+	session, err := auth.NewSession(request.Username, sessionTTL())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "session_error",
+			Message: err.Error(),
+		})
+	}
+
+	c.SetCookie(&http.Cookie{
+		Name:     auth.CookieName,
+		Value:    session.Token,
+		Path:     "/",
+		Expires:  session.Expires,
+		HttpOnly: true,
+		Secure:   c.IsTLS(),
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	return c.JSON(http.StatusOK, session)
+}
+
+func logoutHandler(c echo.Context) error {
+	if cookie, err := c.Cookie(auth.CookieName); err == nil {
+		auth.Revoke(cookie.Value)
+	}
+
+	c.SetCookie(&http.Cookie{
+		Name:     auth.CookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   c.IsTLS(),
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func whoamiHandler(c echo.Context) error {
+	if cookie, err := c.Cookie(auth.CookieName); err == nil {
+		if s := auth.Lookup(cookie.Value); s != nil {
+			return c.JSON(http.StatusOK, s)
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"username": c.Get("user").(string),
+	})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+)
+
+// snapshotSaveTimeout is how long snapshots wait for the server to flush the
+// world before giving up.
+const snapshotSaveTimeout = 30 * time.Second
+
+func getAutosave(c echo.Context) error {
+	return c.JSON(http.StatusOK, server.GetAutosave())
+}
+
+func updateAutosave(c echo.Context) error {
+	var request struct {
+		Enabled  *bool   `json:"enabled"`
+		Interval *string `json:"interval"`
+	}
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+
+	if request.Interval != nil {
+		interval := time.Duration(0)
+		if *request.Interval != "" {
+			d, err := time.ParseDuration(*request.Interval)
+			if err != nil || d < time.Minute {
+				return c.JSON(http.StatusBadRequest, ErrorResponse{
+					Error:   "invalid_interval",
+					Message: "Interval must be a duration of at least 1m, e.g. \"10m\"",
+				})
+			}
+			interval = d
+		}
+		server.SetAutosaveInterval(interval)
+	}
+
+	if request.Enabled != nil {
+		if err := server.SetAutosave(*request.Enabled); err != nil {
+			code := "server_not_running"
+			if errors.Is(err, server.ErrProxy) {
+				code = "server_is_proxy"
+			}
+			return c.JSON(http.StatusConflict, ErrorResponse{
+				Error:   code,
+				Message: err.Error(),
+			})
+		}
+	}
+
+	return c.JSON(http.StatusOK, server.GetAutosave())
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/backup"
+	"pkg.bijsven.nl/MiniMC/pkg/hooks"
+	"pkg.bijsven.nl/MiniMC/pkg/jobs"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+)
+
+func backupError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, backup.ErrNotFound):
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "backup_not_found",
+			Message: err.Error(),
+		})
+	case errors.Is(err, backup.ErrInvalidPath):
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_path",
+			Message: err.Error(),
+		})
+	case errors.Is(err, backup.ErrUnknownProfile):
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "unknown_profile",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(http.StatusInternalServerError, ErrorResponse{
+		Error:   "backup_error",
+		Message: err.Error(),
+	})
+}
+
+func listBackups(c echo.Context) error {
+	list, err := backup.List()
+	if err != nil {
+		return backupError(c, err)
+	}
+	return c.JSON(http.StatusOK, list)
+}
+
+// runBackup creates a backup with saves paused and finishes job with the
+// result.
+func runBackup(profile string, paths []string, job *jobs.Job) (*backup.Backup, error) {
+	if err := hooks.Run(hooks.PreBackup, MinecraftDir, map[string]string{"MINIMC_BACKUP_PROFILE": profile}); err != nil {
+		job.Finish(err)
+		log.Println("[e] Backup cancelled:", err)
+		return nil, err
+	}
+
+	resume, err := server.PauseSaves(snapshotSaveTimeout)
+	if err != nil {
+		job.Finish(err)
+		log.Println("[e] Backup failed:", err)
+		return nil, err
+	}
+	b, err := backup.Create(profile, paths, job)
+	resume()
+	job.Finish(err)
+	if err != nil {
+		log.Println("[e] Backup failed:", err)
+		return nil, err
+	}
+	log.Printf("[i] Backup %s created (%d files, %.2f MB)", b.ID, b.Files, float64(b.Size)/1024/1024)
+
+	hooks.Run(hooks.PostBackup, MinecraftDir, map[string]string{
+		"MINIMC_BACKUP_PROFILE": profile,
+		"MINIMC_BACKUP_ID":      b.ID,
+		"MINIMC_BACKUP_PATH":    backup.ArchivePath(b.ID),
+	})
+	return b, nil
+}
+
+func createBackup(c echo.Context) error {
+	var request struct {
+		Profile string   `json:"profile"`
+		Paths   []string `json:"paths"`
+	}
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+
+	paths := request.Paths
+	if request.Profile != "" {
+		profile, err := backup.FindProfile(request.Profile)
+		if err != nil {
+			return backupError(c, err)
+		}
+		paths = profile.Paths
+	}
+
+	job := jobs.New("backup")
+	go runBackup(request.Profile, paths, job)
+
+	return c.JSON(http.StatusAccepted, map[string]string{
+		"message": "Backup started",
+		"job":     job.ID(),
+	})
+}
+
+func deleteBackup(c echo.Context) error {
+	if err := backup.Delete(c.Param("id")); err != nil {
+		return backupError(c, err)
+	}
+	log.Printf("[i] Backup %s deleted", c.Param("id"))
+	return c.NoContent(http.StatusNoContent)
+}
+
+func restoreBackup(c echo.Context) error {
+	var request struct {
+		Paths []string `json:"paths"`
+	}
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+
+	if server.GetStatus() {
+		return c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "server_running",
+			Message: "Stop the server before restoring a backup",
+		})
+	}
+
+	if _, err := backup.Get(c.Param("id")); err != nil {
+		return backupError(c, err)
+	}
+
+	id := c.Param("id")
+	job := jobs.New("restore")
+	go func() {
+		restored, err := backup.Restore(id, request.Paths, job)
+		job.Finish(err)
+		if err != nil {
+			log.Printf("[e] Restore of backup %s failed: %v", id, err)
+			return
+		}
+		log.Printf("[i] Restored %d files from backup %s", len(restored), id)
+	}()
+
+	return c.JSON(http.StatusAccepted, map[string]string{
+		"message": "Restore started",
+		"job":     job.ID(),
+	})
+}
+
+func verifyBackup(c echo.Context) error {
+	result, err := backup.Verify(c.Param("id"))
+	if err != nil {
+		return backupError(c, err)
+	}
+
+	if !result.OK {
+		log.Printf("[w] Backup %s failed verification", result.ID)
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+func listBackupProfiles(c echo.Context) error {
+	profiles, err := backup.Profiles()
+	if err != nil {
+		return backupError(c, err)
+	}
+	return c.JSON(http.StatusOK, profiles)
+}
+
+func saveBackupProfiles(c echo.Context) error {
+	var profiles []backup.Profile
+	if err := c.Bind(&profiles); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+
+	if err := backup.SaveProfiles(profiles); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_profiles",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, profiles)
+}
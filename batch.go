@@ -0,0 +1,292 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/fileacl"
+)
+
+const maxBatchOperations = 500
+
+type BatchOperation struct {
+	Op   string `json:"op"`
+	Path string `json:"path,omitempty"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+type BatchResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// batch executes file operations in order. Every applied operation leaves an
+// undo step behind, so a failure halfway rolls the earlier ones back. Deleted
+// and overwritten files are parked in a trash directory inside the server
+// directory until the batch succeeds, which keeps the renames on one
+// filesystem.
+type batch struct {
+	trash string
+	undo  []func() error
+	n     int
+}
+
+func (b *batch) park(path string) (string, error) {
+	if err := os.MkdirAll(b.trash, 0755); err != nil {
+		return "", err
+	}
+	b.n++
+	parked := filepath.Join(b.trash, strconv.Itoa(b.n))
+	return parked, os.Rename(path, parked)
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// mkdirAll creates dir and registers undo steps for every directory it had to
+// create.
+func (b *batch) mkdirAll(dir string) error {
+	var created []string
+	for d := dir; !exists(d); d = filepath.Dir(d) {
+		created = append(created, d)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for i := len(created) - 1; i >= 0; i-- {
+		d := created[i]
+		b.undo = append(b.undo, func() error { return os.Remove(d) })
+	}
+	return nil
+}
+
+func (b *batch) apply(op BatchOperation) error {
+	switch op.Op {
+	case "mkdir":
+		path, _ := sanitizePath(op.Path)
+		return b.mkdirAll(path)
+
+	case "delete":
+		path, _ := sanitizePath(op.Path)
+		if !exists(path) {
+			return os.ErrNotExist
+		}
+		parked, err := b.park(path)
+		if err != nil {
+			return err
+		}
+		b.undo = append(b.undo, func() error { return os.Rename(parked, path) })
+		return nil
+
+	case "move", "copy":
+		from, _ := sanitizePath(op.From)
+		to, _ := sanitizePath(op.To)
+
+		info, err := os.Stat(from)
+		if err != nil {
+			return err
+		}
+		if op.Op == "copy" && info.IsDir() {
+			return errors.New("directory copying not supported, use move instead")
+		}
+		if err := b.mkdirAll(filepath.Dir(to)); err != nil {
+			return err
+		}
+
+		if exists(to) {
+			parked, err := b.park(to)
+			if err != nil {
+				return err
+			}
+			b.undo = append(b.undo, func() error { return os.Rename(parked, to) })
+		}
+
+		if op.Op == "move" {
+			if err := os.Rename(from, to); err != nil {
+				return err
+			}
+			b.undo = append(b.undo, func() error { return os.Rename(to, from) })
+			return nil
+		}
+
+		b.undo = append(b.undo, func() error { return os.RemoveAll(to) })
+		return copyRegularFile(from, to, info.Mode())
+	}
+	return fmt.Errorf("unknown operation %q", op.Op)
+}
+
+func copyRegularFile(from, to string, mode os.FileMode) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(to, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+func (b *batch) rollback() error {
+	var failed error
+	for i := len(b.undo) - 1; i >= 0; i-- {
+		if err := b.undo[i](); err != nil && failed == nil {
+			failed = err
+		}
+	}
+	return failed
+}
+
+func validateBatchOperation(op BatchOperation) error {
+	var paths []string
+	switch op.Op {
+	case "mkdir", "delete":
+		paths = []string{op.Path}
+	case "move", "copy":
+		paths = []string{op.From, op.To}
+	default:
+		return fmt.Errorf("unknown operation %q", op.Op)
+	}
+
+	for _, p := range paths {
+		if p == "" {
+			return errors.New("missing path")
+		}
+		full, err := sanitizePath(p)
+		if err != nil {
+			return err
+		}
+		if full == MinecraftDir && op.Op != "mkdir" {
+			return errors.New("cannot modify minecraft root directory")
+		}
+	}
+	return nil
+}
+
+// checkBatchProtected applies the file rules to the paths op changes.
+func checkBatchProtected(user string, op BatchOperation, confirmed bool) error {
+	switch op.Op {
+	case "mkdir":
+		return checkProtected(user, op.Path, false, confirmed)
+	case "delete":
+		return checkProtected(user, op.Path, true, confirmed)
+	case "move":
+		if err := checkProtected(user, op.From, true, confirmed); err != nil {
+			return err
+		}
+		return checkProtected(user, op.To, true, confirmed)
+	case "copy":
+		return checkProtected(user, op.To, false, confirmed)
+	}
+	return nil
+}
+
+func batchFiles(c echo.Context) error {
+	var request struct {
+		Operations []BatchOperation `json:"operations"`
+		Confirm    bool             `json:"confirm"`
+	}
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+	if len(request.Operations) == 0 || len(request.Operations) > maxBatchOperations {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_batch",
+			Message: fmt.Sprintf("A batch needs between 1 and %d operations", maxBatchOperations),
+		})
+	}
+
+	results := make([]BatchResult, len(request.Operations))
+	status := 0
+	for i, op := range request.Operations {
+		results[i] = BatchResult{Index: i, Op: op.Op, Status: "pending"}
+		err := validateBatchOperation(op)
+		if err == nil {
+			err = checkBatchProtected(currentUser(c), op, request.Confirm)
+		}
+		if err == nil {
+			continue
+		}
+		results[i].Status = "invalid"
+		results[i].Error = err.Error()
+		switch {
+		case errors.Is(err, fileacl.ErrRole):
+			status = http.StatusForbidden
+		case errors.Is(err, fileacl.ErrConfirm) && status == 0:
+			status = http.StatusPreconditionRequired
+		case status == 0 || status == http.StatusPreconditionRequired:
+			status = http.StatusBadRequest
+		}
+	}
+	if status != 0 {
+		return c.JSON(status, map[string]interface{}{
+			"committed": false,
+			"results":   results,
+		})
+	}
+
+	b := &batch{trash: filepath.Join(MinecraftDir, fmt.Sprintf(".batch-%d", time.Now().UnixNano()))}
+	defer os.RemoveAll(b.trash)
+
+	for i, op := range request.Operations {
+		if err := b.apply(op); err != nil {
+			results[i].Status = "failed"
+			results[i].Error = err.Error()
+			for j := i + 1; j < len(results); j++ {
+				results[j].Status = "skipped"
+			}
+
+			status := "rolled_back"
+			if err := b.rollback(); err != nil {
+				log.Println("[e] batch rollback incomplete:", err)
+				status = "rollback_failed"
+			}
+			for j := 0; j < i; j++ {
+				results[j].Status = status
+			}
+
+			return c.JSON(http.StatusConflict, map[string]interface{}{
+				"committed": false,
+				"results":   results,
+			})
+		}
+		results[i].Status = "done"
+	}
+
+	log.Printf("[i] Batch of %d file operations applied", len(request.Operations))
+	var touched []string
+	for _, op := range request.Operations {
+		for _, p := range []string{op.Path, op.From, op.To} {
+			if p != "" {
+				touched = append(touched, p)
+			}
+		}
+	}
+	recordConfigChange(c, fmt.Sprintf("Apply %d file operations", len(request.Operations)), touched...)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"committed": true,
+		"results":   results,
+	})
+}
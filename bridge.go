@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"pkg.bijsven.nl/MiniMC/pkg/audit"
+	"pkg.bijsven.nl/MiniMC/pkg/backup"
+	"pkg.bijsven.nl/MiniMC/pkg/jobs"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+	"pkg.bijsven.nl/MiniMC/pkg/tellraw"
+)
+
+// chatCommandPattern matches chat lines like "[12:00:00 INFO]: <Steve> !backup
+// now". It has to start at the log prefix, otherwise a message like "hi ]:
+// <Admin> !restart now" would pass for a command from Admin.
+var chatCommandPattern = regexp.MustCompile(server.LogPrefix + `(?:\[Not Secure\] )?<(\w{1,16})> !(\S.*)$`)
+
+var (
+	bridgePlayers []string
+
+	restartMu      sync.Mutex
+	pendingRestart *time.Timer
+)
+
+// startChatBridge lets the players in CHAT_BRIDGE_PLAYERS trigger MiniMC
+// actions from the in-game chat.
+func startChatBridge() {
+	for _, name := range strings.Split(os.Getenv("CHAT_BRIDGE_PLAYERS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			bridgePlayers = append(bridgePlayers, name)
+		}
+	}
+	if len(bridgePlayers) == 0 {
+		return
+	}
+
+	server.OnLine(func(line string) {
+		if player, args, ok := chatCommand(line); ok {
+			// Don't hold up the console reader while the action runs.
+			go runChatCommand(player, args)
+		}
+	})
+	log.Printf("[i] chat bridge enabled for %s", strings.Join(bridgePlayers, ", "))
+}
+
+// chatCommand returns the player and arguments of a bridge command in a
+// console line, and false when the line isn't one or the player may not run
+// commands.
+func chatCommand(line string) (string, []string, bool) {
+	m := chatCommandPattern.FindStringSubmatch(line)
+	if m == nil || !bridgeAllowed(m[1]) {
+		return "", nil, false
+	}
+	return m[1], strings.Fields(m[2]), true
+}
+
+func bridgeAllowed(player string) bool {
+	for _, name := range bridgePlayers {
+		if strings.EqualFold(name, player) {
+			return true
+		}
+	}
+	return false
+}
+
+// sendChat sends a message to target, a player name or selector.
+func sendChat(target, color, text string) {
+	cmd, err := tellraw.Text(target, color, "[MiniMC] "+text).Command(installedVersion())
+	if err == nil {
+		err = server.RunCommand(cmd)
+	}
+	if err != nil {
+		log.Println("[w] chat bridge: could not reply:", err)
+	}
+}
+
+func runChatCommand(player string, args []string) {
+	if len(args) == 0 {
+		return
+	}
+	audit.Record(player+" (in-game)", "chat_command", strings.Join(args, " "))
+
+	switch args[0] {
+	case "backup":
+		profile := "full"
+		if len(args) > 1 && args[1] != "now" {
+			profile = args[1]
+		}
+		p, err := backup.FindProfile(profile)
+		if err != nil {
+			sendChat(player, "red", "Unknown backup profile "+profile)
+			return
+		}
+		sendChat(player, "yellow", "Creating "+profile+" backup...")
+		b, err := runBackup(profile, p.Paths, jobs.New("backup"))
+		if err != nil {
+			sendChat(player, "red", "Backup failed: "+err.Error())
+			return
+		}
+		sendChat(player, "green", fmt.Sprintf("Backup %s created (%.1f MB)", b.ID, float64(b.Size)/1024/1024))
+
+	case "restart":
+		if len(args) > 1 && args[1] == "cancel" {
+			switch {
+			case cancelRestart():
+				sendChat("@a", "green", "Restart cancelled by "+player)
+			case pendingRestartCountdown() && server.AbortPending():
+				// The countdown tells the players itself.
+			default:
+				sendChat(player, "red", "No restart is pending")
+			}
+			return
+		}
+		// "now" skips the in-game countdown, everything else restarts the
+		// way the API and schedules do, after warning the players.
+		if len(args) > 1 && args[1] == "now" {
+			sendChat("@a", "yellow", "Server restarting, requested by "+player)
+			if err := server.Restart(stopTimeout); err != nil {
+				log.Println("[e] Restart failed:", err)
+			}
+			return
+		}
+		if len(args) == 1 {
+			countdownRestart(player)
+			return
+		}
+		delay, err := time.ParseDuration(args[1])
+		if err != nil || delay <= 0 || delay > 24*time.Hour {
+			sendChat(player, "red", "Usage: !restart [now|10m|cancel]")
+			return
+		}
+		scheduleRestart(delay, player)
+		sendChat("@a", "yellow", fmt.Sprintf("Server restarts in %s, requested by %s", delay, player))
+
+	default:
+		sendChat(player, "gray", "Commands: !backup [now|profile], !restart [now|10m|cancel]")
+	}
+}
+
+// countdownRestart restarts the server after the in-game countdown, which
+// shows up under /api/power/pending and can be cancelled there.
+func countdownRestart(player string) {
+	if err := server.Countdown("restart", "requested by "+player); err != nil {
+		if !errors.Is(err, server.ErrAborted) {
+			sendChat(player, "red", "Restart skipped: "+err.Error())
+		}
+		return
+	}
+	if err := server.Restart(stopTimeout); err != nil {
+		log.Println("[e] Restart failed:", err)
+	}
+}
+
+func pendingRestartCountdown() bool {
+	p := server.Pending()
+	return p != nil && p.Action == "restart"
+}
+
+// scheduleRestart starts the countdown for a restart once delay has passed.
+func scheduleRestart(delay time.Duration, player string) {
+	restartMu.Lock()
+	defer restartMu.Unlock()
+
+	if pendingRestart != nil {
+		pendingRestart.Stop()
+	}
+	pendingRestart = time.AfterFunc(delay, func() {
+		restartMu.Lock()
+		pendingRestart = nil
+		restartMu.Unlock()
+
+		countdownRestart(player)
+	})
+}
+
+func cancelRestart() bool {
+	restartMu.Lock()
+	defer restartMu.Unlock()
+
+	if pendingRestart == nil {
+		return false
+	}
+	pendingRestart.Stop()
+	pendingRestart = nil
+	return true
+}
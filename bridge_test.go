@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestChatCommand(t *testing.T) {
+	saved := bridgePlayers
+	bridgePlayers = []string{"Admin"}
+	defer func() { bridgePlayers = saved }()
+
+	tests := []struct {
+		line   string
+		player string
+	}{
+		{`[12:00:00 INFO]: <Admin> !restart now`, "Admin"},
+		{`[12:00:00 INFO]: [Not Secure] <Admin> !backup`, "Admin"},
+		{`[12:00:00] [Server thread/INFO]: <Admin> !backup now`, "Admin"},
+		{`[12:00:00 INFO]: <Mallory> !restart now`, ""},
+		// Another player's name inside a chat message.
+		{`[12:00:00 INFO]: <Mallory> hi ]: <Admin> !restart now`, ""},
+		{`[12:00:00 INFO]: <Mallory> [12:00:00 INFO]: <Admin> !restart now`, ""},
+		{`[12:00:00 INFO]: [Not Secure] <Mallory> hi ]: [Not Secure] <Admin> !backup`, ""},
+		{`[12:00:00 INFO]: [Mallory] ]: <Admin> !restart now`, ""},
+		{`[12:00:00 INFO]: Admin issued server command: /say ]: <Admin> !restart now`, ""},
+	}
+	for _, tt := range tests {
+		player, _, ok := chatCommand(tt.line)
+		if ok != (tt.player != "") || player != tt.player {
+			t.Errorf("chatCommand(%q) = %q, %v, want %q", tt.line, player, ok, tt.player)
+		}
+	}
+}
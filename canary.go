@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/audit"
+	"pkg.bijsven.nl/MiniMC/pkg/jobs"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+	"pkg.bijsven.nl/MiniMC/pkg/textenc"
+)
+
+// validateConfig starts the server in a temporary copy of its configs with the
+// given files changed, to see whether it still gets through loading them.
+// With apply the files are written to the live server once it does.
+func validateConfig(c echo.Context) error {
+	var request struct {
+		Files   []FileContent `json:"files"`
+		Apply   bool          `json:"apply"`
+		Confirm bool          `json:"confirm"`
+	}
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+
+	launch, err := server.LoadLaunchConfig()
+	if err != nil {
+		launch = server.LaunchConfig{}
+	}
+	workDir, _ := filepath.Abs(launch.Dir())
+
+	files := map[string][]byte{}
+	fullPaths := map[string]string{}
+	var paths []string
+	for _, f := range request.Files {
+		fullPath, err := sanitizePath(f.Path)
+		if err != nil || fullPath == MinecraftDir {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_path",
+				Message: fmt.Sprintf("%q is not a file in the server directory", f.Path),
+			})
+		}
+		abs, _ := filepath.Abs(fullPath)
+		rel, err := filepath.Rel(workDir, abs)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_path",
+				Message: f.Path + " is outside the server's working directory",
+			})
+		}
+		if err := server.CanaryPath(rel); err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_path",
+				Message: err.Error(),
+			})
+		}
+		data, err := textenc.Encode(f.Content, f.Encoding)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "encode_error",
+				Message: err.Error(),
+			})
+		}
+		if request.Apply {
+			if status, denied := protectedDenied(c, f.Path, false, request.Confirm || f.Confirm); denied != nil {
+				return c.JSON(status, denied)
+			}
+		}
+		files[rel] = data
+		fullPaths[rel] = fullPath
+		paths = append(paths, f.Path)
+	}
+
+	job := jobs.New("canary")
+	result, err := server.Canary(files, job)
+	if err == nil && !result.OK {
+		job.Finish(errors.New(result.Error))
+	} else {
+		job.Finish(err)
+	}
+	if errors.Is(err, server.ErrCanaryRunning) {
+		return c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "canary_running",
+			Message: err.Error(),
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "canary_error",
+			Message: err.Error(),
+		})
+	}
+
+	applied := false
+	if request.Apply && result.OK && len(files) > 0 {
+		for rel, data := range files {
+			fullPath := fullPaths[rel]
+			if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err == nil {
+				err = os.WriteFile(fullPath, data, 0644)
+			}
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, ErrorResponse{
+					Error:   "write_error",
+					Message: err.Error(),
+				})
+			}
+		}
+		applied = true
+		log.Printf("[i] Applied validated configs: %s", strings.Join(paths, ", "))
+		audit.Record(currentUser(c), "config_apply", strings.Join(paths, ", "))
+		recordConfigChange(c, "Apply validated "+strings.Join(paths, ", "), paths...)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"result":  result,
+		"applied": applied,
+		"job":     job.ID(),
+	})
+}
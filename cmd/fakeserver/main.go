@@ -0,0 +1,257 @@
+// Command fakeserver pretends to be a Paper server for testing MiniMC without
+// downloading a jar or running java. Set FAKE_SERVER to the built binary and
+// MiniMC starts it in place of the real server.
+//
+// It prints the log lines MiniMC parses (startup progress, "Done", joins,
+// saves, TPS) and answers the console commands MiniMC sends. Commands
+// starting with "fake" simulate what players and the JVM would do:
+//
+//	fake join <player> [ip]   a player logs in
+//	fake leave <player>       a player leaves
+//	fake chat <player> <text> a player chats
+//	fake tps <tps>            TPS reported from now on
+//	fake crash                exit with an exception
+//	fake oom                  exit with an OutOfMemoryError
+//	fake hang                 stop answering, even to "stop"
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	version = flag.String("version", "1.21.4", "Minecraft version to report")
+	startup = flag.Duration("startup", time.Second, "how long starting up takes")
+	level   = flag.String("level", "world", "name of the world")
+)
+
+type fakeServer struct {
+	players []string
+	tps     float64
+	border  float64
+	hung    bool
+}
+
+func main() {
+	flag.Parse()
+
+	s := &fakeServer{tps: 20, border: 59999968}
+	s.start()
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		if s.hung {
+			continue
+		}
+		if s.handle(strings.TrimSpace(scanner.Text())) {
+			return
+		}
+	}
+	// MiniMC went away, a real server keeps running but there is no one
+	// left to test with.
+}
+
+func logf(format string, args ...interface{}) {
+	fmt.Printf("[%s INFO]: %s\n", time.Now().Format("15:04:05"), fmt.Sprintf(format, args...))
+}
+
+func warnf(format string, args ...interface{}) {
+	fmt.Printf("[%s WARN]: %s\n", time.Now().Format("15:04:05"), fmt.Sprintf(format, args...))
+}
+
+func (s *fakeServer) start() {
+	began := time.Now()
+	step := *startup / 5
+
+	logf("Starting minecraft server version %s", *version)
+	logf("Loading properties")
+	logf("This server is running Paper version %s-fake (MC: %s)", *version, *version)
+	logf("Default game type: SURVIVAL")
+	time.Sleep(step)
+	if hasArg("--forceUpgrade") {
+		logf("Forcing world upgrade!")
+		if hasArg("--eraseCache") {
+			logf("Erasing cached data")
+		}
+		for done := 0; done <= 400; done += 100 {
+			logf("%d%% completed (%d / 400 chunks)...", done/4, done)
+			time.Sleep(step / 4)
+		}
+	}
+	logf("Preparing level \"%s\"", *level)
+	logf("Preparing start region for dimension minecraft:overworld")
+	for percent := 0; percent < 100; percent += 25 {
+		logf("Preparing spawn area: %d%%", percent)
+		time.Sleep(step)
+	}
+	logf("Time elapsed: %d ms", time.Since(began).Milliseconds())
+	logf("Done (%.3fs)! For help, type \"help\"", time.Since(began).Seconds())
+}
+
+// hasArg reports whether MiniMC passed a server argument like --forceUpgrade,
+// which come after "nogui" and are left alone by flag.
+func hasArg(name string) bool {
+	for _, arg := range flag.Args() {
+		if arg == name {
+			return true
+		}
+	}
+	return false
+}
+
+// handle runs one console command and reports whether the server exits.
+func (s *fakeServer) handle(line string) bool {
+	if line == "" {
+		return false
+	}
+	cmd, rest, _ := strings.Cut(strings.TrimPrefix(line, "/"), " ")
+	args := strings.Fields(rest)
+
+	switch cmd {
+	case "stop":
+		s.stop()
+		return true
+	case "list":
+		logf("There are %d of a max of 20 players online: %s", len(s.players), strings.Join(s.players, ", "))
+	case "save-all":
+		logf("Saving the game (this may take a moment!)")
+		logf("Saved the game")
+	case "save-off":
+		logf("Automatic saving is now disabled")
+	case "save-on":
+		logf("Automatic saving is now enabled")
+	case "tps":
+		logf("TPS from last 1m, 5m, 15m: %.1f, %.1f, %.1f", s.tps, s.tps, s.tps)
+	case "say":
+		logf("[Server] %s", rest)
+	case "tellraw":
+		// Only players see it.
+	case "difficulty":
+		if len(args) == 0 {
+			logf("The difficulty is Normal")
+		} else {
+			logf("The difficulty has been set to %s", args[0])
+		}
+	case "time":
+		logf("Set the time to %s", rest)
+	case "weather":
+		logf("Changing to %s", rest)
+	case "gamerule":
+		if len(args) == 2 {
+			logf("Gamerule %s is now set to: %s", args[0], args[1])
+		}
+	case "worldborder":
+		s.worldborder(args)
+	case "fake":
+		return s.simulate(args)
+	default:
+		logf("Unknown or incomplete command, see below for error")
+	}
+	return false
+}
+
+func (s *fakeServer) worldborder(args []string) {
+	if len(args) == 0 || args[0] == "get" {
+		logf("The world border is currently %g block(s) wide", s.border)
+		return
+	}
+	if args[0] == "set" && len(args) > 1 {
+		if size, err := strconv.ParseFloat(args[1], 64); err == nil {
+			s.border = size
+			logf("Set the world border to %g block(s) wide", size)
+			return
+		}
+	}
+	if args[0] == "center" && len(args) > 2 {
+		logf("Set the center of the world border to %s, %s", args[1], args[2])
+		return
+	}
+	logf("Unknown or incomplete command, see below for error")
+}
+
+func (s *fakeServer) simulate(args []string) bool {
+	if len(args) == 0 {
+		warnf("fake: missing action")
+		return false
+	}
+
+	switch args[0] {
+	case "join":
+		if len(args) < 2 {
+			break
+		}
+		ip := "127.0.0.1"
+		if len(args) > 2 {
+			ip = args[2]
+		}
+		s.players = append(s.players, args[1])
+		logf("UUID of player %s is 00000000-0000-0000-0000-%012d", args[1], len(s.players))
+		logf("%s[/%s:%d] logged in with entity id %d at ([%s]0.5, 64.0, 0.5)", args[1], ip, 50000+len(s.players), len(s.players), *level)
+		logf("%s joined the game", args[1])
+		return false
+	case "leave":
+		if len(args) < 2 {
+			break
+		}
+		s.leave(args[1])
+		return false
+	case "chat":
+		if len(args) < 3 {
+			break
+		}
+		logf("<%s> %s", args[1], strings.Join(args[2:], " "))
+		return false
+	case "tps":
+		if len(args) < 2 {
+			break
+		}
+		if tps, err := strconv.ParseFloat(args[1], 64); err == nil {
+			s.tps = tps
+			return false
+		}
+	case "crash":
+		fmt.Println("[" + time.Now().Format("15:04:05") + " ERROR]: Encountered an unexpected exception")
+		fmt.Println("java.lang.IllegalStateException: fake crash")
+		fmt.Println("\tat net.minecraft.server.MinecraftServer.runServer(MinecraftServer.java:1234)")
+		os.Exit(1)
+	case "oom":
+		fmt.Println("[" + time.Now().Format("15:04:05") + " ERROR]: Encountered an unexpected exception")
+		fmt.Println("java.lang.OutOfMemoryError: Java heap space")
+		os.Exit(1)
+	case "hang":
+		warnf("Can't keep up! Is the server overloaded? Running 60000ms or 1200 ticks behind")
+		s.hung = true
+		return false
+	}
+	warnf("fake: invalid action %q", strings.Join(args, " "))
+	return false
+}
+
+func (s *fakeServer) leave(player string) {
+	for i, p := range s.players {
+		if p == player {
+			s.players = append(s.players[:i], s.players[i+1:]...)
+			logf("%s lost connection: Disconnected", player)
+			logf("%s left the game", player)
+			return
+		}
+	}
+}
+
+func (s *fakeServer) stop() {
+	logf("Stopping the server")
+	logf("Stopping server")
+	logf("Saving players")
+	for len(s.players) > 0 {
+		s.leave(s.players[0])
+	}
+	logf("Saving worlds")
+	logf("Saving chunks for level 'ServerLevel[%s]'/minecraft:overworld", *level)
+	logf("ThreadedAnvilChunkStorage (%s): All chunks are saved", *level)
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/audit"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+)
+
+// TestMain runs the handler tests in a scratch directory against
+// cmd/fakeserver, started through FAKE_SERVER in place of java.
+func TestMain(m *testing.M) {
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	flag.Parse()
+	dir, err := os.MkdirTemp("", "minimc-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer os.RemoveAll(dir)
+
+	bin := filepath.Join(dir, "fakeserver")
+	build := exec.Command("go", "build", "-o", bin, "./cmd/fakeserver")
+	build.Stdout, build.Stderr = os.Stderr, os.Stderr
+	if err := build.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, "building the fake server:", err)
+		return 1
+	}
+
+	work := filepath.Join(dir, "work")
+	if err := os.MkdirAll(filepath.Join(work, MinecraftDir), 0755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := os.Chdir(work); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	os.Setenv("FAKE_SERVER", bin+" -startup 100ms")
+	os.Setenv("CONSOLE_SESSIONS", "0")
+	if !testing.Verbose() {
+		log.SetOutput(io.Discard)
+	}
+	return m.Run()
+}
+
+const testTimeout = 10 * time.Second
+
+// testAPI routes the power and command endpoints, taking the user from the
+// X-Test-User header in place of a session.
+func testAPI() *echo.Echo {
+	e := echo.New()
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if user := c.Request().Header.Get("X-Test-User"); user != "" {
+				c.Set("user", user)
+			}
+			return next(c)
+		}
+	})
+	e.POST("/api/command", commandHandler)
+	e.GET("/api/power/pending", pendingPower)
+	e.DELETE("/api/power/pending", abortPower)
+	return e
+}
+
+// sendCommand posts command as user and returns the status and error code.
+func sendCommand(t *testing.T, e *echo.Echo, user, command string) (int, string) {
+	t.Helper()
+	form := url.Values{"command": {command}}
+	req := httptest.NewRequest(http.MethodPost, "/api/command", strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	req.Header.Set("X-Test-User", user)
+	return serve(t, e, req)
+}
+
+func serve(t *testing.T, e *echo.Echo, req *http.Request) (int, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	var body ErrorResponse
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	return rec.Code, body.Error
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// startServer starts the fake server through the API and waits until it's
+// ready.
+func startServer(t *testing.T, e *echo.Echo) {
+	t.Helper()
+	if status, code := sendCommand(t, e, "admin", "start"); status != http.StatusOK {
+		t.Fatalf("start: %d %s", status, code)
+	}
+	t.Cleanup(func() {
+		if server.GetStatus() {
+			server.Kill()
+		}
+		waitFor(t, "the server to stop", func() bool { return server.ProcessInfo().State == server.StateStopped })
+	})
+	waitFor(t, "the server to be ready", func() bool { return server.ProcessInfo().Ready })
+}
+
+func TestCommandEndpoint(t *testing.T) {
+	e := testAPI()
+
+	if status, _ := sendCommand(t, e, "admin", ""); status != http.StatusBadRequest {
+		t.Errorf("empty command: %d, want 400", status)
+	}
+	if status, code := sendCommand(t, e, "admin", "say hi"); status != http.StatusConflict || code != "server_not_running" {
+		t.Errorf("command without a server: %d %s, want 409 server_not_running", status, code)
+	}
+
+	startServer(t, e)
+
+	var (
+		mu    sync.Mutex
+		lines []string
+	)
+	server.OnLine(func(line string) {
+		mu.Lock()
+		lines = append(lines, line)
+		mu.Unlock()
+	})
+	if status, code := sendCommand(t, e, "admin", "say hello from the test"); status != http.StatusOK {
+		t.Fatalf("say: %d %s", status, code)
+	}
+	waitFor(t, "the server to run the command", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, line := range lines {
+			if strings.Contains(line, "[Server] hello from the test") {
+				return true
+			}
+		}
+		return false
+	})
+
+	// A blocked command is refused and never reaches the server.
+	rules := `{"default_role": "admin", "users": {"olivia": "operator"}, "roles": {"operator": {"mode": "block", "commands": ["stop", "op"]}}}`
+	if err := os.WriteFile("console-rules.json", []byte(rules), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("console-rules.json")
+	for _, command := range []string{"op Steve", "stop"} {
+		if status, code := sendCommand(t, e, "olivia", command); status != http.StatusForbidden || code != "command_blocked" {
+			t.Errorf("%s as operator: %d %s, want 403 command_blocked", command, status, code)
+		}
+	}
+	if !server.GetStatus() {
+		t.Error("a blocked stop stopped the server")
+	}
+}
+
+func TestPowerEndpoints(t *testing.T) {
+	e := testAPI()
+
+	if status, code := sendCommand(t, e, "admin", "stop"); status != http.StatusConflict || code != "server_not_running" {
+		t.Errorf("stop without a server: %d %s, want 409 server_not_running", status, code)
+	}
+
+	startServer(t, e)
+	if status, code := sendCommand(t, e, "admin", "start"); status != http.StatusInternalServerError {
+		t.Errorf("second start: %d %s, want 500", status, code)
+	}
+
+	pid := server.ProcessInfo().PID
+	if status, code := sendCommand(t, e, "admin", "restart"); status != http.StatusOK {
+		t.Fatalf("restart: %d %s", status, code)
+	}
+	waitFor(t, "the restarted server to be ready", func() bool {
+		p := server.ProcessInfo()
+		return p.Ready && p.PID != pid
+	})
+
+	// No one is online, so there's nothing to count down.
+	req := httptest.NewRequest(http.MethodGet, "/api/power/pending", nil)
+	if status, code := serve(t, e, req); status != http.StatusNotFound || code != "no_pending_action" {
+		t.Errorf("pending power: %d %s, want 404 no_pending_action", status, code)
+	}
+
+	if status, code := sendCommand(t, e, "admin", "stop"); status != http.StatusOK {
+		t.Fatalf("stop: %d %s", status, code)
+	}
+	if server.GetStatus() {
+		t.Error("server still running after stop returned")
+	}
+
+	startServer(t, e)
+	if status, code := sendCommand(t, e, "admin", "kill"); status != http.StatusOK {
+		t.Fatalf("kill: %d %s", status, code)
+	}
+	waitFor(t, "the server to be killed", func() bool { return !server.GetStatus() })
+	if status, _ := sendCommand(t, e, "admin", "kill"); status != http.StatusInternalServerError {
+		t.Errorf("kill without a server: %d, want 500", status)
+	}
+}
+
+func TestStopCountdown(t *testing.T) {
+	t.Setenv("POWER_WARNINGS", "10s")
+	began := time.Now()
+	e := testAPI()
+	startServer(t, e)
+	if _, err := server.RunCommandWait("fake join Alex", regexp.MustCompile(`Alex joined the game`), testTimeout); err != nil {
+		t.Fatal(err)
+	}
+
+	// With someone online the stop waits for the countdown, without holding
+	// up the request.
+	if status, code := sendCommand(t, e, "admin", "stop"); status != http.StatusAccepted {
+		t.Fatalf("stop with a player online: %d %s, want 202", status, code)
+	}
+	if status, code := sendCommand(t, e, "admin", "restart"); status != http.StatusConflict || code != "action_pending" {
+		t.Errorf("restart during the countdown: %d %s, want 409 action_pending", status, code)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/power/pending", nil)
+	if status, code := serve(t, e, req); status != http.StatusOK {
+		t.Errorf("pending power: %d %s, want 200", status, code)
+	}
+	req = httptest.NewRequest(http.MethodDelete, "/api/power/pending", nil)
+	if status, code := serve(t, e, req); status != http.StatusNoContent {
+		t.Fatalf("abort: %d %s, want 204", status, code)
+	}
+	waitFor(t, "the countdown to end", func() bool { return server.Pending() == nil })
+	if !server.GetStatus() {
+		t.Error("the aborted stop stopped the server")
+	}
+	entries, err := audit.List(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.Action == "stop" && entry.Time.After(began) {
+			t.Errorf("aborted stop was audited: %+v", entry)
+		}
+	}
+
+	form := url.Values{"command": {"stop"}, "now": {"true"}}
+	req = httptest.NewRequest(http.MethodPost, "/api/command", strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	if status, code := serve(t, e, req); status != http.StatusOK {
+		t.Errorf("stop with now=true: %d %s, want 200", status, code)
+	}
+	if server.GetStatus() {
+		t.Error("server still running after stop with now=true")
+	}
+}
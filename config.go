@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg"
+	"pkg.bijsven.nl/MiniMC/pkg/audit"
+	"pkg.bijsven.nl/MiniMC/pkg/properties"
+)
+
+const onlineModeWarning = "With online-mode disabled the server no longer verifies accounts with Mojang: " +
+	"anyone can join using any username, including those of operators. Only disable it when " +
+	"the server sits behind a Velocity or BungeeCord proxy that forwards player identities."
+
+// proxyForwardingEnabled reports whether Paper or Spigot is configured to
+// accept player identities forwarded by a proxy.
+func proxyForwardingEnabled() bool {
+	if yamlSectionEnabled(filepath.Join(MinecraftDir, "config", "paper-global.yml"), "velocity:") {
+		return true
+	}
+	return yamlSectionEnabled(filepath.Join(MinecraftDir, "spigot.yml"), "bungeecord: true")
+}
+
+// yamlSectionEnabled is a deliberately small check for either a "key: true"
+// line or a section followed by "enabled: true", which covers Paper's and
+// Spigot's default layouts without a YAML parser.
+func yamlSectionEnabled(path, marker string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	inSection := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == marker {
+			if strings.HasSuffix(marker, "true") {
+				return true
+			}
+			inSection = true
+			continue
+		}
+		if inSection && strings.HasPrefix(line, "enabled:") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "enabled:")) == "true"
+		}
+	}
+	return false
+}
+
+// warnOnlineMode logs a warning at startup when the server runs in offline
+// mode without a proxy doing the authentication.
+func warnOnlineMode() {
+	if serverProperty("online-mode", "true") == "false" && !proxyForwardingEnabled() {
+		log.Println("[!] online-mode is disabled but no proxy forwarding is configured. " + onlineModeWarning)
+	}
+}
+
+func getOnlineMode(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"online_mode":      serverProperty("online-mode", "true") != "false",
+		"proxy_forwarding": proxyForwardingEnabled(),
+	})
+}
+
+func setOnlineMode(c echo.Context) error {
+	var request struct {
+		OnlineMode *bool `json:"online_mode"`
+		Confirm    bool  `json:"confirm"`
+	}
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+
+	if request.OnlineMode == nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "missing_online_mode",
+			Message: "online_mode is required",
+		})
+	}
+
+	if !*request.OnlineMode && !request.Confirm {
+		return c.JSON(http.StatusPreconditionRequired, ErrorResponse{
+			Error:   "confirmation_required",
+			Message: onlineModeWarning + " Resend with \"confirm\": true to continue.",
+		})
+	}
+
+	props, err := properties.Load(serverPropertiesPath())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "read_error",
+			Message: err.Error(),
+		})
+	}
+
+	value := "true"
+	if !*request.OnlineMode {
+		value = "false"
+	}
+	props.Set("online-mode", value)
+	if err := props.Save(); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "write_error",
+			Message: err.Error(),
+		})
+	}
+
+	user, _ := c.Get("user").(string)
+	audit.Record(user, "online_mode", "online-mode="+value)
+	recordConfigChange(c, "Set online-mode="+value, "server.properties")
+	log.Printf("[i] online-mode set to %s by %s, restart the server to apply", value, user)
+
+	response := map[string]interface{}{
+		"message":     "online-mode updated, restart the server to apply",
+		"online_mode": *request.OnlineMode,
+	}
+	if !*request.OnlineMode && !proxyForwardingEnabled() {
+		response["warning"] = "No proxy forwarding is configured. " + onlineModeWarning
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func diffServerProperties(c echo.Context) error {
+	version := c.QueryParam("version")
+	if version == "" {
+		manifest, err := pkg.LoadManifest()
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "unknown_version",
+				Message: "No installed version found, pass ?version= explicitly",
+			})
+		}
+		version = manifest.Version
+	}
+
+	props, err := properties.Load(serverPropertiesPath())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "read_error",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, props.DiffDefaults(version))
+}
+
+func listAudit(c echo.Context) error {
+	entries, err := audit.List(200)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "read_error",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, entries)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/audit"
+	"pkg.bijsven.nl/MiniMC/pkg/cmdfilter"
+	"pkg.bijsven.nl/MiniMC/pkg/security"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+)
+
+// stopTimeout is how long a graceful stop may take before giving up; saving
+// large worlds can take a while.
+const stopTimeout = 2 * time.Minute
+
+// blockCommand returns the response to send when the user's console rules
+// don't allow cmd, and nil otherwise.
+func blockCommand(c echo.Context, cmd string) (int, *ErrorResponse) {
+	err := cmdfilter.Check(currentUser(c), cmd)
+	if err == nil {
+		return 0, nil
+	}
+	security.Emit(security.Event{
+		Kind:   security.PermissionDenied,
+		User:   currentUser(c),
+		IP:     c.RealIP(),
+		Path:   c.Request().URL.Path,
+		Detail: cmd,
+	})
+	return http.StatusForbidden, &ErrorResponse{
+		Error:   "command_blocked",
+		Message: err.Error(),
+	}
+}
+
+// stopServer stops the server gracefully, waiting for the process to exit,
+// and starts it again when restart is set. When players are online the
+// in-game countdown runs first, in the background, and the response is 202
+// with the pending action.
+func stopServer(c echo.Context, restart bool) error {
+	if !server.GetStatus() {
+		return c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "server_not_running",
+			Message: "The server is not running",
+		})
+	}
+
+	action, stop := "stop", server.StopAndWait
+	if restart {
+		action, stop = "restart", server.Restart
+	}
+
+	// With now=true the server goes down without the in-game countdown.
+	if now, _ := strconv.ParseBool(c.FormValue("now")); !now {
+		user := currentUser(c)
+		p, err := server.StartCountdown(action, "requested by "+user, func(err error) {
+			if err != nil {
+				log.Printf("[i] Server %s by %s not done: %v", action, user, err)
+				return
+			}
+			audit.Record(user, action, "")
+			if err := stop(stopTimeout); err != nil {
+				log.Printf("[e] Server %s failed: %v", action, err)
+			}
+		})
+		if err != nil {
+			return c.JSON(http.StatusConflict, powerError(err))
+		}
+		if p != nil {
+			return c.JSON(http.StatusAccepted, p)
+		}
+	}
+	audit.Record(currentUser(c), action, "")
+
+	started := time.Now()
+	if err := stop(stopTimeout); err != nil {
+		status, code := http.StatusInternalServerError, action+"_failed"
+		if errors.Is(err, server.ErrStopTimeout) {
+			status, code = http.StatusGatewayTimeout, "stop_timeout"
+		}
+		log.Printf("[e] Server %s failed: %v", action, err)
+		return c.JSON(status, ErrorResponse{
+			Error:   code,
+			Message: err.Error(),
+		})
+	}
+
+	status := "stopped"
+	if restart {
+		status = "starting"
+	}
+	log.Printf("[i] Server %s by %s took %s", action, currentUser(c), time.Since(started).Round(time.Millisecond))
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status":      status,
+		"running":     server.GetStatus(),
+		"duration_ms": time.Since(started).Milliseconds(),
+	})
+}
+
+// powerError describes a countdown that didn't finish.
+func powerError(err error) *ErrorResponse {
+	code := "action_pending"
+	if errors.Is(err, server.ErrAborted) {
+		code = "aborted"
+	}
+	return &ErrorResponse{
+		Error:   code,
+		Message: err.Error(),
+	}
+}
+
+// pendingPower returns the stop or restart counting down in-game.
+func pendingPower(c echo.Context) error {
+	p := server.Pending()
+	if p == nil {
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "no_pending_action",
+			Message: "No stop or restart is counting down",
+		})
+	}
+	return c.JSON(http.StatusOK, p)
+}
+
+// abortPower cancels the countdown, the server keeps running.
+func abortPower(c echo.Context) error {
+	p := server.Pending()
+	if p == nil || !server.AbortPending() {
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "no_pending_action",
+			Message: "No stop or restart is counting down",
+		})
+	}
+	audit.Record(currentUser(c), "abort_"+p.Action, p.Reason)
+	log.Printf("[i] %s cancelled the server %s", currentUser(c), p.Action)
+	return c.NoContent(http.StatusNoContent)
+}
+
+func getConsoleRules(c echo.Context) error {
+	rules, err := cmdfilter.Load()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "read_error",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"rules": rules,
+		"role":  rules.Role(currentUser(c)),
+	})
+}
+
+// updateConsoleRules replaces the rules. Only users whose role isn't
+// restricted may change them, otherwise an operator could lift their own
+// restrictions.
+func updateConsoleRules(c echo.Context) error {
+	current, err := cmdfilter.Load()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "read_error",
+			Message: err.Error(),
+		})
+	}
+	if !current.Unrestricted(currentUser(c)) {
+		return c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "forbidden",
+			Message: "Only unrestricted roles can change console rules",
+		})
+	}
+
+	var rules cmdfilter.Rules
+	if err := c.Bind(&rules); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+	if err := cmdfilter.Save(rules); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_rules",
+			Message: err.Error(),
+		})
+	}
+
+	audit.Record(currentUser(c), "console_rules", "console rules updated")
+	return c.JSON(http.StatusOK, rules)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/shirou/gopsutil/disk"
+	"pkg.bijsven.nl/MiniMC/pkg"
+	"pkg.bijsven.nl/MiniMC/pkg/properties"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+)
+
+type CheckStatus string
+
+const (
+	CheckPass CheckStatus = "pass"
+	CheckWarn CheckStatus = "warn"
+	CheckFail CheckStatus = "fail"
+)
+
+type CheckResult struct {
+	Name   string      `json:"name"`
+	Status CheckStatus `json:"status"`
+	Detail string      `json:"detail"`
+}
+
+type DoctorReport struct {
+	OK     bool          `json:"ok"`
+	Checks []CheckResult `json:"checks"`
+}
+
+var doctorChecks = []struct {
+	name string
+	run  func() (CheckStatus, string)
+}{
+	{"java", checkJava},
+	{"write_permissions", checkWritable},
+	{"disk_space", checkDiskSpace},
+	{"game_port", checkGamePort},
+	{"memory_limit", checkMemoryLimit},
+	{"papermc_connectivity", checkConnectivity},
+}
+
+func runDoctor() DoctorReport {
+	report := DoctorReport{OK: true}
+	for _, check := range doctorChecks {
+		status, detail := check.run()
+		if status == CheckFail {
+			report.OK = false
+		}
+		report.Checks = append(report.Checks, CheckResult{
+			Name:   check.name,
+			Status: status,
+			Detail: detail,
+		})
+	}
+	return report
+}
+
+// logDoctor runs all checks at startup and logs the ones that didn't pass.
+func logDoctor() {
+	for _, check := range runDoctor().Checks {
+		switch check.Status {
+		case CheckFail:
+			log.Printf("[e] self-check %s failed: %s", check.Name, check.Detail)
+		case CheckWarn:
+			log.Printf("[w] self-check %s: %s", check.Name, check.Detail)
+		}
+	}
+}
+
+func doctorHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, runDoctor())
+}
+
+func checkJava() (CheckStatus, string) {
+	major, line, err := server.JavaVersion()
+	if err != nil {
+		return CheckFail, err.Error()
+	}
+
+	manifest, err := pkg.LoadManifest()
+	if err != nil {
+		return CheckPass, line
+	}
+	if major < manifest.Java {
+		return CheckFail, fmt.Sprintf("%s, but Minecraft %s requires Java %d", line, manifest.Version, manifest.Java)
+	}
+	return CheckPass, line
+}
+
+func checkWritable() (CheckStatus, string) {
+	f, err := os.CreateTemp(MinecraftDir, ".doctor-*")
+	if err != nil {
+		return CheckFail, err.Error()
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return CheckPass, MinecraftDir + " is writable"
+}
+
+func checkDiskSpace() (CheckStatus, string) {
+	usage, err := disk.Usage(MinecraftDir)
+	if err != nil {
+		return CheckFail, err.Error()
+	}
+
+	freeMB := usage.Free / 1024 / 1024
+	detail := fmt.Sprintf("%d MB free (%.1f%% used)", freeMB, usage.UsedPercent)
+	switch {
+	case freeMB < 512:
+		return CheckFail, detail
+	case freeMB < 2048:
+		return CheckWarn, detail
+	}
+	return CheckPass, detail
+}
+
+func checkGamePort() (CheckStatus, string) {
+	port := serverProperty("server-port", "25565")
+	if server.GetStatus() {
+		return CheckPass, "port " + port + " in use by the running server"
+	}
+
+	ln, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return CheckFail, fmt.Sprintf("port %s is not available: %v", port, err)
+	}
+	ln.Close()
+	return CheckPass, "port " + port + " is available"
+}
+
+func checkMemoryLimit() (CheckStatus, string) {
+	_, limit := pkg.CgroupMemory()
+	if limit == 0 {
+		return CheckPass, "no cgroup memory limit"
+	}
+
+	limitMB := limit / 1024 / 1024
+	detail := fmt.Sprintf("cgroup memory limit is %d MB", limitMB)
+	if limitMB < 2048 {
+		return CheckWarn, detail + ", which is tight for a Minecraft server"
+	}
+	return CheckPass, detail
+}
+
+func checkConnectivity() (CheckStatus, string) {
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get("https://api.papermc.io/v2/projects/paper")
+	if err != nil {
+		return CheckFail, err.Error()
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return CheckWarn, "api.papermc.io responded with " + resp.Status
+	}
+	return CheckPass, "api.papermc.io is reachable"
+}
+
+// serverProperty reads a single key from server.properties, returning def when
+// the file or key doesn't exist.
+func serverProperty(key, def string) string {
+	props, err := properties.Load(serverPropertiesPath())
+	if err != nil {
+		return def
+	}
+	return props.GetDefault(key, def)
+}
+
+func serverPropertiesPath() string {
+	return filepath.Join(MinecraftDir, "server.properties")
+}
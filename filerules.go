@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/audit"
+	"pkg.bijsven.nl/MiniMC/pkg/cmdfilter"
+	"pkg.bijsven.nl/MiniMC/pkg/fileacl"
+	"pkg.bijsven.nl/MiniMC/pkg/security"
+)
+
+// confirmedParam reports whether confirm=true was sent as a query or form
+// parameter, for the file endpoints that don't take a JSON body.
+func confirmedParam(c echo.Context) bool {
+	ok, _ := strconv.ParseBool(c.FormValue("confirm"))
+	return ok
+}
+
+// protectedDenied returns the response to send when the file rules don't let
+// the user change p, and nil otherwise. tree is set when p is a folder that
+// is deleted or moved with everything in it.
+func protectedDenied(c echo.Context, p string, tree, confirmed bool) (int, *ErrorResponse) {
+	err := checkProtected(currentUser(c), p, tree, confirmed)
+	if err == nil {
+		return 0, nil
+	}
+	switch {
+	case errors.Is(err, fileacl.ErrRole):
+		security.Emit(security.Event{
+			Kind:   security.PermissionDenied,
+			User:   currentUser(c),
+			IP:     c.RealIP(),
+			Path:   c.Request().URL.Path,
+			Detail: p,
+		})
+		return http.StatusForbidden, &ErrorResponse{
+			Error:   "path_protected",
+			Message: err.Error(),
+		}
+	case errors.Is(err, fileacl.ErrConfirm):
+		return http.StatusPreconditionRequired, &ErrorResponse{
+			Error:   "confirmation_required",
+			Message: err.Error() + ", resend with confirm=true",
+		}
+	}
+	return http.StatusInternalServerError, &ErrorResponse{
+		Error:   "read_error",
+		Message: err.Error(),
+	}
+}
+
+func checkProtected(user, p string, tree, confirmed bool) error {
+	rules, err := fileacl.Load()
+	if err != nil {
+		// Fail closed like the console rules.
+		return err
+	}
+	roles, err := cmdfilter.Load()
+	if err != nil {
+		return err
+	}
+	p = strings.TrimPrefix(cleanFilePath(p), "/")
+	return rules.Check(roles.Role(user), p, tree, confirmed)
+}
+
+func getFileRules(c echo.Context) error {
+	rules, err := fileacl.Load()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "read_error",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, rules)
+}
+
+// updateFileRules replaces the protected paths. Like the console rules only
+// unrestricted roles may change them.
+func updateFileRules(c echo.Context) error {
+	roles, err := cmdfilter.Load()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "read_error",
+			Message: err.Error(),
+		})
+	}
+	if !roles.Unrestricted(currentUser(c)) {
+		return c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "forbidden",
+			Message: "Only unrestricted roles can change file rules",
+		})
+	}
+
+	var rules fileacl.Rules
+	if err := c.Bind(&rules); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+	if rules.Protected == nil {
+		rules.Protected = []fileacl.Rule{}
+	}
+	if err := fileacl.Save(rules); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_file_rules",
+			Message: err.Error(),
+		})
+	}
+
+	audit.Record(currentUser(c), "file_rules", "protected paths updated")
+	return c.JSON(http.StatusOK, rules)
+}
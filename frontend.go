@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+)
+
+// overlayFS serves files from an on-disk directory first and falls back to
+// the embedded frontend, so a customized or newer build can replace single
+// files or the whole frontend without rebuilding MiniMC.
+type overlayFS struct {
+	disk     fs.FS
+	embedded fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.disk.Open(name)
+	if err == nil {
+		return f, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return o.embedded.Open(name)
+}
+
+// frontendFS returns the embedded frontend, overlaid with FRONTEND_DIR when
+// it is set.
+func frontendFS(embedded fs.FS) fs.FS {
+	dir := os.Getenv("FRONTEND_DIR")
+	if dir == "" {
+		return embedded
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		log.Println("[w] FRONTEND_DIR is not a directory, serving the bundled frontend:", dir)
+		return embedded
+	}
+	log.Println("[i] Serving frontend files from", dir, "before the bundled frontend")
+	return overlayFS{disk: os.DirFS(dir), embedded: embedded}
+}
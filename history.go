@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/configgit"
+	"pkg.bijsven.nl/MiniMC/pkg/integrity"
+)
+
+// openConfigHistory starts tracking config files in Git when CONFIG_GIT is
+// set. CONFIG_GIT_PATHS overrides the tracked patterns.
+func openConfigHistory() {
+	if os.Getenv("CONFIG_GIT") != "true" {
+		return
+	}
+
+	patterns := configgit.DefaultPatterns
+	if v := os.Getenv("CONFIG_GIT_PATHS"); v != "" {
+		patterns = nil
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				patterns = append(patterns, p)
+			}
+		}
+	}
+
+	if err := configgit.Open(MinecraftDir, patterns); err != nil {
+		log.Println("[e] Failed to open config history:", err)
+		return
+	}
+	log.Println("[i] tracking config changes in", configgit.GitDir)
+}
+
+// recordConfigChange commits tracked files touched by a panel edit. paths are
+// as used by the files API.
+func recordConfigChange(c echo.Context, message string, paths ...string) {
+	rel := make([]string, len(paths))
+	for i, p := range paths {
+		rel[i] = strings.TrimPrefix(cleanFilePath(p), "/")
+	}
+	integrity.Accept(MinecraftDir, rel...)
+	if err := configgit.Record(currentUser(c), message, rel...); err != nil {
+		log.Println("[e] Failed to record config change:", err)
+	}
+}
+
+func configHistoryError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, configgit.ErrDisabled):
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "config_history_disabled",
+			Message: "Config history is disabled, set CONFIG_GIT=true to enable it",
+		})
+	case errors.Is(err, configgit.ErrNotFound):
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "commit_not_found",
+			Message: err.Error(),
+		})
+	case errors.Is(err, configgit.ErrConflict):
+		return c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "revert_conflict",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(http.StatusInternalServerError, ErrorResponse{
+		Error:   "config_history_error",
+		Message: err.Error(),
+	})
+}
+
+func configHistory(c echo.Context) error {
+	limit := 50
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_limit",
+				Message: "limit must be a positive number",
+			})
+		}
+		limit = n
+	}
+
+	path := c.QueryParam("path")
+	if path != "" {
+		path = strings.TrimPrefix(cleanFilePath(path), "/")
+	}
+
+	commits, err := configgit.History(path, limit)
+	if err != nil {
+		return configHistoryError(c, err)
+	}
+	return c.JSON(http.StatusOK, commits)
+}
+
+func configCommit(c echo.Context) error {
+	commit, patch, err := configgit.Diff(c.Param("hash"))
+	if err != nil {
+		return configHistoryError(c, err)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"commit": commit,
+		"diff":   patch,
+	})
+}
+
+func revertConfigCommit(c echo.Context) error {
+	hash := c.Param("hash")
+	if err := configgit.Revert(hash, currentUser(c)); err != nil {
+		return configHistoryError(c, err)
+	}
+
+	log.Printf("[i] Config commit %s reverted by %s", hash, currentUser(c))
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Commit reverted",
+		"hash":    hash,
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/audit"
+	"pkg.bijsven.nl/MiniMC/pkg/hooks"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+)
+
+// registerHooks runs the configured external commands around server starts
+// and stops. Backup hooks are run by runBackup.
+func registerHooks() {
+	server.BeforeStart(func() error {
+		return hooks.Run(hooks.PreStart, MinecraftDir, nil)
+	})
+	server.AfterStop(func(exitCode int) {
+		hooks.Run(hooks.PostStop, MinecraftDir, map[string]string{
+			"MINIMC_EXIT_CODE": strconv.Itoa(exitCode),
+		})
+	})
+}
+
+func getHooks(c echo.Context) error {
+	config, err := hooks.Load()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "read_error",
+			Message: err.Error(),
+		})
+	}
+	allowlist := hooks.Allowlist()
+	if allowlist == nil {
+		allowlist = []string{}
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"hooks":     config,
+		"allowlist": allowlist,
+		"events":    hooks.Events,
+		"scripts":   hooks.Scripts(),
+	})
+}
+
+func updateHooks(c echo.Context) error {
+	var config hooks.Config
+	if err := c.Bind(&config); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+
+	if err := hooks.Save(config); err != nil {
+		status := http.StatusBadRequest
+		code := "invalid_hooks"
+		if errors.Is(err, hooks.ErrNotAllowed) {
+			status, code = http.StatusForbidden, "hook_not_allowed"
+		}
+		return c.JSON(status, ErrorResponse{
+			Error:   code,
+			Message: err.Error(),
+		})
+	}
+
+	audit.Record(currentUser(c), "hooks", "")
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Hooks updated",
+		"hooks":   config,
+	})
+}
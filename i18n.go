@@ -0,0 +1,43 @@
+package main
+
+import (
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/i18n"
+)
+
+// localizedSerializer translates the message of every ErrorResponse into the
+// language requested through Accept-Language. The error code stays the same
+// so clients can keep matching on it, and the original message is kept in
+// Detail.
+type localizedSerializer struct {
+	echo.DefaultJSONSerializer
+}
+
+func (s localizedSerializer) Serialize(c echo.Context, i interface{}, indent string) error {
+	if resp, ok := i.(ErrorResponse); ok {
+		i = localizeError(c, resp)
+	}
+	return s.DefaultJSONSerializer.Serialize(c, i, indent)
+}
+
+func localizeError(c echo.Context, resp ErrorResponse) ErrorResponse {
+	lang := i18n.Negotiate(c.Request().Header.Get("Accept-Language"))
+	c.Response().Header().Set("Content-Language", lang)
+
+	if lang == i18n.Default {
+		if resp.Message == "" {
+			resp.Message, _ = i18n.Message(lang, resp.Error)
+		}
+		return resp
+	}
+
+	msg, ok := i18n.Message(lang, resp.Error)
+	if !ok {
+		return resp
+	}
+	if resp.Message != msg {
+		resp.Detail = resp.Message
+	}
+	resp.Message = msg
+	return resp
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+)
+
+func listIncidents(c echo.Context) error {
+	incidents, err := server.Incidents()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "read_error",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, incidents)
+}
+
+// lastExit returns the last exit of the server process, with the tail of its
+// output when it crashed, and the exits before it.
+func lastExit(c echo.Context) error {
+	exit, err := server.LastExit()
+	if err != nil {
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "no_exit",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"exit":    exit,
+		"history": server.Exits(),
+	})
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg"
+	"pkg.bijsven.nl/MiniMC/pkg/audit"
+	"pkg.bijsven.nl/MiniMC/pkg/instance"
+	"pkg.bijsven.nl/MiniMC/pkg/integrity"
+	"pkg.bijsven.nl/MiniMC/pkg/jobs"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+)
+
+// exportInstance streams a bundle of the server's configuration, MiniMC's
+// settings and the plugin list. ?plugins=true adds the plugin jars,
+// ?worlds=true the worlds.
+func exportInstance(c echo.Context) error {
+	plugins, _ := strconv.ParseBool(c.QueryParam("plugins"))
+	worlds, _ := strconv.ParseBool(c.QueryParam("worlds"))
+	opts := instance.Options{Plugins: plugins, Worlds: worlds}
+
+	resume := func() {}
+	if opts.Worlds {
+		var err error
+		if resume, err = server.PauseSaves(snapshotSaveTimeout); err != nil {
+			return c.JSON(http.StatusConflict, ErrorResponse{
+				Error:   "export_error",
+				Message: err.Error(),
+			})
+		}
+	}
+	defer resume()
+
+	audit.Record(currentUser(c), "instance_export", fmt.Sprintf("plugins=%t worlds=%t", opts.Plugins, opts.Worlds))
+
+	name := "minimc-instance-" + time.Now().Format("20060102-150405") + ".tar.gz"
+	c.Response().Header().Set(echo.HeaderContentType, "application/gzip")
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+name+`"`)
+	c.Response().WriteHeader(http.StatusOK)
+
+	job := jobs.New("export")
+	info, err := instance.Export(c.Response(), opts, job)
+	job.Finish(err)
+	if err != nil {
+		// The response has started, the client sees a truncated archive.
+		log.Println("[e] Instance export failed:", err)
+		return nil
+	}
+	log.Printf("[i] Instance exported (%d files, %d plugins, %d worlds)", info.Files, len(info.Plugins), len(info.Worlds))
+	return nil
+}
+
+// importInstance unpacks a bundle uploaded as "bundle" over this instance.
+// It replaces config files, settings and the worlds in the bundle, so it
+// needs the server stopped and "confirm" set.
+func importInstance(c echo.Context) error {
+	fileHeader, err := c.FormFile("bundle")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "missing_bundle",
+			Message: "Upload the bundle as the \"bundle\" form file",
+		})
+	}
+
+	if confirm, _ := strconv.ParseBool(c.FormValue("confirm")); !confirm {
+		return c.JSON(http.StatusPreconditionRequired, ErrorResponse{
+			Error:   "confirmation_required",
+			Message: "Importing replaces config files, settings and the worlds in the bundle, resend with confirm=true",
+		})
+	}
+
+	if server.GetStatus() {
+		return c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "server_running",
+			Message: "Stop the server before importing",
+		})
+	}
+
+	src, err := fileHeader.Open()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "open_error",
+			Message: err.Error(),
+		})
+	}
+	defer src.Close()
+
+	job := jobs.New("import")
+	info, err := instance.Import(src, job)
+	job.Finish(err)
+	if errors.Is(err, instance.ErrInvalidBundle) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_bundle",
+			Message: err.Error(),
+		})
+	}
+	if err != nil {
+		log.Println("[e] Instance import failed:", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "import_error",
+			Message: err.Error(),
+		})
+	}
+
+	// The imported files aren't changes made behind MiniMC's back.
+	integrity.Rebaseline(MinecraftDir)
+	if info.Options.Plugins {
+		var jars []string
+		for _, p := range info.Plugins {
+			jars = append(jars, p.File)
+		}
+		integrity.Accept(MinecraftDir, jars...)
+	}
+
+	audit.Record(currentUser(c), "instance_import", fmt.Sprintf("%s %s, %d files", info.Flavor, info.Version, info.Files))
+	log.Printf("[i] Instance imported (%d files, %d worlds)", info.Files, len(info.Worlds))
+
+	var warnings []string
+	if info.Flavor != "" && info.Flavor != pkg.Flavor() {
+		warnings = append(warnings, "The instance ran "+info.Flavor+", set MC_FLAVOR="+info.Flavor)
+	}
+	if info.Version != "" && info.Version != installedVersion() {
+		warnings = append(warnings, "The instance ran "+info.Version+", set MC_VERSION="+info.Version)
+	}
+	if !info.Options.Plugins && len(info.Plugins) > 0 {
+		warnings = append(warnings, "The bundle lists plugins without their jars, install them before starting")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":  "Instance imported, restart MiniMC to load its settings",
+		"instance": info,
+		"warnings": warnings,
+	})
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"pkg.bijsven.nl/MiniMC/pkg/audit"
+	"pkg.bijsven.nl/MiniMC/pkg/integrity"
+	"pkg.bijsven.nl/MiniMC/pkg/notify"
+	"pkg.bijsven.nl/MiniMC/pkg/security"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+)
+
+const externalUser = "(outside MiniMC)"
+
+// registerIntegrity periodically hashes server.jar and the main configs and
+// reports changes MiniMC didn't make, like an edit over SFTP or a plugin
+// replacing jars. The server rewrites its own configs while starting, so those
+// are accepted once it is ready and after it stopped; jars never are.
+func registerIntegrity() {
+	interval := 5 * time.Minute
+	if v := os.Getenv("INTEGRITY_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Println("[w] Invalid INTEGRITY_INTERVAL, using", interval)
+		} else {
+			interval = d
+		}
+	}
+
+	server.OnReady(func() { integrity.Rebaseline(MinecraftDir) })
+	server.AfterStop(func(int) { integrity.Rebaseline(MinecraftDir) })
+
+	starting := func() bool {
+		state := server.ProcessInfo().State
+		return state == server.StateStarting || state == server.StateStopping
+	}
+	go integrity.Watch(MinecraftDir, interval, starting, reportExternalChange)
+}
+
+func reportExternalChange(c integrity.Change) {
+	log.Printf("[!] %s was %s outside MiniMC\n", c.Path, c.Kind)
+	audit.Record(externalUser, "external_change", c.Kind+" "+c.Path)
+	security.Emit(security.Event{
+		Kind:   security.ExternalChange,
+		Path:   c.Path,
+		Detail: c.Kind,
+	})
+	notify.Send(notify.Message{
+		Event: "external_change",
+		Title: "File changed outside MiniMC",
+		Text:  c.Path + " was " + c.Kind,
+		Time:  c.Time,
+	})
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg"
+)
+
+func verifyJar(c echo.Context) error {
+	v, err := pkg.VerifyJar()
+	if errors.Is(err, os.ErrNotExist) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "jar_not_found",
+			Message: "No server jar is installed",
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "verify_error",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, v)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/jobs"
+)
+
+func listJobs(c echo.Context) error {
+	return c.JSON(http.StatusOK, jobs.List())
+}
+
+func getJob(c echo.Context) error {
+	job := jobs.Get(c.Param("id"))
+	if job == nil {
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "job_not_found",
+			Message: "No job with id " + c.Param("id"),
+		})
+	}
+	return c.JSON(http.StatusOK, job.Snapshot())
+}
+
+func jobEvents(c echo.Context) error {
+	job := jobs.Get(c.Param("id"))
+	if job == nil {
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "job_not_found",
+			Message: "No job with id " + c.Param("id"),
+		})
+	}
+	return streamJob(c, job)
+}
+
+// downloadJob returns the job of the last server jar download, which is a
+// migrate job when the flavor was converted.
+func downloadJob() *jobs.Job {
+	return jobs.Latest("download", "migrate")
+}
+
+// downloadProgress reports the percentage, speed and ETA of the current or
+// last server jar download.
+func downloadProgress(c echo.Context) error {
+	job := downloadJob()
+	if job == nil {
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "no_download",
+			Message: "No server jar was downloaded since MiniMC started",
+		})
+	}
+	return c.JSON(http.StatusOK, job.Snapshot())
+}
+
+// downloadEvents streams the progress of the current or last server jar
+// download as server-sent events.
+func downloadEvents(c echo.Context) error {
+	job := downloadJob()
+	if job == nil {
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "no_download",
+			Message: "No server jar was downloaded since MiniMC started",
+		})
+	}
+	return streamJob(c, job)
+}
+
+func streamJob(c echo.Context, job *jobs.Job) error {
+	c.Response().Header().Set(echo.HeaderContentType, "text/event-stream")
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-cache")
+	c.Response().Header().Set(echo.HeaderConnection, "keep-alive")
+
+	flusher, ok := c.Response().Writer.(http.Flusher)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Streaming unsupported")
+	}
+
+	ch := job.Subscribe()
+	defer job.Unsubscribe(ch)
+
+	send := func(s jobs.Snapshot) {
+		data, _ := json.Marshal(s)
+		c.Response().Write([]byte("data: " + string(data) + "\n\n"))
+		flusher.Flush()
+	}
+
+	send(job.Snapshot())
+	for {
+		select {
+		case s, ok := <-ch:
+			if !ok {
+				send(job.Snapshot())
+				return nil
+			}
+			send(s)
+		case <-c.Request().Context().Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/audit"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+)
+
+func getLaunchConfig(c echo.Context) error {
+	cfg, err := server.LoadLaunchConfig()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "read_error",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"config": cfg.Masked(),
+		"args":   server.LaunchArgs(),
+		"dir":    cfg.Dir(),
+	})
+}
+
+// updateLaunchConfig saves heap, extra JVM flags, environment variables and
+// the working directory for the next start. An empty body falls back to the
+// environment again. Env values read back as "***"; sending that keeps them.
+func updateLaunchConfig(c echo.Context) error {
+	var cfg server.LaunchConfig
+	if err := c.Bind(&cfg); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+
+	if err := server.SaveLaunchConfig(cfg); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_launch_config",
+			Message: err.Error(),
+		})
+	}
+
+	args := server.LaunchArgs()
+	detail := strings.Join(args, " ")
+	if len(cfg.Env) > 0 {
+		names := make([]string, 0, len(cfg.Env))
+		for name := range cfg.Env {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		detail += " env " + strings.Join(names, ",")
+	}
+	if cfg.WorkDir != "" {
+		detail += " dir " + cfg.WorkDir
+	}
+	audit.Record(currentUser(c), "launch_config", detail)
+	log.Printf("[i] Launch flags updated by %s, restart the server to apply", currentUser(c))
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Launch flags updated, restart the server to apply",
+		"args":    args,
+	})
+}
@@ -4,12 +4,14 @@ import (
 	"archive/tar"
 	"compress/gzip"
 	"embed"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"log"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -19,7 +21,16 @@ import (
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/shirou/gopsutil/disk"
 	"pkg.bijsven.nl/MiniMC/pkg"
+	"pkg.bijsven.nl/MiniMC/pkg/alerts"
+	"pkg.bijsven.nl/MiniMC/pkg/apistats"
+	"pkg.bijsven.nl/MiniMC/pkg/auth"
+	"pkg.bijsven.nl/MiniMC/pkg/geoip"
+	"pkg.bijsven.nl/MiniMC/pkg/jobs"
+	"pkg.bijsven.nl/MiniMC/pkg/scheduler"
+	"pkg.bijsven.nl/MiniMC/pkg/security"
 	"pkg.bijsven.nl/MiniMC/pkg/server"
+	"pkg.bijsven.nl/MiniMC/pkg/storage"
+	"pkg.bijsven.nl/MiniMC/pkg/textenc"
 )
 
 //go:embed all:client/build
@@ -34,19 +45,28 @@ type FileInfo struct {
 	Extension string `json:"extension,omitempty"`
 }
 
+// FileContent is always UTF-8 on the wire. Encoding reports what the file is
+// stored as on disk when reading, and selects what it is stored as when
+// writing; an empty encoding writes UTF-8.
 type FileContent struct {
-	Path    string `json:"path"`
-	Content string `json:"content"`
+	Path     string `json:"path"`
+	Content  string `json:"content"`
+	Encoding string `json:"encoding,omitempty"`
+	// Confirm allows changing a protected path.
+	Confirm bool `json:"confirm,omitempty"`
 }
 
 type ErrorResponse struct {
 	Error   string `json:"error"`
 	Message string `json:"message"`
+	Detail  string `json:"detail,omitempty"`
 }
 
 type ExtractRequest struct {
 	Path        string `json:"path"`
 	Destination string `json:"destination,omitempty"`
+	Background  bool   `json:"background,omitempty"`
+	Confirm     bool   `json:"confirm,omitempty"`
 }
 
 const MinecraftDir = "./minecraft"
@@ -58,28 +78,149 @@ func main() {
 	if err := os.MkdirAll(MinecraftDir, 0755); err != nil {
 		log.Fatal("Failed to create minecraft directory:", err)
 	}
+	if err := storage.Open(); err != nil {
+		log.Fatal("Failed to open storage:", err)
+	}
+
+	setupRateLimits()
 
 	e := echo.New()
 	e.HideBanner = true
+	e.JSONSerializer = localizedSerializer{}
+	e.IPExtractor = ipExtractor()
 
-	e.Use(middleware.BasicAuth(func(username, password string, c echo.Context) (bool, error) {
-		if username == os.Getenv("username") && password == os.Getenv("password") {
-			return true, nil
-		}
-		return false, nil
-	}))
+	e.Use(middleware.RequestID())
+	e.Use(accessLogMiddleware(pkg.OpenAccessLog()))
+	e.Use(authMiddleware)
+	e.Use(rateLimitMiddleware)
 
 	buildFS, err := fs.Sub(build, "client/build")
 	if err != nil {
 		log.Fatal("Failed to create sub filesystem:", err)
 	}
 
-	e.GET("/*", echo.WrapHandler(http.FileServer(http.FS(buildFS))))
+	e.GET("/*", echo.WrapHandler(http.FileServer(http.FS(frontendFS(buildFS)))))
 
 	api := e.Group("/api")
 
+	api.POST("/auth/session", loginHandler)
+	api.GET("/auth/session", whoamiHandler)
+	api.DELETE("/auth/session", logoutHandler)
+
+	api.GET("/alerts", listAlerts)
+	api.GET("/incidents", listIncidents)
+	api.GET("/server/last-exit", lastExit)
+	api.GET("/jar/verify", verifyJar)
+	api.GET("/public/status", publicStatus)
+	api.GET("/status", serverStatus)
+	api.GET("/status/stream", statusStream)
+	api.GET("/audit", listAudit)
+	api.GET("/security/events", listSecurityEvents)
+	api.GET("/doctor", doctorHandler)
+	api.GET("/metrics", metricsHandler)
+	api.GET("/metrics/requests", requestStats)
+	api.GET("/metrics/gc", gcStats)
+	api.GET("/me/preferences", getPreferences)
+	api.PUT("/me/preferences", updatePreferences)
+	api.GET("/me/files", getQuickAccess)
+	api.POST("/me/favorites", updateFavorite)
+	api.DELETE("/me/favorites", updateFavorite)
+
 	api.GET("/logs", logsHandler)
+	api.GET("/sessions", listSessions)
+	api.GET("/sessions/:id", downloadSession)
+	api.GET("/sessions/:id/replay", replaySession)
+	api.GET("/shares", listShares)
+	api.POST("/shares", createShare)
+	api.DELETE("/shares/:token", revokeShare)
 	api.POST("/command", commandHandler)
+	api.GET("/power/pending", pendingPower)
+	api.DELETE("/power/pending", abortPower)
+	api.POST("/messages/send", sendMessage)
+	api.GET("/console/rules", getConsoleRules)
+	api.PUT("/console/rules", updateConsoleRules)
+	api.GET("/players/geo", playersGeoHandler)
+	api.GET("/players/activity", playersActivity)
+	api.GET("/analytics/players", playerAnalytics)
+
+	api.GET("/jobs", listJobs)
+	api.GET("/jobs/:id", getJob)
+	api.GET("/jobs/:id/events", jobEvents)
+	api.GET("/download/progress", downloadProgress)
+	api.GET("/download/events", downloadEvents)
+	api.GET("/instance/export", exportInstance)
+	api.POST("/instance/import", importInstance)
+	api.GET("/startup/events", startupEvents)
+
+	api.GET("/autosave", getAutosave)
+	api.PUT("/autosave", updateAutosave)
+
+	config := api.Group("/config")
+	config.GET("/online-mode", getOnlineMode)
+	config.PUT("/online-mode", setOnlineMode)
+	config.GET("/server-properties/diff", diffServerProperties)
+	config.GET("/history", configHistory)
+	config.GET("/history/:hash", configCommit)
+	config.POST("/history/:hash/revert", revertConfigCommit)
+	config.POST("/validate", validateConfig)
+
+	api.GET("/packs", listDatapacks)
+	api.GET("/packs/validate", validatePack)
+	api.GET("/hooks", getHooks)
+	api.PUT("/hooks", updateHooks)
+	api.GET("/launch", getLaunchConfig)
+	api.PUT("/launch", updateLaunchConfig)
+	api.POST("/migrate", migrateServer)
+	api.GET("/versions", listVersions)
+	api.GET("/update", updateStatus)
+	api.POST("/update", updateJar)
+	api.GET("/jars", listJars)
+	api.POST("/rollback", rollbackJar)
+	api.GET("/upgrade/check", upgradeCheck)
+	api.POST("/upgrade/acknowledge", acknowledgeUpgrade)
+
+	pluginsGroup := api.Group("/plugins")
+	pluginsGroup.GET("", listPlugins)
+	pluginsGroup.GET("/usage", pluginUsage)
+	pluginsGroup.POST("/usage/cleanup", cleanupPlugins)
+	pluginsGroup.GET("/dependencies", pluginDependencies)
+	pluginsGroup.POST("/install", installPlugin)
+	pluginsGroup.POST("/:name/disable", disablePlugin)
+	pluginsGroup.POST("/:name/enable", enablePlugin)
+
+	backups := api.Group("/backups")
+	backups.GET("", listBackups)
+	backups.POST("", createBackup)
+	backups.GET("/profiles", listBackupProfiles)
+	backups.PUT("/profiles", saveBackupProfiles)
+	backups.DELETE("/:id", deleteBackup)
+	backups.POST("/:id/restore", restoreBackup)
+	backups.POST("/:id/verify", verifyBackup)
+
+	api.POST("/worlds/upgrade", upgradeWorlds)
+	api.GET("/worlds/:name/stats", worldStats)
+	api.GET("/worlds/:name/preview", worldPreview)
+	api.GET("/worldborder", getWorldBorder)
+	api.PUT("/worldborder", setWorldBorder)
+
+	syncGroup := api.Group("/sync")
+	syncGroup.GET("", listSyncTargets)
+	syncGroup.POST("", createSyncTarget)
+	syncGroup.DELETE("/:id", deleteSyncTarget)
+	syncGroup.POST("/:id/run", runSyncTarget)
+
+	notifications := api.Group("/notifications")
+	notifications.GET("", listNotifications)
+	notifications.POST("", createNotification)
+	notifications.DELETE("/:id", deleteNotification)
+	notifications.POST("/:id/test", testNotification)
+	notifications.GET("/:id/deliveries", listDeliveries)
+
+	schedules := api.Group("/schedules")
+	schedules.GET("", listSchedules)
+	schedules.POST("", createSchedule)
+	schedules.DELETE("/:id", deleteSchedule)
+	schedules.GET("/:id/runs", listScheduleRuns)
 
 	files := api.Group("/files")
 	files.GET("", listFiles)
@@ -91,26 +232,122 @@ func main() {
 	files.POST("/mkdir", createDirectory)
 	files.POST("/move", moveFile)
 	files.POST("/copy", copyFile)
+	files.POST("/batch", batchFiles)
 	files.POST("/extract", extractArchive)
 	files.POST("/upload", uploadFile)
+	files.GET("/transfer", transferHandler)
+	files.GET("/rules", getFileRules)
+	files.PUT("/rules", updateFileRules)
 
 	version := os.Getenv("MC_VERSION")
 	if version == "" {
 		version = "no_version"
 	}
 
-	if err := pkg.GetPaper(version); err != nil {
-		log.Println("[e]", err)
+	if server.Fake() {
+		log.Println("[w] FAKE_SERVER is set, skipping the server jar download")
+	} else {
+		server.PublishStartup(server.StartupEvent{Stage: server.StageDownloading, Percent: -1})
+		job := jobs.New("download")
+		err = pkg.GetJar(pkg.Flavor(), version, job)
+		job.Finish(err)
+		if err != nil {
+			// An installed server still starts while the download API is
+			// unreachable, it just isn't updated.
+			if _, statErr := os.Stat(pkg.LaunchFile()); statErr == nil {
+				log.Println("[w] could not check for a newer server jar, starting the installed one:", err)
+			} else {
+				log.Println("[e]", err)
+				server.PublishStartup(server.StartupEvent{Stage: server.StageFailed, Percent: -1, Message: err.Error()})
+			}
+		}
+	}
+
+	logDoctor()
+	warnOnlineMode()
+	openConfigHistory()
+
+	if path := os.Getenv("GEOIP_DB"); path != "" {
+		if err := geoip.Open(path); err != nil {
+			log.Println("[e] Failed to open geoip database:", err)
+		}
+	}
+
+	if url := os.Getenv("SECURITY_WEBHOOK_URL"); url != "" {
+		go security.RunWebhook(url)
+	}
+
+	if interval, err := time.ParseDuration(os.Getenv("AUTOSAVE_INTERVAL")); err == nil {
+		server.SetAutosaveInterval(interval)
+	}
+
+	openPlayerStats()
+	registerScheduleActions()
+	registerHooks()
+	registerNotifications()
+	registerIntegrity()
+	startChatBridge()
+	if err := scheduler.Start(); err != nil {
+		log.Println("[e] Failed to load schedules:", err)
+	}
+
+	registerMetrics()
+	if err := apistats.Open(); err != nil {
+		log.Println("[e] could not load request history:", err)
+	}
+	registerAlertRules()
+	go alerts.Run(30 * time.Second)
+	go runUpdateChecks()
+
+	if cfg, ok := server.TuningConfigFromEnv(); ok {
+		go server.RunTuner(cfg)
 	}
 
 	log.Printf("[i] Welcome to MiniMC! (Ready in ~%.1fs)\n", time.Since(start).Seconds())
 
+	shutdown := handleShutdown(e)
 	if err := e.Start(":8080"); err != http.ErrServerClosed {
 		log.Fatal(err)
 	}
+	<-shutdown
+	log.Println("[i] MiniMC stopped")
 }
 
 func logsHandler(c echo.Context) error {
+	policy := c.QueryParam("policy")
+	if policy != "" && !pkg.ValidPolicy(policy) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_policy",
+			Message: "policy must be drop, drop-oldest, coalesce or disconnect",
+		})
+	}
+
+	// ?grep= filters server-side, sending only matching lines and
+	// ?context= lines around them.
+	var filter *pkg.LineFilter
+	if pattern := c.QueryParam("grep"); pattern != "" {
+		context := 2
+		if v := c.QueryParam("context"); v != "" {
+			context, _ = strconv.Atoi(v)
+		}
+		var err error
+		if filter, err = pkg.NewLineFilter(pattern, context); err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_filter",
+				Message: err.Error(),
+			})
+		}
+	}
+	write := func(line string) {
+		lines := []string{line}
+		if filter != nil {
+			lines = filter.Filter(line)
+		}
+		for _, l := range lines {
+			c.Response().Write([]byte("data: " + l + "\n"))
+		}
+	}
+
 	c.Response().Header().Set(echo.HeaderContentType, "text/event-stream")
 	c.Response().Header().Set(echo.HeaderCacheControl, "no-cache")
 	c.Response().Header().Set(echo.HeaderConnection, "keep-alive")
@@ -120,17 +357,36 @@ func logsHandler(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Streaming unsupported")
 	}
 
-	ch := pkg.Subscribe()
+	// Streams opened through a share link end when the share expires.
+	var expired <-chan time.Time
+	if share, ok := c.Get("share").(*auth.Share); ok {
+		timer := time.NewTimer(time.Until(share.Expires))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	ch := pkg.SubscribeWithPolicy(policy)
+	defer pkg.Unsubscribe(ch)
 	for _, logLine := range pkg.GetSessionLogs() {
-		c.Response().Write([]byte("data: " + logLine + "\n"))
+		write(logLine)
 	}
 	flusher.Flush()
 
-	for msg := range ch {
-		c.Response().Write([]byte("data: " + msg + "\n"))
-		flusher.Flush()
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				// Disconnected for falling behind, the client reconnects.
+				return nil
+			}
+			write(msg)
+			flusher.Flush()
+		case <-expired:
+			return nil
+		case <-c.Request().Context().Done():
+			return nil
+		}
 	}
-	return nil
 }
 
 func commandHandler(c echo.Context) error {
@@ -142,6 +398,17 @@ func commandHandler(c echo.Context) error {
 	switch cmd {
 	case "start":
 		if err := server.Start(); err != nil {
+			var javaErr *server.JavaError
+			if errors.As(err, &javaErr) {
+				code := "java_too_old"
+				if javaErr.Err != nil {
+					code = "java_unavailable"
+				}
+				return c.JSON(http.StatusConflict, ErrorResponse{
+					Error:   code,
+					Message: err.Error(),
+				})
+			}
 			return c.NoContent(http.StatusInternalServerError)
 		}
 		log.Println("[i] Server starting")
@@ -150,31 +417,14 @@ func commandHandler(c echo.Context) error {
 			return c.NoContent(http.StatusInternalServerError)
 		}
 		log.Println("[i] Server killed")
-	case "stats":
-		memUsed, memTotal := uint64(0), uint64(0)
-		memPaths := []struct{ usage, limit string }{
-			{"/sys/fs/cgroup/memory.current", "/sys/fs/cgroup/memory.max"},
-			{"/sys/fs/cgroup/memory/memory.usage_in_bytes", "/sys/fs/cgroup/memory/memory.limit_in_bytes"},
-		}
-
-		for _, p := range memPaths {
-			if data, err := os.ReadFile(p.usage); err == nil {
-				if used, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64); err == nil {
-					memUsed = used / 1024 / 1024
-				}
-			}
-			if data, err := os.ReadFile(p.limit); err == nil {
-				text := strings.TrimSpace(string(data))
-				if text == "max" {
-					memTotal = 0
-				} else if limit, err := strconv.ParseUint(text, 10, 64); err == nil {
-					memTotal = limit / 1024 / 1024
-				}
-			}
-			if memUsed != 0 && memTotal != 0 {
-				break
-			}
+	case "stop", "restart":
+		if status, blocked := blockCommand(c, cmd); blocked != nil {
+			return c.JSON(status, blocked)
 		}
+		return stopServer(c, cmd == "restart")
+	case "stats":
+		memUsed, memTotal := pkg.CgroupMemory()
+		memUsed, memTotal = memUsed/1024/1024, memTotal/1024/1024
 
 		cpuPercent := 0.0
 		cpuStatPath := "/sys/fs/cgroup/cpu.stat"
@@ -215,14 +465,52 @@ func commandHandler(c echo.Context) error {
 			cpuPercent, memUsed, memTotal, diskStat.UsedPercent, diskStat.Used/1024/1024, diskStat.Total/1024/1024)
 
 	default:
+		if status, blocked := blockCommand(c, cmd); blocked != nil {
+			return c.JSON(status, blocked)
+		}
+		// With queue=true, commands sent while the server is starting run
+		// once it is ready.
+		if queue, _ := strconv.ParseBool(c.FormValue("queue")); queue {
+			queued, err := server.QueueCommand(cmd)
+			if err != nil {
+				return commandError(c, err)
+			}
+			if queued {
+				return c.JSON(http.StatusAccepted, map[string]interface{}{
+					"queued":  true,
+					"message": "The server is starting, the command runs once it is ready",
+				})
+			}
+			break
+		}
 		if err := server.RunCommand(cmd); err != nil {
-			return c.NoContent(http.StatusInternalServerError)
+			return commandError(c, err)
 		}
 	}
 
 	return c.NoContent(http.StatusOK)
 }
 
+// commandError reports a console command that didn't reach the server.
+func commandError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, server.ErrCommandQueueFull):
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error:   "command_queue_full",
+			Message: err.Error(),
+		})
+	case errors.Is(err, server.ErrServerExited), !server.GetStatus():
+		return c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "server_not_running",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(http.StatusInternalServerError, ErrorResponse{
+		Error:   "command_failed",
+		Message: err.Error(),
+	})
+}
+
 func sanitizePath(path string) (string, error) {
 	path = strings.TrimSpace(path)
 	if path == "" || path == "/" {
@@ -249,6 +537,9 @@ func listFiles(c echo.Context) error {
 			Message: err.Error(),
 		})
 	}
+	if denied := shareDenied(c, path, true); denied != nil {
+		return c.JSON(http.StatusForbidden, denied)
+	}
 
 	entries, err := os.ReadDir(fullPath)
 	if err != nil {
@@ -269,6 +560,13 @@ func listFiles(c echo.Context) error {
 		if err != nil {
 			relativePath = entry.Name()
 		}
+		if share, ok := c.Get("share").(*auth.Share); ok {
+			// Only show what the share gives access to.
+			p := filepath.ToSlash(relativePath)
+			if (entry.IsDir() && !share.AllowsDir(p)) || (!entry.IsDir() && !share.AllowsFile(p)) {
+				continue
+			}
+		}
 
 		fileInfo := FileInfo{
 			Name:    entry.Name(),
@@ -304,6 +602,9 @@ func readFile(c echo.Context) error {
 			Message: err.Error(),
 		})
 	}
+	if denied := shareDenied(c, path, false); denied != nil {
+		return c.JSON(http.StatusForbidden, denied)
+	}
 
 	info, err := os.Stat(fullPath)
 	if err != nil {
@@ -328,9 +629,19 @@ func readFile(c echo.Context) error {
 		})
 	}
 
+	encoding := textenc.Detect(content)
+	text, err := textenc.Decode(content, encoding)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "decode_error",
+			Message: err.Error(),
+		})
+	}
+
 	return c.JSON(http.StatusOK, FileContent{
-		Path:    path,
-		Content: string(content),
+		Path:     path,
+		Content:  text,
+		Encoding: encoding,
 	})
 }
 
@@ -357,6 +668,9 @@ func writeFile(c echo.Context) error {
 			Message: err.Error(),
 		})
 	}
+	if status, denied := protectedDenied(c, fileContent.Path, false, fileContent.Confirm || confirmedParam(c)); denied != nil {
+		return c.JSON(status, denied)
+	}
 
 	dir := filepath.Dir(fullPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -366,7 +680,15 @@ func writeFile(c echo.Context) error {
 		})
 	}
 
-	if err := os.WriteFile(fullPath, []byte(fileContent.Content), 0644); err != nil {
+	data, err := textenc.Encode(fileContent.Content, fileContent.Encoding)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "encode_error",
+			Message: err.Error(),
+		})
+	}
+
+	if err := os.WriteFile(fullPath, data, 0644); err != nil {
 		return c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error:   "write_error",
 			Message: err.Error(),
@@ -374,6 +696,8 @@ func writeFile(c echo.Context) error {
 	}
 
 	log.Printf("[i] File written: %s", fileContent.Path)
+	recordRecentFile(currentUser(c), fileContent.Path)
+	recordConfigChange(c, "Edit "+cleanFilePath(fileContent.Path), fileContent.Path)
 	return c.JSON(http.StatusOK, map[string]string{
 		"message": "File written successfully",
 		"path":    fileContent.Path,
@@ -403,6 +727,9 @@ func deleteFile(c echo.Context) error {
 			Message: "Cannot delete minecraft root directory",
 		})
 	}
+	if status, denied := protectedDenied(c, path, true, confirmedParam(c)); denied != nil {
+		return c.JSON(status, denied)
+	}
 
 	if err := os.RemoveAll(fullPath); err != nil {
 		return c.JSON(http.StatusInternalServerError, ErrorResponse{
@@ -412,6 +739,7 @@ func deleteFile(c echo.Context) error {
 	}
 
 	log.Printf("[i] Deleted: %s", path)
+	recordConfigChange(c, "Delete "+cleanFilePath(path), path)
 	return c.JSON(http.StatusOK, map[string]string{
 		"message": "File/directory deleted successfully",
 		"path":    path,
@@ -420,7 +748,8 @@ func deleteFile(c echo.Context) error {
 
 func createDirectory(c echo.Context) error {
 	var request struct {
-		Path string `json:"path"`
+		Path    string `json:"path"`
+		Confirm bool   `json:"confirm"`
 	}
 
 	if err := c.Bind(&request); err != nil {
@@ -444,6 +773,9 @@ func createDirectory(c echo.Context) error {
 			Message: err.Error(),
 		})
 	}
+	if status, denied := protectedDenied(c, request.Path, false, request.Confirm || confirmedParam(c)); denied != nil {
+		return c.JSON(status, denied)
+	}
 
 	if err := os.MkdirAll(fullPath, 0755); err != nil {
 		return c.JSON(http.StatusInternalServerError, ErrorResponse{
@@ -461,8 +793,9 @@ func createDirectory(c echo.Context) error {
 
 func moveFile(c echo.Context) error {
 	var request struct {
-		From string `json:"from"`
-		To   string `json:"to"`
+		From    string `json:"from"`
+		To      string `json:"to"`
+		Confirm bool   `json:"confirm"`
 	}
 
 	if err := c.Bind(&request); err != nil {
@@ -494,6 +827,13 @@ func moveFile(c echo.Context) error {
 			Message: err.Error(),
 		})
 	}
+	confirmed := request.Confirm || confirmedParam(c)
+	if status, denied := protectedDenied(c, request.From, true, confirmed); denied != nil {
+		return c.JSON(status, denied)
+	}
+	if status, denied := protectedDenied(c, request.To, true, confirmed); denied != nil {
+		return c.JSON(status, denied)
+	}
 
 	dir := filepath.Dir(toPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -511,6 +851,7 @@ func moveFile(c echo.Context) error {
 	}
 
 	log.Printf("[i] Moved: %s -> %s", request.From, request.To)
+	recordConfigChange(c, "Move "+cleanFilePath(request.From)+" to "+cleanFilePath(request.To), request.From, request.To)
 	return c.JSON(http.StatusOK, map[string]string{
 		"message": "File/directory moved successfully",
 		"from":    request.From,
@@ -520,8 +861,9 @@ func moveFile(c echo.Context) error {
 
 func copyFile(c echo.Context) error {
 	var request struct {
-		From string `json:"from"`
-		To   string `json:"to"`
+		From    string `json:"from"`
+		To      string `json:"to"`
+		Confirm bool   `json:"confirm"`
 	}
 
 	if err := c.Bind(&request); err != nil {
@@ -553,6 +895,9 @@ func copyFile(c echo.Context) error {
 			Message: err.Error(),
 		})
 	}
+	if status, denied := protectedDenied(c, request.To, false, request.Confirm || confirmedParam(c)); denied != nil {
+		return c.JSON(status, denied)
+	}
 
 	info, err := os.Stat(fromPath)
 	if err != nil {
@@ -603,6 +948,7 @@ func copyFile(c echo.Context) error {
 	}
 
 	log.Printf("[i] Copied: %s -> %s", request.From, request.To)
+	recordConfigChange(c, "Copy "+cleanFilePath(request.From)+" to "+cleanFilePath(request.To), request.To)
 	return c.JSON(http.StatusOK, map[string]string{
 		"message": "File copied successfully",
 		"from":    request.From,
@@ -666,8 +1012,33 @@ func extractArchive(c echo.Context) error {
 			})
 		}
 	}
+	dest := request.Destination
+	if dest == "" {
+		dest = path.Dir(cleanFilePath(request.Path))
+	}
+	if status, denied := protectedDenied(c, dest, true, request.Confirm || confirmedParam(c)); denied != nil {
+		return c.JSON(status, denied)
+	}
+
+	job := jobs.New("extract")
+	if request.Background {
+		go func() {
+			extractedFiles, err := extractTarGz(fullPath, destPath, job)
+			job.Finish(err)
+			if err != nil {
+				log.Printf("[e] Extraction of %s failed: %v", request.Path, err)
+				return
+			}
+			log.Printf("[i] Extracted %d files from %s to %s", len(extractedFiles), request.Path, destPath)
+		}()
+		return c.JSON(http.StatusAccepted, map[string]string{
+			"message": "Extraction started",
+			"job":     job.ID(),
+		})
+	}
 
-	extractedFiles, err := extractTarGz(fullPath, destPath)
+	extractedFiles, err := extractTarGz(fullPath, destPath, job)
+	job.Finish(err)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error:   "extraction_failed",
@@ -685,7 +1056,7 @@ func extractArchive(c echo.Context) error {
 	})
 }
 
-func extractTarGz(src, dest string) ([]string, error) {
+func extractTarGz(src, dest string, job *jobs.Job) ([]string, error) {
 	var extractedFiles []string
 
 	file, err := os.Open(src)
@@ -694,7 +1065,13 @@ func extractTarGz(src, dest string) ([]string, error) {
 	}
 	defer file.Close()
 
-	gzr, err := gzip.NewReader(file)
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	counter := &countingReader{r: file}
+
+	gzr, err := gzip.NewReader(counter)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
 	}
@@ -711,6 +1088,8 @@ func extractTarGz(src, dest string) ([]string, error) {
 			return nil, fmt.Errorf("failed to read tar header: %w", err)
 		}
 
+		job.Update(header.Name, counter.n, info.Size())
+
 		target := filepath.Join(dest, header.Name)
 		target = filepath.Clean(target)
 
@@ -769,6 +1148,9 @@ func uploadFile(c echo.Context) error {
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
+	if status, denied := protectedDenied(c, path, false, confirmedParam(c)); denied != nil {
+		return c.JSON(status, denied)
+	}
 
 	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
@@ -791,5 +1173,25 @@ func uploadFile(c echo.Context) error {
 	}
 
 	log.Printf("[i] Uploaded file: %s", path)
-	return c.JSON(http.StatusOK, map[string]string{"message": "File uploaded successfully", "path": path})
+	recordConfigChange(c, "Upload "+cleanFilePath(path), path)
+
+	response := map[string]interface{}{"message": "File uploaded successfully", "path": path}
+	if pack := validateUploadedPack(path, fullPath); pack != nil {
+		response["pack"] = pack
+		if !pack.Compatible {
+			log.Printf("[w] Uploaded pack %s: %s", path, strings.Join(pack.Problems, "; "))
+		}
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
 }
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/userdata"
+)
+
+type Preferences struct {
+	FontSize         int      `json:"font_size"`
+	Theme            string   `json:"theme"`
+	PinnedFiles      []string `json:"pinned_files"`
+	DefaultDirectory string   `json:"default_directory"`
+}
+
+func defaultPreferences() Preferences {
+	return Preferences{
+		FontSize:    14,
+		Theme:       "dark",
+		PinnedFiles: []string{},
+	}
+}
+
+func (p Preferences) validate() error {
+	if p.FontSize < 8 || p.FontSize > 32 {
+		return errors.New("font_size must be between 8 and 32")
+	}
+	if p.Theme != "dark" && p.Theme != "light" {
+		return errors.New("theme must be dark or light")
+	}
+	if len(p.PinnedFiles) > 50 {
+		return errors.New("at most 50 files can be pinned")
+	}
+	for _, path := range append([]string{p.DefaultDirectory}, p.PinnedFiles...) {
+		if _, err := sanitizePath(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func currentUser(c echo.Context) string {
+	user, _ := c.Get("user").(string)
+	return user
+}
+
+func getPreferences(c echo.Context) error {
+	prefs := defaultPreferences()
+	if err := userdata.Load(currentUser(c), "preferences", &prefs); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "read_error",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, prefs)
+}
+
+func updatePreferences(c echo.Context) error {
+	prefs := defaultPreferences()
+	if err := userdata.Load(currentUser(c), "preferences", &prefs); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "read_error",
+			Message: err.Error(),
+		})
+	}
+
+	// Binding onto the stored preferences lets clients send only the fields
+	// they want to change.
+	if err := c.Bind(&prefs); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+	if prefs.PinnedFiles == nil {
+		prefs.PinnedFiles = []string{}
+	}
+
+	if err := prefs.validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_preferences",
+			Message: err.Error(),
+		})
+	}
+
+	if err := userdata.Save(currentUser(c), "preferences", prefs); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "write_error",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, prefs)
+}
+
+const maxRecentFiles = 20
+
+// suggestedFiles are offered for quick access when they exist on disk.
+var suggestedFiles = []string{
+	"/server.properties",
+	"/config/paper-global.yml",
+	"/config/paper-world-defaults.yml",
+	"/bukkit.yml",
+	"/spigot.yml",
+}
+
+type RecentFile struct {
+	Path   string    `json:"path"`
+	Edited time.Time `json:"edited"`
+}
+
+// cleanFilePath normalizes a path as used by the files API, so the same file
+// is never listed twice.
+func cleanFilePath(p string) string {
+	return path.Clean("/" + p)
+}
+
+// recordRecentFile moves p to the front of the user's recently edited files.
+// Failures are only logged, they never fail the edit itself.
+func recordRecentFile(user, p string) {
+	if user == "" {
+		return
+	}
+	p = cleanFilePath(p)
+
+	recent := []RecentFile{}
+	if err := userdata.Load(user, "recent", &recent); err != nil {
+		log.Println("[w] could not read recent files:", err)
+	}
+
+	list := []RecentFile{{Path: p, Edited: time.Now()}}
+	for _, f := range recent {
+		if f.Path != p && len(list) < maxRecentFiles {
+			list = append(list, f)
+		}
+	}
+
+	if err := userdata.Save(user, "recent", list); err != nil {
+		log.Println("[w] could not save recent files:", err)
+	}
+}
+
+func getQuickAccess(c echo.Context) error {
+	prefs := defaultPreferences()
+	recent := []RecentFile{}
+	if err := userdata.Load(currentUser(c), "preferences", &prefs); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "read_error",
+			Message: err.Error(),
+		})
+	}
+	if err := userdata.Load(currentUser(c), "recent", &recent); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "read_error",
+			Message: err.Error(),
+		})
+	}
+
+	suggested := []string{}
+	for _, p := range suggestedFiles {
+		full, _ := sanitizePath(p)
+		if _, err := os.Stat(full); err == nil {
+			suggested = append(suggested, p)
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"favorites": prefs.PinnedFiles,
+		"recent":    recent,
+		"suggested": suggested,
+	})
+}
+
+// updateFavorite pins (POST) or unpins (DELETE) a file. Favorites are the
+// pinned files of the user's preferences.
+func updateFavorite(c echo.Context) error {
+	var request struct {
+		Path string `json:"path"`
+	}
+	if c.Request().Method == http.MethodDelete {
+		request.Path = c.QueryParam("path")
+	} else if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+
+	if request.Path == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "missing_path",
+			Message: "Path is required",
+		})
+	}
+	if _, err := sanitizePath(request.Path); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_path",
+			Message: err.Error(),
+		})
+	}
+	p := cleanFilePath(request.Path)
+
+	prefs := defaultPreferences()
+	if err := userdata.Load(currentUser(c), "preferences", &prefs); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "read_error",
+			Message: err.Error(),
+		})
+	}
+
+	pinned := []string{}
+	for _, f := range prefs.PinnedFiles {
+		if cleanFilePath(f) != p {
+			pinned = append(pinned, f)
+		}
+	}
+	if c.Request().Method != http.MethodDelete {
+		pinned = append(pinned, p)
+	}
+	prefs.PinnedFiles = pinned
+
+	if err := prefs.validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_preferences",
+			Message: err.Error(),
+		})
+	}
+	if err := userdata.Save(currentUser(c), "preferences", prefs); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "write_error",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, prefs.PinnedFiles)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/audit"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+	"pkg.bijsven.nl/MiniMC/pkg/tellraw"
+)
+
+// sendMessage turns a structured message into a tellraw command for the
+// installed Minecraft version and runs it. With ?dry_run=true the command is
+// only returned.
+func sendMessage(c echo.Context) error {
+	var msg tellraw.Message
+	if err := c.Bind(&msg); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+
+	cmd, err := msg.Command(installedVersion())
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_message",
+			Message: err.Error(),
+		})
+	}
+	if c.QueryParam("dry_run") == "true" {
+		return c.JSON(http.StatusOK, map[string]string{"command": cmd})
+	}
+
+	if status, blocked := blockCommand(c, cmd); blocked != nil {
+		return c.JSON(status, blocked)
+	}
+	if err := server.RunCommand(cmd); err != nil {
+		return c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "server_not_running",
+			Message: err.Error(),
+		})
+	}
+
+	audit.Record(currentUser(c), "message", cmd)
+	log.Printf("[i] %s sent a message to %s\n", currentUser(c), msg.Target)
+	return c.JSON(http.StatusOK, map[string]string{"command": cmd})
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/apistats"
+	"pkg.bijsven.nl/MiniMC/pkg/gclog"
+	"pkg.bijsven.nl/MiniMC/pkg/metrics"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+)
+
+func registerMetrics() {
+	metrics.Gauge("minimc_server_running", "Whether the Minecraft server process is running.", func() float64 {
+		if server.GetStatus() {
+			return 1
+		}
+		return 0
+	})
+	metrics.Gauge("minimc_players_online", "Number of players currently online.", func() float64 {
+		return float64(server.PlayerCount())
+	})
+	metrics.Gauge("minimc_players_active", "Number of online players that aren't AFK.", func() float64 {
+		return float64(server.ActivePlayerCount())
+	})
+	metrics.Gauge("minimc_tps", "Average of the most recent TPS samples.", func() float64 {
+		tps, _ := server.AverageTPS()
+		return tps
+	})
+}
+
+func metricsHandler(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderContentType, "text/plain; version=0.0.4")
+	c.Response().WriteHeader(http.StatusOK)
+	return metrics.Write(c.Response())
+}
+
+// requestStats aggregates the API requests of the last ?window= (default 1h,
+// at most apistats.Retention).
+func requestStats(c echo.Context) error {
+	window := time.Hour
+	if v := c.QueryParam("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Minute || d > apistats.Retention {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_window",
+				Message: "window must be a duration between 1m and 24h",
+			})
+		}
+		window = d
+	}
+
+	limit := 10
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_limit",
+				Message: "limit must be a positive number",
+			})
+		}
+		limit = n
+	}
+
+	return c.JSON(http.StatusOK, apistats.Summarize(window, limit))
+}
+
+// gcStats summarizes the GC pauses of the last ?window= (default 1h) from
+// the JVM's GC log, which is written when GC_LOG=true.
+func gcStats(c echo.Context) error {
+	window := time.Hour
+	if v := c.QueryParam("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Minute || d > 24*time.Hour {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_window",
+				Message: "window must be a duration between 1m and 24h",
+			})
+		}
+		window = d
+	}
+
+	pauses, err := gclog.Read(server.GCLogDir(), time.Now().Add(-window))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "read_error",
+			Message: err.Error(),
+		})
+	}
+	if len(pauses) == 0 && !server.GCLogEnabled() {
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "gc_log_disabled",
+			Message: "Set GC_LOG=true and restart the server to log garbage collections",
+		})
+	}
+	return c.JSON(http.StatusOK, gclog.Summarize(pauses))
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg"
+	"pkg.bijsven.nl/MiniMC/pkg/audit"
+	"pkg.bijsven.nl/MiniMC/pkg/backup"
+	"pkg.bijsven.nl/MiniMC/pkg/jobs"
+	"pkg.bijsven.nl/MiniMC/pkg/plugins"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+)
+
+const migrationBackupProfile = "full"
+
+type MigrationPlan struct {
+	From     string   `json:"from"`
+	To       string   `json:"to"`
+	Version  string   `json:"version"`
+	Backup   string   `json:"backup_profile"`
+	Notes    []string `json:"notes"`
+	Plugins  []string `json:"plugins"`
+	Warnings []string `json:"warnings"`
+}
+
+// planMigration works out what converting to another flavor involves. from
+// and version are only needed when no manifest exists, e.g. for a Spigot jar
+// that was uploaded by hand.
+func planMigration(from, to, version string) (*MigrationPlan, error) {
+	if m, err := pkg.LoadManifest(); err == nil {
+		from, version = m.Flavor, m.Version
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if from == "" || version == "" {
+		return nil, errors.New("no manifest found, pass the current flavor as \"from\" and the Minecraft \"version\"")
+	}
+
+	notes, err := pkg.MigrationNotes(from, to)
+	if err != nil {
+		return nil, err
+	}
+	plan := &MigrationPlan{
+		From:     from,
+		To:       to,
+		Version:  version,
+		Backup:   migrationBackupProfile,
+		Notes:    notes,
+		Plugins:  []string{},
+		Warnings: []string{},
+	}
+
+	installed, err := plugins.Installed()
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range installed {
+		plan.Plugins = append(plan.Plugins, d.Name)
+		if from == "purpur" && dependsOn(d, "purpur") {
+			plan.Warnings = append(plan.Warnings, d.Name+" declares a dependency on Purpur and will likely not load on "+to)
+		}
+	}
+	return plan, nil
+}
+
+func dependsOn(d plugins.Descriptor, name string) bool {
+	for _, dep := range append(d.Depend, d.SoftDepend...) {
+		if strings.EqualFold(dep, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// migrateServer converts the install to another flavor. Without "confirm" it
+// only returns the plan; with it, it backs up and swaps the jar.
+func migrateServer(c echo.Context) error {
+	var request struct {
+		From    string `json:"from"`
+		To      string `json:"to"`
+		Version string `json:"version"`
+		Confirm bool   `json:"confirm"`
+	}
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+
+	plan, err := planMigration(request.From, request.To, request.Version)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_migration",
+			Message: err.Error(),
+		})
+	}
+
+	if !request.Confirm {
+		return c.JSON(http.StatusPreconditionRequired, map[string]interface{}{
+			"error":   "confirmation_required",
+			"message": "Review the plan and resend with \"confirm\": true to migrate.",
+			"plan":    plan,
+		})
+	}
+
+	if server.GetStatus() {
+		return c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "server_running",
+			Message: "Stop the server before migrating",
+		})
+	}
+
+	profile, err := backup.FindProfile(plan.Backup)
+	if err != nil {
+		return backupError(c, err)
+	}
+
+	user, _ := c.Get("user").(string)
+	audit.Record(user, "migrate", plan.From+" -> "+plan.To+" "+plan.Version)
+
+	backupJob := jobs.New("backup")
+	migrateJob := jobs.New("migrate")
+	go func() {
+		if _, err := runBackup(plan.Backup, profile.Paths, backupJob); err != nil {
+			migrateJob.Finish(errors.New("backup failed, nothing was changed: " + err.Error()))
+			return
+		}
+
+		log.Printf("[i] Migrating from %s to %s (%s)", plan.From, plan.To, plan.Version)
+		err := pkg.Migrate(plan.To, plan.Version, migrateJob)
+		migrateJob.Finish(err)
+		if err != nil {
+			log.Println("[e] Migration failed:", err)
+			return
+		}
+		log.Printf("[i] Migrated to %s, start the server to finish", plan.To)
+	}()
+
+	return c.JSON(http.StatusAccepted, map[string]interface{}{
+		"message":    "Migration started",
+		"plan":       plan,
+		"backup_job": backupJob.ID(),
+		"job":        migrateJob.ID(),
+	})
+}
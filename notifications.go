@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/alerts"
+	"pkg.bijsven.nl/MiniMC/pkg/notify"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+)
+
+// registerNotifications forwards alerts and server incidents to the
+// configured notification destinations.
+func registerNotifications() {
+	alerts.OnChange(func(a alerts.Alert) {
+		notify.Send(notify.Message{
+			Event: "alert_" + string(a.State),
+			Title: "Alert " + a.Name + " " + string(a.State),
+			Text:  a.Message,
+		})
+	})
+	server.OnIncident(func(i server.Incident) {
+		notify.Send(notify.Message{
+			Event: "incident",
+			Title: "Server " + i.Kind,
+			Text:  i.Detail + " " + i.Recommendation,
+			Time:  i.Time,
+		})
+	})
+}
+
+func notificationError(c echo.Context, err error) error {
+	if errors.Is(err, notify.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "notification_not_found",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(http.StatusInternalServerError, ErrorResponse{
+		Error:   "notification_error",
+		Message: err.Error(),
+	})
+}
+
+func listNotifications(c echo.Context) error {
+	list, err := notify.List()
+	if err != nil {
+		return notificationError(c, err)
+	}
+	for i := range list {
+		list[i] = list[i].Masked()
+	}
+	return c.JSON(http.StatusOK, list)
+}
+
+func createNotification(c echo.Context) error {
+	var request notify.Destination
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+	if err := notify.Validate(request); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_notification",
+			Message: err.Error(),
+		})
+	}
+
+	d, err := notify.Add(request)
+	if err != nil {
+		return notificationError(c, err)
+	}
+	log.Printf("[i] Notification destination %q created (%s)", d.Name, d.Type)
+	return c.JSON(http.StatusCreated, d.Masked())
+}
+
+func deleteNotification(c echo.Context) error {
+	if err := notify.Delete(c.Param("id")); err != nil {
+		return notificationError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// testNotification sends a test message right away and reports how the
+// destination responded.
+func testNotification(c echo.Context) error {
+	delivery, err := notify.Test(c.Param("id"))
+	if err != nil {
+		return notificationError(c, err)
+	}
+	status := http.StatusOK
+	if !delivery.OK {
+		status = http.StatusBadGateway
+	}
+	return c.JSON(status, delivery)
+}
+
+func listDeliveries(c echo.Context) error {
+	if _, err := notify.Get(c.Param("id")); err != nil {
+		return notificationError(c, err)
+	}
+	return c.JSON(http.StatusOK, notify.Deliveries(c.Param("id")))
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg"
+	"pkg.bijsven.nl/MiniMC/pkg/packs"
+)
+
+// installedVersion returns the Minecraft version of the installed jar, or ""
+// when nothing is installed.
+func installedVersion() string {
+	if m, err := pkg.LoadManifest(); err == nil {
+		return m.Version
+	}
+	return ""
+}
+
+// validateUploadedPack checks a freshly uploaded zip when it looks like a
+// datapack or resource pack. It returns nil for any other file.
+func validateUploadedPack(path, fullPath string) *packs.Result {
+	if !strings.EqualFold(filepath.Ext(fullPath), ".zip") {
+		return nil
+	}
+	r, err := packs.Validate(fullPath, installedVersion())
+	if errors.Is(err, packs.ErrNoMcmeta) && strings.Contains(filepath.ToSlash(fullPath), "/datapacks/") {
+		return &packs.Result{Path: path, Type: packs.TypeDatapack, Problems: []string{err.Error() + " at the root of the zip"}}
+	}
+	if err != nil {
+		return nil
+	}
+	r.Path = path
+	return r
+}
+
+func listDatapacks(c echo.Context) error {
+	results, err := packs.Datapacks(MinecraftDir, installedVersion())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "read_error",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, results)
+}
+
+func validatePack(c echo.Context) error {
+	path := c.QueryParam("path")
+	fullPath, err := sanitizePath(path)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_path",
+			Message: err.Error(),
+		})
+	}
+
+	version := c.QueryParam("version")
+	if version == "" {
+		version = installedVersion()
+	}
+	r, err := packs.Validate(fullPath, version)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "file_not_found",
+			Message: "File not found",
+		})
+	case errors.Is(err, packs.ErrNoMcmeta):
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "not_a_pack",
+			Message: err.Error(),
+		})
+	case err != nil:
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "read_error",
+			Message: err.Error(),
+		})
+	}
+	r.Path = path
+	return c.JSON(http.StatusOK, r)
+}
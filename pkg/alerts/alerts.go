@@ -0,0 +1,154 @@
+package alerts
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"pkg.bijsven.nl/MiniMC/pkg/metrics"
+)
+
+type State string
+
+const (
+	Firing   State = "firing"
+	Resolved State = "resolved"
+)
+
+// Rule is evaluated periodically. Check returns whether the condition is
+// unhealthy, the observed value and a human readable description.
+type Rule struct {
+	Name     string
+	Severity string
+	Labels   map[string]string
+	Check    func() (bool, float64, string)
+}
+
+type Alert struct {
+	Name     string            `json:"name"`
+	State    State             `json:"state"`
+	Labels   map[string]string `json:"labels"`
+	Value    float64           `json:"value"`
+	Message  string            `json:"message"`
+	Since    time.Time         `json:"since"`
+	Resolved *time.Time        `json:"resolved,omitempty"`
+}
+
+var (
+	mu        sync.Mutex
+	rules     []Rule
+	states    = map[string]*Alert{}
+	listeners []func(Alert)
+)
+
+func init() {
+	metrics.Register(metrics.Family{
+		Name: "minimc_alert_firing",
+		Help: "Whether an alert is currently firing (1) or resolved (0).",
+		Type: "gauge",
+		Collect: func() []metrics.Sample {
+			var samples []metrics.Sample
+			for _, a := range List() {
+				labels := map[string]string{"alertname": a.Name}
+				for k, v := range a.Labels {
+					labels[k] = v
+				}
+				value := 0.0
+				if a.State == Firing {
+					value = 1
+				}
+				samples = append(samples, metrics.Sample{Labels: labels, Value: value})
+			}
+			return samples
+		},
+	})
+}
+
+func Register(r Rule) {
+	mu.Lock()
+	rules = append(rules, r)
+	mu.Unlock()
+}
+
+// OnChange registers fn to be called whenever an alert starts firing or
+// resolves.
+func OnChange(fn func(Alert)) {
+	mu.Lock()
+	listeners = append(listeners, fn)
+	mu.Unlock()
+}
+
+// Run evaluates all rules every interval. It blocks, so start it in a
+// goroutine.
+func Run(interval time.Duration) {
+	for {
+		Evaluate()
+		time.Sleep(interval)
+	}
+}
+
+func Evaluate() {
+	mu.Lock()
+	list := make([]Rule, len(rules))
+	copy(list, rules)
+	mu.Unlock()
+
+	for _, r := range list {
+		firing, value, message := r.Check()
+		update(r, firing, value, message)
+	}
+}
+
+func update(r Rule, firing bool, value float64, message string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	now := time.Now()
+	a, ok := states[r.Name]
+	if !ok {
+		if !firing {
+			return
+		}
+		labels := map[string]string{"severity": r.Severity}
+		for k, v := range r.Labels {
+			labels[k] = v
+		}
+		a = &Alert{Name: r.Name, Labels: labels}
+		states[r.Name] = a
+	}
+
+	a.Value = value
+	a.Message = message
+	switch {
+	case firing && a.State != Firing:
+		a.State = Firing
+		a.Since = now
+		a.Resolved = nil
+		log.Printf("[!] alert %s firing: %s", r.Name, message)
+	case !firing && a.State == Firing:
+		a.State = Resolved
+		a.Resolved = &now
+		log.Printf("[i] alert %s resolved", r.Name)
+	default:
+		return
+	}
+
+	for _, fn := range listeners {
+		go fn(*a)
+	}
+}
+
+// List returns every alert that has fired since MiniMC started, including
+// the ones that have resolved since.
+func List() []Alert {
+	mu.Lock()
+	defer mu.Unlock()
+
+	list := make([]Alert, 0, len(states))
+	for _, a := range states {
+		list = append(list, *a)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
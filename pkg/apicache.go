@@ -0,0 +1,119 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// How long Paper API responses are used before asking again. Builds appear
+// often, a published build never changes.
+const (
+	versionsTTL  = time.Hour
+	buildsTTL    = 10 * time.Minute
+	buildInfoTTL = 24 * time.Hour
+)
+
+// apiCacheDir keeps Paper API responses on disk, so they survive restarts
+// and an outage of the API.
+const apiCacheDir = "cache"
+
+type cachedResponse struct {
+	URL     string          `json:"url"`
+	Fetched time.Time       `json:"fetched"`
+	Data    json.RawMessage `json:"data"`
+}
+
+var (
+	apiCacheMu sync.Mutex
+	apiCache   = map[string]*cachedResponse{}
+)
+
+func apiCachePath(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(apiCacheDir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// loadCached returns the response for url from memory, or from disk.
+func loadCached(url string) *cachedResponse {
+	apiCacheMu.Lock()
+	defer apiCacheMu.Unlock()
+	if r, ok := apiCache[url]; ok {
+		return r
+	}
+
+	data, err := os.ReadFile(apiCachePath(url))
+	if err != nil {
+		return nil
+	}
+	var r cachedResponse
+	if err := json.Unmarshal(data, &r); err != nil || r.URL != url {
+		return nil
+	}
+	apiCache[url] = &r
+	return &r
+}
+
+func storeCached(r *cachedResponse) {
+	apiCacheMu.Lock()
+	apiCache[r.URL] = r
+	apiCacheMu.Unlock()
+
+	data, err := json.Marshal(r)
+	if err == nil {
+		err = os.MkdirAll(apiCacheDir, 0755)
+	}
+	if err == nil {
+		path := apiCachePath(r.URL)
+		if err = os.WriteFile(path+".tmp", data, 0644); err == nil {
+			err = os.Rename(path+".tmp", path)
+		}
+	}
+	if err != nil {
+		log.Println("[w] could not cache API response:", err)
+	}
+}
+
+// getCachedJSON is getJSON with responses kept in memory and on disk for ttl.
+// When the API can't be reached an expired response is used instead; a 404
+// is never answered from the cache.
+func getCachedJSON(url string, ttl time.Duration, v interface{}) error {
+	cached := loadCached(url)
+	if cached != nil && time.Since(cached.Fetched) < ttl {
+		return json.Unmarshal(cached.Data, v)
+	}
+
+	data, err := fetchJSON(url)
+	if err == nil {
+		storeCached(&cachedResponse{URL: url, Fetched: time.Now(), Data: data})
+		return json.Unmarshal(data, v)
+	}
+	if cached == nil || errors.Is(err, errNotFound) {
+		return err
+	}
+	log.Printf("[w] %v, using the response cached %s ago\n", err, time.Since(cached.Fetched).Round(time.Second))
+	return json.Unmarshal(cached.Data, v)
+}
+
+func fetchJSON(url string) (json.RawMessage, error) {
+	resp, err := httpGet(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(data) {
+		return nil, errors.New("invalid JSON from " + url)
+	}
+	return data, nil
+}
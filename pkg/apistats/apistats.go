@@ -0,0 +1,201 @@
+package apistats
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"pkg.bijsven.nl/MiniMC/pkg/metrics"
+	"pkg.bijsven.nl/MiniMC/pkg/storage"
+)
+
+const (
+	path = "requests.log"
+
+	// Retention is how long records are kept, both in memory and on disk.
+	Retention = 24 * time.Hour
+)
+
+// Record is a single API request.
+type Record struct {
+	Time    time.Time `json:"time"`
+	Method  string    `json:"method"`
+	Route   string    `json:"route"`
+	User    string    `json:"user"`
+	Status  int       `json:"status"`
+	Latency float64   `json:"latency_ms"`
+}
+
+type Endpoint struct {
+	Method string  `json:"method"`
+	Route  string  `json:"route"`
+	Count  int     `json:"count"`
+	Errors int     `json:"errors"`
+	AvgMs  float64 `json:"avg_ms"`
+	MaxMs  float64 `json:"max_ms"`
+}
+
+type Summary struct {
+	Window            string     `json:"window"`
+	Requests          int        `json:"requests"`
+	RequestsPerMinute float64    `json:"requests_per_minute"`
+	ErrorRate         float64    `json:"error_rate"`
+	Unauthorized      int        `json:"unauthorized"`
+	Slowest           []Endpoint `json:"slowest"`
+}
+
+var (
+	mu      sync.Mutex
+	records []Record
+	opened  bool
+)
+
+// Open drops records older than Retention from requests.log, loads the rest
+// and registers the request metrics.
+func Open() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	cutoff := time.Now().Add(-Retention)
+	recent := func(data []byte) (Record, bool) {
+		var r Record
+		return r, json.Unmarshal(data, &r) == nil && r.Time.After(cutoff)
+	}
+
+	err := storage.Prune(path, func(data []byte) bool {
+		_, ok := recent(data)
+		return ok
+	})
+	if err != nil {
+		return err
+	}
+	err = storage.Scan(path, func(data []byte) error {
+		if r, ok := recent(data); ok {
+			records = append(records, r)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	opened = true
+	register()
+	return nil
+}
+
+// Add stores a request. Records older than Retention are dropped from memory;
+// the log is compacted on the next start.
+func Add(r Record) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	records = append(records, r)
+	cutoff := r.Time.Add(-Retention)
+	drop := 0
+	for drop < len(records) && records[drop].Time.Before(cutoff) {
+		drop++
+	}
+	if drop > 0 {
+		records = append(records[:0], records[drop:]...)
+	}
+
+	if !opened {
+		return
+	}
+	if err := storage.Append(path, r); err != nil {
+		log.Println("[e] apistats:", err)
+	}
+}
+
+// Summarize aggregates the records of the last window. At most limit of the
+// slowest endpoints, by average latency, are returned.
+func Summarize(window time.Duration, limit int) Summary {
+	mu.Lock()
+	defer mu.Unlock()
+
+	cutoff := time.Now().Add(-window)
+	summary := Summary{Window: window.String(), Slowest: []Endpoint{}}
+	endpoints := make(map[string]*Endpoint)
+	var failed int
+
+	for _, r := range records {
+		if r.Time.Before(cutoff) {
+			continue
+		}
+		summary.Requests++
+		if r.Status >= 500 {
+			failed++
+		}
+		if r.Status == 401 || r.Status == 403 {
+			summary.Unauthorized++
+		}
+
+		key := r.Method + " " + r.Route
+		e, ok := endpoints[key]
+		if !ok {
+			e = &Endpoint{Method: r.Method, Route: r.Route}
+			endpoints[key] = e
+		}
+		e.Count++
+		if r.Status >= 500 {
+			e.Errors++
+		}
+		e.AvgMs += r.Latency
+		if r.Latency > e.MaxMs {
+			e.MaxMs = r.Latency
+		}
+	}
+
+	if summary.Requests > 0 {
+		summary.RequestsPerMinute = float64(summary.Requests) / window.Minutes()
+		summary.ErrorRate = float64(failed) / float64(summary.Requests)
+	}
+
+	for _, e := range endpoints {
+		e.AvgMs /= float64(e.Count)
+		summary.Slowest = append(summary.Slowest, *e)
+	}
+	sort.Slice(summary.Slowest, func(a, b int) bool {
+		return summary.Slowest[a].AvgMs > summary.Slowest[b].AvgMs
+	})
+	if limit > 0 && len(summary.Slowest) > limit {
+		summary.Slowest = summary.Slowest[:limit]
+	}
+	return summary
+}
+
+func register() {
+	metrics.Register(metrics.Family{
+		Name: "minimc_api_requests_last_minute",
+		Help: "API requests handled in the last minute by route and status.",
+		Type: "gauge",
+		Collect: func() []metrics.Sample {
+			mu.Lock()
+			defer mu.Unlock()
+
+			cutoff := time.Now().Add(-time.Minute)
+			counts := make(map[[3]string]int)
+			for _, r := range records {
+				if r.Time.After(cutoff) {
+					counts[[3]string{r.Method, r.Route, strconv.Itoa(r.Status)}]++
+				}
+			}
+
+			samples := make([]metrics.Sample, 0, len(counts))
+			for k, n := range counts {
+				samples = append(samples, metrics.Sample{
+					Labels: map[string]string{"method": k[0], "route": k[1], "status": k[2]},
+					Value:  float64(n),
+				})
+			}
+			return samples
+		},
+	})
+	metrics.Gauge("minimc_api_error_rate", "Fraction of API requests in the last 5 minutes that failed with a 5xx status.", func() float64 {
+		return Summarize(5*time.Minute, 0).ErrorRate
+	})
+}
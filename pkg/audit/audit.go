@@ -0,0 +1,56 @@
+package audit
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"pkg.bijsven.nl/MiniMC/pkg/storage"
+)
+
+const path = "audit.log"
+
+type Entry struct {
+	Time   time.Time `json:"time"`
+	User   string    `json:"user"`
+	Action string    `json:"action"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// Record appends an entry to audit.log. Failures are logged but never block
+// the action being audited.
+func Record(user, action, detail string) {
+	entry := Entry{
+		Time:   time.Now(),
+		User:   user,
+		Action: action,
+		Detail: detail,
+	}
+
+	if err := storage.Append(path, entry); err != nil {
+		log.Println("[e] audit:", err)
+	}
+}
+
+// List returns the most recent entries, newest first.
+func List(limit int) ([]Entry, error) {
+	entries := []Entry{}
+	err := storage.Scan(path, func(data []byte) error {
+		var e Entry
+		if err := json.Unmarshal(data, &e); err == nil {
+			entries = append(entries, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
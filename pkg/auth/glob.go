@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"errors"
+	"path"
+	"strings"
+)
+
+// ValidateGlob checks a share path pattern like "logs/**" or "plugins/*.yml".
+func ValidateGlob(pattern string) error {
+	clean := path.Clean("/" + pattern)
+	if pattern == "" || clean == "/" {
+		return errors.New("empty path pattern")
+	}
+	for _, seg := range strings.Split(clean[1:], "/") {
+		if seg == "**" {
+			continue
+		}
+		if _, err := path.Match(seg, ""); err != nil {
+			return errors.New("invalid path pattern " + pattern)
+		}
+	}
+	return nil
+}
+
+// MatchGlob matches a slash separated path against a pattern in which "*"
+// matches within a single segment and "**" matches any number of segments.
+func MatchGlob(pattern, p string) bool {
+	return matchSegments(splitPath(pattern), splitPath(p))
+}
+
+// MatchGlobPrefix reports whether dir could contain paths matching pattern,
+// so it can be listed.
+func MatchGlobPrefix(pattern, dir string) bool {
+	pat, segs := splitPath(pattern), splitPath(dir)
+	for i, seg := range segs {
+		if i >= len(pat) {
+			return false
+		}
+		if pat[i] == "**" {
+			return true
+		}
+		if ok, _ := path.Match(pat[i], seg); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func splitPath(p string) []string {
+	clean := path.Clean("/" + p)
+	if clean == "/" {
+		return nil
+	}
+	return strings.Split(clean[1:], "/")
+}
+
+func matchSegments(pat, segs []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			for i := 0; i <= len(segs); i++ {
+				if matchSegments(pat[1:], segs[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segs) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], segs[0]); !ok {
+			return false
+		}
+		pat, segs = pat[1:], segs[1:]
+	}
+	return len(segs) == 0
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	maxFailures   = 5
+	failureWindow = 10 * time.Minute
+	lockoutPeriod = 15 * time.Minute
+
+	// pruneInterval is how often addresses whose failures and lockout have
+	// expired are forgotten.
+	pruneInterval = time.Minute
+)
+
+type attempts struct {
+	failures    []time.Time
+	lockedUntil time.Time
+}
+
+var (
+	lockMu     sync.Mutex
+	failures   = map[string]*attempts{}
+	lastPruned time.Time
+)
+
+// expired reports whether a holds nothing that still counts at now.
+func (a *attempts) expired(now time.Time) bool {
+	if now.Before(a.lockedUntil) {
+		return false
+	}
+	return len(a.failures) == 0 || now.Sub(a.failures[len(a.failures)-1]) >= failureWindow
+}
+
+// prune forgets the addresses that expired, so logins from many addresses
+// don't keep growing the map. It must be called with lockMu held.
+func prune(now time.Time) {
+	if now.Sub(lastPruned) < pruneInterval {
+		return
+	}
+	lastPruned = now
+	for addr, a := range failures {
+		if a.expired(now) {
+			delete(failures, addr)
+		}
+	}
+}
+
+// Locked reports how long logins from addr are still refused.
+func Locked(addr string) time.Duration {
+	lockMu.Lock()
+	defer lockMu.Unlock()
+
+	prune(time.Now())
+	a, ok := failures[addr]
+	if !ok {
+		return 0
+	}
+	if remaining := time.Until(a.lockedUntil); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// RecordFailure counts a failed login from addr and reports whether it caused
+// addr to be locked out.
+func RecordFailure(addr string) bool {
+	lockMu.Lock()
+	defer lockMu.Unlock()
+
+	now := time.Now()
+	prune(now)
+	a, ok := failures[addr]
+	if !ok {
+		a = &attempts{}
+		failures[addr] = a
+	}
+
+	recent := a.failures[:0]
+	for _, t := range a.failures {
+		if now.Sub(t) < failureWindow {
+			recent = append(recent, t)
+		}
+	}
+	a.failures = append(recent, now)
+
+	if len(a.failures) >= maxFailures {
+		a.failures = nil
+		a.lockedUntil = now.Add(lockoutPeriod)
+		return true
+	}
+	return false
+}
+
+// ClearFailures forgets the failed logins of addr after a successful login.
+func ClearFailures(addr string) {
+	lockMu.Lock()
+	delete(failures, addr)
+	lockMu.Unlock()
+}
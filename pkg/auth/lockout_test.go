@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPruneFailures(t *testing.T) {
+	defer func() {
+		lockMu.Lock()
+		failures = map[string]*attempts{}
+		lastPruned = time.Time{}
+		lockMu.Unlock()
+	}()
+
+	now := time.Now()
+	lockMu.Lock()
+	failures = map[string]*attempts{
+		"old":       {failures: []time.Time{now.Add(-failureWindow - time.Second)}},
+		"recent":    {failures: []time.Time{now.Add(-time.Minute)}},
+		"locked":    {lockedUntil: now.Add(time.Minute)},
+		"unlocked":  {lockedUntil: now.Add(-time.Second)},
+		"lockedOld": {failures: []time.Time{now.Add(-time.Hour)}, lockedUntil: now.Add(time.Minute)},
+	}
+	lastPruned = time.Time{}
+	prune(now)
+	got := len(failures)
+	_, recent := failures["recent"]
+	_, locked := failures["locked"]
+	_, lockedOld := failures["lockedOld"]
+	lockMu.Unlock()
+
+	if got != 3 || !recent || !locked || !lockedOld {
+		t.Errorf("after pruning %d addresses are left, want recent, locked and lockedOld", got)
+	}
+	if Locked("locked") <= 0 {
+		t.Error("pruning lifted a lockout")
+	}
+}
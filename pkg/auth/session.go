@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"os"
+	"sync"
+	"time"
+)
+
+const CookieName = "minimc_session"
+
+type Session struct {
+	Token    string    `json:"-"`
+	Username string    `json:"username"`
+	Created  time.Time `json:"created"`
+	Expires  time.Time `json:"expires"`
+}
+
+var (
+	mu       sync.Mutex
+	sessions = map[string]*Session{}
+)
+
+// CheckCredentials compares the given credentials against the username and
+// password environment variables.
+func CheckCredentials(username, password string) bool {
+	userOK := subtle.ConstantTimeCompare([]byte(username), []byte(os.Getenv("username"))) == 1
+	passOK := subtle.ConstantTimeCompare([]byte(password), []byte(os.Getenv("password"))) == 1
+	return userOK && passOK && username != ""
+}
+
+func NewSession(username string, ttl time.Duration) (*Session, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	s := &Session{
+		Token:    hex.EncodeToString(buf),
+		Username: username,
+		Created:  now,
+		Expires:  now.Add(ttl),
+	}
+
+	mu.Lock()
+	sessions[s.Token] = s
+	mu.Unlock()
+	return s, nil
+}
+
+// Lookup returns the session for token, or nil if it doesn't exist or has
+// expired.
+func Lookup(token string) *Session {
+	mu.Lock()
+	defer mu.Unlock()
+
+	s, ok := sessions[token]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(s.Expires) {
+		delete(sessions, token)
+		return nil
+	}
+	return s
+}
+
+func Revoke(token string) {
+	mu.Lock()
+	delete(sessions, token)
+	mu.Unlock()
+}
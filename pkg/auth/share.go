@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"time"
+)
+
+// Share is a short-lived, read-only token granting anonymous access to a
+// single scope such as "logs". Shares of the "files" scope are limited to the
+// files matching Paths.
+type Share struct {
+	Token     string    `json:"token"`
+	Scope     string    `json:"scope"`
+	Paths     []string  `json:"paths,omitempty"`
+	CreatedBy string    `json:"created_by"`
+	Created   time.Time `json:"created"`
+	Expires   time.Time `json:"expires"`
+}
+
+var shares = map[string]*Share{}
+
+func NewShare(scope, createdBy string, ttl time.Duration) (*Share, error) {
+	return newShare(scope, createdBy, nil, ttl)
+}
+
+// NewFileShare creates a "files" share for the files matching the given
+// patterns, see MatchGlob.
+func NewFileShare(createdBy string, paths []string, ttl time.Duration) (*Share, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("at least one path pattern is required")
+	}
+	for _, p := range paths {
+		if err := ValidateGlob(p); err != nil {
+			return nil, err
+		}
+	}
+	return newShare("files", createdBy, paths, ttl)
+}
+
+func newShare(scope, createdBy string, paths []string, ttl time.Duration) (*Share, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	s := &Share{
+		Token:     hex.EncodeToString(buf),
+		Scope:     scope,
+		Paths:     paths,
+		CreatedBy: createdBy,
+		Created:   now,
+		Expires:   now.Add(ttl),
+	}
+
+	mu.Lock()
+	shares[s.Token] = s
+	mu.Unlock()
+	return s, nil
+}
+
+// LookupShare returns the share for token if it is still valid for scope.
+func LookupShare(token, scope string) *Share {
+	mu.Lock()
+	defer mu.Unlock()
+
+	s, ok := shares[token]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(s.Expires) {
+		delete(shares, token)
+		return nil
+	}
+	if s.Scope != scope {
+		return nil
+	}
+	return s
+}
+
+// AllowsFile reports whether the share grants access to file p.
+func (s *Share) AllowsFile(p string) bool {
+	for _, pattern := range s.Paths {
+		if MatchGlob(pattern, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsDir reports whether directory p may be listed, because it leads to
+// files the share grants access to.
+func (s *Share) AllowsDir(p string) bool {
+	for _, pattern := range s.Paths {
+		if MatchGlobPrefix(pattern, p) || MatchGlob(pattern, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func ListShares() []Share {
+	mu.Lock()
+	defer mu.Unlock()
+
+	now := time.Now()
+	list := make([]Share, 0, len(shares))
+	for token, s := range shares {
+		if now.After(s.Expires) {
+			delete(shares, token)
+			continue
+		}
+		list = append(list, *s)
+	}
+	sort.Slice(list, func(a, b int) bool {
+		return list[a].Created.Before(list[b].Created)
+	})
+	return list
+}
+
+func RevokeShare(token string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	_, ok := shares[token]
+	delete(shares, token)
+	return ok
+}
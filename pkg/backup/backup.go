@@ -0,0 +1,393 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"pkg.bijsven.nl/MiniMC/pkg/jobs"
+	"pkg.bijsven.nl/MiniMC/pkg/storage"
+)
+
+const (
+	Dir   = "backups"
+	mcDir = "minecraft"
+)
+
+var (
+	ErrNotFound       = errors.New("backup not found")
+	ErrInvalidPath    = errors.New("invalid path")
+	ErrUnknownProfile = errors.New("unknown backup profile")
+)
+
+type Backup struct {
+	ID      string    `json:"id"`
+	Profile string    `json:"profile,omitempty"`
+	Paths   []string  `json:"paths"`
+	Created time.Time `json:"created"`
+	Size    int64     `json:"size"`
+	Files   int       `json:"files"`
+	SHA256  string    `json:"sha256,omitempty"`
+
+	Verified *time.Time `json:"verified,omitempty"`
+	Corrupt  bool       `json:"corrupt,omitempty"`
+}
+
+var indexMu sync.Mutex
+
+func indexPath() string {
+	return filepath.Join(Dir, "index.json")
+}
+
+func ArchivePath(id string) string {
+	return filepath.Join(Dir, id+".tar.gz")
+}
+
+func loadIndex() ([]Backup, error) {
+	var list []Backup
+	err := storage.Get(indexPath(), &list)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup index: %w", err)
+	}
+	return list, nil
+}
+
+func saveIndex(list []Backup) error {
+	return storage.Put(indexPath(), list)
+}
+
+func List() ([]Backup, error) {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+
+	list, err := loadIndex()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(a, b int) bool {
+		return list[a].Created.After(list[b].Created)
+	})
+	return list, nil
+}
+
+func Get(id string) (*Backup, error) {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+
+	list, err := loadIndex()
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range list {
+		if b.ID == id {
+			return &b, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func Delete(id string) error {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+
+	list, err := loadIndex()
+	if err != nil {
+		return err
+	}
+	for i, b := range list {
+		if b.ID == id {
+			if err := os.Remove(ArchivePath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			os.Remove(sumsPath(id))
+			return saveIndex(append(list[:i], list[i+1:]...))
+		}
+	}
+	return ErrNotFound
+}
+
+// cleanPaths validates paths relative to the minecraft directory. An empty
+// list means the whole directory.
+func cleanPaths(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return []string{"."}, nil
+	}
+
+	cleaned := make([]string, 0, len(paths))
+	for _, p := range paths {
+		p = filepath.Clean(strings.TrimPrefix(strings.TrimSpace(p), "/"))
+		if p == ".." || strings.HasPrefix(p, "../") || filepath.IsAbs(p) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPath, p)
+		}
+		cleaned = append(cleaned, p)
+	}
+	return cleaned, nil
+}
+
+func newID() string {
+	buf := make([]byte, 4)
+	rand.Read(buf)
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(buf)
+}
+
+// Create archives paths into a new backup. Paths that don't exist are skipped,
+// so a profile listing world_nether still works on servers without a nether.
+func Create(profile string, paths []string, job *jobs.Job) (*Backup, error) {
+	paths, err := cleanPaths(paths)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	b := &Backup{
+		ID:      newID(),
+		Profile: profile,
+		Paths:   paths,
+		Created: time.Now(),
+	}
+
+	total := int64(0)
+	for _, p := range paths {
+		total += dirSize(filepath.Join(mcDir, p))
+	}
+
+	partPath := ArchivePath(b.ID) + ".part"
+	out, err := os.Create(partPath)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(partPath)
+
+	archiveHash := sha256.New()
+	sums := map[string]string{}
+	gzw := gzip.NewWriter(io.MultiWriter(out, archiveHash))
+	tw := tar.NewWriter(gzw)
+
+	var done int64
+	for _, p := range paths {
+		root := filepath.Join(mcDir, p)
+		if _, err := os.Lstat(root); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.Name() == "session.lock" {
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() && !info.IsDir() {
+				return nil
+			}
+
+			rel, err := filepath.Rel(mcDir, path)
+			if err != nil || rel == "." {
+				return err
+			}
+
+			header, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			header.Name = filepath.ToSlash(rel)
+			if info.IsDir() {
+				header.Name += "/"
+			}
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			fileHash := sha256.New()
+			n, err := io.Copy(io.MultiWriter(tw, fileHash), f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+			sums[header.Name] = hex.EncodeToString(fileHash.Sum(nil))
+
+			done += n
+			b.Files++
+			job.Update(header.Name, done, total)
+			return nil
+		})
+		if err != nil {
+			out.Close()
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		out.Close()
+		return nil, err
+	}
+	if err := gzw.Close(); err != nil {
+		out.Close()
+		return nil, err
+	}
+	if err := out.Close(); err != nil {
+		return nil, err
+	}
+	b.SHA256 = hex.EncodeToString(archiveHash.Sum(nil))
+	if err := writeSums(b.ID, sums); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(partPath, ArchivePath(b.ID)); err != nil {
+		return nil, err
+	}
+
+	if info, err := os.Stat(ArchivePath(b.ID)); err == nil {
+		b.Size = info.Size()
+	}
+
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	list, err := loadIndex()
+	if err != nil {
+		return nil, err
+	}
+	if err := saveIndex(append(list, *b)); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func dirSize(root string) int64 {
+	var size int64
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// Restore extracts the entries of a backup that fall under paths back into the
+// minecraft directory, leaving everything else untouched. An empty paths list
+// restores the whole archive. Existing directories being restored are
+// replaced, so files created after the backup don't linger.
+func Restore(id string, paths []string, job *jobs.Job) ([]string, error) {
+	b, err := Get(id)
+	if err != nil {
+		return nil, err
+	}
+	paths, err = cleanPaths(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range paths {
+		if !matches(p, b.Paths) {
+			return nil, fmt.Errorf("%w: %s is not part of backup %s", ErrInvalidPath, p, b.ID)
+		}
+	}
+
+	f, err := os.Open(ArchivePath(b.ID))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gzr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gzr.Close()
+
+	for _, p := range paths {
+		if p == "." {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(mcDir, p)); err != nil {
+			return nil, err
+		}
+	}
+
+	var restored []string
+	var done int64
+	tr := tar.NewReader(gzr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return restored, err
+		}
+
+		name := strings.TrimSuffix(header.Name, "/")
+		if !matches(name, paths) {
+			continue
+		}
+
+		target := filepath.Join(mcDir, filepath.FromSlash(name))
+		if !strings.HasPrefix(target, filepath.Clean(mcDir)+string(os.PathSeparator)) {
+			return restored, fmt.Errorf("%w: %s", ErrInvalidPath, header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return restored, err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return restored, err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode).Perm())
+			if err != nil {
+				return restored, err
+			}
+			n, err := io.Copy(out, tr)
+			out.Close()
+			if err != nil {
+				return restored, err
+			}
+			done += n
+			restored = append(restored, name)
+			job.Update(name, done, 0)
+		}
+	}
+	return restored, nil
+}
+
+func matches(name string, paths []string) bool {
+	for _, p := range paths {
+		if p == "." || name == p || strings.HasPrefix(name, p+"/") {
+			return true
+		}
+	}
+	return false
+}
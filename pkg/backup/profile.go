@@ -0,0 +1,77 @@
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Profile is a named selection of paths to back up, e.g. only the worlds.
+type Profile struct {
+	Name  string   `json:"name"`
+	Paths []string `json:"paths"`
+}
+
+var defaultProfiles = []Profile{
+	{Name: "full", Paths: []string{"."}},
+	{Name: "worlds", Paths: []string{"world", "world_nether", "world_the_end"}},
+	{Name: "plugins", Paths: []string{"plugins"}},
+}
+
+func profilesPath() string {
+	return filepath.Join(Dir, "profiles.json")
+}
+
+// Profiles returns the configured backup profiles, falling back to the
+// built-in ones when none have been saved yet.
+func Profiles() ([]Profile, error) {
+	data, err := os.ReadFile(profilesPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return defaultProfiles, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles []Profile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+func SaveProfiles(profiles []Profile) error {
+	for i, p := range profiles {
+		if p.Name == "" {
+			return errors.New("profile name is required")
+		}
+		paths, err := cleanPaths(p.Paths)
+		if err != nil {
+			return err
+		}
+		profiles[i].Paths = paths
+	}
+
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(profiles, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(profilesPath(), data, 0644)
+}
+
+func FindProfile(name string) (*Profile, error) {
+	profiles, err := Profiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range profiles {
+		if p.Name == name {
+			return &p, nil
+		}
+	}
+	return nil, ErrUnknownProfile
+}
@@ -0,0 +1,191 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type WorldCheck struct {
+	Name        string `json:"name"`
+	LevelDat    bool   `json:"level_dat"`
+	RegionFiles int    `json:"region_files"`
+}
+
+type VerifyResult struct {
+	ID           string       `json:"id"`
+	OK           bool         `json:"ok"`
+	ArchiveOK    bool         `json:"archive_ok"`
+	FilesChecked int          `json:"files_checked"`
+	Mismatched   []string     `json:"mismatched,omitempty"`
+	Missing      []string     `json:"missing,omitempty"`
+	Worlds       []WorldCheck `json:"worlds,omitempty"`
+	Problems     []string     `json:"problems,omitempty"`
+}
+
+func sumsPath(id string) string {
+	return filepath.Join(Dir, id+".sha256.json")
+}
+
+func writeSums(id string, sums map[string]string) error {
+	data, err := json.MarshalIndent(sums, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(sumsPath(id), data, 0644)
+}
+
+func readSums(id string) (map[string]string, error) {
+	data, err := os.ReadFile(sumsPath(id))
+	if err != nil {
+		return nil, err
+	}
+	var sums map[string]string
+	if err := json.Unmarshal(data, &sums); err != nil {
+		return nil, err
+	}
+	return sums, nil
+}
+
+// Verify re-reads a backup archive, compares it with the checksums recorded
+// when it was created and checks that every world in it has a level.dat and
+// region files. The outcome is stored in the backup index.
+func Verify(id string) (*VerifyResult, error) {
+	b, err := Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &VerifyResult{ID: id}
+	result.scan(b)
+	result.OK = result.ArchiveOK && len(result.Mismatched) == 0 &&
+		len(result.Missing) == 0 && len(result.Problems) == 0
+
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	list, err := loadIndex()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i := range list {
+		if list[i].ID == id {
+			list[i].Verified = &now
+			list[i].Corrupt = !result.OK
+		}
+	}
+	if err := saveIndex(list); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *VerifyResult) problem(format string, args ...interface{}) {
+	r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+}
+
+func (r *VerifyResult) scan(b *Backup) {
+	sums, err := readSums(b.ID)
+	if err != nil {
+		r.problem("no checksums recorded: %v", err)
+	}
+
+	f, err := os.Open(ArchivePath(b.ID))
+	if err != nil {
+		r.problem("cannot open archive: %v", err)
+		return
+	}
+	defer f.Close()
+
+	archiveHash := sha256.New()
+	gzr, err := gzip.NewReader(io.TeeReader(f, archiveHash))
+	if err != nil {
+		r.problem("archive is not a valid gzip file: %v", err)
+		return
+	}
+	defer gzr.Close()
+
+	seen := map[string]bool{}
+	worlds := map[string]*WorldCheck{}
+	world := func(name string) *WorldCheck {
+		if worlds[name] == nil {
+			worlds[name] = &WorldCheck{Name: name}
+		}
+		return worlds[name]
+	}
+
+	tr := tar.NewReader(gzr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			r.problem("archive is truncated or corrupt: %v", err)
+			return
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		h := sha256.New()
+		if _, err := io.Copy(h, tr); err != nil {
+			r.problem("cannot read %s: %v", header.Name, err)
+			return
+		}
+		r.FilesChecked++
+		seen[header.Name] = true
+		if want, ok := sums[header.Name]; ok && want != hex.EncodeToString(h.Sum(nil)) {
+			r.Mismatched = append(r.Mismatched, header.Name)
+		}
+
+		parts := strings.Split(header.Name, "/")
+		switch {
+		case len(parts) == 2 && parts[1] == "level.dat":
+			world(parts[0]).LevelDat = true
+		case len(parts) >= 3 && parts[len(parts)-2] == "region" && strings.HasSuffix(header.Name, ".mca"):
+			world(parts[0]).RegionFiles++
+		}
+	}
+
+	// Drain the remainder of the gzip stream so the archive hash covers the
+	// whole file.
+	io.Copy(archiveHash, f)
+	if b.SHA256 == "" {
+		r.problem("no archive checksum recorded")
+	} else {
+		r.ArchiveOK = hex.EncodeToString(archiveHash.Sum(nil)) == b.SHA256
+	}
+
+	for name := range sums {
+		if !seen[name] {
+			r.Missing = append(r.Missing, name)
+		}
+	}
+	sort.Strings(r.Missing)
+
+	names := make([]string, 0, len(worlds))
+	for name := range worlds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w := worlds[name]
+		if !w.LevelDat {
+			r.problem("world %s has no level.dat", name)
+		}
+		if w.RegionFiles == 0 {
+			r.problem("world %s has no region files", name)
+		}
+		r.Worlds = append(r.Worlds, *w)
+	}
+}
@@ -0,0 +1,65 @@
+package pkg
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+var memPaths = []struct{ usage, limit string }{
+	{"/sys/fs/cgroup/memory.current", "/sys/fs/cgroup/memory.max"},
+	{"/sys/fs/cgroup/memory/memory.usage_in_bytes", "/sys/fs/cgroup/memory/memory.limit_in_bytes"},
+}
+
+// unlimitedV1 is roughly what cgroup v1 reports as the limit of a container
+// without one.
+const unlimitedV1 = 1 << 62
+
+// CgroupMemory returns the memory usage and limit of the container in bytes,
+// checking cgroup v2 first and falling back to v1. A limit of 0 means
+// unlimited or unknown.
+func CgroupMemory() (used, limit uint64) {
+	for _, p := range memPaths {
+		if data, err := os.ReadFile(p.usage); err == nil {
+			if u, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64); err == nil {
+				used = u
+			}
+		}
+		if data, err := os.ReadFile(p.limit); err == nil {
+			text := strings.TrimSpace(string(data))
+			if text == "max" {
+				limit = 0
+			} else if l, err := strconv.ParseUint(text, 10, 64); err == nil && l < unlimitedV1 {
+				limit = l
+			}
+		}
+		if used != 0 && limit != 0 {
+			break
+		}
+	}
+	return used, limit
+}
+
+var oomPaths = []string{
+	"/sys/fs/cgroup/memory.events",
+	"/sys/fs/cgroup/memory/memory.oom_control",
+}
+
+// CgroupOOMKills returns how often the kernel OOM killer fired inside the
+// container. ok is false when no counter is readable.
+func CgroupOOMKills() (kills uint64, ok bool) {
+	for _, p := range oomPaths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if n, found := strings.CutPrefix(line, "oom_kill "); found {
+				if v, err := strconv.ParseUint(strings.TrimSpace(n), 10, 64); err == nil {
+					return v, true
+				}
+			}
+		}
+	}
+	return 0, false
+}
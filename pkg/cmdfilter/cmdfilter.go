@@ -0,0 +1,169 @@
+package cmdfilter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+const path = "console-rules.json"
+
+// Rule restricts the console commands of a role. In "block" mode the listed
+// commands are refused, in "allow" mode everything else is.
+type Rule struct {
+	Mode     string   `json:"mode"`
+	Commands []string `json:"commands"`
+}
+
+// Rules maps users to roles and roles to rules. Users without a role get
+// DefaultRole; roles without a rule are unrestricted.
+type Rules struct {
+	DefaultRole string            `json:"default_role"`
+	Users       map[string]string `json:"users"`
+	Roles       map[string]Rule   `json:"roles"`
+}
+
+var (
+	mu sync.Mutex
+
+	ErrBlocked = errors.New("command not allowed for your role")
+)
+
+func defaults() Rules {
+	return Rules{
+		DefaultRole: "admin",
+		Users:       map[string]string{},
+		Roles: map[string]Rule{
+			"operator": {Mode: "block", Commands: []string{"stop", "op", "deop", "whitelist off"}},
+		},
+	}
+}
+
+func Load() (Rules, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	return load()
+}
+
+func load() (Rules, error) {
+	rules := defaults()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return rules, nil
+	}
+	if err != nil {
+		return rules, err
+	}
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return rules, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	if rules.Users == nil {
+		rules.Users = map[string]string{}
+	}
+	if rules.Roles == nil {
+		rules.Roles = map[string]Rule{}
+	}
+	return rules, nil
+}
+
+func (r Rules) Validate() error {
+	if r.DefaultRole == "" {
+		return errors.New("default_role is required")
+	}
+	for role, rule := range r.Roles {
+		if rule.Mode != "block" && rule.Mode != "allow" {
+			return fmt.Errorf("role %s: mode must be block or allow", role)
+		}
+		for _, cmd := range rule.Commands {
+			if normalize(cmd) == "" {
+				return fmt.Errorf("role %s: empty command", role)
+			}
+		}
+	}
+	return nil
+}
+
+func Save(r Rules) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Role returns the role of user.
+func (r Rules) Role(user string) string {
+	if role, ok := r.Users[user]; ok {
+		return role
+	}
+	return r.DefaultRole
+}
+
+// Unrestricted reports whether user's role has no rule.
+func (r Rules) Unrestricted(user string) bool {
+	_, ok := r.Roles[r.Role(user)]
+	return !ok
+}
+
+// normalize lowercases cmd, collapses whitespace and strips a leading slash
+// and namespace, so "/minecraft:stop" and "stop" are the same command.
+func normalize(cmd string) string {
+	fields := strings.Fields(strings.ToLower(cmd))
+	if len(fields) == 0 {
+		return ""
+	}
+	fields[0] = strings.TrimPrefix(fields[0], "/")
+	if i := strings.LastIndex(fields[0], ":"); i >= 0 {
+		fields[0] = fields[0][i+1:]
+	}
+	return strings.Join(fields, " ")
+}
+
+// matches reports whether cmd is pattern or starts with it followed by more
+// arguments.
+func matches(cmd, pattern string) bool {
+	pattern = normalize(pattern)
+	return cmd == pattern || strings.HasPrefix(cmd, pattern+" ")
+}
+
+// Check returns ErrBlocked when user's role may not run cmd.
+func Check(user, cmd string) error {
+	rules, err := Load()
+	if err != nil {
+		// Fail closed, a broken rule file shouldn't open up the console.
+		return err
+	}
+
+	rule, ok := rules.Roles[rules.Role(user)]
+	if !ok {
+		return nil
+	}
+
+	cmd = normalize(cmd)
+	listed := false
+	for _, p := range rule.Commands {
+		if matches(cmd, p) {
+			listed = true
+			break
+		}
+	}
+
+	if listed == (rule.Mode == "block") {
+		return ErrBlocked
+	}
+	return nil
+}
@@ -0,0 +1,389 @@
+package configgit
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+)
+
+// GitDir holds the repository. It lives outside the server directory so the
+// file browser and the server itself never see it.
+const GitDir = "config-history"
+
+// DefaultPatterns are tracked when CONFIG_GIT_PATHS isn't set.
+var DefaultPatterns = []string{
+	"server.properties",
+	"bukkit.yml",
+	"spigot.yml",
+	"commands.yml",
+	"config/*.yml",
+	"plugins/*/config.yml",
+}
+
+var (
+	ErrDisabled = errors.New("config tracking is disabled")
+	ErrNotFound = errors.New("commit not found")
+	ErrConflict = errors.New("file changed since this commit")
+)
+
+type Commit struct {
+	Hash    string    `json:"hash"`
+	Author  string    `json:"author"`
+	Date    time.Time `json:"date"`
+	Message string    `json:"message"`
+	Files   []string  `json:"files"`
+}
+
+var (
+	mu       sync.Mutex
+	repo     *git.Repository
+	root     string
+	patterns []string
+)
+
+// Open opens or creates the repository tracking patterns, relative to dir,
+// and commits whatever changed while MiniMC wasn't running.
+func Open(dir string, tracked []string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	storage := filesystem.NewStorage(osfs.New(GitDir), cache.NewObjectLRUDefault())
+	worktree := osfs.New(dir)
+
+	r, err := git.Open(storage, worktree)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		r, err = git.Init(storage, worktree)
+	}
+	if err != nil {
+		return err
+	}
+
+	repo, root, patterns = r, dir, tracked
+	return commit("MiniMC", "Record changes made outside the panel", matching())
+}
+
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return repo != nil
+}
+
+// matching returns all files on disk matching the tracked patterns.
+func matching() []string {
+	var files []string
+	for _, p := range patterns {
+		found, _ := filepath.Glob(filepath.Join(root, p))
+		for _, f := range found {
+			if info, err := os.Stat(f); err == nil && info.Mode().IsRegular() {
+				rel, _ := filepath.Rel(root, f)
+				files = append(files, filepath.ToSlash(rel))
+			}
+		}
+	}
+	return files
+}
+
+// Record commits the tracked files at or below paths, relative to the server
+// directory, with user as author. Untracked paths are ignored, and nothing is
+// committed when none of the tracked files changed.
+func Record(user, message string, paths ...string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if repo == nil {
+		return nil
+	}
+
+	var files []string
+	under := func(f string) bool {
+		for _, p := range paths {
+			p = strings.Trim(filepath.ToSlash(filepath.Clean(p)), "/")
+			if p == "" || p == "." || f == p || strings.HasPrefix(f, p+"/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, f := range matching() {
+		if under(f) {
+			files = append(files, f)
+		}
+	}
+
+	// Deleted or moved away files are only left in the index.
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return err
+	}
+	for _, e := range idx.Entries {
+		if under(e.Name) {
+			if _, err := os.Stat(filepath.Join(root, e.Name)); errors.Is(err, os.ErrNotExist) {
+				files = append(files, e.Name)
+			}
+		}
+	}
+
+	return commit(user, message, files)
+}
+
+func commit(user, message string, files []string) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return err
+	}
+	removed := false
+	for _, f := range files {
+		if _, err := os.Stat(filepath.Join(root, f)); errors.Is(err, os.ErrNotExist) {
+			// Adding a missing file makes go-git scan the whole worktree,
+			// worlds included, so drop it from the index directly.
+			if _, err := idx.Remove(f); err == nil {
+				removed = true
+			}
+		}
+	}
+	if removed {
+		if err := repo.Storer.SetIndex(idx); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range files {
+		if _, err := os.Stat(filepath.Join(root, f)); err != nil {
+			continue
+		}
+		if err := wt.AddWithOptions(&git.AddOptions{Path: f, SkipStatus: true}); err != nil {
+			return err
+		}
+	}
+
+	_, err = wt.Commit(message, &git.CommitOptions{
+		Author: &object.Signature{
+			Name:  user,
+			Email: user + "@minimc",
+			When:  time.Now(),
+		},
+	})
+	if errors.Is(err, git.ErrEmptyCommit) {
+		return nil
+	}
+	return err
+}
+
+// History returns the most recent commits, newest first, optionally limited
+// to those touching path.
+func History(path string, limit int) ([]Commit, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if repo == nil {
+		return nil, ErrDisabled
+	}
+
+	opts := &git.LogOptions{}
+	if path != "" {
+		p := strings.Trim(filepath.ToSlash(filepath.Clean(path)), "/")
+		opts.FileName = &p
+	}
+
+	iter, err := repo.Log(opts)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return []Commit{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	commits := []Commit{}
+	for limit <= 0 || len(commits) < limit {
+		c, err := iter.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		entry, err := describe(c)
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, entry)
+	}
+	return commits, nil
+}
+
+func describe(c *object.Commit) (Commit, error) {
+	stats, err := c.Stats()
+	if err != nil {
+		return Commit{}, err
+	}
+	files := make([]string, len(stats))
+	for i, s := range stats {
+		files[i] = s.Name
+	}
+	return Commit{
+		Hash:    c.Hash.String(),
+		Author:  c.Author.Name,
+		Date:    c.Author.When,
+		Message: strings.TrimSpace(c.Message),
+		Files:   files,
+	}, nil
+}
+
+func lookup(hash string) (*object.Commit, error) {
+	if !plumbing.IsHash(hash) {
+		return nil, ErrNotFound
+	}
+	c, err := repo.CommitObject(plumbing.NewHash(hash))
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		return nil, ErrNotFound
+	}
+	return c, err
+}
+
+// Diff returns the commit and its changes as a unified diff.
+func Diff(hash string) (*Commit, string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if repo == nil {
+		return nil, "", ErrDisabled
+	}
+
+	c, err := lookup(hash)
+	if err != nil {
+		return nil, "", err
+	}
+	entry, err := describe(c)
+	if err != nil {
+		return nil, "", err
+	}
+
+	to, err := c.Tree()
+	if err != nil {
+		return nil, "", err
+	}
+	var from *object.Tree
+	if parent, err := c.Parent(0); err == nil {
+		if from, err = parent.Tree(); err != nil {
+			return nil, "", err
+		}
+	}
+
+	changes, err := object.DiffTree(from, to)
+	if err != nil {
+		return nil, "", err
+	}
+	patch, err := changes.Patch()
+	if err != nil {
+		return nil, "", err
+	}
+	return &entry, patch.String(), nil
+}
+
+// Revert undoes the changes of a commit and records that as a new commit by
+// user. Files changed again since are left alone and reported as a conflict.
+func Revert(hash, user string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if repo == nil {
+		return ErrDisabled
+	}
+
+	c, err := lookup(hash)
+	if err != nil {
+		return err
+	}
+	parent, err := c.Parent(0)
+	if err != nil {
+		return errors.New("the first commit can't be reverted")
+	}
+
+	stats, err := c.Stats()
+	if err != nil {
+		return err
+	}
+
+	type restore struct {
+		path    string
+		content []byte
+		remove  bool
+	}
+	var restores []restore
+
+	for _, s := range stats {
+		current, readErr := os.ReadFile(filepath.Join(root, s.Name))
+		after, err := fileContent(c, s.Name)
+		if err != nil {
+			return err
+		}
+		if (after == nil) != errors.Is(readErr, os.ErrNotExist) || (after != nil && string(current) != string(after)) {
+			return ErrConflict
+		}
+
+		before, err := fileContent(parent, s.Name)
+		if err != nil {
+			return err
+		}
+		restores = append(restores, restore{path: s.Name, content: before, remove: before == nil})
+	}
+
+	files := make([]string, len(restores))
+	for i, r := range restores {
+		full := filepath.Join(root, r.path)
+		if r.remove {
+			if err := os.Remove(full); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		} else {
+			if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+				return err
+			}
+			if err := os.WriteFile(full, r.content, 0644); err != nil {
+				return err
+			}
+		}
+		files[i] = r.path
+	}
+
+	return commit(user, "Revert \""+strings.TrimSpace(c.Message)+"\"\n\nThis reverts commit "+c.Hash.String()+".", files)
+}
+
+// fileContent returns the content of path in c, or nil if it doesn't exist
+// there.
+func fileContent(c *object.Commit, path string) ([]byte, error) {
+	f, err := c.File(path)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	content, err := f.Contents()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(content), nil
+}
@@ -1,18 +1,23 @@
 package pkg
 
 import (
-	"encoding/json"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
+
+	"pkg.bijsven.nl/MiniMC/pkg/jobs"
 )
 
 const (
-	baseURL = "https://api.papermc.io/v2"
 	mcDir   = "minecraft"
 	jarName = "server.jar"
 )
@@ -31,7 +36,8 @@ type BuildsResponse struct {
 }
 
 type DownloadInfo struct {
-	Name string `json:"name"`
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
 }
 
 type BuildResponse struct {
@@ -40,185 +46,440 @@ type BuildResponse struct {
 	} `json:"downloads"`
 }
 
-func GetPaper(version string) error {
+var (
+	// ErrUpToDate is returned when the requested build is already installed.
+	ErrUpToDate = errors.New("already up to date")
+	// ErrUpdateRejected is returned when installing the build could break
+	// the server and needs another step first, like a migration or an
+	// acknowledged upgrade.
+	ErrUpdateRejected = errors.New("update rejected")
+)
+
+// GetJar installs the latest build of version for flavor, or of the latest
+// version with "no_version", unless a build is pinned with MC_BUILD or in
+// the manifest. A server of another flavor is left alone, those are
+// converted with Migrate.
+func GetJar(flavor, version string, job *jobs.Job) error {
+	build, err := PinnedBuild(version)
+	if err != nil {
+		return err
+	}
+	return logRejected(getJar(flavor, version, build, false, job))
+}
+
+// Update installs build of version for flavor, or the pinned or latest build
+// when build is 0. Unlike GetJar it reports a build that is already
+// installed or can't be installed as ErrUpToDate or ErrUpdateRejected.
+func Update(flavor, version string, build int, job *jobs.Job) error {
+	if build == 0 {
+		pinned, err := PinnedBuild(version)
+		if err != nil {
+			return err
+		}
+		build = pinned
+	}
+	return getJar(flavor, version, build, false, job)
+}
+
+// logRejected logs an update that was skipped instead of failing with it, a
+// server that can't be updated still starts on its current jar.
+func logRejected(err error) error {
+	switch {
+	case errors.Is(err, ErrUpToDate):
+		log.Println("[i] requested function rejected:", err)
+		return nil
+	case errors.Is(err, ErrUpdateRejected):
+		log.Println("[!]", err)
+		return nil
+	}
+	return err
+}
+
+func getJar(flavor, version string, build int, migrate bool, job *jobs.Job) error {
+	provider, err := GetProvider(flavor)
+	if err != nil {
+		return err
+	}
+
 	var manual = true
 	if version == "no_version" {
 		manual = false
 	}
+	if build != 0 && !manual {
+		return fmt.Errorf("%w: build %d can only be pinned together with a version, set MC_VERSION", ErrNoBuilds, build)
+	}
 
 	log.Println("[i] mkdir /minecraft")
 	if err := os.MkdirAll(mcDir, 0755); err != nil {
 		return err
 	}
 
+	// An explicitly requested build is installed even if it is older,
+	// experimental or failed before, that's the operator's call.
+	pinned := build != 0
+	channel := Channel()
+	if pinned {
+		channel = "experimental"
+	}
+
+	var builds []int
 	if !manual {
 		log.Println("[i] get latest version")
-		resp, err := http.Get(baseURL + "/projects/paper")
+		versions, err := provider.Versions()
 		if err != nil {
 			return err
 		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != 200 {
-			return errors.New("bad status: " + resp.Status)
+		if len(versions) == 0 {
+			return fmt.Errorf("%w: %s has no versions", ErrVersionNotFound, flavor)
 		}
+		// A new version may only have experimental builds yet, fall back
+		// to the newest one with a build in the channel.
+		for i := len(versions) - 1; i >= 0 && len(builds) == 0; i-- {
+			version = versions[i]
+			if builds, err = channelBuilds(provider, version, channel); err != nil {
+				return err
+			}
+		}
+	}
 
-		var project ProjectResponse
-		if err := json.NewDecoder(resp.Body).Decode(&project); err != nil {
+	log.Println("[i] using", flavor, "version", version)
+	if pinned {
+		log.Println("[i] using pinned build", build)
+	} else {
+		log.Println("[i] get latest", channel, "build")
+	}
+
+	if manual {
+		if builds, err = channelBuilds(provider, version, channel); err != nil {
 			return err
 		}
-
-		if len(project.Versions) == 0 {
-			return errors.New("no versions found")
+	}
+	if len(builds) == 0 {
+		if channel != "experimental" {
+			return fmt.Errorf("%w for %s %s in the %s channel, set MC_CHANNEL=experimental to install experimental builds",
+				ErrNoBuilds, flavor, version, channel)
 		}
+		return fmt.Errorf("%w for %s %s", ErrNoBuilds, flavor, version)
+	}
+	latestBuild := builds[len(builds)-1]
+	if pinned {
+		if !containsBuild(builds, build) {
+			return fmt.Errorf("%w: %s %s has no build %d", ErrNoBuilds, flavor, version, build)
+		}
+		latestBuild = build
+	}
 
-		version = project.Versions[len(project.Versions)-1]
+	oldManifest, err := LoadManifest()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Println("[w] ignoring unreadable manifest:", err)
+	}
+	if oldManifest != nil {
+		if oldManifest.Flavor != flavor && !migrate {
+			if _, err := MigrationNotes(oldManifest.Flavor, flavor); err == nil {
+				return fmt.Errorf("%w: the installed server is %s, not %s. Convert it with /api/migrate to keep a backup",
+					ErrUpdateRejected, oldManifest.Flavor, flavor)
+			}
+			return fmt.Errorf("%w: the installed server is %s, not %s, and can't be converted. Remove %s to install %s from scratch",
+				ErrUpdateRejected, oldManifest.Flavor, flavor, ManifestPath(), flavor)
+		}
+		if !pinned && oldManifest.HasFailed(version, latestBuild) {
+			return fmt.Errorf("%w: build %d of %s was reverted before because it failed to start, skipping",
+				ErrUpdateRejected, latestBuild, version)
+		}
+		if oldManifest.Flavor == flavor && oldManifest.Version == version {
+			if oldManifest.Build == latestBuild || (!pinned && oldManifest.Build > latestBuild) {
+				return fmt.Errorf("%w: version %s (build %d) is already installed (manifest-check)",
+					ErrUpToDate, oldManifest.Version, oldManifest.Build)
+			}
+		} else if oldManifest.Version != version {
+			log.Printf("[!] manifest version (%s) differs from requested version (%s). "+
+				"This may cause issues!\n", oldManifest.Version, version)
+			// Proxies have no worlds to convert.
+			if CompareVersions(version, oldManifest.Version) > 0 && !IsProxy(flavor) && !UpgradeAcknowledged(oldManifest.Version, version) {
+				return fmt.Errorf("%w: upgrade from %s to %s, review /api/upgrade/check?version=%s and acknowledge it first",
+					ErrUpdateRejected, oldManifest.Version, version, version)
+			}
+			if !manual {
+				return fmt.Errorf("%w: automatic versioning is enabled, set a version in manifest.json or env to prevent unexpected issues",
+					ErrUpdateRejected)
+			}
+		}
 	}
 
-	log.Println("[i] using version", version)
-	log.Println("[i] get latest build")
+	log.Println("[i] get download info for build", latestBuild)
 
-	resp, err := http.Get(fmt.Sprintf("%s/projects/paper/versions/%s/builds", baseURL, version))
+	dl, err := provider.Download(version, latestBuild)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	return installJar(flavor, version, latestBuild, dl, oldManifest, job)
+}
 
-	if resp.StatusCode != 200 {
-		return errors.New("bad status: " + resp.Status)
+func containsBuild(builds []int, build int) bool {
+	for _, b := range builds {
+		if b == build {
+			return true
+		}
 	}
+	return false
+}
 
-	var builds BuildsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&builds); err != nil {
-		return err
-	}
+// downloaded describes a finished download.
+type downloaded struct {
+	Size       int64
+	SHA256     string
+	Provenance *Provenance
+}
 
-	if len(builds.Builds) == 0 {
-		return errors.New("no builds found")
-	}
+// maxBackoff caps the wait between download attempts.
+const maxBackoff = time.Minute
 
-	latestBuild := builds.Builds[len(builds.Builds)-1]
+// statusError is an HTTP response with an unexpected status.
+type statusError struct {
+	Code   int
+	Status string
+}
 
-	manifestPath := mcDir + "/manifest.json"
-	if _, err := os.Stat(manifestPath); err == nil {
-		mf, err := os.Open(manifestPath)
-		if err != nil {
-			return err
+func (e *statusError) Error() string {
+	return "bad status: " + e.Status
+}
+
+// retryable reports whether a failed download is worth another attempt.
+// Client errors won't go away by asking again.
+func retryable(err error) bool {
+	var status *statusError
+	if errors.As(err, &status) {
+		return status.Code >= 500 || status.Code == http.StatusRequestTimeout || status.Code == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// downloadRetry returns the attempts and first backoff for downloads from
+// DOWNLOAD_ATTEMPTS (default 5) and DOWNLOAD_BACKOFF (default 2s).
+func downloadRetry() (int, time.Duration) {
+	attempts, backoff := 5, 2*time.Second
+	if v := os.Getenv("DOWNLOAD_ATTEMPTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			attempts = n
+		} else {
+			log.Printf("[w] ignoring invalid DOWNLOAD_ATTEMPTS=%q", v)
 		}
-		defer mf.Close()
-
-		var oldManifest struct {
-			Version string `json:"version"`
-			Build   int    `json:"build"`
-		}
-		if err := json.NewDecoder(mf).Decode(&oldManifest); err == nil {
-			if oldManifest.Version == version {
-				if oldManifest.Build >= latestBuild.Build {
-					log.Printf("[i] requested function rejected, because version %s (build %d) is already up-to-date (manifest-check)\n",
-						oldManifest.Version, oldManifest.Build)
-					return nil
-				}
-			} else {
-				log.Printf("[!] manifest version (%s) differs from requested version (%s). "+
-					"This may cause issues!\n", oldManifest.Version, version)
-				if !manual {
-					log.Println("[!] requested function rejected, because automatic versioning is enabled.")
-					log.Println("[!] overwrite by manually setting a version in manifest.json or env to prevent unexpected issues.")
-					return nil
-				}
-			}
+	}
+	if v := os.Getenv("DOWNLOAD_BACKOFF"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			backoff = d
+		} else {
+			log.Printf("[w] ignoring invalid DOWNLOAD_BACKOFF=%q", v)
 		}
 	}
+	return attempts, backoff
+}
 
-	log.Println("[i] get download info for build", latestBuild.Build)
+// download fetches dl to path.part, which is renamed to path once complete.
+// Failed attempts are retried with a doubling backoff, resuming where they
+// stopped when the server supports Range requests. A download whose SHA256
+// or SHA1 doesn't match the published one starts over, and fails with
+// ErrChecksumMismatch if it never matches.
+func download(dl Download, path string, job *jobs.Job) (*downloaded, error) {
+	partPath := path + ".part"
+	os.Remove(partPath)
+	defer os.Remove(partPath)
+
+	attempts, backoff := downloadRetry()
+	for attempt := 1; ; attempt++ {
+		d, err := fetch(dl, partPath, job)
+		if err == nil {
+			return d, os.Rename(partPath, path)
+		}
+		if errors.Is(err, ErrChecksumMismatch) {
+			os.Remove(partPath)
+		}
+		if attempt >= attempts || !retryable(err) {
+			return nil, err
+		}
+		log.Printf("[w] download of %s failed: %v, retrying in %s (%d/%d)\n", dl.Filename, err, backoff, attempt+1, attempts)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
 
-	resp, err = http.Get(fmt.Sprintf("%s/projects/paper/versions/%s/builds/%d", baseURL, version, latestBuild.Build))
+// fetch makes one attempt at downloading dl to partPath, continuing after
+// what an earlier attempt left there.
+func fetch(dl Download, partPath string, job *jobs.Job) (*downloaded, error) {
+	file, err := os.OpenFile(partPath, os.O_RDWR|os.O_CREATE, 0644)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer resp.Body.Close()
+	defer file.Close()
 
-	if resp.StatusCode != 200 {
-		return errors.New("bad status: " + resp.Status)
+	hasher := sha256.New()
+	sha1Hasher := sha1.New()
+	offset, err := io.Copy(io.MultiWriter(hasher, sha1Hasher), file)
+	if err != nil {
+		return nil, err
 	}
 
-	var buildInfo BuildResponse
-	if err := json.NewDecoder(resp.Body).Decode(&buildInfo); err != nil {
-		return err
+	req, err := http.NewRequest(http.MethodGet, dl.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		log.Printf("[i] resuming %s after %.2f MB\n", dl.Filename, float64(offset)/1024.0/1024.0)
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	} else {
+		log.Println("[i] downloading", dl.Filename)
 	}
 
-	filename := buildInfo.Downloads.Application.Name
-	log.Println("[i] downloading", filename)
-
-	downloadURL := fmt.Sprintf("%s/projects/paper/versions/%s/builds/%d/downloads/%s",
-		baseURL, version, latestBuild.Build, filename)
-
-	resp, err = http.Get(downloadURL)
+	resp, err := httpClient().Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return errors.New("bad status: " + resp.Status)
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+	case resp.StatusCode == http.StatusOK:
+		if offset > 0 {
+			// The server ignored the range, start over.
+			if err := restart(file); err != nil {
+				return nil, err
+			}
+			hasher.Reset()
+			sha1Hasher.Reset()
+			offset = 0
+		}
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// The file changed since the last attempt, start over.
+		if err := restart(file); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("resume rejected, starting over")
+	default:
+		return nil, &statusError{Code: resp.StatusCode, Status: resp.Status}
 	}
+	provenance := newProvenance(resp, dl.SHA256)
 
-	file, err := os.Create(mcDir + "/" + jarName)
-	if err != nil {
-		return err
+	var size int64
+	if resp.ContentLength >= 0 {
+		size = offset + resp.ContentLength
 	}
-	defer file.Close()
-
-	start := time.Now()
-	var totalBytes int64
+	totalBytes := offset
 	buffer := make([]byte, 32*1024)
 
 	for {
 		bytesRead, readErr := resp.Body.Read(buffer)
 		if bytesRead > 0 {
 			if _, writeErr := file.Write(buffer[:bytesRead]); writeErr != nil {
-				return writeErr
+				return nil, writeErr
 			}
+			hasher.Write(buffer[:bytesRead])
+			sha1Hasher.Write(buffer[:bytesRead])
 			totalBytes += int64(bytesRead)
-
-			elapsed := time.Since(start).Seconds()
-			if elapsed < 0.1 {
-				elapsed = 0.1
-			}
-			speed := float64(totalBytes) / 1024.0 / 1024.0 / elapsed
-			log.Printf("\r[i] downloading: %.2f MB done, %.2f MB/s",
-				float64(totalBytes)/1024.0/1024.0, speed)
+			job.Update(dl.Filename, totalBytes, size)
 		}
 
 		if readErr == io.EOF {
 			break
 		}
 		if readErr != nil {
-			return readErr
+			return nil, readErr
+		}
+	}
+
+	log.Printf("[i] done dl %s (%.2f MB)\n",
+		dl.Filename, float64(totalBytes)/1024.0/1024.0)
+
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+	sum := hex.EncodeToString(hasher.Sum(nil))
+	if dl.SHA256 != "" && !strings.EqualFold(sum, dl.SHA256) {
+		return nil, fmt.Errorf("%w: sha256 of %s is %s, expected %s", ErrChecksumMismatch, dl.Filename, sum, dl.SHA256)
+	}
+	if dl.SHA1 != "" {
+		if sum := hex.EncodeToString(sha1Hasher.Sum(nil)); !strings.EqualFold(sum, dl.SHA1) {
+			return nil, fmt.Errorf("%w: sha1 of %s is %s, expected %s", ErrChecksumMismatch, dl.Filename, sum, dl.SHA1)
 		}
 	}
+	return &downloaded{
+		Size:       totalBytes,
+		SHA256:     sum,
+		Provenance: provenance,
+	}, nil
+}
 
-	log.Printf("\n[i] done dl build %d (%.2f MB)\n",
-		latestBuild.Build, float64(totalBytes)/1024.0/1024.0)
+// restart empties a partial download.
+func restart(file *os.File) error {
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	_, err := file.Seek(0, io.SeekStart)
+	return err
+}
 
-	manifest := map[string]interface{}{
-		"filename": filename,
-		"version":  version,
-		"build":    latestBuild.Build,
-		"size":     totalBytes,
-		"download": downloadURL,
-		"date":     time.Now().Format(time.RFC3339),
+// installJar downloads a server jar, swaps it in and writes the manifest.
+// Flavors that ship an installer are handed to runInstaller instead.
+func installJar(flavor, version string, build int, dl Download, oldManifest *Manifest, job *jobs.Job) error {
+	if dl.Installer {
+		return runInstaller(flavor, version, build, dl, oldManifest, job)
 	}
 
-	manifestFile, err := os.Create(mcDir + "/manifest.json")
+	newPath := JarPath() + ".new"
+	defer os.Remove(newPath)
+	d, err := download(dl, newPath, job)
 	if err != nil {
 		return err
 	}
-	defer manifestFile.Close()
+	// Installs from before jars were kept can still be rolled back to.
+	if oldManifest != nil {
+		if err := keepJar(oldManifest); err != nil {
+			log.Println("[w] could not keep the current jar:", err)
+		}
+	}
+	kept, err := swapJar(newPath)
+	if err != nil {
+		return err
+	}
+
+	manifest := newManifest(flavor, version, build, dl, d, oldManifest)
+	manifest.SHA256 = d.SHA256
+	manifest.Trial = kept && oldManifest != nil
+	if err := manifest.write(); err != nil {
+		return err
+	}
+	if err := keepJar(manifest); err != nil {
+		log.Println("[w] could not keep the new jar:", err)
+	}
+	return nil
+}
+
+// newManifest returns the manifest for a fresh install, keeping the history
+// of the previous one.
+func newManifest(flavor, version string, build int, dl Download, d *downloaded, oldManifest *Manifest) *Manifest {
+	manifest := &Manifest{}
+	if oldManifest != nil {
+		manifest.History = oldManifest.History
+		manifest.Failed = oldManifest.Failed
+		// A pin only holds for the version it was set for.
+		if oldManifest.Flavor == flavor && oldManifest.Version == version {
+			manifest.Pin = oldManifest.Pin
+		}
+	}
+	manifest.Flavor = flavor
+	manifest.Filename = dl.Filename
+	manifest.Version = version
+	manifest.Build = build
+	manifest.Size = d.Size
+	manifest.Java = RequiredJavaFor(flavor, version)
+	manifest.Download = dl.URL
+	manifest.Date = time.Now().Format(time.RFC3339)
+	manifest.Provenance = d.Provenance
+	return manifest
+}
 
-	enc := json.NewEncoder(manifestFile)
-	enc.SetIndent("", "  ")
-	if err := enc.Encode(manifest); err != nil {
+func (m *Manifest) write() error {
+	m.Record()
+	if err := m.Save(); err != nil {
 		return err
 	}
 
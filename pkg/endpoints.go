@@ -0,0 +1,91 @@
+package pkg
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+)
+
+var (
+	ErrVersionNotFound  = errors.New("version not found")
+	ErrNoBuilds         = errors.New("no builds found")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+
+	// errNotFound is returned by httpGet for a 404, providers turn it into
+	// ErrVersionNotFound.
+	errNotFound = errors.New("not found")
+)
+
+// Endpoints are the base URLs the providers download from.
+type Endpoints struct {
+	// Paper is the Paper API, which also hosts Folia and Velocity.
+	Paper  string
+	Purpur string
+	// Mojang is the version manifest listing every vanilla release.
+	Mojang string
+	Fabric string
+	// Forge and NeoForge are the Maven directories of their installers.
+	Forge    string
+	NeoForge string
+	// BungeeCord is the Jenkins job that builds it.
+	BungeeCord string
+}
+
+var DefaultEndpoints = Endpoints{
+	Paper:      "https://api.papermc.io/v2",
+	Purpur:     "https://api.purpurmc.org/v2/purpur",
+	Mojang:     "https://piston-meta.mojang.com/mc/game/version_manifest_v2.json",
+	Fabric:     "https://meta.fabricmc.net/v2",
+	Forge:      "https://maven.minecraftforge.net/net/minecraftforge/forge",
+	NeoForge:   "https://maven.neoforged.net/releases/net/neoforged/neoforge",
+	BungeeCord: "https://ci.md-5.net/job/BungeeCord",
+}
+
+var (
+	endpointsMu sync.RWMutex
+	client      = http.DefaultClient
+	endpoints   = DefaultEndpoints
+)
+
+// SetHTTPClient replaces the client used for API requests and downloads, to
+// go through a proxy or talk to a test server.
+func SetHTTPClient(c *http.Client) {
+	endpointsMu.Lock()
+	defer endpointsMu.Unlock()
+	client = c
+}
+
+// SetEndpoints points the providers at mirrors or a test server. Empty
+// fields keep their default.
+func SetEndpoints(e Endpoints) {
+	def := DefaultEndpoints
+	for _, f := range []struct{ to, from *string }{
+		{&def.Paper, &e.Paper},
+		{&def.Purpur, &e.Purpur},
+		{&def.Mojang, &e.Mojang},
+		{&def.Fabric, &e.Fabric},
+		{&def.Forge, &e.Forge},
+		{&def.NeoForge, &e.NeoForge},
+		{&def.BungeeCord, &e.BungeeCord},
+	} {
+		if *f.from != "" {
+			*f.to = *f.from
+		}
+	}
+
+	endpointsMu.Lock()
+	defer endpointsMu.Unlock()
+	endpoints = def
+}
+
+func httpClient() *http.Client {
+	endpointsMu.RLock()
+	defer endpointsMu.RUnlock()
+	return client
+}
+
+func urls() Endpoints {
+	endpointsMu.RLock()
+	defer endpointsMu.RUnlock()
+	return endpoints
+}
@@ -0,0 +1,128 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// fabricServerJar is where the Fabric launcher keeps the vanilla server.
+	// It would use server.jar by default, which is the launcher itself here.
+	fabricServerJar        = "vanilla-server.jar"
+	fabricLauncherSettings = "fabric-server-launcher.properties"
+)
+
+// fabricProvider installs the Fabric server launcher, which downloads the
+// vanilla server on its first start and runs it with the Fabric loader.
+//
+// Fabric has loader versions instead of build numbers, so build n is the nth
+// stable loader release. New loaders get higher numbers, which keeps the
+// up-to-date check working.
+type fabricProvider struct{}
+
+type fabricVersion struct {
+	Version string `json:"version"`
+	Stable  bool   `json:"stable"`
+}
+
+// stableFabric returns the stable versions at path, oldest first.
+func stableFabric(path string) ([]string, error) {
+	var list []fabricVersion
+	if err := getJSON(urls().Fabric+path, &list); err != nil {
+		return nil, err
+	}
+	var stable []string
+	for i := len(list) - 1; i >= 0; i-- {
+		if list[i].Stable {
+			stable = append(stable, list[i].Version)
+		}
+	}
+	return stable, nil
+}
+
+func (fabricProvider) Versions() ([]string, error) {
+	return stableFabric("/versions/game")
+}
+
+func (fabricProvider) Builds(version string) ([]int, error) {
+	var supported []struct {
+		Loader fabricVersion `json:"loader"`
+	}
+	if err := getJSON(urls().Fabric+"/versions/loader/"+version, &supported); err != nil {
+		return nil, versionError(err, "fabric", version)
+	}
+	if len(supported) == 0 {
+		return nil, fmt.Errorf("%w: fabric doesn't support minecraft %s", ErrVersionNotFound, version)
+	}
+
+	loaders, err := stableFabric("/versions/loader")
+	if err != nil {
+		return nil, err
+	}
+	builds := make([]int, len(loaders))
+	for i := range loaders {
+		builds[i] = i + 1
+	}
+	return builds, nil
+}
+
+func (fabricProvider) Download(version string, build int) (Download, error) {
+	loaders, err := stableFabric("/versions/loader")
+	if err != nil {
+		return Download{}, err
+	}
+	installers, err := stableFabric("/versions/installer")
+	if err != nil {
+		return Download{}, err
+	}
+	if build < 1 || build > len(loaders) || len(installers) == 0 {
+		return Download{}, fmt.Errorf("%w: no fabric loader for minecraft %s", ErrNoBuilds, version)
+	}
+	loader, installer := loaders[build-1], installers[len(installers)-1]
+
+	return Download{
+		Filename: fmt.Sprintf("fabric-server-mc.%s-loader.%s-launcher.%s.jar", version, loader, installer),
+		URL: fmt.Sprintf("%s/versions/loader/%s/%s/%s/server/jar",
+			urls().Fabric, version, loader, installer),
+	}, nil
+}
+
+// PrepareFabric points the Fabric launcher at its own vanilla jar, so it
+// doesn't overwrite itself with the vanilla server. It's a no-op for other
+// flavors.
+func PrepareFabric() error {
+	m, err := LoadManifest()
+	if err != nil || m.Flavor != "fabric" {
+		return nil
+	}
+
+	path := filepath.Join(mcDir, fabricLauncherSettings)
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	var lines []string
+	found := false
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "serverJar=") {
+			if strings.TrimSpace(line) == "serverJar="+fabricServerJar {
+				return nil
+			}
+			line, found = "serverJar="+fabricServerJar, true
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if !found {
+		lines = append(lines, "serverJar="+fabricServerJar)
+	}
+
+	log.Println("[i] fabric: launcher keeps the vanilla server in", fabricServerJar)
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
@@ -0,0 +1,141 @@
+// Package fileacl protects paths in the server directory against accidental
+// writes and deletes through the file API.
+package fileacl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"pkg.bijsven.nl/MiniMC/pkg/auth"
+)
+
+const path = "file-rules.json"
+
+// Rule protects the paths matching Path, a pattern relative to the server
+// directory in which "*" matches within a folder and "**" any number of
+// folders. Changing them needs one of Roles, when any are listed, and an
+// explicit confirmation when Confirm is set.
+type Rule struct {
+	Path    string   `json:"path"`
+	Roles   []string `json:"roles,omitempty"`
+	Confirm bool     `json:"confirm"`
+}
+
+type Rules struct {
+	Protected []Rule `json:"protected"`
+}
+
+var (
+	mu sync.Mutex
+
+	ErrRole    = errors.New("path is protected for your role")
+	ErrConfirm = errors.New("path is protected, confirm to change it")
+)
+
+func defaults() Rules {
+	return Rules{
+		Protected: []Rule{
+			{Path: "world/**", Confirm: true},
+			{Path: "world_nether/**", Confirm: true},
+			{Path: "world_the_end/**", Confirm: true},
+			{Path: "server.jar", Confirm: true},
+			{Path: "manifest.json", Confirm: true},
+		},
+	}
+}
+
+func Load() (Rules, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	rules := defaults()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return rules, nil
+	}
+	if err != nil {
+		return rules, err
+	}
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return rules, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	if rules.Protected == nil {
+		rules.Protected = []Rule{}
+	}
+	return rules, nil
+}
+
+func (r Rules) Validate() error {
+	for i, rule := range r.Protected {
+		if strings.Trim(rule.Path, "/") == "" {
+			return fmt.Errorf("rule %d: path is required", i)
+		}
+		if strings.Contains(rule.Path, "..") {
+			return fmt.Errorf("rule %d: path may not contain ..", i)
+		}
+		if len(rule.Roles) == 0 && !rule.Confirm {
+			return fmt.Errorf("rule %s: needs roles or confirm", rule.Path)
+		}
+	}
+	return nil
+}
+
+func Save(r Rules) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Match returns the rules protecting p. With tree set, p is a folder that is
+// deleted or moved as a whole, so rules for paths inside it count too.
+func (r Rules) Match(p string, tree bool) []Rule {
+	var matched []Rule
+	for _, rule := range r.Protected {
+		if auth.MatchGlob(rule.Path, p) || (tree && auth.MatchGlobPrefix(rule.Path, p)) {
+			matched = append(matched, rule)
+		}
+	}
+	return matched
+}
+
+// Check returns ErrRole when role may not change p, and ErrConfirm when it
+// may but the change wasn't confirmed.
+func (r Rules) Check(role, p string, tree, confirmed bool) error {
+	needConfirm := false
+	for _, rule := range r.Match(p, tree) {
+		if len(rule.Roles) > 0 && !hasRole(rule.Roles, role) {
+			return fmt.Errorf("%w: %s", ErrRole, rule.Path)
+		}
+		needConfirm = needConfirm || rule.Confirm
+	}
+	if needConfirm && !confirmed {
+		return fmt.Errorf("%w: %s", ErrConfirm, p)
+	}
+	return nil
+}
+
+func hasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,276 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// Download is a server jar a provider offers for one build.
+type Download struct {
+	Filename string
+	URL      string
+	// SHA256 and SHA1 are the hashes the download API publishes, if any.
+	SHA256 string
+	SHA1   string
+	// Installer is set when the download is an installer that has to be
+	// run to set up the server, which is then started with ArgsFile, a JVM
+	// argument file relative to the server directory.
+	Installer bool
+	ArgsFile  string
+}
+
+// Provider resolves the server jars of one flavor.
+type Provider interface {
+	// Versions returns the Minecraft versions with builds, oldest first.
+	Versions() ([]string, error)
+	// Builds returns the build numbers of version, oldest first.
+	Builds(version string) ([]int, error)
+	Download(version string, build int) (Download, error)
+}
+
+var providers = map[string]Provider{
+	"bungeecord": bungeecordProvider{},
+	"fabric":     fabricProvider{},
+	"folia":      paperProvider{"folia"},
+	"forge":      forgeProvider{},
+	"neoforge":   neoforgeProvider{},
+	"paper":      paperProvider{"paper"},
+	"purpur":     purpurProvider{},
+	"vanilla":    vanillaProvider{},
+	"velocity":   paperProvider{"velocity"},
+}
+
+var ErrUnknownFlavor = errors.New("unknown flavor")
+
+// Flavors returns the names of the supported flavors.
+func Flavors() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetProvider returns the provider of flavor, or ErrUnknownFlavor.
+func GetProvider(flavor string) (Provider, error) {
+	p, ok := providers[flavor]
+	if !ok {
+		return nil, fmt.Errorf("%w %q, use one of %v", ErrUnknownFlavor, flavor, Flavors())
+	}
+	return p, nil
+}
+
+// Flavor returns the server flavor from MC_FLAVOR, "paper" by default.
+func Flavor() string {
+	if f := os.Getenv("MC_FLAVOR"); f != "" {
+		return f
+	}
+	return "paper"
+}
+
+// httpGet fetches url, treating any status but 200 as an error. A 404 is
+// errNotFound.
+func httpGet(url string) (*http.Response, error) {
+	resp, err := httpClient().Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s", errNotFound, url)
+	}
+	if resp.StatusCode != 200 {
+		resp.Body.Close()
+		return nil, errors.New("bad status: " + resp.Status)
+	}
+	return resp, nil
+}
+
+// versionError turns a 404 from a download API into ErrVersionNotFound.
+func versionError(err error, project, version string) error {
+	if errors.Is(err, errNotFound) {
+		return fmt.Errorf("%w: %s %s", ErrVersionNotFound, project, version)
+	}
+	return err
+}
+
+func getJSON(url string, v interface{}) error {
+	resp, err := httpGet(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// paperProvider downloads a project from the Paper API, which also hosts
+// Folia and the Velocity proxy. Its responses are cached, see
+// getCachedJSON.
+type paperProvider struct {
+	project string
+}
+
+func (p paperProvider) Versions() ([]string, error) {
+	var project ProjectResponse
+	if err := getCachedJSON(urls().Paper+"/projects/"+p.project, versionsTTL, &project); err != nil {
+		return nil, err
+	}
+	return project.Versions, nil
+}
+
+func (p paperProvider) Builds(version string) ([]int, error) {
+	builds, err := p.BuildInfos(version)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]int, len(builds))
+	for i, b := range builds {
+		list[i] = b.Build
+	}
+	return list, nil
+}
+
+func (p paperProvider) BuildInfos(version string) ([]BuildInfo, error) {
+	var builds BuildsResponse
+	if err := getCachedJSON(fmt.Sprintf("%s/projects/%s/versions/%s/builds", urls().Paper, p.project, version), buildsTTL, &builds); err != nil {
+		return nil, versionError(err, p.project, version)
+	}
+	list := make([]BuildInfo, len(builds.Builds))
+	for i, b := range builds.Builds {
+		list[i] = BuildInfo{Build: b.Build, Channel: b.Channel}
+	}
+	return list, nil
+}
+
+func (p paperProvider) Download(version string, build int) (Download, error) {
+	base := urls().Paper
+	var info BuildResponse
+	if err := getCachedJSON(fmt.Sprintf("%s/projects/%s/versions/%s/builds/%d", base, p.project, version, build), buildInfoTTL, &info); err != nil {
+		return Download{}, versionError(err, p.project, version)
+	}
+	filename := info.Downloads.Application.Name
+	return Download{
+		Filename: filename,
+		URL: fmt.Sprintf("%s/projects/%s/versions/%s/builds/%d/downloads/%s",
+			base, p.project, version, build, filename),
+		SHA256: info.Downloads.Application.SHA256,
+	}, nil
+}
+
+type purpurProvider struct{}
+
+func (purpurProvider) Versions() ([]string, error) {
+	var project struct {
+		Versions []string `json:"versions"`
+	}
+	if err := getJSON(urls().Purpur, &project); err != nil {
+		return nil, err
+	}
+	return project.Versions, nil
+}
+
+func (purpurProvider) Builds(version string) ([]int, error) {
+	var info struct {
+		Builds struct {
+			All []string `json:"all"`
+		} `json:"builds"`
+	}
+	if err := getJSON(urls().Purpur+"/"+version, &info); err != nil {
+		return nil, versionError(err, "purpur", version)
+	}
+	var list []int
+	for _, b := range info.Builds.All {
+		if n, err := strconv.Atoi(b); err == nil {
+			list = append(list, n)
+		}
+	}
+	sort.Ints(list)
+	return list, nil
+}
+
+func (purpurProvider) Download(version string, build int) (Download, error) {
+	// Purpur only publishes MD5 checksums, so there's no expected SHA256.
+	return Download{
+		Filename: fmt.Sprintf("purpur-%s-%d.jar", version, build),
+		URL:      fmt.Sprintf("%s/%s/%d/download", urls().Purpur, version, build),
+	}, nil
+}
+
+// vanillaProvider downloads the official server from Mojang. Vanilla has no
+// builds, every version is build 0.
+type vanillaProvider struct{}
+
+type mojangVersion struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+func mojangVersions() ([]mojangVersion, error) {
+	var manifest struct {
+		Versions []mojangVersion `json:"versions"`
+	}
+	if err := getJSON(urls().Mojang, &manifest); err != nil {
+		return nil, err
+	}
+	return manifest.Versions, nil
+}
+
+// Versions returns the releases; snapshots can still be installed by setting
+// their id as the version.
+func (vanillaProvider) Versions() ([]string, error) {
+	all, err := mojangVersions()
+	if err != nil {
+		return nil, err
+	}
+	// The manifest lists the newest version first.
+	var releases []string
+	for i := len(all) - 1; i >= 0; i-- {
+		if all[i].Type == "release" {
+			releases = append(releases, all[i].ID)
+		}
+	}
+	return releases, nil
+}
+
+func (vanillaProvider) Builds(version string) ([]int, error) {
+	return []int{0}, nil
+}
+
+func (vanillaProvider) Download(version string, build int) (Download, error) {
+	all, err := mojangVersions()
+	if err != nil {
+		return Download{}, err
+	}
+	for _, v := range all {
+		if v.ID != version {
+			continue
+		}
+		var info struct {
+			Downloads struct {
+				Server *struct {
+					SHA1 string `json:"sha1"`
+					URL  string `json:"url"`
+				} `json:"server"`
+			} `json:"downloads"`
+		}
+		if err := getJSON(v.URL, &info); err != nil {
+			return Download{}, err
+		}
+		if info.Downloads.Server == nil {
+			return Download{}, fmt.Errorf("minecraft %s has no server download", version)
+		}
+		return Download{
+			Filename: "minecraft_server." + version + ".jar",
+			URL:      info.Downloads.Server.URL,
+			SHA1:     info.Downloads.Server.SHA1,
+		}, nil
+	}
+	return Download{}, fmt.Errorf("%w: minecraft %s", ErrVersionNotFound, version)
+}
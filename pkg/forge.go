@@ -0,0 +1,251 @@
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"pkg.bijsven.nl/MiniMC/pkg/jobs"
+)
+
+const installerTimeout = 15 * time.Minute
+
+// Forge and NeoForge can't be started like a plain server.jar. Their
+// installer is run with --installServer, which downloads the libraries and
+// writes JVM argument files the server is started with. Those exist since
+// Minecraft 1.17, older versions aren't supported.
+//
+// Like Fabric there are no build numbers, build n is the nth release for a
+// Minecraft version.
+
+func mavenVersions(base string) ([]string, error) {
+	resp, err := httpGet(base + "/maven-metadata.xml")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var metadata struct {
+		Versions []string `xml:"versioning>versions>version"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&metadata); err != nil {
+		return nil, err
+	}
+	return metadata.Versions, nil
+}
+
+// loaderReleases maps Minecraft versions to their loader versions, oldest
+// first. mcVersion returns the Minecraft version of a loader version, or ""
+// to skip it.
+func loaderReleases(base string, mcVersion func(string) string) (map[string][]string, error) {
+	versions, err := mavenVersions(base)
+	if err != nil {
+		return nil, err
+	}
+	releases := map[string][]string{}
+	for _, v := range versions {
+		if mc := mcVersion(v); mc != "" && CompareVersions(mc, "1.17") >= 0 {
+			releases[mc] = append(releases[mc], v)
+		}
+	}
+	for _, list := range releases {
+		sort.SliceStable(list, func(i, j int) bool {
+			return CompareVersions(loaderPart(list[i]), loaderPart(list[j])) < 0
+		})
+	}
+	return releases, nil
+}
+
+// loaderPart strips the Minecraft version from a Forge version.
+func loaderPart(v string) string {
+	if _, loader, ok := strings.Cut(v, "-"); ok {
+		return loader
+	}
+	return v
+}
+
+func sortedKeys(releases map[string][]string) []string {
+	keys := make([]string, 0, len(releases))
+	for k := range releases {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return CompareVersions(keys[i], keys[j]) < 0 })
+	return keys
+}
+
+func buildNumbers(list []string, version string) ([]int, error) {
+	if len(list) == 0 {
+		return nil, fmt.Errorf("%w: no releases for minecraft %s", ErrVersionNotFound, version)
+	}
+	builds := make([]int, len(list))
+	for i := range list {
+		builds[i] = i + 1
+	}
+	return builds, nil
+}
+
+func pickRelease(list []string, version string, build int) (string, error) {
+	if build < 1 || build > len(list) {
+		return "", fmt.Errorf("%w: no release %d for minecraft %s", ErrNoBuilds, build, version)
+	}
+	return list[build-1], nil
+}
+
+type forgeProvider struct{}
+
+// forgeMC returns the Minecraft version of a Forge version like
+// "1.20.1-47.3.0".
+func forgeMC(v string) string {
+	mc, _, ok := strings.Cut(v, "-")
+	if !ok {
+		return ""
+	}
+	return mc
+}
+
+func (forgeProvider) Versions() ([]string, error) {
+	releases, err := loaderReleases(urls().Forge, forgeMC)
+	if err != nil {
+		return nil, err
+	}
+	return sortedKeys(releases), nil
+}
+
+func (forgeProvider) Builds(version string) ([]int, error) {
+	releases, err := loaderReleases(urls().Forge, forgeMC)
+	if err != nil {
+		return nil, err
+	}
+	return buildNumbers(releases[version], version)
+}
+
+func (forgeProvider) Download(version string, build int) (Download, error) {
+	releases, err := loaderReleases(urls().Forge, forgeMC)
+	if err != nil {
+		return Download{}, err
+	}
+	v, err := pickRelease(releases[version], version, build)
+	if err != nil {
+		return Download{}, err
+	}
+	return Download{
+		Filename:  "forge-" + v + "-installer.jar",
+		URL:       fmt.Sprintf("%s/%s/forge-%s-installer.jar", urls().Forge, v, v),
+		Installer: true,
+		ArgsFile:  filepath.Join("libraries", "net", "minecraftforge", "forge", v, "unix_args.txt"),
+	}, nil
+}
+
+type neoforgeProvider struct{}
+
+// neoforgeMC returns the Minecraft version of a NeoForge version, whose first
+// two parts are the minor and patch version: 21.1.77 is for 1.21.1, 21.0.167
+// for 1.21. Betas are skipped.
+func neoforgeMC(v string) string {
+	if strings.Contains(v, "-") {
+		return ""
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) < 3 {
+		return ""
+	}
+	if parts[1] == "0" {
+		return "1." + parts[0]
+	}
+	return "1." + parts[0] + "." + parts[1]
+}
+
+func (neoforgeProvider) Versions() ([]string, error) {
+	releases, err := loaderReleases(urls().NeoForge, neoforgeMC)
+	if err != nil {
+		return nil, err
+	}
+	return sortedKeys(releases), nil
+}
+
+func (neoforgeProvider) Builds(version string) ([]int, error) {
+	releases, err := loaderReleases(urls().NeoForge, neoforgeMC)
+	if err != nil {
+		return nil, err
+	}
+	return buildNumbers(releases[version], version)
+}
+
+func (neoforgeProvider) Download(version string, build int) (Download, error) {
+	releases, err := loaderReleases(urls().NeoForge, neoforgeMC)
+	if err != nil {
+		return Download{}, err
+	}
+	v, err := pickRelease(releases[version], version, build)
+	if err != nil {
+		return Download{}, err
+	}
+	return Download{
+		Filename:  "neoforge-" + v + "-installer.jar",
+		URL:       fmt.Sprintf("%s/%s/neoforge-%s-installer.jar", urls().NeoForge, v, v),
+		Installer: true,
+		ArgsFile:  filepath.Join("libraries", "net", "neoforged", "neoforge", v, "unix_args.txt"),
+	}, nil
+}
+
+// runInstaller downloads an installer and runs it headless in the server
+// directory. The server is started from the argument file it generates from
+// then on.
+func runInstaller(flavor, version string, build int, dl Download, oldManifest *Manifest, job *jobs.Job) error {
+	installerPath := filepath.Join(mcDir, dl.Filename)
+	defer os.Remove(installerPath)
+	defer os.Remove(installerPath + ".log")
+
+	d, err := download(dl, installerPath, job)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), installerTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, JavaBin(), "-jar", dl.Filename, "--installServer")
+	cmd.Dir = mcDir
+	pr, pw := io.Pipe()
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	cmd.WaitDelay = 5 * time.Second
+
+	log.Printf("[i] running %s installer %s\n", flavor, dl.Filename)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(pr)
+		for scanner.Scan() {
+			job.Log(scanner.Text())
+		}
+	}()
+	err = cmd.Run()
+	pw.Close()
+	<-done
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%s installer timed out after %s", flavor, installerTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%s installer failed: %w", flavor, err)
+	}
+	if _, err := os.Stat(filepath.Join(mcDir, dl.ArgsFile)); err != nil {
+		return errors.New(flavor + " installer didn't create " + dl.ArgsFile)
+	}
+	log.Printf("[i] %s %s installed\n", flavor, version)
+
+	manifest := newManifest(flavor, version, build, dl, d, oldManifest)
+	manifest.ArgsFile = filepath.ToSlash(dl.ArgsFile)
+	return manifest.write()
+}
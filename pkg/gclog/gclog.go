@@ -0,0 +1,189 @@
+// Package gclog reads the JVM's unified GC log and summarizes its pauses and
+// allocation rate.
+package gclog
+
+import (
+	"bufio"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Name is the file the JVM logs to; rotated files get a numbered suffix.
+const Name = "gc.log"
+
+// Pause is one stop-the-world GC pause.
+type Pause struct {
+	Time   time.Time `json:"time"`
+	Uptime float64   `json:"uptime"`
+	Kind   string    `json:"kind"`
+	// Before, After and Heap are in MB, and 0 for collectors that don't
+	// log heap sizes with their pauses.
+	Before int64   `json:"before_mb,omitempty"`
+	After  int64   `json:"after_mb,omitempty"`
+	Heap   int64   `json:"heap_mb,omitempty"`
+	Millis float64 `json:"ms"`
+}
+
+// Summary describes the pauses in a stretch of the GC log.
+type Summary struct {
+	Pauses int            `json:"pauses"`
+	Kinds  map[string]int `json:"kinds"`
+	From   *time.Time     `json:"from,omitempty"`
+	To     *time.Time     `json:"to,omitempty"`
+	P50    float64        `json:"p50_ms"`
+	P90    float64        `json:"p90_ms"`
+	P99    float64        `json:"p99_ms"`
+	Max    float64        `json:"max_ms"`
+	Total  float64        `json:"total_ms"`
+	// Overhead is the share of run time spent paused, in percent.
+	Overhead float64 `json:"overhead_percent"`
+	// AllocRate is how fast the server allocates, in MB/s, from the heap
+	// growth between collections.
+	AllocRate float64 `json:"alloc_rate_mb_s"`
+	// HeapAfter is the average heap in use after a collection, in MB.
+	HeapAfter float64 `json:"heap_after_mb"`
+	Recent    []Pause `json:"recent"`
+}
+
+const recentPauses = 20
+
+var (
+	// decoratorPattern matches the time and uptime decorators,
+	// "[2024-05-01T12:00:00.000+0000][12.345s]".
+	decoratorPattern = regexp.MustCompile(`^\[([^\]]+)\]\[([\d.]+)s\]`)
+	// pausePattern matches pause lines of G1, Parallel and Serial, e.g.
+	// "GC(12) Pause Young (Normal) (G1 Evacuation Pause) 512M->128M(1024M) 12.345ms",
+	// and ZGC's "GC(3) Pause Mark Start 0.010ms".
+	pausePattern = regexp.MustCompile(`GC\(\d+\) (Pause [A-Za-z ]+?)(?: \([^)]*\))*(?: (\d+)M->(\d+)M\((\d+)M\))? ([\d.]+)ms$`)
+)
+
+const timeLayout = "2006-01-02T15:04:05.000-0700"
+
+// ParseLine parses a pause from a line of the GC log.
+func ParseLine(line string) (Pause, bool) {
+	d := decoratorPattern.FindStringSubmatch(line)
+	m := pausePattern.FindStringSubmatch(line)
+	if d == nil || m == nil {
+		return Pause{}, false
+	}
+	var p Pause
+	p.Time, _ = time.Parse(timeLayout, d[1])
+	p.Uptime, _ = strconv.ParseFloat(d[2], 64)
+	p.Kind = strings.TrimPrefix(m[1], "Pause ")
+	p.Before, _ = strconv.ParseInt(m[2], 10, 64)
+	p.After, _ = strconv.ParseInt(m[3], 10, 64)
+	p.Heap, _ = strconv.ParseInt(m[4], 10, 64)
+	p.Millis, _ = strconv.ParseFloat(m[5], 64)
+	return p, true
+}
+
+// Read returns the pauses logged in dir since the given time, oldest first,
+// including the rotated files.
+func Read(dir string, since time.Time) ([]Pause, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, Name+"*"))
+	if err != nil {
+		return nil, err
+	}
+	type logFile struct {
+		path string
+		mod  time.Time
+	}
+	var files []logFile
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || info.ModTime().Before(since) {
+			continue
+		}
+		files = append(files, logFile{path, info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].mod.Before(files[j].mod)
+	})
+
+	pauses := []Pause{}
+	for _, f := range files {
+		file, err := os.Open(f.path)
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if p, ok := ParseLine(scanner.Text()); ok && !p.Time.Before(since) {
+				pauses = append(pauses, p)
+			}
+		}
+		file.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return pauses, nil
+}
+
+// Summarize computes pause percentiles and the allocation rate. The uptime
+// going back marks a restart of the JVM, those gaps aren't counted.
+func Summarize(pauses []Pause) Summary {
+	s := Summary{Pauses: len(pauses), Kinds: map[string]int{}, Recent: []Pause{}}
+	if len(pauses) == 0 {
+		return s
+	}
+	s.From, s.To = &pauses[0].Time, &pauses[len(pauses)-1].Time
+
+	millis := make([]float64, len(pauses))
+	var runTime, allocated, allocTime, heapAfter float64
+	var withHeap int
+	for i, p := range pauses {
+		millis[i] = p.Millis
+		s.Total += p.Millis
+		s.Kinds[p.Kind]++
+		if p.Heap > 0 {
+			heapAfter += float64(p.After)
+			withHeap++
+		}
+		if i == 0 {
+			continue
+		}
+		prev := pauses[i-1]
+		if p.Uptime < prev.Uptime {
+			continue
+		}
+		runTime += p.Uptime - prev.Uptime
+		if p.Heap > 0 && prev.Heap > 0 && p.Before >= prev.After {
+			allocated += float64(p.Before - prev.After)
+			allocTime += p.Uptime - prev.Uptime
+		}
+	}
+
+	sort.Float64s(millis)
+	s.P50 = percentile(millis, 50)
+	s.P90 = percentile(millis, 90)
+	s.P99 = percentile(millis, 99)
+	s.Max = millis[len(millis)-1]
+	if runTime > 0 {
+		s.Overhead = round(s.Total / 10 / runTime)
+	}
+	if allocTime > 0 {
+		s.AllocRate = round(allocated / allocTime)
+	}
+	if withHeap > 0 {
+		s.HeapAfter = round(heapAfter / float64(withHeap))
+	}
+	s.Recent = pauses[max(0, len(pauses)-recentPauses):]
+	return s
+}
+
+// percentile returns the nearest-rank percentile of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[max(0, min(i, len(sorted)-1))]
+}
+
+func round(f float64) float64 {
+	return math.Round(f*100) / 100
+}
@@ -0,0 +1,104 @@
+package geoip
+
+import (
+	"log"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/oschwald/maxminddb-golang"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+)
+
+// loginPattern matches the server's login line, starting at the log prefix so
+// a player can't count fake connections by typing one in chat.
+var loginPattern = regexp.MustCompile(server.LogPrefix + `\w{1,16}\[/([^\]]+)\] logged in`)
+
+type Summary struct {
+	Enabled   bool           `json:"enabled"`
+	Total     int            `json:"total"`
+	Unknown   int            `json:"unknown"`
+	Countries map[string]int `json:"countries"`
+}
+
+var (
+	mu        sync.Mutex
+	db        *maxminddb.Reader
+	countries = map[string]int{}
+	unknown   int
+)
+
+// Open loads the MMDB database at path and starts counting connections per
+// country. Only the resolved country is kept, the addresses themselves are
+// never stored.
+func Open(path string) error {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	db = reader
+	mu.Unlock()
+
+	server.OnLine(track)
+	log.Println("[i] geoip database loaded:", path)
+	return nil
+}
+
+func track(line string) {
+	m := loginPattern.FindStringSubmatch(line)
+	if m == nil {
+		return
+	}
+
+	addr := m[1]
+	if i := strings.LastIndex(addr, ":"); i != -1 {
+		addr = addr[:i]
+	}
+	ip := net.ParseIP(strings.Trim(addr, "[]"))
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	country := lookup(ip)
+	if country == "" {
+		unknown++
+		return
+	}
+	countries[country]++
+}
+
+func lookup(ip net.IP) string {
+	if ip == nil || db == nil {
+		return ""
+	}
+
+	var record struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+	if err := db.Lookup(ip, &record); err != nil {
+		return ""
+	}
+	return record.Country.ISOCode
+}
+
+func GetSummary() Summary {
+	mu.Lock()
+	defer mu.Unlock()
+
+	summary := Summary{
+		Enabled:   db != nil,
+		Unknown:   unknown,
+		Countries: make(map[string]int, len(countries)),
+	}
+	for country, count := range countries {
+		summary.Countries[country] = count
+		summary.Total += count
+	}
+	summary.Total += unknown
+	return summary
+}
@@ -0,0 +1,283 @@
+package hooks
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"pkg.bijsven.nl/MiniMC/pkg/jobs"
+)
+
+const (
+	PreStart   = "pre-start"
+	PostStop   = "post-stop"
+	PreBackup  = "pre-backup"
+	PostBackup = "post-backup"
+
+	configPath = "hooks.json"
+	// ScriptDir holds scripts named after an event, e.g. hooks/pre-start.sh.
+	// It lives next to hooks.json, outside the server directory, so it can't
+	// be written through the file manager.
+	ScriptDir      = "hooks"
+	defaultTimeout = 30 * time.Second
+)
+
+var Events = []string{PreStart, PostStop, PreBackup, PostBackup}
+
+var ErrNotAllowed = errors.New("command is not in HOOK_ALLOWLIST")
+
+// secretEnv are MiniMC's own credentials and keys, which are left out of the
+// environment of hooks.
+var secretEnv = []string{"username", "password", "STATUS_PAGE_PASSWORD", "SECURITY_WEBHOOK_URL"}
+
+// environ returns MiniMC's environment without secretEnv.
+func environ() []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		secret := false
+		for _, s := range secretEnv {
+			if name == s {
+				secret = true
+			}
+		}
+		if !secret {
+			env = append(env, kv)
+		}
+	}
+	return env
+}
+
+// Hook runs an external command around a lifecycle event. When a pre-hook
+// marked Required fails, the action it guards is cancelled.
+type Hook struct {
+	Command  string   `json:"command"`
+	Args     []string `json:"args,omitempty"`
+	Timeout  string   `json:"timeout,omitempty"`
+	Required bool     `json:"required,omitempty"`
+
+	script bool
+}
+
+// Config maps events to the hooks that run for them, in order.
+type Config map[string][]Hook
+
+var configMu sync.Mutex
+
+// Allowlist returns the executables hooks may run, from the comma separated
+// HOOK_ALLOWLIST. It's read from the environment only, so the web interface
+// can't add commands of its own.
+func Allowlist() []string {
+	var list []string
+	for _, path := range strings.Split(os.Getenv("HOOK_ALLOWLIST"), ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			list = append(list, filepath.Clean(path))
+		}
+	}
+	return list
+}
+
+func allowed(command string) bool {
+	for _, path := range Allowlist() {
+		if filepath.Clean(command) == path {
+			return true
+		}
+	}
+	return false
+}
+
+func (h Hook) name() string {
+	if h.script {
+		return h.Args[0]
+	}
+	return h.Command
+}
+
+func (h Hook) timeout() time.Duration {
+	if d, err := time.ParseDuration(h.Timeout); err == nil {
+		return d
+	}
+	return defaultTimeout
+}
+
+func (c Config) Validate() error {
+	for event, hooks := range c {
+		known := false
+		for _, e := range Events {
+			known = known || e == event
+		}
+		if !known {
+			return fmt.Errorf("unknown event %q, use one of %s", event, strings.Join(Events, ", "))
+		}
+		for _, h := range hooks {
+			if !filepath.IsAbs(h.Command) {
+				return fmt.Errorf("%s: command must be an absolute path", h.Command)
+			}
+			if !allowed(h.Command) {
+				return fmt.Errorf("%s: %w", h.Command, ErrNotAllowed)
+			}
+			if h.Timeout != "" {
+				d, err := time.ParseDuration(h.Timeout)
+				if err != nil || d <= 0 || d > time.Hour {
+					return fmt.Errorf("%s: timeout must be between 1s and 1h", h.Command)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func Load() (Config, error) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	return load()
+}
+
+func load() (Config, error) {
+	data, err := os.ReadFile(configPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", configPath, err)
+	}
+	return c, nil
+}
+
+func Save(c Config) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := configPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, configPath)
+}
+
+// Scripts returns the hook scripts found in ScriptDir by event.
+func Scripts() map[string]string {
+	scripts := map[string]string{}
+	for _, event := range Events {
+		if h, ok := script(event); ok {
+			scripts[event] = h.Args[0]
+		}
+	}
+	return scripts
+}
+
+// script returns the hook for ScriptDir/<event>.sh if it exists. Scripts are
+// put there by whoever runs MiniMC, so they don't need to be allowlisted. A
+// failing pre-script cancels the action like a required hook.
+func script(event string) (Hook, bool) {
+	path, err := filepath.Abs(filepath.Join(ScriptDir, event+".sh"))
+	if err != nil {
+		return Hook{}, false
+	}
+	if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+		return Hook{}, false
+	}
+	return Hook{
+		Command:  "sh",
+		Args:     []string{path},
+		Required: strings.HasPrefix(event, "pre-"),
+		script:   true,
+	}, true
+}
+
+// Run executes the hook script and then the configured hooks of an event one
+// after another in dir, each as a "hook" job whose log holds the command's
+// output. Extra environment variables are passed along with MINIMC_EVENT. It
+// returns the error of the first failing required hook; other failures are
+// only logged.
+func Run(event, dir string, env map[string]string) error {
+	var hooks []Hook
+	if h, ok := script(event); ok {
+		hooks = append(hooks, h)
+	}
+	c, err := Load()
+	if err != nil {
+		log.Println("[e] hooks:", err)
+	}
+	hooks = append(hooks, c[event]...)
+
+	for _, h := range hooks {
+		job := jobs.New("hook")
+		err := run(h, event, dir, env, job)
+		job.Finish(err)
+		if err == nil {
+			continue
+		}
+		log.Printf("[e] %s hook %s failed: %v", event, h.name(), err)
+		if h.Required {
+			return fmt.Errorf("%s hook %s failed: %w", event, h.name(), err)
+		}
+	}
+	return nil
+}
+
+func run(h Hook, event, dir string, env map[string]string, job *jobs.Job) error {
+	// Re-checked on every run in case HOOK_ALLOWLIST changed since saving.
+	if !h.script && !allowed(h.Command) {
+		return ErrNotAllowed
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout())
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, h.Command, h.Args...)
+	cmd.Dir = dir
+	cmd.Env = append(environ(), "MINIMC_EVENT="+event)
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+
+	pr, pw := io.Pipe()
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	// Don't wait forever on children that keep the output open.
+	cmd.WaitDelay = 5 * time.Second
+
+	job.Log(fmt.Sprintf("%s: %s %s", event, h.Command, strings.Join(h.Args, " ")))
+	log.Printf("[i] running %s hook %s", event, h.name())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(pr)
+		for scanner.Scan() {
+			job.Log(scanner.Text())
+		}
+	}()
+
+	err := cmd.Run()
+	pw.Close()
+	<-done
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", h.timeout())
+	}
+	return err
+}
@@ -0,0 +1,26 @@
+package hooks
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnvironWithoutSecrets(t *testing.T) {
+	t.Setenv("password", "hunter2")
+	t.Setenv("STATUS_PAGE_PASSWORD", "key")
+	t.Setenv("MC_VERSION", "1.21.4")
+
+	kept := map[string]bool{}
+	for _, kv := range environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		kept[name] = true
+	}
+	for _, name := range secretEnv {
+		if kept[name] {
+			t.Errorf("%s is passed to hooks", name)
+		}
+	}
+	if !kept["MC_VERSION"] {
+		t.Error("MC_VERSION isn't passed to hooks")
+	}
+}
@@ -0,0 +1,201 @@
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const Default = "en"
+
+// catalogs maps a language to localized messages per stable error code.
+// English messages are only used when a handler didn't provide its own.
+var catalogs = map[string]map[string]string{
+	"en": {
+		"unauthorized":        "Login required",
+		"invalid_credentials": "Invalid username or password",
+		"invalid_json":        "The request body is not valid JSON",
+		"invalid_path":        "The path is invalid",
+		"missing_path":        "A path is required",
+		"missing_paths":       "Both 'from' and 'to' paths are required",
+		"file_not_found":      "File not found",
+		"directory_not_found": "Directory not found",
+		"is_directory":        "The path is a directory",
+		"forbidden":           "This action is not allowed",
+		"server_running":      "Stop the server first",
+		"server_not_running":  "The server is not running",
+	},
+	"nl": {
+		"unauthorized":            "Inloggen vereist",
+		"invalid_credentials":     "Ongeldige gebruikersnaam of wachtwoord",
+		"session_error":           "De sessie kon niet worden aangemaakt",
+		"invalid_json":            "De inhoud van het verzoek is geen geldige JSON",
+		"invalid_path":            "Het pad is ongeldig",
+		"invalid_from_path":       "Het bronpad is ongeldig",
+		"invalid_to_path":         "Het doelpad is ongeldig",
+		"invalid_destination":     "De bestemming is ongeldig",
+		"missing_path":            "Een pad is verplicht",
+		"missing_paths":           "Zowel 'from' als 'to' zijn verplicht",
+		"file_not_found":          "Bestand niet gevonden",
+		"directory_not_found":     "Map niet gevonden",
+		"source_not_found":        "Bronbestand niet gevonden",
+		"is_directory":            "Het pad is een map",
+		"forbidden":               "Deze actie is niet toegestaan",
+		"read_error":              "Het bestand kon niet worden gelezen",
+		"write_error":             "Het bestand kon niet worden geschreven",
+		"mkdir_error":             "De map kon niet worden aangemaakt",
+		"delete_error":            "Verwijderen is mislukt",
+		"move_error":              "Verplaatsen is mislukt",
+		"copy_error":              "Kopiëren is mislukt",
+		"open_error":              "Het bestand kon niet worden geopend",
+		"create_error":            "Het bestand kon niet worden aangemaakt",
+		"unsupported_format":      "Alleen .tar.gz en .tgz bestanden worden ondersteund",
+		"extraction_failed":       "Uitpakken is mislukt",
+		"job_not_found":           "Taak niet gevonden",
+		"backup_not_found":        "Back-up niet gevonden",
+		"backup_error":            "De back-up is mislukt",
+		"unknown_profile":         "Onbekend back-upprofiel",
+		"invalid_profiles":        "Ongeldige back-upprofielen",
+		"server_running":          "Stop eerst de server",
+		"server_not_running":      "De server draait niet",
+		"invalid_interval":        "Het interval moet minstens 1m zijn, bijvoorbeeld \"10m\"",
+		"shares_disabled":         "Logs delen is uitgeschakeld",
+		"share_not_found":         "Gedeelde link niet gevonden",
+		"share_error":             "De gedeelde link kon niet worden aangemaakt",
+		"invalid_duration":        "Een gedeelde link kan maximaal 24 uur geldig zijn",
+		"confirmation_required":   "Bevestiging vereist, stuur het verzoek opnieuw met \"confirm\": true",
+		"missing_online_mode":     "online_mode is verplicht",
+		"unknown_version":         "Geen geïnstalleerde versie gevonden, geef ?version= op",
+		"missing_folders":         "Geef de verweesde mappen op die verwijderd moeten worden",
+		"cleanup_failed":          "Opruimen is mislukt",
+		"world_not_found":         "Wereld niet gevonden",
+		"world_error":             "De wereld kon niet worden gelezen",
+		"worldborder_unavailable": "De wereldgrens kon niet worden opgevraagd",
+		"worldborder_error":       "De wereldgrens kon niet worden aangepast",
+		"schedule_not_found":      "Planning niet gevonden",
+		"schedule_error":          "De planning kon niet worden opgeslagen",
+		"invalid_schedule":        "Ongeldige planning",
+		"invalid_preferences":     "Ongeldige voorkeuren",
+		"invalid_window":          "Het venster moet tussen 1m en 24h liggen",
+		"invalid_limit":           "De limiet moet een positief getal zijn",
+		"locked_out":              "Te veel mislukte inlogpogingen, probeer het later opnieuw",
+		"status_page_disabled":    "De publieke statuspagina is uitgeschakeld",
+		"invalid_batch":           "Ongeldige batch",
+		"encode_error":            "De tekst kan niet in deze codering worden opgeslagen",
+		"config_history_disabled": "Configuratiegeschiedenis is uitgeschakeld",
+		"commit_not_found":        "Commit niet gevonden",
+		"revert_conflict":         "Het bestand is sindsdien gewijzigd en kan niet worden teruggezet",
+		"sync_not_found":          "Synchronisatiedoel niet gevonden",
+		"sync_running":            "Deze synchronisatie loopt al",
+		"invalid_sync_target":     "Ongeldig synchronisatiedoel",
+		"jar_not_found":           "Er is geen server.jar geïnstalleerd",
+		"invalid_policy":          "Onbekend beleid, kies drop, drop-oldest, coalesce of disconnect",
+		"command_blocked":         "Dit commando is niet toegestaan voor jouw rol",
+		"invalid_rules":           "Ongeldige consoleregels",
+		"stop_timeout":            "De server is niet op tijd gestopt",
+		"stop_failed":             "Stoppen is mislukt",
+		"restart_failed":          "Herstarten is mislukt",
+		"invalid_migration":       "Deze migratie wordt niet ondersteund",
+		"missing_version":         "Geef de doelversie op",
+		"not_an_upgrade":          "Deze versie is niet nieuwer dan de geïnstalleerde versie",
+		"preflight_failed":        "De controles voor de upgrade zijn niet geslaagd",
+		"invalid_launch_config":   "Ongeldige opstartinstellingen",
+		"not_a_pack":              "Geen datapack of resourcepack, pack.mcmeta ontbreekt",
+		"invalid_hooks":           "Ongeldige hooks",
+		"hook_not_allowed":        "Dit commando staat niet in HOOK_ALLOWLIST",
+		"notification_not_found":  "Meldingsbestemming niet gevonden",
+		"notification_error":      "De meldingsbestemming kon niet worden opgeslagen",
+		"invalid_notification":    "Ongeldige meldingsbestemming",
+		"invalid_filter":          "Ongeldig zoekpatroon",
+		"no_exit":                 "De server is nog niet gestopt sinds MiniMC is gestart",
+		"invalid_scope":           "De scope moet logs of files zijn",
+		"invalid_share_paths":     "Ongeldige padpatronen voor de gedeelde link",
+		"java_too_old":            "De Java-versie is te oud voor deze Minecraft-versie",
+		"java_unavailable":        "Java kan niet worden gestart, controleer JAVA_BIN",
+		"invalid_message":         "Ongeldig bericht",
+		"server_is_proxy":         "De server is een proxy en heeft geen werelden",
+		"no_download":             "Er is geen server.jar gedownload sinds MiniMC is gestart",
+		"export_error":            "De export is mislukt",
+		"missing_bundle":          "Upload de bundel als het formulierbestand \"bundle\"",
+		"invalid_bundle":          "Ongeldige instantiebundel",
+		"import_error":            "De import is mislukt",
+		"unknown_flavor":          "Onbekende serversoftware",
+		"versions_unavailable":    "De versies konden niet worden opgehaald",
+		"version_not_found":       "Deze versie bestaat niet",
+		"invalid_build":           "Ongeldig buildnummer",
+		"download_running":        "Er loopt al een download van de serverjar",
+		"update_rejected":         "De update is geweigerd",
+		"download_failed":         "De download is mislukt",
+		"no_rollback":             "Er is geen eerdere serverjar bewaard",
+		"version_change":          "Terugzetten wijzigt de Minecraft-versie",
+		"rollback_failed":         "Terugzetten is mislukt",
+		"upgrade_running":         "De werelden worden al bijgewerkt",
+		"gc_log_disabled":         "Het GC-logboek staat uit",
+		"action_pending":          "Er loopt al een aftelling voor stoppen of herstarten",
+		"aborted":                 "De actie is geannuleerd",
+		"no_pending_action":       "Er loopt geen aftelling",
+		"missing_project":         "Geef de plugin op",
+		"plugins_unsupported":     "Deze server kan geen plugins laden",
+		"unknown_repository":      "Onbekende pluginbron",
+		"plugin_not_found":        "Plugin niet gevonden",
+		"no_compatible_version":   "Geen geschikte versie van de plugin gevonden",
+		"repository_unavailable":  "De pluginbron is niet bereikbaar",
+		"install_failed":          "Installeren is mislukt",
+		"path_protected":          "Dit pad is beschermd voor jouw rol",
+		"invalid_file_rules":      "Ongeldige bestandsregels",
+		"session_not_found":       "Consolesessie niet gevonden",
+		"invalid_speed":           "Ongeldige afspeelsnelheid",
+		"invalid_max_gap":         "Ongeldige maximale pauze",
+		"player_stats_disabled":   "Spelersstatistieken staan uit",
+		"invalid_days":            "Ongeldig aantal dagen",
+		"invalid_hours":           "Ongeldig aantal uren",
+		"canary_running":          "Er loopt al een proefstart",
+		"canary_error":            "De proefstart kon niet worden uitgevoerd",
+		"plugin_not_installed":    "Deze plugin is niet geïnstalleerd",
+		"file_exists":             "Het bestand bestaat al",
+		"rename_failed":           "Hernoemen is mislukt",
+		"dependency_conflict":     "Een afhankelijkheid botst met een geïnstalleerde plugin",
+		"unresolved_dependencies": "Niet alle vereiste afhankelijkheden zijn te vinden",
+		"command_queue_full":      "Er wachten te veel commando's, probeer het later opnieuw",
+		"command_failed":          "Het commando kon niet naar de server worden gestuurd",
+		"rate_limited":            "Te veel verzoeken, probeer het later opnieuw",
+	},
+}
+
+// Negotiate picks the best supported language from an Accept-Language
+// header, falling back to English.
+func Negotiate(header string) string {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		lang := strings.ToLower(strings.SplitN(strings.TrimSpace(tag), "-", 2)[0])
+		if _, ok := catalogs[lang]; !ok {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		candidates = append(candidates, candidate{lang, q})
+	}
+
+	if len(candidates) == 0 {
+		return Default
+	}
+	sort.SliceStable(candidates, func(a, b int) bool { return candidates[a].q > candidates[b].q })
+	return candidates[0].lang
+}
+
+// Message returns the localized message for an error code.
+func Message(lang, code string) (string, bool) {
+	msg, ok := catalogs[lang][code]
+	return msg, ok
+}
@@ -0,0 +1,463 @@
+// Package instance moves a server between MiniMC hosts. An export bundles the
+// server's configuration, MiniMC's settings, the plugin list and optionally
+// the plugin jars and worlds into one .tar.gz that Import unpacks again.
+package instance
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"pkg.bijsven.nl/MiniMC/pkg"
+	"pkg.bijsven.nl/MiniMC/pkg/jobs"
+	"pkg.bijsven.nl/MiniMC/pkg/plugins"
+	"pkg.bijsven.nl/MiniMC/pkg/storage"
+)
+
+// Format is the bundle layout version. Bundles of a newer format are
+// rejected.
+const Format = 1
+
+const (
+	Dir = "minecraft"
+
+	infoName     = "instance.json"
+	settingsRoot = "minimc/"
+	serverRoot   = "minecraft/"
+)
+
+var ErrInvalidBundle = errors.New("invalid instance bundle")
+
+// Settings are MiniMC's own settings, relative to its working directory.
+// Stored ones go through pkg/storage, the others are plain files.
+var (
+	Settings = []string{
+		"launch.json",
+		"hooks.json",
+		"notifications.json",
+		"sync.json",
+		"console-rules.json",
+		"backups/profiles.json",
+	}
+	StoredSettings = []string{
+		"schedules.json",
+	}
+)
+
+// ConfigPatterns are the server files in a bundle, relative to the server
+// directory. config/ is copied as a whole, mods and plugins keep their
+// settings there.
+var ConfigPatterns = []string{
+	"*.properties",
+	"*.yml",
+	"*.yaml",
+	"*.toml",
+	"ops.json",
+	"whitelist.json",
+	"banned-players.json",
+	"banned-ips.json",
+	"plugins/*/*.yml",
+	"plugins/*/*.yaml",
+	"plugins/*/*.json",
+	"plugins/*/*.toml",
+	"plugins/*/*.conf",
+	"plugins/*/*.properties",
+}
+
+const configDir = "config"
+
+// Options select the optional parts of an export.
+type Options struct {
+	// Plugins adds the plugin and mod jars, not just their list.
+	Plugins bool `json:"plugins"`
+	Worlds  bool `json:"worlds"`
+}
+
+// Plugin is a jar in plugins/ or mods/.
+type Plugin struct {
+	File    string `json:"file"`
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// Info describes a bundle, it is stored as its first entry.
+type Info struct {
+	Format   int       `json:"format"`
+	Created  time.Time `json:"created"`
+	Flavor   string    `json:"flavor,omitempty"`
+	Version  string    `json:"version,omitempty"`
+	Build    int       `json:"build,omitempty"`
+	Plugins  []Plugin  `json:"plugins"`
+	Worlds   []string  `json:"worlds,omitempty"`
+	Settings []string  `json:"settings"`
+	Files    int       `json:"files"`
+	Options  Options   `json:"options"`
+}
+
+// entry is a file to bundle.
+type entry struct {
+	name string
+	path string
+	size int64
+}
+
+// Export writes a bundle of the instance to w.
+func Export(w io.Writer, opts Options, job *jobs.Job) (*Info, error) {
+	info := &Info{
+		Format:  Format,
+		Created: time.Now(),
+		Plugins: listPlugins(),
+		Options: opts,
+	}
+	if m, err := pkg.LoadManifest(); err == nil {
+		info.Flavor, info.Version, info.Build = m.Flavor, m.Version, m.Build
+	}
+
+	var entries []entry
+	add := func(name, path string) {
+		if fi, err := os.Stat(path); err == nil && fi.Mode().IsRegular() {
+			entries = append(entries, entry{name, path, fi.Size()})
+		}
+	}
+
+	for _, name := range Settings {
+		if _, err := os.Stat(name); err == nil {
+			add(settingsRoot+filepath.ToSlash(name), name)
+			info.Settings = append(info.Settings, name)
+		}
+	}
+	stored := map[string]json.RawMessage{}
+	for _, name := range StoredSettings {
+		var raw json.RawMessage
+		if err := storage.Get(name, &raw); err == nil {
+			stored[name] = raw
+			info.Settings = append(info.Settings, name)
+		}
+	}
+
+	for _, pattern := range ConfigPatterns {
+		matches, _ := filepath.Glob(filepath.Join(Dir, pattern))
+		for _, m := range matches {
+			if filepath.Base(m) == "manifest.json" {
+				continue
+			}
+			rel, _ := filepath.Rel(Dir, m)
+			add(serverRoot+filepath.ToSlash(rel), m)
+		}
+	}
+	dirs := []string{configDir}
+	if opts.Plugins {
+		for _, p := range info.Plugins {
+			add(serverRoot+p.File, filepath.Join(Dir, filepath.FromSlash(p.File)))
+		}
+	}
+	if opts.Worlds {
+		info.Worlds = listWorlds()
+		dirs = append(dirs, info.Worlds...)
+	}
+	for _, dir := range dirs {
+		filepath.WalkDir(filepath.Join(Dir, dir), func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || d.Name() == "session.lock" {
+				return nil
+			}
+			rel, _ := filepath.Rel(Dir, path)
+			add(serverRoot+filepath.ToSlash(rel), path)
+			return nil
+		})
+	}
+	info.Files = len(entries) + len(stored)
+
+	var total int64
+	for _, e := range entries {
+		total += e.size
+	}
+
+	gzw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gzw)
+
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeData(tw, infoName, data); err != nil {
+		return nil, err
+	}
+	for _, name := range StoredSettings {
+		if raw, ok := stored[name]; ok {
+			if err := writeData(tw, settingsRoot+name, raw); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var done int64
+	for _, e := range entries {
+		n, err := writeFile(tw, e)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", e.path, err)
+		}
+		done += n
+		job.Update(e.name, done, total)
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gzw.Close(); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func writeData(tw *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+func writeFile(tw *tar.Writer, e entry) (int64, error) {
+	f, err := os.Open(e.path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	header, err := tar.FileInfoHeader(fi, "")
+	if err != nil {
+		return 0, err
+	}
+	header.Name = e.name
+	if err := tw.WriteHeader(header); err != nil {
+		return 0, err
+	}
+	// The file may have grown since it was listed, copy what the header
+	// announced.
+	return io.CopyN(tw, f, header.Size)
+}
+
+// listPlugins returns the jars in plugins/ and mods/, with the name and
+// version from their descriptor when they have one.
+func listPlugins() []Plugin {
+	list := []Plugin{}
+	for _, dir := range []string{"plugins", "mods"} {
+		matches, _ := filepath.Glob(filepath.Join(Dir, dir, "*.jar"))
+		for _, jar := range matches {
+			p := Plugin{File: dir + "/" + filepath.Base(jar)}
+			if d, err := plugins.ReadDescriptor(jar); err == nil {
+				p.Name, p.Version = d.Name, d.Version
+			}
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// listWorlds returns the directories of the server directory that hold a
+// level.dat.
+func listWorlds() []string {
+	entries, _ := os.ReadDir(Dir)
+	var worlds []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(Dir, e.Name(), "level.dat")); err == nil {
+			worlds = append(worlds, e.Name())
+		}
+	}
+	sort.Strings(worlds)
+	return worlds
+}
+
+// Import unpacks a bundle over the current instance. Settings and config
+// files in the bundle replace the current ones, worlds in it replace the
+// world of the same name. Everything else is left alone.
+func Import(r io.Reader, job *jobs.Job) (*Info, error) {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	defer gzr.Close()
+	tr := tar.NewReader(gzr)
+
+	header, err := tr.Next()
+	if err != nil || header.Name != infoName {
+		return nil, fmt.Errorf("%w: %s must come first", ErrInvalidBundle, infoName)
+	}
+	var info Info
+	if err := json.NewDecoder(tr).Decode(&info); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	if info.Format < 1 || info.Format > Format {
+		return nil, fmt.Errorf("%w: format %d isn't supported, update MiniMC", ErrInvalidBundle, info.Format)
+	}
+
+	worlds := map[string]bool{}
+	for _, w := range info.Worlds {
+		worlds[w] = true
+	}
+	// Worlds are extracted next to the current ones and only swapped in once
+	// the whole bundle has been read, so a truncated or corrupt bundle leaves
+	// them alone.
+	staged := map[string]string{}
+	defer func() {
+		for _, dir := range staged {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	files := 0
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(header.Name, settingsRoot):
+			err = importSetting(strings.TrimPrefix(header.Name, settingsRoot), tr)
+		case strings.HasPrefix(header.Name, serverRoot):
+			rel := path.Clean(strings.TrimPrefix(header.Name, serverRoot))
+			if rel == "." || path.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, "../") {
+				return nil, fmt.Errorf("%w: unsafe path %s", ErrInvalidBundle, header.Name)
+			}
+			target := filepath.Join(Dir, filepath.FromSlash(rel))
+			if world, inWorld, _ := strings.Cut(rel, "/"); worlds[world] {
+				if inWorld == "" {
+					return nil, fmt.Errorf("%w: world %s is a file", ErrInvalidBundle, world)
+				}
+				if staged[world] == "" {
+					staged[world] = filepath.Join(Dir, fmt.Sprintf(".import-%s-%d", world, time.Now().UnixNano()))
+				}
+				target = filepath.Join(staged[world], filepath.FromSlash(inWorld))
+			}
+			err = writeAtomic(target, tr, os.FileMode(header.Mode).Perm())
+		default:
+			log.Println("[w] instance import: skipping unknown entry", header.Name)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", header.Name, err)
+		}
+		files++
+		job.Update(header.Name, int64(files), int64(info.Files))
+	}
+	if err := swapWorlds(staged); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// swapWorlds moves the extracted worlds in place of the current ones, which
+// replaces them as a whole so files that only exist in the current world
+// don't end up mixed in. The current worlds are kept until every world has
+// been swapped, and put back when one fails.
+func swapWorlds(staged map[string]string) error {
+	type swap struct{ path, old string }
+	var done []swap
+	undo := func() {
+		for i := len(done) - 1; i >= 0; i-- {
+			os.RemoveAll(done[i].path)
+			if done[i].old != "" {
+				os.Rename(done[i].old, done[i].path)
+			}
+		}
+	}
+
+	for world, dir := range staged {
+		s := swap{path: filepath.Join(Dir, world)}
+		if _, err := os.Lstat(s.path); err == nil {
+			s.old = dir + ".old"
+			if err := os.Rename(s.path, s.old); err != nil {
+				undo()
+				return err
+			}
+		}
+		if err := os.Rename(dir, s.path); err != nil {
+			if s.old != "" {
+				os.Rename(s.old, s.path)
+			}
+			undo()
+			return err
+		}
+		done = append(done, s)
+	}
+	for _, s := range done {
+		if s.old != "" {
+			os.RemoveAll(s.old)
+		}
+	}
+	return nil
+}
+
+func importSetting(name string, r io.Reader) error {
+	for _, s := range StoredSettings {
+		if name == s {
+			var raw json.RawMessage
+			if err := json.NewDecoder(r).Decode(&raw); err != nil {
+				return err
+			}
+			return storage.Put(name, raw)
+		}
+	}
+	for _, s := range Settings {
+		if name == filepath.ToSlash(s) {
+			return writeAtomic(s, r, 0644)
+		}
+	}
+	log.Println("[w] instance import: skipping unknown setting", name)
+	return nil
+}
+
+func writeAtomic(path string, r io.Reader, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if perm == 0 {
+		perm = 0644
+	}
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,91 @@
+package instance
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"pkg.bijsven.nl/MiniMC/pkg/jobs"
+)
+
+// bundle writes a bundle with the world "world" holding files.
+func bundle(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	add := func(name string, data []byte) {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write(data)
+	}
+	info, _ := json.Marshal(Info{Format: Format, Worlds: []string{"world"}, Files: len(files)})
+	add(infoName, info)
+	for name, data := range files {
+		add(serverRoot+"world/"+name, []byte(data))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// currentWorld runs the test in a scratch directory with a world holding
+// level.dat and old.dat.
+func currentWorld(t *testing.T) {
+	t.Helper()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	if err := os.MkdirAll(filepath.Join(Dir, "world"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"level.dat", "old.dat"} {
+		if err := os.WriteFile(filepath.Join(Dir, "world", name), []byte("current"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestImportReplacesWorld(t *testing.T) {
+	currentWorld(t)
+	data := bundle(t, map[string]string{"level.dat": "imported"})
+	if _, err := Import(bytes.NewReader(data), jobs.New("test")); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(Dir, "world", "level.dat")); string(b) != "imported" {
+		t.Errorf("level.dat holds %q, want the imported one", b)
+	}
+	if _, err := os.Stat(filepath.Join(Dir, "world", "old.dat")); !os.IsNotExist(err) {
+		t.Error("a file only in the current world survived the import")
+	}
+	if entries, _ := os.ReadDir(Dir); len(entries) != 1 {
+		t.Errorf("%d entries left in %s, want only the world", len(entries), Dir)
+	}
+}
+
+func TestImportTruncatedKeepsWorld(t *testing.T) {
+	currentWorld(t)
+	data := bundle(t, map[string]string{"level.dat": "imported"})
+	if _, err := Import(bytes.NewReader(data[:len(data)-20]), jobs.New("test")); err == nil {
+		t.Fatal("importing a truncated bundle succeeded")
+	}
+	for _, name := range []string{"level.dat", "old.dat"} {
+		if b, _ := os.ReadFile(filepath.Join(Dir, "world", name)); string(b) != "current" {
+			t.Errorf("%s holds %q after a failed import, want the current one", name, b)
+		}
+	}
+	if entries, _ := os.ReadDir(Dir); len(entries) != 1 {
+		t.Errorf("%d entries left in %s, want only the world", len(entries), Dir)
+	}
+}
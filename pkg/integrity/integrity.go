@@ -0,0 +1,242 @@
+package integrity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"pkg.bijsven.nl/MiniMC/pkg"
+)
+
+const statePath = "integrity.json"
+
+// Patterns are the files, relative to the server directory, whose changes
+// are reported. server.jar is checked against the manifest instead.
+var Patterns = []string{
+	"server.properties",
+	"bukkit.yml",
+	"spigot.yml",
+	"config/*.yml",
+	"plugins/*.jar",
+}
+
+const (
+	Modified = "modified"
+	Added    = "added"
+	Removed  = "removed"
+)
+
+// Change is a file that changed without MiniMC changing it.
+type Change struct {
+	Path string    `json:"path"`
+	Kind string    `json:"kind"`
+	Old  string    `json:"old_sha256,omitempty"`
+	New  string    `json:"new_sha256,omitempty"`
+	Time time.Time `json:"time"`
+}
+
+var (
+	mu sync.Mutex
+	// known maps tracked paths to their last accepted hash.
+	known  map[string]string
+	jarSum string
+)
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func scan(dir string) map[string]string {
+	sums := map[string]string{}
+	for _, pattern := range Patterns {
+		matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+		for _, m := range matches {
+			rel, err := filepath.Rel(dir, m)
+			if err != nil {
+				continue
+			}
+			if sum, err := hashFile(m); err == nil {
+				sums[filepath.ToSlash(rel)] = sum
+			}
+		}
+	}
+	return sums
+}
+
+func tracked(rel string) bool {
+	for _, pattern := range Patterns {
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// load must be called with mu held.
+func load() {
+	if known != nil {
+		return
+	}
+	known = map[string]string{}
+	data, err := os.ReadFile(statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		known = nil
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &known)
+	}
+	if err != nil {
+		log.Println("[w] integrity: ignoring unreadable state:", err)
+		known = nil
+	}
+}
+
+// save must be called with mu held.
+func save() {
+	data, err := json.MarshalIndent(known, "", "  ")
+	if err != nil {
+		return
+	}
+	tmp := statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Println("[e] integrity: could not save state:", err)
+		return
+	}
+	os.Rename(tmp, statePath)
+}
+
+// Accept records the current contents of paths as known, for changes made
+// through MiniMC itself.
+func Accept(dir string, paths ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	load()
+	if known == nil {
+		return
+	}
+
+	for _, rel := range paths {
+		if !tracked(rel) {
+			continue
+		}
+		if sum, err := hashFile(filepath.Join(dir, rel)); err == nil {
+			known[rel] = sum
+		} else {
+			delete(known, rel)
+		}
+	}
+	save()
+}
+
+// Rebaseline accepts the current state of the tracked configs, used after
+// the server process itself rewrote them. Jars are left alone, the server
+// doesn't replace those.
+func Rebaseline(dir string) {
+	sums := scan(dir)
+
+	mu.Lock()
+	defer mu.Unlock()
+	load()
+	if known == nil {
+		known = sums
+		save()
+		return
+	}
+	for p, sum := range sums {
+		if !isJar(p) {
+			known[p] = sum
+		}
+	}
+	for p := range known {
+		if _, ok := sums[p]; !ok && !isJar(p) {
+			delete(known, p)
+		}
+	}
+	save()
+}
+
+func isJar(rel string) bool {
+	return strings.HasSuffix(rel, ".jar")
+}
+
+// Check compares the tracked files and server.jar with the known state. The
+// first check without any saved state only records it.
+func Check(dir string) []Change {
+	sums := scan(dir)
+	now := time.Now()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	load()
+	if known == nil {
+		known = sums
+		save()
+		return nil
+	}
+
+	var changes []Change
+	for p, sum := range sums {
+		old, ok := known[p]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Path: p, Kind: Added, New: sum, Time: now})
+		case old != sum:
+			changes = append(changes, Change{Path: p, Kind: Modified, Old: old, New: sum, Time: now})
+		}
+	}
+	for p, old := range known {
+		if _, ok := sums[p]; !ok {
+			changes = append(changes, Change{Path: p, Kind: Removed, Old: old, Time: now})
+		}
+	}
+	if len(changes) > 0 {
+		// Report each change once.
+		known = sums
+		save()
+	}
+
+	if m, err := pkg.LoadManifest(); err == nil && m.SHA256 != "" {
+		if sum, err := hashFile(pkg.JarPath()); err == nil && sum != m.SHA256 && sum != jarSum {
+			changes = append(changes, Change{Path: "server.jar", Kind: Modified, Old: m.SHA256, New: sum, Time: now})
+			jarSum = sum
+		}
+	}
+
+	sort.Slice(changes, func(a, b int) bool { return changes[a].Path < changes[b].Path })
+	return changes
+}
+
+// Watch runs Check every interval and passes each change to fn. skip is
+// consulted before each check, e.g. to pause while the server is starting and
+// rewriting its configs. It blocks, so run it in a goroutine.
+func Watch(dir string, interval time.Duration, skip func() bool, fn func(Change)) {
+	for {
+		if !skip() {
+			for _, c := range Check(dir) {
+				fn(c)
+			}
+		}
+		time.Sleep(interval)
+	}
+}
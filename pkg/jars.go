@@ -0,0 +1,258 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoRollback is returned when there is no kept jar to roll back to.
+var ErrNoRollback = errors.New("nothing to roll back to")
+
+// KeptJar is a previously installed jar kept for a rollback.
+type KeptJar struct {
+	Version string    `json:"version"`
+	Build   int       `json:"build"`
+	Size    int64     `json:"size"`
+	Date    time.Time `json:"date"`
+	Current bool      `json:"current"`
+}
+
+// JarsDir holds copies of the last installed jars as
+// server-<version>-<build>.jar.
+func JarsDir() string {
+	return filepath.Join(mcDir, "jars")
+}
+
+func keptJarPath(version string, build int) string {
+	return filepath.Join(JarsDir(), fmt.Sprintf("server-%s-%d.jar", version, build))
+}
+
+// keepJars returns how many jars to keep from JAR_KEEP, 3 by default.
+func keepJars() int {
+	if v := os.Getenv("JAR_KEEP"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+		log.Printf("[w] ignoring invalid JAR_KEEP=%q", v)
+	}
+	return 3
+}
+
+// keepJar copies the installed server.jar of m into JarsDir and removes the
+// oldest copies beyond JAR_KEEP. Servers started from an installer's
+// argument file have no single jar to keep.
+func keepJar(m *Manifest) error {
+	if m.ArgsFile != "" {
+		return nil
+	}
+	if err := os.MkdirAll(JarsDir(), 0755); err != nil {
+		return err
+	}
+	path := keptJarPath(m.Version, m.Build)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := copyJar(JarPath(), path); err != nil {
+		return err
+	}
+	return pruneJars(path)
+}
+
+func copyJar(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := to + ".tmp"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, to)
+}
+
+// pruneJars removes the oldest kept jars beyond JAR_KEEP, never keep.
+func pruneJars(keep string) error {
+	jars, err := KeptJars()
+	if err != nil {
+		return err
+	}
+	for i := keepJars(); i < len(jars); i++ {
+		path := keptJarPath(jars[i].Version, jars[i].Build)
+		if path == keep {
+			continue
+		}
+		log.Println("[i] removing old jar", filepath.Base(path))
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// KeptJars lists the kept jars, newest first.
+func KeptJars() ([]KeptJar, error) {
+	entries, err := os.ReadDir(JarsDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return []KeptJar{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var current *Manifest
+	if m, err := LoadManifest(); err == nil {
+		current = m
+	}
+	jars := []KeptJar{}
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, "server-") || !strings.HasSuffix(name, ".jar") {
+			continue
+		}
+		// Versions may contain dashes themselves, like 1.21-pre1.
+		rest := strings.TrimSuffix(strings.TrimPrefix(name, "server-"), ".jar")
+		i := strings.LastIndex(rest, "-")
+		if i <= 0 {
+			continue
+		}
+		build, err := strconv.Atoi(rest[i+1:])
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		jar := KeptJar{
+			Version: rest[:i],
+			Build:   build,
+			Size:    info.Size(),
+			Date:    info.ModTime(),
+		}
+		jar.Current = current != nil && current.Version == jar.Version && current.Build == jar.Build
+		jars = append(jars, jar)
+	}
+	sort.Slice(jars, func(i, j int) bool {
+		return jars[i].Date.After(jars[j].Date)
+	})
+	return jars, nil
+}
+
+// RollbackTarget returns the install a rollback switches to: build of
+// version if given, otherwise the newest earlier install of the current
+// flavor whose jar is still kept.
+func RollbackTarget(version string, build int) (*InstallRecord, error) {
+	m, err := LoadManifest()
+	if err != nil {
+		return nil, err
+	}
+	if m.ArgsFile != "" {
+		return nil, fmt.Errorf("%w: %s servers are started from their installer's files, not a single jar", ErrNoRollback, m.Flavor)
+	}
+
+	for i := len(m.History) - 1; i >= 0; i-- {
+		r := m.History[i]
+		if r.Flavor != m.Flavor || (r.Version == m.Version && r.Build == m.Build) {
+			continue
+		}
+		if build != 0 && (r.Version != version || r.Build != build) {
+			continue
+		}
+		if _, err := os.Stat(keptJarPath(r.Version, r.Build)); err != nil {
+			continue
+		}
+		return &r, nil
+	}
+	if build != 0 {
+		return nil, fmt.Errorf("%w: no kept jar for %s build %d", ErrNoRollback, version, build)
+	}
+	return nil, fmt.Errorf("%w: no earlier %s jar is kept", ErrNoRollback, m.Flavor)
+}
+
+// Rollback puts the kept jar of target back in place. The build it replaces
+// is recorded as failed, so it isn't installed again on the next start.
+func Rollback(target *InstallRecord, reason string) (*Manifest, error) {
+	m, err := LoadManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	path := keptJarPath(target.Version, target.Build)
+	if target.SHA256 != "" {
+		sum, err := fileSHA256(path)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.EqualFold(sum, target.SHA256) {
+			return nil, fmt.Errorf("%w: kept jar %s doesn't match the recorded sha256", ErrChecksumMismatch, filepath.Base(path))
+		}
+	}
+
+	newPath := JarPath() + ".new"
+	defer os.Remove(newPath)
+	if err := copyJar(path, newPath); err != nil {
+		return nil, err
+	}
+	if _, err := swapJar(newPath); err != nil {
+		return nil, err
+	}
+
+	m.Failed = append(m.Failed, FailedInstall{
+		Version: m.Version,
+		Build:   m.Build,
+		Reason:  reason,
+		Date:    time.Now().Format(time.RFC3339),
+	})
+	m.Flavor = target.Flavor
+	m.Filename = target.Filename
+	m.Version = target.Version
+	m.Build = target.Build
+	m.Size = target.Size
+	m.SHA256 = target.SHA256
+	m.Java = RequiredJavaFor(target.Flavor, target.Version)
+	m.Download = target.Download
+	m.Date = time.Now().Format(time.RFC3339)
+	m.Provenance = target.Provenance
+	m.Trial = false
+
+	// Touch the jar so it counts as the newest when pruning.
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return m, m.write()
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
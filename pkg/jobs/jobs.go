@@ -0,0 +1,239 @@
+package jobs
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	Running Status = "running"
+	Done    Status = "done"
+	Failed  Status = "failed"
+)
+
+const (
+	maxFinished = 50
+	maxLogLines = 200
+
+	// updateInterval limits how often progress is published to subscribers.
+	updateInterval = 250 * time.Millisecond
+)
+
+type Snapshot struct {
+	ID          string     `json:"id"`
+	Kind        string     `json:"kind"`
+	Status      Status     `json:"status"`
+	Percent     float64    `json:"percent"`
+	CurrentFile string     `json:"current_file,omitempty"`
+	Bytes       int64      `json:"bytes"`
+	TotalBytes  int64      `json:"total_bytes,omitempty"`
+	BytesPerSec float64    `json:"bytes_per_sec"`
+	ETA         float64    `json:"eta_seconds,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	Log         []string   `json:"log,omitempty"`
+	Started     time.Time  `json:"started"`
+	Finished    *time.Time `json:"finished,omitempty"`
+}
+
+type Job struct {
+	mu          sync.Mutex
+	snap        Snapshot
+	subscribers []chan Snapshot
+	published   time.Time
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]*Job{}
+	nextID     int
+)
+
+// New registers a running job of the given kind, e.g. "download" or
+// "extract".
+func New(kind string) *Job {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	nextID++
+	j := &Job{snap: Snapshot{
+		ID:      strconv.Itoa(nextID),
+		Kind:    kind,
+		Status:  Running,
+		Started: time.Now(),
+	}}
+	registry[j.snap.ID] = j
+	prune()
+	return j
+}
+
+// prune drops the oldest finished jobs. Must be called with registryMu held.
+func prune() {
+	var finished []*Job
+	for _, j := range registry {
+		if s := j.Snapshot(); s.Status != Running {
+			finished = append(finished, j)
+		}
+	}
+	if len(finished) <= maxFinished {
+		return
+	}
+	sort.Slice(finished, func(a, b int) bool {
+		return finished[a].Snapshot().Started.Before(finished[b].Snapshot().Started)
+	})
+	for _, j := range finished[:len(finished)-maxFinished] {
+		delete(registry, j.Snapshot().ID)
+	}
+}
+
+func Get(id string) *Job {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	return registry[id]
+}
+
+// Latest returns the most recently started job of one of kinds, or nil.
+func Latest(kinds ...string) *Job {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	var latest *Job
+	var started time.Time
+	for _, j := range registry {
+		s := j.Snapshot()
+		for _, kind := range kinds {
+			if s.Kind == kind && (latest == nil || s.Started.After(started)) {
+				latest, started = j, s.Started
+			}
+		}
+	}
+	return latest
+}
+
+func List() []Snapshot {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	list := make([]Snapshot, 0, len(registry))
+	for _, j := range registry {
+		list = append(list, j.Snapshot())
+	}
+	sort.Slice(list, func(a, b int) bool {
+		return list[a].Started.Before(list[b].Started)
+	})
+	return list
+}
+
+func (j *Job) ID() string {
+	return j.Snapshot().ID
+}
+
+func (j *Job) Snapshot() Snapshot {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.snap
+}
+
+// Update records progress. total may be 0 when the size isn't known up front.
+func (j *Job) Update(currentFile string, bytes, total int64) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.snap.CurrentFile = currentFile
+	j.snap.Bytes = bytes
+	j.snap.TotalBytes = total
+	if total > 0 {
+		j.snap.Percent = float64(bytes) / float64(total) * 100
+	}
+	if elapsed := time.Since(j.snap.Started).Seconds(); elapsed > 0 {
+		j.snap.BytesPerSec = float64(bytes) / elapsed
+	}
+	j.snap.ETA = 0
+	if total > 0 && j.snap.BytesPerSec > 0 {
+		j.snap.ETA = float64(total-bytes) / j.snap.BytesPerSec
+	}
+
+	if (total <= 0 || bytes < total) && time.Since(j.published) < updateInterval {
+		return
+	}
+	j.published = time.Now()
+	j.publish()
+}
+
+// Log appends a line of output, e.g. from an external command, keeping the
+// last maxLogLines.
+func (j *Job) Log(line string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.snap.Log = append(j.snap.Log, line)
+	if len(j.snap.Log) > maxLogLines {
+		j.snap.Log = append([]string(nil), j.snap.Log[len(j.snap.Log)-maxLogLines:]...)
+	}
+	j.publish()
+}
+
+// Finish marks the job as done, or failed when err is not nil, and closes all
+// subscriber channels.
+func (j *Job) Finish(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	j.snap.Finished = &now
+	if err != nil {
+		j.snap.Status = Failed
+		j.snap.Error = err.Error()
+	} else {
+		j.snap.Status = Done
+		j.snap.Percent = 100
+	}
+	j.publish()
+
+	for _, ch := range j.subscribers {
+		close(ch)
+	}
+	j.subscribers = nil
+}
+
+// Subscribe returns a channel receiving progress updates. The channel is
+// closed once the job finishes; a finished job returns an already closed
+// channel.
+func (j *Job) Subscribe() <-chan Snapshot {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	ch := make(chan Snapshot, 16)
+	if j.snap.Status != Running {
+		close(ch)
+		return ch
+	}
+	j.subscribers = append(j.subscribers, ch)
+	return ch
+}
+
+func (j *Job) Unsubscribe(ch <-chan Snapshot) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for i, sub := range j.subscribers {
+		if sub == ch {
+			close(sub)
+			j.subscribers = append(j.subscribers[:i], j.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// publish must be called with j.mu held.
+func (j *Job) publish() {
+	for _, ch := range j.subscribers {
+		select {
+		case ch <- j.snap:
+		default:
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package pkg
+
+import (
+	"errors"
+	"regexp"
+)
+
+const (
+	MaxFilterContext = 10
+	maxFilterPattern = 256
+)
+
+// LineFilter passes only lines matching a pattern plus up to context lines
+// around each match, like grep -C. A "--" line separates groups that aren't
+// adjacent.
+type LineFilter struct {
+	re      *regexp.Regexp
+	context int
+	before  []string
+	after   int
+	sent    bool
+	gap     bool
+}
+
+func NewLineFilter(pattern string, context int) (*LineFilter, error) {
+	if len(pattern) > maxFilterPattern {
+		return nil, errors.New("pattern is too long")
+	}
+	if context < 0 || context > MaxFilterContext {
+		return nil, errors.New("context must be between 0 and 10")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &LineFilter{re: re, context: context}, nil
+}
+
+// Filter returns the lines to send for line, which may be none.
+func (f *LineFilter) Filter(line string) []string {
+	if f.re.MatchString(line) {
+		var out []string
+		if f.sent && f.gap {
+			out = append(out, "--")
+		}
+		out = append(out, f.before...)
+		out = append(out, line)
+		f.before = f.before[:0]
+		f.after = f.context
+		f.sent, f.gap = true, false
+		return out
+	}
+
+	if f.after > 0 {
+		f.after--
+		return []string{line}
+	}
+
+	if f.context == 0 {
+		f.gap = true
+		return nil
+	}
+	if len(f.before) == f.context {
+		f.before = append(f.before[:0], f.before[1:]...)
+		f.gap = true
+	}
+	f.before = append(f.before, line)
+	return nil
+}
@@ -1,29 +1,187 @@
 package pkg
 
 import (
+	"fmt"
 	"io"
 	"log"
 	"os"
 	"sync"
+
+	"pkg.bijsven.nl/MiniMC/pkg/metrics"
 )
 
 type sessionWriter struct{}
 
 var logFile *os.File
 
+// Policies for subscribers that can't keep up with the log.
+const (
+	// PolicyDrop drops new lines while the subscriber's buffer is full.
+	PolicyDrop = "drop"
+	// PolicyDropOldest discards the oldest buffered line to make room.
+	PolicyDropOldest = "drop-oldest"
+	// PolicyCoalesce drops new lines like PolicyDrop, but tells the
+	// subscriber how many it missed once there is room again.
+	PolicyCoalesce = "coalesce"
+	// PolicyDisconnect closes the channel of a subscriber that fell behind.
+	PolicyDisconnect = "disconnect"
+)
+
+const subscriberBuffer = 100
+
+type subscriber struct {
+	ch      chan string
+	policy  string
+	skipped int
+}
+
 var (
 	sessionMu   sync.Mutex
 	sessionLogs []string
-	subscribers []chan string
+	subscribers []*subscriber
+
+	dropped     = map[string]uint64{}
+	disconnects uint64
 )
 
+func init() {
+	metrics.Gauge("minimc_log_subscribers", "Number of connected log subscribers.", func() float64 {
+		sessionMu.Lock()
+		defer sessionMu.Unlock()
+		return float64(len(subscribers))
+	})
+	metrics.Register(metrics.Family{
+		Name: "minimc_log_dropped_lines_total",
+		Help: "Log lines not delivered to slow subscribers, by backpressure policy.",
+		Type: "counter",
+		Collect: func() []metrics.Sample {
+			sessionMu.Lock()
+			defer sessionMu.Unlock()
+			samples := []metrics.Sample{}
+			for policy, n := range dropped {
+				samples = append(samples, metrics.Sample{
+					Labels: map[string]string{"policy": policy},
+					Value:  float64(n),
+				})
+			}
+			return samples
+		},
+	})
+	metrics.Register(metrics.Family{
+		Name: "minimc_log_subscriber_disconnects_total",
+		Help: "Slow log subscribers disconnected by the disconnect policy.",
+		Type: "counter",
+		Collect: func() []metrics.Sample {
+			sessionMu.Lock()
+			defer sessionMu.Unlock()
+			return []metrics.Sample{{Value: float64(disconnects)}}
+		},
+	})
+}
+
+// ValidPolicy reports whether policy is one of the backpressure policies.
+func ValidPolicy(policy string) bool {
+	switch policy {
+	case PolicyDrop, PolicyDropOldest, PolicyCoalesce, PolicyDisconnect:
+		return true
+	}
+	return false
+}
+
+// DefaultPolicy is LOG_SUBSCRIBER_POLICY, or coalesce when it isn't set.
+func DefaultPolicy() string {
+	if p := os.Getenv("LOG_SUBSCRIBER_POLICY"); ValidPolicy(p) {
+		return p
+	}
+	return PolicyCoalesce
+}
+
+// Subscribe returns a channel receiving every log line, using the default
+// backpressure policy.
 func Subscribe() <-chan string {
-	ch := make(chan string, 100)
+	return SubscribeWithPolicy(DefaultPolicy())
+}
+
+// SubscribeWithPolicy is like Subscribe with an explicit policy for when the
+// subscriber falls behind. With PolicyDisconnect the channel is closed.
+func SubscribeWithPolicy(policy string) <-chan string {
+	if !ValidPolicy(policy) {
+		policy = DefaultPolicy()
+	}
+	sub := &subscriber{ch: make(chan string, subscriberBuffer), policy: policy}
 	sessionMu.Lock()
-	subscribers = append(subscribers, ch)
+	subscribers = append(subscribers, sub)
 	sessionMu.Unlock()
-	return ch
+	return sub.ch
+}
+
+func Unsubscribe(ch <-chan string) {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	for i, sub := range subscribers {
+		if sub.ch == ch {
+			subscribers = append(subscribers[:i], subscribers[i+1:]...)
+			return
+		}
+	}
 }
+
+// deliver passes msg to sub according to its policy. It reports false when
+// sub has to be disconnected. Must be called with sessionMu held, which makes
+// this the only sender, so the buffer can only drain while we look at it.
+func (sub *subscriber) deliver(msg string) bool {
+	switch sub.policy {
+	case PolicyDropOldest:
+		for {
+			select {
+			case sub.ch <- msg:
+				return true
+			default:
+			}
+			select {
+			case <-sub.ch:
+				dropped[sub.policy]++
+			default:
+			}
+		}
+
+	case PolicyCoalesce:
+		if sub.skipped > 0 {
+			if len(sub.ch) > cap(sub.ch)-2 {
+				sub.skipped++
+				dropped[sub.policy]++
+				return true
+			}
+			sub.ch <- fmt.Sprintf("[w] %d log lines skipped, the connection is too slow\n", sub.skipped)
+			sub.skipped = 0
+		}
+		select {
+		case sub.ch <- msg:
+		default:
+			sub.skipped++
+			dropped[sub.policy]++
+		}
+		return true
+
+	case PolicyDisconnect:
+		select {
+		case sub.ch <- msg:
+			return true
+		default:
+			dropped[sub.policy]++
+			disconnects++
+			return false
+		}
+	}
+
+	select {
+	case sub.ch <- msg:
+	default:
+		dropped[sub.policy]++
+	}
+	return true
+}
+
 func GetSessionLogs() []string {
 	sessionMu.Lock()
 	defer sessionMu.Unlock()
@@ -48,12 +206,25 @@ func (sessionWriter) Write(p []byte) (n int, err error) {
 	msg := string(p)
 	sessionMu.Lock()
 	sessionLogs = append(sessionLogs, msg)
+	remaining := subscribers[:0]
 	for _, sub := range subscribers {
-		select {
-		case sub <- msg:
-		default:
+		if sub.deliver(msg) {
+			remaining = append(remaining, sub)
+		} else {
+			close(sub.ch)
 		}
 	}
+	subscribers = remaining
 	sessionMu.Unlock()
 	return len(p), nil
 }
+
+// OpenAccessLog returns a logger writing to access.log. It is kept separate
+// from the session log so API traffic doesn't flood the console stream.
+func OpenAccessLog() *log.Logger {
+	f, err := os.OpenFile("access.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		log.Fatalln("[e] Could not open access log file:", err)
+	}
+	return log.New(f, "", 0)
+}
@@ -0,0 +1,212 @@
+package pkg
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const maxHistory = 20
+
+// Manifest describes the installed server jar. It is stored as
+// minecraft/manifest.json.
+type Manifest struct {
+	Flavor   string          `json:"flavor"`
+	Filename string          `json:"filename"`
+	Version  string          `json:"version"`
+	Build    int             `json:"build"`
+	Size     int64           `json:"size"`
+	SHA256   string          `json:"sha256,omitempty"`
+	Java     int             `json:"java,omitempty"`
+	Download string          `json:"download"`
+	Date     string          `json:"date"`
+	History  []InstallRecord `json:"history,omitempty"`
+	// ArgsFile is the JVM argument file the server is started with instead
+	// of server.jar, relative to the server directory. Forge and NeoForge
+	// installers generate one.
+	ArgsFile string `json:"args_file,omitempty"`
+
+	Provenance *Provenance `json:"provenance,omitempty"`
+
+	// Pin is a build of Version set by hand that is installed instead of
+	// the latest one, like MC_BUILD.
+	Pin int `json:"pin,omitempty"`
+
+	// Trial is set while a freshly swapped-in jar hasn't completed its
+	// first start yet.
+	Trial  bool            `json:"trial,omitempty"`
+	Failed []FailedInstall `json:"failed,omitempty"`
+}
+
+type InstallRecord struct {
+	Flavor   string `json:"flavor"`
+	Filename string `json:"filename,omitempty"`
+	Version  string `json:"version"`
+	Build    int    `json:"build"`
+	Size     int64  `json:"size,omitempty"`
+	SHA256   string `json:"sha256,omitempty"`
+	Download string `json:"download,omitempty"`
+	Date     string `json:"date"`
+
+	Provenance *Provenance `json:"provenance,omitempty"`
+}
+
+type FailedInstall struct {
+	Version string `json:"version"`
+	Build   int    `json:"build"`
+	Reason  string `json:"reason"`
+	Date    string `json:"date"`
+}
+
+func ManifestPath() string {
+	return filepath.Join(mcDir, "manifest.json")
+}
+
+// LoadManifest reads the manifest. The returned error wraps os.ErrNotExist
+// when nothing has been installed yet.
+func LoadManifest() (*Manifest, error) {
+	f, err := os.Open(ManifestPath())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var m Manifest
+	if err := json.NewDecoder(f).Decode(&m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if m.Flavor == "" {
+		// Manifests written before flavors were tracked are always Paper.
+		m.Flavor = "paper"
+	}
+	return &m, nil
+}
+
+func (m *Manifest) Validate() error {
+	if m.Version == "" {
+		return errors.New("manifest: missing version")
+	}
+	if m.Build <= 0 {
+		return errors.New("manifest: missing build")
+	}
+	if m.Filename == "" {
+		return errors.New("manifest: missing filename")
+	}
+	if m.SHA256 != "" {
+		if b, err := hex.DecodeString(m.SHA256); err != nil || len(b) != 32 {
+			return errors.New("manifest: invalid sha256")
+		}
+	}
+	return nil
+}
+
+func (m *Manifest) Save() error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+
+	tmp := ManifestPath() + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, ManifestPath())
+}
+
+// Record appends the current install to the history.
+func (m *Manifest) Record() {
+	m.History = append(m.History, InstallRecord{
+		Flavor:   m.Flavor,
+		Filename: m.Filename,
+		Version:  m.Version,
+		Build:    m.Build,
+		Size:     m.Size,
+		SHA256:   m.SHA256,
+		Download: m.Download,
+		Date:     m.Date,
+
+		Provenance: m.Provenance,
+	})
+	if len(m.History) > maxHistory {
+		m.History = m.History[len(m.History)-maxHistory:]
+	}
+}
+
+// HasFailed reports whether a build was reverted before because it didn't
+// start.
+func (m *Manifest) HasFailed(version string, build int) bool {
+	for _, f := range m.Failed {
+		if f.Version == version && f.Build == build {
+			return true
+		}
+	}
+	return false
+}
+
+// PinnedBuild returns the build to install instead of the latest one of
+// version: MC_BUILD, or the pin in manifest.json when it is for version. It
+// returns 0 when nothing is pinned.
+func PinnedBuild(version string) (int, error) {
+	if v := os.Getenv("MC_BUILD"); v != "" {
+		build, err := strconv.Atoi(v)
+		if err != nil || build <= 0 {
+			return 0, fmt.Errorf("invalid MC_BUILD=%q, use a build number", v)
+		}
+		return build, nil
+	}
+	m, err := LoadManifest()
+	if err != nil || m.Pin == 0 || m.Version != version {
+		return 0, nil
+	}
+	return m.Pin, nil
+}
+
+// JavaBin returns the java executable, from JAVA_BIN or "java" from PATH.
+func JavaBin() string {
+	if bin := os.Getenv("JAVA_BIN"); bin != "" {
+		return bin
+	}
+	return "java"
+}
+
+// RequiredJava returns the minimum Java major version for a Minecraft
+// version.
+func RequiredJava(version string) int {
+	parts := strings.Split(version, ".")
+	if len(parts) < 2 || parts[0] != "1" {
+		return 21
+	}
+	minor, _ := strconv.Atoi(parts[1])
+	patch := 0
+	if len(parts) > 2 {
+		patch, _ = strconv.Atoi(strings.SplitN(parts[2], "-", 2)[0])
+	}
+
+	switch {
+	case minor > 20 || (minor == 20 && patch >= 5):
+		return 21
+	case minor >= 18:
+		return 17
+	case minor == 17:
+		return 16
+	default:
+		return 8
+	}
+}
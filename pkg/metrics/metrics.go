@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type Sample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// Family is a group of samples sharing a name, exposed in the Prometheus text
+// format. Collect is called on every scrape.
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Collect func() []Sample
+}
+
+var (
+	mu       sync.Mutex
+	families []Family
+)
+
+func Register(f Family) {
+	mu.Lock()
+	families = append(families, f)
+	mu.Unlock()
+}
+
+// Write renders all registered families in the Prometheus text exposition
+// format.
+func Write(w io.Writer) error {
+	mu.Lock()
+	list := make([]Family, len(families))
+	copy(list, families)
+	mu.Unlock()
+
+	sort.Slice(list, func(a, b int) bool { return list[a].Name < list[b].Name })
+
+	for _, f := range list {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.Name, f.Help, f.Name, f.Type); err != nil {
+			return err
+		}
+		for _, s := range f.Collect() {
+			if _, err := fmt.Fprintf(w, "%s%s %g\n", f.Name, formatLabels(s.Labels), s.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		v := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(labels[k])
+		parts[i] = fmt.Sprintf(`%s="%s"`, k, v)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// Gauge is a convenience for families with a single unlabeled value.
+func Gauge(name, help string, value func() float64) {
+	Register(Family{
+		Name: name,
+		Help: help,
+		Type: "gauge",
+		Collect: func() []Sample {
+			return []Sample{{Value: value()}}
+		},
+	})
+}
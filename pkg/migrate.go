@@ -0,0 +1,72 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+
+	"pkg.bijsven.nl/MiniMC/pkg/jobs"
+)
+
+var ErrUnsupportedMigration = errors.New("unsupported flavor migration")
+
+// migrationNotes lists the supported flavor conversions with what changes for
+// the server. Purpur and Folia are Paper forks and Paper runs Spigot plugins,
+// so these are the only pairs that keep worlds and plugins working. Vanilla
+// has no plugins to keep.
+var migrationNotes = map[string]map[string][]string{
+	"paper": {
+		"purpur": {
+			"Purpur is a fork of Paper, all Paper and Spigot plugins keep working.",
+			"purpur.yml is created on first start, Paper's config files are kept.",
+		},
+		"folia": {
+			"Folia ticks regions of the world on separate threads. Plugins only load when their plugin.yml declares folia-supported: true, most Paper and Spigot plugins don't.",
+			"Folia keeps Paper's config files and worlds.",
+		},
+	},
+	"folia": {
+		"paper": {
+			"Paper ticks the whole server on one thread again, plugins that work on Folia keep working.",
+			"Plugins that didn't declare folia-supported load again.",
+		},
+	},
+	"purpur": {
+		"paper": {
+			"Plugins that use the Purpur API stop working on Paper.",
+			"purpur.yml is left in place but no longer used, gameplay tweaks configured there are lost.",
+		},
+	},
+	"vanilla": {
+		"paper": {
+			"Paper loads vanilla worlds, but moves the Nether and the End out of world into world_nether and world_the_end on first start. Going back to vanilla needs them moved back by hand.",
+			"Paper fixes several vanilla exploits and redstone quirks that some farms rely on.",
+		},
+	},
+	"spigot": {
+		"paper": {
+			"Paper runs Spigot and Bukkit plugins unchanged.",
+			"Paper generates config/paper-global.yml and paper-world-defaults.yml on first start, spigot.yml and bukkit.yml are still read.",
+			"Paper fixes several vanilla exploits and redstone quirks that some farms rely on.",
+		},
+	},
+}
+
+// MigrationNotes returns the notes for converting from one flavor to another,
+// or ErrUnsupportedMigration.
+func MigrationNotes(from, to string) ([]string, error) {
+	notes, ok := migrationNotes[from][to]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s to %s", ErrUnsupportedMigration, from, to)
+	}
+	return notes, nil
+}
+
+// Migrate installs the latest build of the target flavor for version,
+// replacing the current jar. The old jar is kept the same way as after an
+// update, so a build that fails to start is reverted.
+func Migrate(to, version string, job *jobs.Job) error {
+	if _, err := GetProvider(to); err != nil {
+		return fmt.Errorf("%w: unknown flavor %s", ErrUnsupportedMigration, to)
+	}
+	return logRejected(getJar(to, version, 0, true, job))
+}
@@ -0,0 +1,337 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	path          = "notifications.json"
+	maxDeliveries = 50
+)
+
+const (
+	TypeWebhook  = "webhook"
+	TypeDiscord  = "discord"
+	TypeTelegram = "telegram"
+)
+
+var ErrNotFound = errors.New("notification destination not found")
+
+var client = http.Client{Timeout: 10 * time.Second}
+
+// Destination receives notifications. Webhooks get the Message as JSON,
+// Discord webhooks and Telegram chats a formatted text message.
+type Destination struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Type   string   `json:"type"`
+	URL    string   `json:"url,omitempty"`
+	Token  string   `json:"token,omitempty"`
+	ChatID string   `json:"chat_id,omitempty"`
+	Events []string `json:"events,omitempty"`
+}
+
+type Message struct {
+	Event string    `json:"event"`
+	Title string    `json:"title"`
+	Text  string    `json:"text"`
+	Time  time.Time `json:"time"`
+}
+
+// Delivery is one attempt to send a message to a destination.
+type Delivery struct {
+	Time      time.Time `json:"time"`
+	Event     string    `json:"event"`
+	Status    int       `json:"status,omitempty"`
+	LatencyMS int64     `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+	OK        bool      `json:"ok"`
+}
+
+var (
+	mu           sync.Mutex
+	destinations []*Destination
+	loaded       bool
+
+	deliveryMu sync.Mutex
+	deliveries = map[string][]Delivery{}
+)
+
+func (d *Destination) validate() error {
+	if d.Name == "" {
+		return errors.New("name is required")
+	}
+	switch d.Type {
+	case TypeWebhook, TypeDiscord:
+		u, err := url.Parse(d.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("url must be an http(s) URL")
+		}
+	case TypeTelegram:
+		if d.Token == "" || d.ChatID == "" {
+			return errors.New("token and chat_id are required for telegram")
+		}
+	default:
+		return errors.New("type must be webhook, discord or telegram")
+	}
+	return nil
+}
+
+func (d *Destination) wants(event string) bool {
+	if len(d.Events) == 0 || event == "test" {
+		return true
+	}
+	for _, e := range d.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Masked returns d without its secrets, for listing.
+func (d Destination) Masked() Destination {
+	if d.Token != "" {
+		d.Token = "***"
+	}
+	if d.Type == TypeDiscord && d.URL != "" {
+		// The webhook URL itself is the secret.
+		if i := strings.LastIndex(d.URL, "/"); i > 0 {
+			d.URL = d.URL[:i] + "/***"
+		}
+	}
+	return d
+}
+
+// load must be called with mu held.
+func load() error {
+	if loaded {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		loaded = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &destinations); err != nil {
+		return err
+	}
+	loaded = true
+	return nil
+}
+
+// save must be called with mu held.
+func save() error {
+	data, err := json.MarshalIndent(destinations, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func List() ([]Destination, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := load(); err != nil {
+		return nil, err
+	}
+	list := make([]Destination, len(destinations))
+	for i, d := range destinations {
+		list[i] = *d
+	}
+	return list, nil
+}
+
+func Get(id string) (*Destination, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := load(); err != nil {
+		return nil, err
+	}
+	for _, d := range destinations {
+		if d.ID == id {
+			dest := *d
+			return &dest, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func Add(d Destination) (*Destination, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := load(); err != nil {
+		return nil, err
+	}
+	if err := d.validate(); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 6)
+	rand.Read(buf)
+	d.ID = hex.EncodeToString(buf)
+
+	destinations = append(destinations, &d)
+	if err := save(); err != nil {
+		destinations = destinations[:len(destinations)-1]
+		return nil, err
+	}
+	return &d, nil
+}
+
+// Validate reports whether d could be added, without adding it.
+func Validate(d Destination) error {
+	return d.validate()
+}
+
+func Delete(id string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := load(); err != nil {
+		return err
+	}
+	for i, d := range destinations {
+		if d.ID == id {
+			destinations = append(destinations[:i], destinations[i+1:]...)
+			deliveryMu.Lock()
+			delete(deliveries, id)
+			deliveryMu.Unlock()
+			return save()
+		}
+	}
+	return ErrNotFound
+}
+
+// Send delivers msg to every destination subscribed to its event in the
+// background.
+func Send(msg Message) {
+	if msg.Time.IsZero() {
+		msg.Time = time.Now()
+	}
+	list, err := List()
+	if err != nil {
+		log.Println("[e] notifications:", err)
+		return
+	}
+	for i := range list {
+		if list[i].wants(msg.Event) {
+			go Deliver(list[i], msg)
+		}
+	}
+}
+
+// Test sends a test message to destination id and returns the delivery.
+func Test(id string) (*Delivery, error) {
+	d, err := Get(id)
+	if err != nil {
+		return nil, err
+	}
+	delivery := Deliver(*d, Message{
+		Event: "test",
+		Title: "MiniMC test notification",
+		Text:  "If you can read this, notifications to " + d.Name + " work.",
+		Time:  time.Now(),
+	})
+	return &delivery, nil
+}
+
+// Deliveries returns the recent deliveries to destination id, newest first.
+func Deliveries(id string) []Delivery {
+	deliveryMu.Lock()
+	defer deliveryMu.Unlock()
+
+	list := deliveries[id]
+	result := make([]Delivery, len(list))
+	for i, d := range list {
+		result[len(list)-1-i] = d
+	}
+	return result
+}
+
+// Deliver sends msg to d and records the outcome in its delivery history.
+func Deliver(d Destination, msg Message) Delivery {
+	start := time.Now()
+	status, err := post(d, msg)
+
+	delivery := Delivery{
+		Time:      start,
+		Event:     msg.Event,
+		Status:    status,
+		LatencyMS: time.Since(start).Milliseconds(),
+		OK:        err == nil,
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+		log.Printf("[w] notification to %s failed: %v", d.Name, err)
+	}
+
+	deliveryMu.Lock()
+	list := append(deliveries[d.ID], delivery)
+	if len(list) > maxDeliveries {
+		list = list[len(list)-maxDeliveries:]
+	}
+	deliveries[d.ID] = list
+	deliveryMu.Unlock()
+	return delivery
+}
+
+func post(d Destination, msg Message) (int, error) {
+	target := d.URL
+	var payload interface{} = msg
+	text := msg.Title
+	if msg.Text != "" {
+		text += "\n" + msg.Text
+	}
+
+	switch d.Type {
+	case TypeDiscord:
+		payload = map[string]string{"content": "**" + msg.Title + "**\n" + msg.Text}
+	case TypeTelegram:
+		target = "https://api.telegram.org/bot" + d.Token + "/sendMessage"
+		payload = map[string]string{"chat_id": d.ChatID, "text": text}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Post(target, "application/json", bytes.NewReader(body))
+	if err != nil {
+		if d.Token != "" {
+			// Don't leak the bot token through the URL in the error.
+			return 0, errors.New(strings.ReplaceAll(err.Error(), d.Token, "***"))
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return resp.StatusCode, nil
+}
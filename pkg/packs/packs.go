@@ -0,0 +1,261 @@
+package packs
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"pkg.bijsven.nl/MiniMC/pkg"
+)
+
+const (
+	TypeDatapack     = "datapack"
+	TypeResourcePack = "resourcepack"
+)
+
+var ErrNoMcmeta = errors.New("pack.mcmeta not found")
+
+type formatSince struct {
+	version string
+	format  int
+}
+
+// Pack formats per Minecraft version, oldest first. Each entry applies from
+// its version up to the next one.
+var (
+	dataFormats = []formatSince{
+		{"1.13", 4}, {"1.15", 5}, {"1.16.2", 6}, {"1.17", 7}, {"1.18", 8},
+		{"1.18.2", 9}, {"1.19", 10}, {"1.19.4", 12}, {"1.20", 15}, {"1.20.2", 18},
+		{"1.20.3", 26}, {"1.20.5", 41}, {"1.21", 48}, {"1.21.2", 57}, {"1.21.4", 61},
+		{"1.21.5", 71}, {"1.21.6", 80}, {"1.21.7", 81},
+	}
+	resourceFormats = []formatSince{
+		{"1.13", 4}, {"1.15", 5}, {"1.16.2", 6}, {"1.17", 7}, {"1.18", 8},
+		{"1.19", 9}, {"1.19.3", 12}, {"1.19.4", 13}, {"1.20", 15}, {"1.20.2", 18},
+		{"1.20.3", 22}, {"1.20.5", 32}, {"1.21", 34}, {"1.21.2", 42}, {"1.21.4", 46},
+		{"1.21.5", 55}, {"1.21.6", 63}, {"1.21.7", 64},
+	}
+)
+
+// lastKnownVersion is the newest version the tables above cover; formats of
+// anything newer are unknown.
+const lastKnownVersion = "1.21.8"
+
+// ExpectedFormat returns the pack format of a Minecraft version, or 0 when
+// it isn't known.
+func ExpectedFormat(packType, version string) int {
+	table := dataFormats
+	if packType == TypeResourcePack {
+		table = resourceFormats
+	}
+	if pkg.CompareVersions(version, lastKnownVersion) > 0 {
+		return 0
+	}
+
+	format := 0
+	for _, f := range table {
+		if pkg.CompareVersions(version, f.version) >= 0 {
+			format = f.format
+		}
+	}
+	return format
+}
+
+type Result struct {
+	Path        string   `json:"path"`
+	Type        string   `json:"type"`
+	Description string   `json:"description,omitempty"`
+	Format      int      `json:"pack_format"`
+	Supported   []int    `json:"supported_formats,omitempty"`
+	Version     string   `json:"minecraft_version,omitempty"`
+	Expected    int      `json:"expected_format,omitempty"`
+	Compatible  bool     `json:"compatible"`
+	Problems    []string `json:"problems"`
+}
+
+type mcmeta struct {
+	Pack struct {
+		Format      int             `json:"pack_format"`
+		Description json.RawMessage `json:"description"`
+		Supported   json.RawMessage `json:"supported_formats"`
+		MinFormat   json.RawMessage `json:"min_format"`
+		MaxFormat   json.RawMessage `json:"max_format"`
+	} `json:"pack"`
+}
+
+// Validate reads pack.mcmeta from a pack zip or directory and checks its
+// format against the Minecraft version. An empty version skips that check.
+func Validate(path, version string) (*Result, error) {
+	data, hasData, hasAssets, err := readPack(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Result{Path: path, Version: version, Problems: []string{}}
+	switch {
+	case hasData:
+		r.Type = TypeDatapack
+	case hasAssets:
+		r.Type = TypeResourcePack
+	case strings.Contains(filepath.ToSlash(path), "/datapacks/"):
+		r.Type = TypeDatapack
+		r.Problems = append(r.Problems, "datapack has no data/ folder at its root, it may be nested one folder too deep")
+	default:
+		r.Type = TypeResourcePack
+		r.Problems = append(r.Problems, "pack has neither data/ nor assets/ at its root, it may be nested one folder too deep")
+	}
+
+	var meta mcmeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		r.Problems = append(r.Problems, "pack.mcmeta is not valid JSON: "+err.Error())
+		return r, nil
+	}
+	r.Format = meta.Pack.Format
+	if s, ok := formatRange(meta.Pack.Supported); ok {
+		r.Supported = s
+	} else if lo, ok := majorFormat(meta.Pack.MinFormat); ok {
+		hi, _ := majorFormat(meta.Pack.MaxFormat)
+		r.Supported = []int{lo, max(lo, hi)}
+	}
+	if r.Format == 0 && r.Supported != nil {
+		r.Format = r.Supported[1]
+	}
+	var desc string
+	if json.Unmarshal(meta.Pack.Description, &desc) == nil {
+		r.Description = desc
+	}
+	if r.Format == 0 {
+		r.Problems = append(r.Problems, "pack.mcmeta has no pack_format")
+	}
+
+	if version != "" && r.Format != 0 {
+		r.Expected = ExpectedFormat(r.Type, version)
+		switch {
+		case r.Expected == 0:
+			// Newer than MiniMC knows about, can't tell.
+		case r.Supported != nil && r.Expected >= r.Supported[0] && r.Expected <= r.Supported[1]:
+		case r.Format == r.Expected:
+		case r.Format < r.Expected:
+			r.Problems = append(r.Problems, fmt.Sprintf("pack_format %d is made for an older version, %s expects %d", r.Format, version, r.Expected))
+		default:
+			r.Problems = append(r.Problems, fmt.Sprintf("pack_format %d is made for a newer version, %s expects %d", r.Format, version, r.Expected))
+		}
+	}
+
+	r.Compatible = len(r.Problems) == 0
+	return r, nil
+}
+
+// formatRange parses supported_formats, which is a number, [min, max] or
+// {"min_inclusive": min, "max_inclusive": max}.
+func formatRange(raw json.RawMessage) ([]int, bool) {
+	if len(raw) == 0 {
+		return nil, false
+	}
+	var n int
+	if json.Unmarshal(raw, &n) == nil {
+		return []int{n, n}, true
+	}
+	var pair []int
+	if json.Unmarshal(raw, &pair) == nil && len(pair) == 2 {
+		return pair, true
+	}
+	var obj struct {
+		Min int `json:"min_inclusive"`
+		Max int `json:"max_inclusive"`
+	}
+	if json.Unmarshal(raw, &obj) == nil && obj.Max != 0 {
+		return []int{obj.Min, obj.Max}, true
+	}
+	return nil, false
+}
+
+// majorFormat parses min_format/max_format, a number or [major, minor].
+func majorFormat(raw json.RawMessage) (int, bool) {
+	if len(raw) == 0 {
+		return 0, false
+	}
+	var n int
+	if json.Unmarshal(raw, &n) == nil {
+		return n, true
+	}
+	var pair []int
+	if json.Unmarshal(raw, &pair) == nil && len(pair) > 0 {
+		return pair[0], true
+	}
+	return 0, false
+}
+
+func readPack(path string) (mcmetaData []byte, hasData, hasAssets bool, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false, false, err
+	}
+
+	if info.IsDir() {
+		data, err := os.ReadFile(filepath.Join(path, "pack.mcmeta"))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, false, false, ErrNoMcmeta
+		}
+		_, dataErr := os.Stat(filepath.Join(path, "data"))
+		_, assetsErr := os.Stat(filepath.Join(path, "assets"))
+		return data, dataErr == nil, assetsErr == nil, err
+	}
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, false, false, err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		switch {
+		case f.Name == "pack.mcmeta":
+			rc, err := f.Open()
+			if err != nil {
+				return nil, false, false, err
+			}
+			mcmetaData, err = io.ReadAll(io.LimitReader(rc, 1<<20))
+			rc.Close()
+			if err != nil {
+				return nil, false, false, err
+			}
+		case strings.HasPrefix(f.Name, "data/"):
+			hasData = true
+		case strings.HasPrefix(f.Name, "assets/"):
+			hasAssets = true
+		}
+	}
+	if mcmetaData == nil {
+		return nil, false, false, ErrNoMcmeta
+	}
+	return mcmetaData, hasData, hasAssets, nil
+}
+
+// Datapacks validates the datapacks of every world in dir.
+func Datapacks(dir, version string) ([]Result, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*", "datapacks", "*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+
+	results := []Result{}
+	for _, path := range matches {
+		r, err := Validate(path, version)
+		if err != nil {
+			r = &Result{Path: path, Type: TypeDatapack, Problems: []string{err.Error()}}
+		}
+		if rel, err := filepath.Rel(dir, path); err == nil {
+			r.Path = filepath.ToSlash(rel)
+		}
+		results = append(results, *r)
+	}
+	return results, nil
+}
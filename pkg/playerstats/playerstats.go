@@ -0,0 +1,386 @@
+// Package playerstats keeps the join and leave events of players and turns
+// them into concurrency curves and retention figures.
+package playerstats
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"pkg.bijsven.nl/MiniMC/pkg/storage"
+)
+
+const path = "players.log"
+
+// Event is a player joining or leaving.
+type Event struct {
+	Time   time.Time `json:"time"`
+	Player string    `json:"player"`
+	Join   bool      `json:"join"`
+}
+
+var (
+	mu        sync.Mutex
+	events    []Event
+	retention time.Duration
+	opened    bool
+)
+
+// Open drops events older than keep from players.log and loads the rest. It
+// must be called before the server starts.
+func Open(keep time.Duration) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	cutoff := time.Now().Add(-keep)
+	recent := func(data []byte) (Event, bool) {
+		var e Event
+		return e, json.Unmarshal(data, &e) == nil && e.Time.After(cutoff)
+	}
+
+	err := storage.Prune(path, func(data []byte) bool {
+		_, ok := recent(data)
+		return ok
+	})
+	if err != nil {
+		return err
+	}
+	err = storage.Scan(path, func(data []byte) error {
+		if e, ok := recent(data); ok {
+			events = append(events, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	retention = keep
+	opened = true
+
+	// Players still online when MiniMC went down left unnoticed, end their
+	// sessions at the last thing that was logged.
+	if len(events) == 0 {
+		return nil
+	}
+	last := events[len(events)-1].Time
+	online := map[string]bool{}
+	for _, e := range events {
+		online[e.Player] = e.Join
+	}
+	for player, joined := range online {
+		if joined {
+			e := Event{Time: last, Player: player}
+			events = append(events, e)
+			if err := storage.Append(path, e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Add stores an event. Events older than the retention are dropped from
+// memory; the log is compacted on the next start.
+func Add(e Event) {
+	mu.Lock()
+	defer mu.Unlock()
+	if !opened {
+		return
+	}
+
+	events = append(events, e)
+	cutoff := e.Time.Add(-retention)
+	drop := 0
+	for drop < len(events) && events[drop].Time.Before(cutoff) {
+		drop++
+	}
+	if drop > 0 {
+		events = append(events[:0], events[drop:]...)
+	}
+
+	if err := storage.Append(path, e); err != nil {
+		log.Println("[e] playerstats:", err)
+	}
+}
+
+// Retention returns how long events are kept.
+func Retention() time.Duration {
+	mu.Lock()
+	defer mu.Unlock()
+	return retention
+}
+
+// Point is the concurrency in one hour or day: the average number of players
+// online and the most at once.
+type Point struct {
+	Time    time.Time `json:"time"`
+	Average float64   `json:"avg"`
+	Peak    int       `json:"peak"`
+}
+
+// Day describes the players of one day.
+type Day struct {
+	Point
+	Date     string  `json:"date"`
+	Unique   int     `json:"unique"`
+	New      int     `json:"new"`
+	Joins    int     `json:"joins"`
+	Playtime float64 `json:"playtime_hours"`
+}
+
+// HourOfDay is the average number of players online at an hour of the day,
+// to find the peak hours.
+type HourOfDay struct {
+	Hour    int     `json:"hour"`
+	Average float64 `json:"avg"`
+}
+
+// RetentionStats tells how many players come back. Returning players joined
+// again a day or more after their first join. Returned1d, 7d and 30d are the
+// share of the players first seen at least that long ago who joined again
+// that long or longer after their first join. Players first seen before the
+// oldest kept event count as new on their first join after it.
+type RetentionStats struct {
+	Players     int     `json:"players"`
+	Returning   int     `json:"returning"`
+	Returned1d  float64 `json:"returned_1d"`
+	Returned7d  float64 `json:"returned_7d"`
+	Returned30d float64 `json:"returned_30d"`
+}
+
+// Summary aggregates the player events of a period.
+type Summary struct {
+	From       time.Time      `json:"from"`
+	To         time.Time      `json:"to"`
+	Peak       Peak           `json:"peak"`
+	Daily      []Day          `json:"daily"`
+	Hourly     []Point        `json:"hourly"`
+	HoursOfDay []HourOfDay    `json:"hours_of_day"`
+	Retention  RetentionStats `json:"retention"`
+}
+
+// Peak is the most players online at once, in the hour or day starting at
+// Time.
+type Peak struct {
+	Players int       `json:"players"`
+	Time    time.Time `json:"time"`
+}
+
+// session is the time a player was online.
+type session struct {
+	player     string
+	start, end time.Time
+}
+
+// sessions pairs joins with leaves. A join without a leave before the next
+// join ends there, one without any leave is still online at now.
+func sessions(list []Event, now time.Time) []session {
+	open := map[string]time.Time{}
+	var out []session
+	for _, e := range list {
+		start, online := open[e.Player]
+		if online {
+			out = append(out, session{e.Player, start, e.Time})
+			delete(open, e.Player)
+		}
+		if e.Join {
+			open[e.Player] = e.Time
+		}
+	}
+	for player, start := range open {
+		out = append(out, session{player, start, now})
+	}
+	return out
+}
+
+// Summarize aggregates the last days, in loc, with an hourly curve for the
+// last hours.
+func Summarize(days, hours int, loc *time.Location) Summary {
+	mu.Lock()
+	list := append([]Event(nil), events...)
+	mu.Unlock()
+
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	from := today.AddDate(0, 0, 1-days)
+	hourFrom := now.Truncate(time.Hour).Add(time.Duration(1-hours) * time.Hour)
+	all := sessions(list, now)
+
+	s := Summary{
+		From:       from,
+		To:         now,
+		Daily:      make([]Day, days),
+		Hourly:     curve(all, hourFrom, hours, func(t time.Time) time.Time { return t.Add(time.Hour) }, now),
+		HoursOfDay: make([]HourOfDay, 24),
+	}
+
+	dayPoints := curve(all, from, days, func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }, now)
+	firstSeen := map[string]time.Time{}
+	for _, e := range list {
+		if _, ok := firstSeen[e.Player]; !ok && e.Join {
+			firstSeen[e.Player] = e.Time
+		}
+	}
+	for i := range s.Daily {
+		start := from.AddDate(0, 0, i)
+		end := start.AddDate(0, 0, 1)
+		d := &s.Daily[i]
+		d.Point = dayPoints[i]
+		d.Date = start.Format("2006-01-02")
+
+		unique := map[string]bool{}
+		for _, e := range list {
+			if e.Join && !e.Time.Before(start) && e.Time.Before(end) {
+				d.Joins++
+				unique[e.Player] = true
+			}
+		}
+		for _, ses := range all {
+			if overlap := clip(ses, start, end); overlap > 0 {
+				unique[ses.player] = true
+				d.Playtime += overlap.Hours()
+			}
+		}
+		d.Unique = len(unique)
+		d.Playtime = round(d.Playtime)
+		for player := range unique {
+			if first := firstSeen[player]; !first.Before(start) && first.Before(end) {
+				d.New++
+			}
+		}
+		if d.Peak > s.Peak.Players {
+			s.Peak = Peak{Players: d.Peak, Time: start}
+		}
+	}
+
+	// Average per hour of the day over the whole period.
+	var seconds [24]float64
+	for h := 0; h < days*24; h++ {
+		start := from.Add(time.Duration(h) * time.Hour)
+		if start.After(now) {
+			break
+		}
+		end := start.Add(time.Hour)
+		for _, ses := range all {
+			seconds[start.Hour()] += clip(ses, start, end).Seconds()
+		}
+	}
+	for h := range s.HoursOfDay {
+		s.HoursOfDay[h] = HourOfDay{Hour: h, Average: round(seconds[h] / 3600 / float64(days))}
+	}
+	// The hourly curve narrows the peak down when it falls within it.
+	for _, p := range s.Hourly {
+		if p.Peak >= s.Peak.Players && p.Peak > 0 {
+			s.Peak = Peak{Players: p.Peak, Time: p.Time}
+		}
+	}
+
+	s.Retention = retentionStats(list, firstSeen, now)
+	return s
+}
+
+// curve computes n points, from each start to next(start), of the players
+// online at the same time.
+func curve(all []session, start time.Time, n int, next func(time.Time) time.Time, now time.Time) []Point {
+	type delta struct {
+		at time.Time
+		d  int
+	}
+	var deltas []delta
+	for _, ses := range all {
+		deltas = append(deltas, delta{ses.start, 1}, delta{ses.end, -1})
+	}
+	// Leaves go first, a player who rejoined isn't counted twice.
+	sort.Slice(deltas, func(i, j int) bool {
+		if deltas[i].at.Equal(deltas[j].at) {
+			return deltas[i].d < deltas[j].d
+		}
+		return deltas[i].at.Before(deltas[j].at)
+	})
+
+	points := make([]Point, n)
+	online, i := 0, 0
+	for p := range points {
+		end := next(start)
+		for i < len(deltas) && !deltas[i].at.After(start) {
+			online += deltas[i].d
+			i++
+		}
+		peak, total := online, 0.0
+		for _, ses := range all {
+			total += clip(ses, start, end).Seconds()
+		}
+		for j := i; j < len(deltas) && deltas[j].at.Before(end); j++ {
+			online += deltas[j].d
+			peak = max(peak, online)
+			i = j + 1
+		}
+		length := end.Sub(start)
+		if end.After(now) {
+			length = now.Sub(start)
+		}
+		points[p] = Point{Time: start, Peak: peak}
+		if length > 0 {
+			points[p].Average = round(total / length.Seconds())
+		}
+		start = end
+	}
+	return points
+}
+
+// clip returns how long ses overlaps start to end.
+func clip(ses session, start, end time.Time) time.Duration {
+	if ses.start.After(start) {
+		start = ses.start
+	}
+	if ses.end.Before(end) {
+		end = ses.end
+	}
+	if end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+func retentionStats(list []Event, firstSeen map[string]time.Time, now time.Time) RetentionStats {
+	lastJoin := map[string]time.Time{}
+	for _, e := range list {
+		if e.Join {
+			lastJoin[e.Player] = e.Time
+		}
+	}
+
+	r := RetentionStats{Players: len(firstSeen)}
+	returned := func(after time.Duration) float64 {
+		eligible, back := 0, 0
+		for player, first := range firstSeen {
+			if now.Sub(first) < after {
+				continue
+			}
+			eligible++
+			if lastJoin[player].Sub(first) >= after {
+				back++
+			}
+		}
+		if eligible == 0 {
+			return 0
+		}
+		return round(float64(back) / float64(eligible))
+	}
+	for player, first := range firstSeen {
+		if lastJoin[player].Sub(first) >= 24*time.Hour {
+			r.Returning++
+		}
+	}
+	r.Returned1d = returned(24 * time.Hour)
+	r.Returned7d = returned(7 * 24 * time.Hour)
+	r.Returned30d = returned(30 * 24 * time.Hour)
+	return r
+}
+
+func round(f float64) float64 {
+	return float64(int64(f*100+0.5)) / 100
+}
@@ -0,0 +1,199 @@
+package plugins
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The repository APIs, variables so tests can point them elsewhere.
+var (
+	modrinthAPI = "https://api.modrinth.com/v2"
+	hangarAPI   = "https://hangar.papermc.io/api/v1"
+)
+
+const userAgent = "MiniMC (https://github.com/bijsven/MiniMC)"
+
+var client = http.Client{Timeout: 10 * time.Second}
+
+// Dependency is a plugin that an installed plugin declares but that isn't
+// present in plugins/.
+type Dependency struct {
+	Name       string   `json:"name"`
+	Required   bool     `json:"required"`
+	RequiredBy []string `json:"required_by"`
+	Source     *Source  `json:"source,omitempty"`
+	// Reason tells why a dependency in an install plan can't be installed.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Source is where a missing dependency can be installed from.
+type Source struct {
+	Repository string `json:"repository"`
+	Slug       string `json:"slug"`
+	Title      string `json:"title"`
+	URL        string `json:"url"`
+}
+
+// Installed returns the descriptors of all plugin jars in plugins/.
+func Installed() ([]Descriptor, error) {
+	matches, err := filepath.Glob(filepath.Join(Dir, "*.jar"))
+	if err != nil {
+		return nil, err
+	}
+	var list []Descriptor
+	for _, jar := range matches {
+		if d, err := ReadDescriptor(jar); err == nil {
+			list = append(list, *d)
+		}
+	}
+	return list, nil
+}
+
+// MissingDependencies lists the dependencies of the given descriptors that
+// aren't installed, required ones first. Soft dependencies are included so
+// they can be offered, but never block an install.
+func MissingDependencies(descriptors []Descriptor) ([]Dependency, error) {
+	installed, err := Installed()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	present := map[string]bool{}
+	for _, d := range append(installed, descriptors...) {
+		present[strings.ToLower(d.Name)] = true
+	}
+
+	byName := map[string]*Dependency{}
+	add := func(name, by string, required bool) {
+		key := strings.ToLower(name)
+		if present[key] {
+			return
+		}
+		dep, ok := byName[key]
+		if !ok {
+			dep = &Dependency{Name: name}
+			byName[key] = dep
+		}
+		dep.Required = dep.Required || required
+		dep.RequiredBy = append(dep.RequiredBy, by)
+	}
+	for _, d := range descriptors {
+		for _, name := range d.Depend {
+			add(name, d.Name, true)
+		}
+		for _, name := range d.SoftDepend {
+			add(name, d.Name, false)
+		}
+	}
+
+	list := make([]Dependency, 0, len(byName))
+	for _, dep := range byName {
+		list = append(list, *dep)
+	}
+	sort.Slice(list, func(a, b int) bool {
+		if list[a].Required != list[b].Required {
+			return list[a].Required
+		}
+		return list[a].Name < list[b].Name
+	})
+	return list, nil
+}
+
+// Resolve fills in a Source for every dependency that can be found on
+// Modrinth or Hangar, matching on the exact plugin name. Lookups that fail
+// leave Source empty; the plan then shows the dependency as manual.
+func Resolve(deps []Dependency) {
+	for i := range deps {
+		deps[i].Source = findSource(deps[i].Name)
+	}
+}
+
+// findSource looks a plugin up by name on Modrinth, then Hangar.
+func findSource(name string) *Source {
+	if src, err := findModrinth(name); err == nil && src != nil {
+		return src
+	}
+	if src, err := findHangar(name); err == nil && src != nil {
+		return src
+	}
+	return nil
+}
+
+func getJSON(u string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return os.ErrNotExist
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("bad status: " + resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func findModrinth(name string) (*Source, error) {
+	var result struct {
+		Hits []struct {
+			Slug  string `json:"slug"`
+			Title string `json:"title"`
+		} `json:"hits"`
+	}
+	query := url.Values{
+		"query":  {name},
+		"facets": {`[["project_type:plugin"]]`},
+		"limit":  {"10"},
+	}
+	if err := getJSON(modrinthAPI+"/search?"+query.Encode(), &result); err != nil {
+		return nil, err
+	}
+	for _, hit := range result.Hits {
+		if strings.EqualFold(hit.Title, name) || strings.EqualFold(hit.Slug, name) {
+			return &Source{
+				Repository: "modrinth",
+				Slug:       hit.Slug,
+				Title:      hit.Title,
+				URL:        "https://modrinth.com/plugin/" + hit.Slug,
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+func findHangar(name string) (*Source, error) {
+	var project struct {
+		Name      string `json:"name"`
+		Namespace struct {
+			Owner string `json:"owner"`
+			Slug  string `json:"slug"`
+		} `json:"namespace"`
+	}
+	err := getJSON(hangarAPI+"/projects/"+url.PathEscape(name), &project)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Source{
+		Repository: "hangar",
+		Slug:       project.Namespace.Slug,
+		Title:      project.Name,
+		URL:        "https://hangar.papermc.io/" + project.Namespace.Owner + "/" + project.Namespace.Slug,
+	}, nil
+}
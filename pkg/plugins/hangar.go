@@ -0,0 +1,149 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// hangarPages bounds how many pages of versions are searched for one that
+// fits, newest first.
+const (
+	hangarPageSize = 25
+	hangarPages    = 4
+)
+
+type hangarVersion struct {
+	Name    string `json:"name"`
+	Channel struct {
+		Name string `json:"name"`
+	} `json:"channel"`
+	Downloads map[string]struct {
+		FileInfo *struct {
+			Name       string `json:"name"`
+			SHA256Hash string `json:"sha256Hash"`
+		} `json:"fileInfo"`
+		DownloadURL string `json:"downloadUrl"`
+	} `json:"downloads"`
+	PlatformDependencies map[string][]string `json:"platformDependencies"`
+}
+
+// hangarPlatform returns the Hangar platform for the loaders of a server.
+func hangarPlatform(loaders []string) (string, bool) {
+	for _, l := range loaders {
+		switch l {
+		case "paper":
+			return "PAPER", true
+		case "velocity":
+			return "VELOCITY", true
+		case "bungeecord", "waterfall":
+			return "WATERFALL", true
+		}
+	}
+	return "", false
+}
+
+// resolveHangar picks the newest version of a Hangar project, by slug, that
+// lists gameVersion for the server's platform. Versions in the Release
+// channel are preferred over snapshots and betas. Versions hosted elsewhere
+// can't be checked against a hash and are skipped.
+func resolveHangar(project, version, gameVersion string, loaders []string) (*Release, error) {
+	platform, ok := hangarPlatform(loaders)
+	if !ok {
+		return nil, fmt.Errorf("%w: Hangar has no plugins for %v", ErrNoCompatible, loaders)
+	}
+
+	var info struct {
+		Name      string `json:"name"`
+		Namespace struct {
+			Slug string `json:"slug"`
+		} `json:"namespace"`
+	}
+	err := getJSON(hangarAPI+"/projects/"+url.PathEscape(project), &info)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: no Hangar project %q", ErrProjectNotFound, project)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var pick *hangarVersion
+	for page := 0; page < hangarPages && pick == nil; page++ {
+		query := url.Values{
+			"platform": {platform},
+			"limit":    {strconv.Itoa(hangarPageSize)},
+			"offset":   {strconv.Itoa(page * hangarPageSize)},
+		}
+		var result struct {
+			Result []hangarVersion `json:"result"`
+		}
+		if err := getJSON(hangarAPI+"/projects/"+url.PathEscape(info.Namespace.Slug)+"/versions?"+query.Encode(), &result); err != nil {
+			return nil, err
+		}
+
+		for i, v := range result.Result {
+			d, ok := v.Downloads[platform]
+			if !ok || d.FileInfo == nil || d.DownloadURL == "" {
+				continue
+			}
+			if version != "" {
+				if v.Name == version {
+					pick = &result.Result[i]
+					break
+				}
+				continue
+			}
+			if gameVersion != "" && !supportsVersion(v.PlatformDependencies[platform], gameVersion) {
+				continue
+			}
+			if strings.EqualFold(v.Channel.Name, "release") {
+				pick = &result.Result[i]
+				break
+			}
+			if pick == nil {
+				pick = &result.Result[i]
+			}
+		}
+		if len(result.Result) < hangarPageSize {
+			break
+		}
+	}
+	if pick == nil {
+		if version != "" {
+			return nil, fmt.Errorf("%w: %s has no version %s on Hangar", ErrNoCompatible, info.Name, version)
+		}
+		return nil, fmt.Errorf("%w: %s has no version for Minecraft %s on %s", ErrNoCompatible, info.Name, gameVersion, strings.ToLower(platform))
+	}
+	if gameVersion != "" && !supportsVersion(pick.PlatformDependencies[platform], gameVersion) {
+		return nil, fmt.Errorf("%w: %s %s doesn't support Minecraft %s", ErrNoCompatible, info.Name, pick.Name, gameVersion)
+	}
+
+	d := pick.Downloads[platform]
+	return &Release{
+		Repository: "hangar",
+		Project:    info.Namespace.Slug,
+		Title:      info.Name,
+		Version:    pick.Name,
+		Filename:   d.FileInfo.Name,
+		URL:        d.DownloadURL,
+		SHA256:     d.FileInfo.SHA256Hash,
+	}, nil
+}
+
+// supportsVersion reports whether gameVersion is listed in versions. Hangar
+// lists the exact versions a plugin supports; an entry like "1.21.x" covers
+// every patch of 1.21.
+func supportsVersion(versions []string, gameVersion string) bool {
+	for _, v := range versions {
+		if v == gameVersion {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(v, ".x"); ok && (gameVersion == prefix || strings.HasPrefix(gameVersion, prefix+".")) {
+			return true
+		}
+	}
+	return false
+}
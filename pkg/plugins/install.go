@@ -0,0 +1,148 @@
+package plugins
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"pkg.bijsven.nl/MiniMC/pkg/storage"
+)
+
+const recordsPath = "plugins.json"
+
+var (
+	ErrUnknownRepository = errors.New("unknown plugin repository")
+	ErrProjectNotFound   = errors.New("plugin not found")
+	ErrNoCompatible      = errors.New("no compatible version")
+	ErrUnsupportedLoader = errors.New("this server can't load plugins")
+)
+
+// Release is a downloadable version of a plugin, resolved for the installed
+// Minecraft version and loader.
+type Release struct {
+	Repository string `json:"repository"`
+	Project    string `json:"project"`
+	Title      string `json:"title,omitempty"`
+	Version    string `json:"version"`
+	VersionID  string `json:"version_id,omitempty"`
+	Filename   string `json:"filename"`
+	URL        string `json:"url"`
+	// SHA512 or SHA256 is the hash the repository publishes.
+	SHA512 string `json:"sha512,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// Record is where an installed plugin jar came from, kept to check it for
+// updates later.
+type Record struct {
+	Release
+	Name      string    `json:"name"`
+	File      string    `json:"file"`
+	Installed time.Time `json:"installed"`
+}
+
+// Resolver finds the release of project to install for a Minecraft version
+// and the loaders the server accepts plugins for, or a specific version.
+type Resolver func(project, version, gameVersion string, loaders []string) (*Release, error)
+
+var resolvers = map[string]Resolver{
+	"modrinth": resolveModrinth,
+	"hangar":   resolveHangar,
+}
+
+// FindRelease finds the release of project in repository.
+func FindRelease(repository, project, version, gameVersion string, loaders []string) (*Release, error) {
+	resolve, ok := resolvers[repository]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownRepository, repository)
+	}
+	return resolve(project, version, gameVersion, loaders)
+}
+
+// Loaders returns the plugin platforms a server flavor loads plugins for,
+// best match first. Fabric, Forge and NeoForge load mods instead.
+func Loaders(flavor string) ([]string, error) {
+	switch flavor {
+	case "paper":
+		return []string{"paper", "spigot", "bukkit"}, nil
+	case "purpur":
+		return []string{"purpur", "paper", "spigot", "bukkit"}, nil
+	case "folia":
+		return []string{"folia"}, nil
+	case "velocity":
+		return []string{"velocity"}, nil
+	case "bungeecord":
+		return []string{"bungeecord", "waterfall"}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedLoader, flavor)
+}
+
+var recordsMu sync.Mutex
+
+// Records returns the provenance of the plugins MiniMC installed.
+func Records() ([]Record, error) {
+	recordsMu.Lock()
+	defer recordsMu.Unlock()
+	return loadRecords()
+}
+
+func loadRecords() ([]Record, error) {
+	var records []Record
+	if err := storage.Get(recordsPath, &records); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+	if records == nil {
+		records = []Record{}
+	}
+	return records, nil
+}
+
+// download fetches rel to path, checking the published hash.
+func download(rel *Release, path string) error {
+	req, err := http.NewRequest(http.MethodGet, rel.URL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	dl := http.Client{Timeout: 5 * time.Minute}
+	resp, err := dl.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("bad status: " + resp.Status)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	var h hash.Hash
+	expected := rel.SHA512
+	if expected != "" {
+		h = sha512.New()
+	} else {
+		h, expected = sha256.New(), rel.SHA256
+	}
+	if _, err := io.Copy(io.MultiWriter(f, h), resp.Body); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); expected != "" && !strings.EqualFold(sum, expected) {
+		return fmt.Errorf("checksum mismatch for %s: got %s, expected %s", rel.Filename, sum, expected)
+	}
+	return nil
+}
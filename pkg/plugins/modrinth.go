@@ -0,0 +1,98 @@
+package plugins
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+)
+
+type modrinthVersion struct {
+	ID            string   `json:"id"`
+	VersionNumber string   `json:"version_number"`
+	VersionType   string   `json:"version_type"`
+	Loaders       []string `json:"loaders"`
+	Files         []struct {
+		URL      string `json:"url"`
+		Filename string `json:"filename"`
+		Primary  bool   `json:"primary"`
+		Hashes   struct {
+			SHA512 string `json:"sha512"`
+		} `json:"hashes"`
+	} `json:"files"`
+}
+
+// resolveModrinth picks the newest release of a Modrinth project, by slug or
+// ID, for gameVersion and one of loaders. Betas and alphas are only used
+// when there is no release, or when asked for by version number.
+func resolveModrinth(project, version, gameVersion string, loaders []string) (*Release, error) {
+	var info struct {
+		ID    string `json:"id"`
+		Slug  string `json:"slug"`
+		Title string `json:"title"`
+	}
+	err := getJSON(modrinthAPI+"/project/"+url.PathEscape(project), &info)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: no Modrinth project %q", ErrProjectNotFound, project)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	loadersJSON, _ := json.Marshal(loaders)
+	query := url.Values{"loaders": {string(loadersJSON)}}
+	if gameVersion != "" {
+		query.Set("game_versions", fmt.Sprintf(`[%q]`, gameVersion))
+	}
+	var versions []modrinthVersion
+	if err := getJSON(modrinthAPI+"/project/"+info.ID+"/version?"+query.Encode(), &versions); err != nil {
+		return nil, err
+	}
+
+	// Versions come newest first.
+	var pick *modrinthVersion
+	for i, v := range versions {
+		if version != "" {
+			if v.VersionNumber == version || v.ID == version {
+				pick = &versions[i]
+				break
+			}
+			continue
+		}
+		if v.VersionType == "release" {
+			pick = &versions[i]
+			break
+		}
+		if pick == nil {
+			pick = &versions[i]
+		}
+	}
+	if pick == nil {
+		if version != "" {
+			return nil, fmt.Errorf("%w: %s has no version %s for Minecraft %s", ErrNoCompatible, info.Title, version, gameVersion)
+		}
+		return nil, fmt.Errorf("%w: %s has no version for Minecraft %s on %v", ErrNoCompatible, info.Title, gameVersion, loaders)
+	}
+	if len(pick.Files) == 0 {
+		return nil, fmt.Errorf("%w: %s %s has no files", ErrNoCompatible, info.Title, pick.VersionNumber)
+	}
+
+	file := pick.Files[0]
+	for _, f := range pick.Files {
+		if f.Primary {
+			file = f
+			break
+		}
+	}
+	return &Release{
+		Repository: "modrinth",
+		Project:    info.Slug,
+		Title:      info.Title,
+		Version:    pick.VersionNumber,
+		VersionID:  pick.ID,
+		Filename:   file.Filename,
+		URL:        file.URL,
+		SHA512:     file.Hashes.SHA512,
+	}, nil
+}
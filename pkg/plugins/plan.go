@@ -0,0 +1,328 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"pkg.bijsven.nl/MiniMC/pkg/jobs"
+	"pkg.bijsven.nl/MiniMC/pkg/storage"
+)
+
+// maxPlanSteps bounds how many plugins one install pulls in, dependencies of
+// dependencies included.
+const maxPlanSteps = 20
+
+var ErrTooManyDependencies = errors.New("too many dependencies")
+
+// Step is a plugin an install plan puts in the plugins folder.
+type Step struct {
+	Release
+	// Name is the name in the plugin's descriptor.
+	Name string `json:"name"`
+	// RequiredBy is empty for the plugin that was asked for.
+	RequiredBy []string `json:"required_by,omitempty"`
+	// Replaces is the jar of an earlier version of the same project.
+	Replaces string `json:"replaces,omitempty"`
+
+	staged string
+}
+
+// Conflict is a plugin that can't be installed next to what's in the plugins
+// folder.
+type Conflict struct {
+	Name   string `json:"name"`
+	File   string `json:"file,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// Plan is everything an install changes, worked out before anything is
+// written to the plugins folder. The jars are downloaded and checked into a
+// staging folder, which Close removes.
+type Plan struct {
+	// Install lists dependencies before the plugins that need them.
+	Install []Step `json:"install"`
+	// Missing are required dependencies that can't be installed.
+	Missing []Dependency `json:"missing"`
+	// Optional are soft dependencies that aren't installed, offered with
+	// where they can be found.
+	Optional  []Dependency `json:"optional"`
+	Conflicts []Conflict   `json:"conflicts"`
+
+	staging string
+}
+
+// Ready reports whether the plan can be applied as it is.
+func (p *Plan) Ready() bool {
+	return len(p.Missing) == 0 && len(p.Conflicts) == 0
+}
+
+// Close removes the staged jars.
+func (p *Plan) Close() error {
+	return os.RemoveAll(p.staging)
+}
+
+type planned struct {
+	rel        *Release
+	want       string
+	requiredBy []string
+}
+
+// PlanInstall downloads rel and, following the depend lists of the
+// descriptors, the newest compatible release of every required dependency
+// that isn't installed, found by name on Modrinth or Hangar. Nothing in the
+// plugins folder changes until the plan is applied.
+func PlanInstall(rel *Release, gameVersion string, loaders []string, job *jobs.Job) (*Plan, error) {
+	installed, err := List()
+	if err != nil {
+		return nil, err
+	}
+	records, err := Records()
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string]Plugin{}
+	for _, p := range installed {
+		byName[strings.ToLower(p.Name)] = p
+	}
+
+	plan := &Plan{
+		Install:   []Step{},
+		Missing:   []Dependency{},
+		Optional:  []Dependency{},
+		Conflicts: []Conflict{},
+		staging:   filepath.Join(filepath.Dir(Dir), fmt.Sprintf(".plugin-staging-%d", time.Now().UnixNano())),
+	}
+	if err := os.MkdirAll(plan.staging, 0755); err != nil {
+		return nil, err
+	}
+
+	queue := []*planned{{rel: rel}}
+	queued := map[string]*planned{}
+	missing := map[string]*Dependency{}
+	optional := map[string]*Dependency{}
+	done := map[string]bool{}
+
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		if len(plan.Install) >= maxPlanSteps {
+			plan.Close()
+			return nil, fmt.Errorf("%w: an install may pull in at most %d plugins", ErrTooManyDependencies, maxPlanSteps)
+		}
+
+		step, err := stage(plan.staging, len(plan.Install), next.rel, job)
+		if err != nil && next.want == "" {
+			plan.Close()
+			return nil, err
+		}
+		if err == nil && next.want != "" && !strings.EqualFold(step.Name, next.want) {
+			err = fmt.Errorf("%s on %s is the plugin %s", next.rel.Project, next.rel.Repository, step.Name)
+		}
+		if err != nil {
+			missing[strings.ToLower(next.want)] = &Dependency{Name: next.want, Required: true, RequiredBy: next.requiredBy, Reason: err.Error()}
+			continue
+		}
+		step.RequiredBy = next.requiredBy
+		key := strings.ToLower(step.Name)
+		done[key] = true
+
+		for _, r := range records {
+			if r.Repository == step.Repository && r.Project == step.Project {
+				step.Replaces = r.File
+			}
+		}
+		if p, ok := byName[key]; ok && p.File != step.Replaces {
+			plan.Conflicts = append(plan.Conflicts, Conflict{
+				Name:   step.Name,
+				File:   p.File,
+				Reason: fmt.Sprintf("%s is already installed as %s, remove it first", step.Name, p.File),
+			})
+		}
+		d := step.descriptor
+		step.descriptor = nil
+		plan.Install = append(plan.Install, step.Step)
+
+		for _, name := range d.Depend {
+			key := strings.ToLower(name)
+			if p, ok := byName[key]; ok {
+				if p.Disabled {
+					plan.Conflicts = append(plan.Conflicts, Conflict{
+						Name:   p.Name,
+						File:   p.File,
+						Reason: fmt.Sprintf("%s needs %s, which is disabled", step.Name, p.Name),
+					})
+				}
+				continue
+			}
+			if done[key] {
+				for i := range plan.Install {
+					if strings.EqualFold(plan.Install[i].Name, name) {
+						plan.Install[i].RequiredBy = append(plan.Install[i].RequiredBy, step.Name)
+					}
+				}
+				continue
+			}
+			if q, ok := queued[key]; ok {
+				q.requiredBy = append(q.requiredBy, step.Name)
+				continue
+			}
+			if dep, ok := missing[key]; ok {
+				dep.RequiredBy = append(dep.RequiredBy, step.Name)
+				continue
+			}
+
+			job.Log("looking up " + name)
+			src := findSource(name)
+			if src == nil {
+				missing[key] = &Dependency{Name: name, Required: true, RequiredBy: []string{step.Name}, Reason: "not found on Modrinth or Hangar"}
+				continue
+			}
+			depRel, err := FindRelease(src.Repository, src.Slug, "", gameVersion, loaders)
+			if err != nil {
+				missing[key] = &Dependency{Name: name, Required: true, RequiredBy: []string{step.Name}, Source: src, Reason: err.Error()}
+				continue
+			}
+			q := &planned{rel: depRel, want: name, requiredBy: []string{step.Name}}
+			queued[key] = q
+			queue = append(queue, q)
+		}
+		for _, name := range d.SoftDepend {
+			key := strings.ToLower(name)
+			if _, ok := byName[key]; ok {
+				continue
+			}
+			if dep, ok := optional[key]; ok {
+				dep.RequiredBy = append(dep.RequiredBy, step.Name)
+				continue
+			}
+			optional[key] = &Dependency{Name: name, RequiredBy: []string{step.Name}}
+		}
+	}
+
+	// Dependencies go in first.
+	for i, j := 0, len(plan.Install)-1; i < j; i, j = i+1, j-1 {
+		plan.Install[i], plan.Install[j] = plan.Install[j], plan.Install[i]
+	}
+	for _, dep := range missing {
+		plan.Missing = append(plan.Missing, *dep)
+	}
+	for key, dep := range optional {
+		if !done[key] && missing[key] == nil {
+			plan.Optional = append(plan.Optional, *dep)
+		}
+	}
+	sortDependencies(plan.Missing)
+	sortDependencies(plan.Optional)
+	Resolve(plan.Optional)
+	return plan, nil
+}
+
+func sortDependencies(list []Dependency) {
+	sort.Slice(list, func(a, b int) bool {
+		return list[a].Name < list[b].Name
+	})
+}
+
+type stagedStep struct {
+	Step
+	descriptor *Descriptor
+}
+
+// stage downloads rel into the staging folder and reads its descriptor.
+func stage(staging string, n int, rel *Release, job *jobs.Job) (*stagedStep, error) {
+	if strings.ContainsAny(rel.Filename, `/\`) || !strings.HasSuffix(rel.Filename, ".jar") {
+		return nil, fmt.Errorf("refusing to install %q, not a jar file name", rel.Filename)
+	}
+	job.Log(fmt.Sprintf("downloading %s %s from %s", rel.Project, rel.Version, rel.Repository))
+	path := filepath.Join(staging, fmt.Sprintf("%d-%s", n, rel.Filename))
+	if err := download(rel, path); err != nil {
+		return nil, err
+	}
+	d, err := ReadDescriptor(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a plugin: %w", rel.Filename, err)
+	}
+	return &stagedStep{Step: Step{Release: *rel, Name: d.Name, staged: path}, descriptor: d}, nil
+}
+
+// Apply moves the staged jars into the plugins folder, replacing earlier
+// versions of the same projects, and records where they came from. When a
+// step fails, the jars moved so far are taken out and the replaced ones put
+// back, leaving the plugins folder as it was.
+func (p *Plan) Apply(job *jobs.Job) ([]Record, error) {
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	recordsMu.Lock()
+	defer recordsMu.Unlock()
+	records, err := loadRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	var undo []func() error
+	rollback := func(cause error) error {
+		job.Log("rolling back: " + cause.Error())
+		for i := len(undo) - 1; i >= 0; i-- {
+			if err := undo[i](); err != nil {
+				return fmt.Errorf("%w (rollback incomplete: %v)", cause, err)
+			}
+		}
+		return cause
+	}
+	// setAside moves a jar out of the plugins folder into the staging folder.
+	setAside := func(file string) error {
+		from := filepath.Join(Dir, file)
+		if _, err := os.Stat(from); err != nil {
+			return nil
+		}
+		to := filepath.Join(p.staging, "replaced-"+file)
+		if err := os.Rename(from, to); err != nil {
+			return err
+		}
+		undo = append(undo, func() error { return os.Rename(to, from) })
+		return nil
+	}
+
+	var added []Record
+	for _, step := range p.Install {
+		if step.Replaces != "" && step.Replaces != step.Filename {
+			if err := setAside(step.Replaces); err != nil {
+				return nil, rollback(err)
+			}
+		}
+		if err := setAside(step.Filename); err != nil {
+			return nil, rollback(err)
+		}
+		target := filepath.Join(Dir, step.Filename)
+		if err := os.Rename(step.staged, target); err != nil {
+			return nil, rollback(err)
+		}
+		undo = append(undo, func() error { return os.Remove(target) })
+		job.Log(fmt.Sprintf("installed %s %s", step.Name, step.Version))
+		added = append(added, Record{Release: step.Release, Name: step.Name, File: step.Filename, Installed: time.Now()})
+	}
+
+	kept := []Record{}
+	for _, r := range records {
+		replaced := false
+		for _, a := range added {
+			if (r.Repository == a.Repository && r.Project == a.Project) || r.File == a.File {
+				replaced = true
+			}
+		}
+		if !replaced {
+			kept = append(kept, r)
+		}
+	}
+	if err := storage.Put(recordsPath, append(kept, added...)); err != nil {
+		return nil, rollback(err)
+	}
+	return added, nil
+}
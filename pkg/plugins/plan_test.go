@@ -0,0 +1,258 @@
+package plugins
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pkg.bijsven.nl/MiniMC/pkg/jobs"
+	"pkg.bijsven.nl/MiniMC/pkg/storage"
+)
+
+type fakeProject struct {
+	name       string
+	depend     []string
+	softdepend []string
+}
+
+// fakeProjects are served by fakeModrinth by slug.
+var fakeProjects = map[string]fakeProject{
+	"shop":    {name: "Shop", depend: []string{"Vault", "Economy"}, softdepend: []string{"PlaceholderAPI"}},
+	"vault":   {name: "Vault", depend: []string{"LibCore"}},
+	"libcore": {name: "LibCore"},
+}
+
+func pluginJar(t *testing.T, p fakeProject) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	w, err := z.Create("plugin.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	yml := "name: " + p.name + "\nversion: 1.0\n"
+	if len(p.depend) > 0 {
+		yml += "depend: [" + strings.Join(p.depend, ", ") + "]\n"
+	}
+	if len(p.softdepend) > 0 {
+		yml += "softdepend: [" + strings.Join(p.softdepend, ", ") + "]\n"
+	}
+	w.Write([]byte(yml))
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// fakeModrinth serves the parts of the Modrinth API the installer uses, and
+// a Hangar that knows no projects. It runs the test in a scratch directory.
+func fakeModrinth(t *testing.T) {
+	t.Helper()
+	jars := map[string][]byte{}
+	for slug, p := range fakeProjects {
+		jars[slug] = pluginJar(t, p)
+	}
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		switch {
+		case len(parts) == 1 && parts[0] == "search":
+			hits := []map[string]string{}
+			for slug, p := range fakeProjects {
+				if strings.EqualFold(p.name, r.URL.Query().Get("query")) {
+					hits = append(hits, map[string]string{"slug": slug, "title": p.name})
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"hits": hits})
+			return
+		case len(parts) >= 2 && parts[0] == "project":
+			p, ok := fakeProjects[parts[1]]
+			if !ok {
+				break
+			}
+			if len(parts) == 2 {
+				json.NewEncoder(w).Encode(map[string]string{"id": parts[1], "slug": parts[1], "title": p.name})
+				return
+			}
+			sum := sha512.Sum512(jars[parts[1]])
+			json.NewEncoder(w).Encode([]map[string]interface{}{{
+				"id":             parts[1] + "-v1",
+				"version_number": "1.0",
+				"version_type":   "release",
+				"files": []map[string]interface{}{{
+					"url":      srv.URL + "/files/" + parts[1],
+					"filename": parts[1] + "-1.0.jar",
+					"primary":  true,
+					"hashes":   map[string]string{"sha512": hex.EncodeToString(sum[:])},
+				}},
+			}})
+			return
+		case len(parts) == 2 && parts[0] == "files":
+			if jar, ok := jars[parts[1]]; ok {
+				w.Write(jar)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	// Hangar requests go to /hangar/..., which the handler doesn't know.
+	savedModrinth, savedHangar := modrinthAPI, hangarAPI
+	modrinthAPI, hangarAPI = srv.URL, srv.URL+"/hangar"
+	t.Cleanup(func() { modrinthAPI, hangarAPI = savedModrinth, savedHangar })
+
+	wd, _ := os.Getwd()
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func planShop(t *testing.T) *Plan {
+	t.Helper()
+	loaders := []string{"paper"}
+	rel, err := FindRelease("modrinth", "shop", "", "1.21.4", loaders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := PlanInstall(rel, "1.21.4", loaders, jobs.New("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { plan.Close() })
+	return plan
+}
+
+func stepNames(plan *Plan) []string {
+	var names []string
+	for _, s := range plan.Install {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+func TestPlanInstall(t *testing.T) {
+	fakeModrinth(t)
+	plan := planShop(t)
+
+	if got := strings.Join(stepNames(plan), " "); got != "LibCore Vault Shop" {
+		t.Errorf("install order %q, want dependencies first", got)
+	}
+	if len(plan.Missing) != 1 || plan.Missing[0].Name != "Economy" || plan.Missing[0].Reason == "" {
+		t.Errorf("missing %+v, want Economy with a reason", plan.Missing)
+	}
+	if len(plan.Optional) != 1 || plan.Optional[0].Name != "PlaceholderAPI" {
+		t.Errorf("optional %+v, want PlaceholderAPI", plan.Optional)
+	}
+	if plan.Ready() {
+		t.Error("plan with a missing dependency is ready")
+	}
+	if entries, _ := os.ReadDir(Dir); len(entries) != 0 {
+		t.Errorf("planning wrote %d files to the plugins folder", len(entries))
+	}
+}
+
+func TestPlanApply(t *testing.T) {
+	fakeModrinth(t)
+	plan := planShop(t)
+
+	records, err := plan.Apply(jobs.New("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Errorf("recorded %d plugins, want 3", len(records))
+	}
+	list, err := List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range list {
+		if p.Source == nil {
+			t.Errorf("%s installed without a record", p.Name)
+		}
+	}
+	if len(list) != 3 {
+		t.Errorf("%d plugins installed, want 3", len(list))
+	}
+}
+
+func TestPlanApplyRollsBack(t *testing.T) {
+	fakeModrinth(t)
+
+	// An earlier Vault installed by MiniMC, which the plan replaces.
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	old := filepath.Join(Dir, "vault-0.9.jar")
+	if err := os.WriteFile(old, pluginJar(t, fakeProjects["vault"]), 0644); err != nil {
+		t.Fatal(err)
+	}
+	before := []Record{{Release: Release{Repository: "modrinth", Project: "vault", Version: "0.9"}, Name: "Vault", File: "vault-0.9.jar"}}
+	if err := storage.Put(recordsPath, before); err != nil {
+		t.Fatal(err)
+	}
+
+	rel, err := FindRelease("modrinth", "vault", "", "1.21.4", []string{"paper"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := PlanInstall(rel, "1.21.4", []string{"paper"}, jobs.New("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plan.Close()
+	if got := strings.Join(stepNames(plan), " "); got != "LibCore Vault" || plan.Install[1].Replaces != "vault-0.9.jar" {
+		t.Fatalf("plan %q replacing %q, want LibCore and Vault replacing vault-0.9.jar", got, plan.Install[1].Replaces)
+	}
+
+	// Break the last step.
+	os.Remove(plan.Install[1].staged)
+	if _, err := plan.Apply(jobs.New("test")); err == nil {
+		t.Fatal("Apply succeeded with a step missing")
+	}
+
+	entries, _ := os.ReadDir(Dir)
+	if len(entries) != 1 || entries[0].Name() != "vault-0.9.jar" {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("plugins folder holds %v after the rollback, want only vault-0.9.jar", names)
+	}
+	records, err := Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].File != "vault-0.9.jar" {
+		t.Errorf("records %+v after the rollback, want the old Vault", records)
+	}
+}
+
+func TestPlanConflict(t *testing.T) {
+	fakeModrinth(t)
+
+	// A Shop put in by hand.
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(Dir, "Shop.jar"), pluginJar(t, fakeProjects["shop"]), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	plan := planShop(t)
+	if len(plan.Conflicts) != 1 || plan.Conflicts[0].File != "Shop.jar" {
+		t.Errorf("conflicts %+v, want the Shop.jar put in by hand", plan.Conflicts)
+	}
+}
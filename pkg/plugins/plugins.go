@@ -0,0 +1,340 @@
+package plugins
+
+import (
+	"archive/zip"
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const Dir = "minecraft/plugins"
+
+var ErrNoDescriptor = errors.New("no plugin.yml or paper-plugin.yml found")
+
+// Descriptor holds the fields MiniMC reads from a plugin's plugin.yml or
+// paper-plugin.yml.
+type Descriptor struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	Main       string `json:"main,omitempty"`
+	APIVersion string `json:"api_version,omitempty"`
+	// Authors holds both "author" and "authors".
+	Authors []string `json:"authors,omitempty"`
+	// Depend lists plugins that must be installed, SoftDepend plugins that
+	// are only used when present.
+	Depend     []string `json:"depend,omitempty"`
+	SoftDepend []string `json:"softdepend,omitempty"`
+}
+
+// ReadDescriptor opens a plugin jar and parses its descriptor. Only
+// top-level scalar keys are read, which avoids pulling in a YAML parser.
+func ReadDescriptor(jarPath string) (*Descriptor, error) {
+	r, err := zip.OpenReader(jarPath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	for _, name := range []string{"paper-plugin.yml", "plugin.yml"} {
+		f, err := r.Open(name)
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+
+		d := &Descriptor{}
+		parseDescriptor(bufio.NewScanner(f), d)
+		if d.Name == "" {
+			return nil, errors.New(name + " has no name")
+		}
+		return d, nil
+	}
+	return nil, ErrNoDescriptor
+}
+
+// parseDescriptor reads top-level scalar keys, the authors and the
+// dependency lists of both plugin.yml ("depend: [A, B]" or a block list) and
+// paper-plugin.yml ("dependencies: server: Name: required: bool").
+func parseDescriptor(scanner *bufio.Scanner, d *Descriptor) {
+	var (
+		list       *[]string // block list being read for depend/softdepend/authors
+		inDeps     bool      // inside paper-plugin.yml dependencies
+		inServer   bool      // inside paper-plugin.yml dependencies.server
+		serverDeps []string
+		optional   = map[string]bool{}
+		current    string
+		srvIndent  int
+		depIndent  = -1
+	)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed[0] == '#' {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+
+		if indent > 0 {
+			switch {
+			case list != nil && strings.HasPrefix(trimmed, "- "):
+				*list = append(*list, unquote(strings.TrimPrefix(trimmed, "- ")))
+			case inServer && indent <= srvIndent:
+				// A sibling of server:, e.g. bootstrap:.
+				inServer = trimmed == "server:"
+			case inServer:
+				key, value, _ := strings.Cut(trimmed, ":")
+				if depIndent < 0 || indent <= depIndent {
+					depIndent = indent
+					current = unquote(key)
+					serverDeps = append(serverDeps, current)
+				} else if strings.TrimSpace(key) == "required" && unquote(value) == "false" {
+					optional[current] = true
+				}
+			case inDeps && trimmed == "server:":
+				inServer, srvIndent = true, indent
+			}
+			continue
+		}
+
+		list, inDeps, inServer = nil, false, false
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = unquote(value)
+		switch strings.TrimSpace(key) {
+		case "name":
+			d.Name = value
+		case "version":
+			d.Version = value
+		case "main":
+			d.Main = value
+		case "api-version":
+			d.APIVersion = value
+		case "author":
+			if value != "" {
+				d.Authors = append([]string{value}, d.Authors...)
+			}
+		case "authors":
+			d.Authors = append(d.Authors, inlineList(value)...)
+			list = &d.Authors
+		case "depend":
+			d.Depend = inlineList(value)
+			list = &d.Depend
+		case "softdepend":
+			d.SoftDepend = inlineList(value)
+			list = &d.SoftDepend
+		case "dependencies":
+			inDeps = true
+		}
+	}
+
+	for _, name := range serverDeps {
+		if optional[name] {
+			d.SoftDepend = append(d.SoftDepend, name)
+		} else {
+			d.Depend = append(d.Depend, name)
+		}
+	}
+}
+
+func unquote(s string) string {
+	return strings.Trim(strings.TrimSpace(s), `"'`)
+}
+
+// inlineList parses a flow list like "[A, B]".
+func inlineList(value string) []string {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "[") {
+		return nil
+	}
+	var names []string
+	for _, name := range strings.Split(strings.Trim(value, "[]"), ",") {
+		if name = unquote(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// sharedFolders are created in plugins/ by libraries or the server itself
+// rather than by a single plugin, so they're never reported as orphaned.
+var sharedFolders = map[string]bool{
+	".paper-remapped": true,
+	"bStats":          true,
+	"PluginMetrics":   true,
+	"update":          true,
+}
+
+// Plugin is a jar in the plugins folder. Jars without a readable descriptor
+// are listed under their file name with Error set.
+type Plugin struct {
+	Descriptor
+	File     string    `json:"file"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	Error    string    `json:"error,omitempty"`
+	// Disabled jars end in .jar.disabled and aren't loaded by the server.
+	Disabled bool `json:"disabled"`
+	// Source is where MiniMC installed the plugin from, if it did.
+	Source *Record `json:"source,omitempty"`
+}
+
+// List returns the installed plugin jars, disabled ones included, sorted by
+// name.
+func List() ([]Plugin, error) {
+	entries, err := os.ReadDir(Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Plugin{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	records, err := Records()
+	if err != nil {
+		return nil, err
+	}
+
+	list := []Plugin{}
+	for _, e := range entries {
+		jar, disabled, ok := jarName(e.Name())
+		if e.IsDir() || !ok {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		p := Plugin{File: e.Name(), Size: info.Size(), Modified: info.ModTime(), Disabled: disabled}
+		if d, err := ReadDescriptor(filepath.Join(Dir, e.Name())); err == nil {
+			p.Descriptor = *d
+		} else {
+			p.Name = strings.TrimSuffix(jar, ".jar")
+			p.Error = err.Error()
+		}
+		for i := range records {
+			if records[i].File == p.File {
+				p.Source = &records[i]
+				break
+			}
+		}
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name)
+	})
+	return list, nil
+}
+
+type Usage struct {
+	Name     string `json:"name"`
+	Version  string `json:"version,omitempty"`
+	Jar      string `json:"jar,omitempty"`
+	JarSize  int64  `json:"jar_size"`
+	DataDir  string `json:"data_dir,omitempty"`
+	DataSize int64  `json:"data_size"`
+	Orphaned bool   `json:"orphaned"`
+}
+
+// DiskUsage reports the size of every plugin jar and data folder, flagging
+// data folders whose plugin is no longer installed.
+func DiskUsage() ([]Usage, error) {
+	entries, err := os.ReadDir(Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Usage{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	byName := map[string]*Usage{}
+	var list []*Usage
+	for _, e := range entries {
+		// The data of disabled plugins isn't orphaned.
+		jar, _, ok := jarName(e.Name())
+		if e.IsDir() || !ok {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+
+		u := &Usage{Name: strings.TrimSuffix(jar, ".jar"), Jar: e.Name(), JarSize: info.Size()}
+		if d, err := ReadDescriptor(filepath.Join(Dir, e.Name())); err == nil {
+			u.Name = d.Name
+			u.Version = d.Version
+		}
+		byName[strings.ToLower(u.Name)] = u
+		list = append(list, u)
+	}
+
+	for _, e := range entries {
+		if !e.IsDir() || sharedFolders[e.Name()] {
+			continue
+		}
+		size := dirSize(filepath.Join(Dir, e.Name()))
+		if u, ok := byName[strings.ToLower(e.Name())]; ok {
+			u.DataDir = e.Name()
+			u.DataSize = size
+			continue
+		}
+		list = append(list, &Usage{Name: e.Name(), DataDir: e.Name(), DataSize: size, Orphaned: true})
+	}
+
+	sort.Slice(list, func(a, b int) bool {
+		return list[a].JarSize+list[a].DataSize > list[b].JarSize+list[b].DataSize
+	})
+
+	result := make([]Usage, len(list))
+	for i, u := range list {
+		result[i] = *u
+	}
+	return result, nil
+}
+
+// RemoveOrphaned deletes the given data folders, refusing any folder that
+// still belongs to an installed plugin.
+func RemoveOrphaned(folders []string) ([]string, error) {
+	usage, err := DiskUsage()
+	if err != nil {
+		return nil, err
+	}
+	orphaned := map[string]bool{}
+	for _, u := range usage {
+		if u.Orphaned {
+			orphaned[u.DataDir] = true
+		}
+	}
+
+	var removed []string
+	for _, folder := range folders {
+		if !orphaned[folder] {
+			return removed, errors.New(folder + " is not an orphaned plugin folder")
+		}
+		if err := os.RemoveAll(filepath.Join(Dir, folder)); err != nil {
+			return removed, err
+		}
+		removed = append(removed, folder)
+	}
+	return removed, nil
+}
+
+func dirSize(root string) int64 {
+	var size int64
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,101 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pkg.bijsven.nl/MiniMC/pkg/storage"
+)
+
+// DisabledSuffix is appended to the jar of a disabled plugin, which the
+// server then skips when loading plugins.
+const DisabledSuffix = ".disabled"
+
+var (
+	ErrNotInstalled = errors.New("plugin not installed")
+	ErrFileExists   = errors.New("file already exists")
+)
+
+// Disable renames the jar of the plugin with the given name or file name to
+// end in .jar.disabled. It takes effect when the server restarts.
+func Disable(name string) (*Plugin, error) {
+	return toggle(name, false)
+}
+
+// Enable renames a disabled jar back to .jar.
+func Enable(name string) (*Plugin, error) {
+	return toggle(name, true)
+}
+
+func toggle(name string, enable bool) (*Plugin, error) {
+	list, err := List()
+	if err != nil {
+		return nil, err
+	}
+	p := find(list, name, enable)
+	if p == nil {
+		state := "enabled"
+		if enable {
+			state = "disabled"
+		}
+		return nil, fmt.Errorf("%w: no %s plugin %q", ErrNotInstalled, state, name)
+	}
+
+	from := p.File
+	to := from + DisabledSuffix
+	if enable {
+		to = strings.TrimSuffix(from, DisabledSuffix)
+	}
+	if _, err := os.Stat(filepath.Join(Dir, to)); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrFileExists, to)
+	}
+
+	recordsMu.Lock()
+	defer recordsMu.Unlock()
+	if err := os.Rename(filepath.Join(Dir, from), filepath.Join(Dir, to)); err != nil {
+		return nil, err
+	}
+	p.File, p.Disabled = to, !enable
+
+	// Keep the provenance with the jar, so the plugin can still be updated.
+	records, err := loadRecords()
+	if err != nil {
+		return p, err
+	}
+	for i := range records {
+		if records[i].File == from {
+			records[i].File = to
+			p.Source = &records[i]
+			return p, storage.Put(recordsPath, records)
+		}
+	}
+	return p, nil
+}
+
+// find returns the plugin in list with the given file name, or else the given
+// plugin name, that is disabled when enable is set and enabled otherwise.
+func find(list []Plugin, name string, enable bool) *Plugin {
+	for i := range list {
+		if list[i].Disabled == enable && list[i].File == name {
+			return &list[i]
+		}
+	}
+	for i := range list {
+		if list[i].Disabled == enable && strings.EqualFold(list[i].Name, name) {
+			return &list[i]
+		}
+	}
+	return nil
+}
+
+// jarName returns the name of a plugin jar in the plugins folder and whether
+// it's disabled, or false when file isn't a jar.
+func jarName(file string) (string, bool, bool) {
+	if jar, ok := strings.CutSuffix(file, DisabledSuffix); ok && strings.HasSuffix(jar, ".jar") {
+		return jar, true, true
+	}
+	return file, false, strings.HasSuffix(file, ".jar")
+}
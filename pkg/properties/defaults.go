@@ -0,0 +1,168 @@
+package properties
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type defaultValue struct {
+	value string
+	since string
+	until string
+}
+
+// vanillaDefaults are the values a vanilla server writes into a fresh
+// server.properties. since/until limit keys to the versions that have them.
+var vanillaDefaults = map[string]defaultValue{
+	"accepts-transfers":                 {value: "false", since: "1.20.5"},
+	"allow-flight":                      {value: "false"},
+	"allow-nether":                      {value: "true"},
+	"broadcast-console-to-ops":          {value: "true"},
+	"broadcast-rcon-to-ops":             {value: "true"},
+	"bug-report-link":                   {value: "", since: "1.21"},
+	"difficulty":                        {value: "easy"},
+	"enable-command-block":              {value: "false"},
+	"enable-jmx-monitoring":             {value: "false"},
+	"enable-query":                      {value: "false"},
+	"enable-rcon":                       {value: "false"},
+	"enable-status":                     {value: "true"},
+	"enforce-secure-profile":            {value: "true", since: "1.19"},
+	"enforce-whitelist":                 {value: "false"},
+	"entity-broadcast-range-percentage": {value: "100"},
+	"force-gamemode":                    {value: "false"},
+	"function-permission-level":         {value: "2"},
+	"gamemode":                          {value: "survival"},
+	"generate-structures":               {value: "true"},
+	"generator-settings":                {value: "{}"},
+	"hardcore":                          {value: "false"},
+	"hide-online-players":               {value: "false"},
+	"initial-disabled-packs":            {value: "", since: "1.19.3"},
+	"initial-enabled-packs":             {value: "vanilla", since: "1.19.3"},
+	"level-name":                        {value: "world"},
+	"level-seed":                        {value: ""},
+	"level-type":                        {value: "minecraft:normal"},
+	"log-ips":                           {value: "true", since: "1.20.2"},
+	"max-chained-neighbor-updates":      {value: "1000000"},
+	"max-players":                       {value: "20"},
+	"max-tick-time":                     {value: "60000"},
+	"max-world-size":                    {value: "29999984"},
+	"motd":                              {value: "A Minecraft Server"},
+	"network-compression-threshold":     {value: "256"},
+	"online-mode":                       {value: "true"},
+	"op-permission-level":               {value: "4"},
+	"pause-when-empty-seconds":          {value: "60", since: "1.21.2"},
+	"player-idle-timeout":               {value: "0"},
+	"prevent-proxy-connections":         {value: "false"},
+	"previews-chat":                     {value: "false", since: "1.19", until: "1.19.3"},
+	"pvp":                               {value: "true"},
+	"query.port":                        {value: "25565"},
+	"rate-limit":                        {value: "0"},
+	"rcon.password":                     {value: ""},
+	"rcon.port":                         {value: "25575"},
+	"region-file-compression":           {value: "deflate", since: "1.20.5"},
+	"require-resource-pack":             {value: "false"},
+	"resource-pack":                     {value: ""},
+	"resource-pack-id":                  {value: "", since: "1.20.3"},
+	"resource-pack-prompt":              {value: ""},
+	"resource-pack-sha1":                {value: ""},
+	"server-ip":                         {value: ""},
+	"server-port":                       {value: "25565"},
+	"simulation-distance":               {value: "10"},
+	"spawn-animals":                     {value: "true", until: "1.21.2"},
+	"spawn-monsters":                    {value: "true"},
+	"spawn-npcs":                        {value: "true", until: "1.21.2"},
+	"spawn-protection":                  {value: "16"},
+	"sync-chunk-writes":                 {value: "true"},
+	"text-filtering-config":             {value: ""},
+	"text-filtering-version":            {value: "0", since: "1.21.2"},
+	"use-native-transport":              {value: "true"},
+	"view-distance":                     {value: "10"},
+	"white-list":                        {value: "false"},
+}
+
+// Defaults returns the vanilla server.properties defaults for a Minecraft
+// version.
+func Defaults(version string) map[string]string {
+	defaults := map[string]string{}
+	for key, d := range vanillaDefaults {
+		if d.since != "" && compareVersions(version, d.since) < 0 {
+			continue
+		}
+		if d.until != "" && compareVersions(version, d.until) >= 0 {
+			continue
+		}
+		defaults[key] = d.value
+	}
+	return defaults
+}
+
+type Change struct {
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Default string `json:"default,omitempty"`
+}
+
+type Diff struct {
+	Version string   `json:"version"`
+	Changed []Change `json:"changed"`
+	Extra   []Change `json:"extra"`
+	Missing []string `json:"missing"`
+}
+
+// DiffDefaults compares the file with the vanilla defaults of version.
+// Changed lists customized keys, Extra keys that vanilla doesn't know about
+// and Missing default keys absent from the file.
+func (f *File) DiffDefaults(version string) Diff {
+	defaults := Defaults(version)
+	current := f.All()
+
+	diff := Diff{Version: version, Changed: []Change{}, Extra: []Change{}, Missing: []string{}}
+	for key, value := range current {
+		value = unescape(value)
+		def, ok := defaults[key]
+		switch {
+		case !ok:
+			diff.Extra = append(diff.Extra, Change{Key: key, Value: value})
+		case value != def:
+			diff.Changed = append(diff.Changed, Change{Key: key, Value: value, Default: def})
+		}
+	}
+	for key := range defaults {
+		if _, ok := current[key]; !ok {
+			diff.Missing = append(diff.Missing, key)
+		}
+	}
+
+	sort.Slice(diff.Changed, func(a, b int) bool { return diff.Changed[a].Key < diff.Changed[b].Key })
+	sort.Slice(diff.Extra, func(a, b int) bool { return diff.Extra[a].Key < diff.Extra[b].Key })
+	sort.Strings(diff.Missing)
+	return diff
+}
+
+// unescape undoes the escaping Java applies to ':' and '=' in values.
+func unescape(v string) string {
+	return strings.NewReplacer(`\:`, ":", `\=`, "=").Replace(v)
+}
+
+// compareVersions compares dotted Minecraft versions numerically.
+func compareVersions(a, b string) int {
+	pa := strings.Split(a, ".")
+	pb := strings.Split(b, ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var na, nb int
+		if i < len(pa) {
+			na, _ = strconv.Atoi(strings.SplitN(pa[i], "-", 2)[0])
+		}
+		if i < len(pb) {
+			nb, _ = strconv.Atoi(strings.SplitN(pb[i], "-", 2)[0])
+		}
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,92 @@
+package properties
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strings"
+)
+
+// File is a Java style .properties file. Comments, blank lines and key order
+// are preserved when it is written back.
+type File struct {
+	path  string
+	lines []string
+}
+
+func Load(path string) (*File, error) {
+	f := &File{path: path}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		f.lines = append(f.lines, scanner.Text())
+	}
+	return f, scanner.Err()
+}
+
+func parseLine(line string) (string, string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "!") {
+		return "", "", false
+	}
+	k, v, ok := strings.Cut(trimmed, "=")
+	if !ok {
+		return "", "", false
+	}
+	return strings.TrimSpace(k), strings.TrimSpace(v), true
+}
+
+func (f *File) Get(key string) (string, bool) {
+	for _, line := range f.lines {
+		if k, v, ok := parseLine(line); ok && k == key {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// GetDefault returns the value of key, or def when it is missing or empty.
+func (f *File) GetDefault(key, def string) string {
+	if v, ok := f.Get(key); ok && v != "" {
+		return v
+	}
+	return def
+}
+
+func (f *File) Set(key, value string) {
+	for i, line := range f.lines {
+		if k, _, ok := parseLine(line); ok && k == key {
+			f.lines[i] = key + "=" + value
+			return
+		}
+	}
+	f.lines = append(f.lines, key+"="+value)
+}
+
+// All returns every key/value pair in the file.
+func (f *File) All() map[string]string {
+	all := map[string]string{}
+	for _, line := range f.lines {
+		if k, v, ok := parseLine(line); ok {
+			all[k] = v
+		}
+	}
+	return all
+}
+
+func (f *File) Save() error {
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.Join(f.lines, "\n")+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
@@ -0,0 +1,159 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Provenance records where a jar came from, so it can be re-checked later.
+type Provenance struct {
+	URL            string   `json:"url"`
+	ExpectedSHA256 string   `json:"expected_sha256,omitempty"`
+	TLS            *TLSInfo `json:"tls,omitempty"`
+	Fetched        string   `json:"fetched"`
+}
+
+type TLSInfo struct {
+	Version     string `json:"version"`
+	ServerName  string `json:"server_name"`
+	Subject     string `json:"subject"`
+	Issuer      string `json:"issuer"`
+	Fingerprint string `json:"sha256_fingerprint"`
+	NotAfter    string `json:"not_after"`
+}
+
+func tlsInfo(state *tls.ConnectionState) *TLSInfo {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	cert := state.PeerCertificates[0]
+	sum := sha256.Sum256(cert.Raw)
+	return &TLSInfo{
+		Version:     tls.VersionName(state.Version),
+		ServerName:  state.ServerName,
+		Subject:     cert.Subject.String(),
+		Issuer:      cert.Issuer.String(),
+		Fingerprint: hex.EncodeToString(sum[:]),
+		NotAfter:    cert.NotAfter.Format(time.RFC3339),
+	}
+}
+
+func newProvenance(resp *http.Response, expected string) *Provenance {
+	return &Provenance{
+		URL:            resp.Request.URL.String(),
+		ExpectedSHA256: expected,
+		TLS:            tlsInfo(resp.TLS),
+		Fetched:        time.Now().Format(time.RFC3339),
+	}
+}
+
+// JarVerification is the result of re-checking the installed jar against its
+// recorded provenance.
+type JarVerification struct {
+	Path       string   `json:"path"`
+	Size       int64    `json:"size"`
+	SHA256     string   `json:"sha256"`
+	Manifest   string   `json:"manifest_sha256"`
+	Expected   string   `json:"expected_sha256,omitempty"`
+	API        string   `json:"api_sha256,omitempty"`
+	TLS        *TLSInfo `json:"tls,omitempty"`
+	TLSChanged bool     `json:"tls_changed"`
+	Problems   []string `json:"problems"`
+	OK         bool     `json:"ok"`
+}
+
+// VerifyJar hashes the installed jar and compares it with the manifest, the
+// hash the download API reported at install time and the hash it reports now.
+// A changed TLS certificate is reported but not counted as a problem, as
+// certificates are rotated regularly.
+func VerifyJar() (*JarVerification, error) {
+	m, err := LoadManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(JarPath())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, f)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &JarVerification{
+		Path:     JarPath(),
+		Size:     size,
+		SHA256:   hex.EncodeToString(hasher.Sum(nil)),
+		Manifest: m.SHA256,
+		Problems: []string{},
+	}
+
+	if m.SHA256 == "" {
+		v.Problems = append(v.Problems, "manifest has no sha256 recorded")
+	} else if v.SHA256 != m.SHA256 {
+		v.Problems = append(v.Problems, "jar doesn't match the manifest sha256")
+	}
+	if m.Size != 0 && size != m.Size {
+		v.Problems = append(v.Problems, fmt.Sprintf("jar is %d bytes, manifest says %d", size, m.Size))
+	}
+
+	if m.Provenance == nil {
+		v.Problems = append(v.Problems, "no provenance recorded, reinstall the jar to record it")
+	} else {
+		v.Expected = m.Provenance.ExpectedSHA256
+		if v.Expected != "" && v.Expected != v.SHA256 {
+			v.Problems = append(v.Problems, "jar doesn't match the sha256 the download API reported at install time")
+		}
+	}
+
+	if m.Flavor == "paper" || m.Flavor == "folia" || m.Flavor == "velocity" {
+		api, info, err := paperBuildHash(m.Flavor, m.Version, m.Build)
+		if err != nil {
+			v.Problems = append(v.Problems, "could not query the download API: "+err.Error())
+		} else {
+			v.API, v.TLS = api, info
+			if api != "" && api != v.SHA256 {
+				v.Problems = append(v.Problems, "jar doesn't match the sha256 the download API reports now")
+			}
+			if m.Provenance != nil && m.Provenance.TLS != nil && info != nil &&
+				m.Provenance.TLS.Fingerprint != info.Fingerprint {
+				v.TLSChanged = true
+			}
+		}
+	}
+
+	v.OK = len(v.Problems) == 0
+	return v, nil
+}
+
+func paperBuildHash(project, version string, build int) (string, *TLSInfo, error) {
+	client := *httpClient()
+	client.Timeout = 10 * time.Second
+	resp, err := client.Get(fmt.Sprintf("%s/projects/%s/versions/%s/builds/%d", urls().Paper, project, version, build))
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", nil, errors.New("bad status: " + resp.Status)
+	}
+
+	var info BuildResponse
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return "", nil, err
+	}
+	return info.Downloads.Application.SHA256, tlsInfo(resp.TLS), nil
+}
@@ -0,0 +1,70 @@
+package pkg
+
+import (
+	"fmt"
+	"sort"
+)
+
+// bungeecordVersion is the only version BungeeCord has: every build supports
+// the current Minecraft releases.
+const bungeecordVersion = "latest"
+
+// IsProxy reports whether flavor is a proxy rather than a Minecraft server.
+// Proxies have no worlds and use MC_VERSION for their own version.
+func IsProxy(flavor string) bool {
+	return flavor == "velocity" || flavor == "bungeecord"
+}
+
+// RequiredJavaFor is RequiredJava for a release of flavor. Proxy versions
+// are their own, not Minecraft's.
+func RequiredJavaFor(flavor, version string) int {
+	switch flavor {
+	case "velocity":
+		if CompareVersions(version, "3.4.0") >= 0 {
+			return 21
+		}
+		return 17
+	case "bungeecord":
+		return 8
+	}
+	return RequiredJava(version)
+}
+
+// bungeecordProvider downloads BungeeCord from its Jenkins, where build n is
+// Jenkins build n.
+type bungeecordProvider struct{}
+
+func (bungeecordProvider) Versions() ([]string, error) {
+	return []string{bungeecordVersion}, nil
+}
+
+func (bungeecordProvider) Builds(version string) ([]int, error) {
+	if version != bungeecordVersion {
+		return nil, fmt.Errorf("%w: bungeecord has no version %s, leave MC_VERSION unset", ErrVersionNotFound, version)
+	}
+	var job struct {
+		Builds []struct {
+			Number int    `json:"number"`
+			Result string `json:"result"`
+		} `json:"builds"`
+	}
+	if err := getJSON(urls().BungeeCord+"/api/json?tree=builds[number,result]", &job); err != nil {
+		return nil, err
+	}
+	var list []int
+	for _, b := range job.Builds {
+		if b.Result == "SUCCESS" {
+			list = append(list, b.Number)
+		}
+	}
+	sort.Ints(list)
+	return list, nil
+}
+
+func (bungeecordProvider) Download(version string, build int) (Download, error) {
+	// Jenkins only publishes MD5 fingerprints, so there's no expected hash.
+	return Download{
+		Filename: fmt.Sprintf("BungeeCord-%d.jar", build),
+		URL:      fmt.Sprintf("%s/%d/artifact/bootstrap/target/BungeeCord.jar", urls().BungeeCord, build),
+	}, nil
+}
@@ -0,0 +1,128 @@
+// Package ratelimit limits how often a user may hit a class of API routes.
+// Every user and class has a token bucket: a burst of requests is allowed
+// up to the limit, after which requests are let through at the limit's rate.
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cleanupInterval is how often buckets that refilled completely are dropped.
+const cleanupInterval = 10 * time.Minute
+
+// Limit allows Burst requests per Per.
+type Limit struct {
+	Burst int
+	Per   time.Duration
+}
+
+// ParseLimit parses a limit like "10/s", "60/m" or "100/h". "off" and ""
+// disable the limit.
+func ParseLimit(s string) (*Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "off" {
+		return nil, nil
+	}
+	count, unit, ok := strings.Cut(s, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid rate limit %q, use e.g. 10/s or 60/m", s)
+	}
+	burst, err := strconv.Atoi(count)
+	if err != nil || burst < 1 {
+		return nil, fmt.Errorf("invalid rate limit %q, the count must be a positive number", s)
+	}
+	per, ok := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}[unit]
+	if !ok {
+		return nil, errors.New("invalid rate limit " + strconv.Quote(s) + ", the unit must be s, m or h")
+	}
+	return &Limit{Burst: burst, Per: per}, nil
+}
+
+func (l Limit) String() string {
+	unit := map[time.Duration]string{time.Second: "s", time.Minute: "m", time.Hour: "h"}[l.Per]
+	return fmt.Sprintf("%d/%s", l.Burst, unit)
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Limiter keeps a bucket per key for one limit.
+type Limiter struct {
+	limit Limit
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	cleaned time.Time
+}
+
+func New(limit Limit) *Limiter {
+	return &Limiter{
+		limit:   limit,
+		buckets: map[string]*bucket{},
+		cleaned: time.Now(),
+	}
+}
+
+// Limit returns the limit the limiter enforces.
+func (l *Limiter) Limit() Limit {
+	return l.limit
+}
+
+// Allow takes a token from the bucket of key. When the bucket is empty it
+// returns false and how long until the next token.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.cleanup(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit.Burst), updated: now}
+		l.buckets[key] = b
+	}
+	b.tokens = l.refill(b, now)
+	b.updated = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) * float64(l.interval()))
+	return false, wait
+}
+
+// interval is the time it takes to earn one token.
+func (l *Limiter) interval() time.Duration {
+	return l.limit.Per / time.Duration(l.limit.Burst)
+}
+
+func (l *Limiter) refill(b *bucket, now time.Time) float64 {
+	tokens := b.tokens + float64(now.Sub(b.updated))/float64(l.interval())
+	if max := float64(l.limit.Burst); tokens > max {
+		return max
+	}
+	return tokens
+}
+
+// cleanup drops the buckets that are full again, they'd be recreated as
+// they are.
+func (l *Limiter) cleanup(now time.Time) {
+	if now.Sub(l.cleaned) < cleanupInterval {
+		return
+	}
+	l.cleaned = now
+	for key, b := range l.buckets {
+		if l.refill(b, now) >= float64(l.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
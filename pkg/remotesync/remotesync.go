@@ -0,0 +1,233 @@
+package remotesync
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const path = "sync.json"
+
+var (
+	ErrNotFound = errors.New("sync target not found")
+	ErrRunning  = errors.New("sync target is already running")
+)
+
+// Target pushes or pulls the files matching Paths, relative to the server
+// directory, to an rclone remote such as "s3:bucket/servers/lobby". Remotes
+// are configured with rclone itself, so S3, SFTP, WebDAV and everything else
+// rclone supports work the same way.
+type Target struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Remote    string     `json:"remote"`
+	Direction string     `json:"direction"`
+	Paths     []string   `json:"paths"`
+	LastRun   *time.Time `json:"last_run,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+var (
+	mu      sync.Mutex
+	targets []*Target
+	running = map[string]bool{}
+	loaded  bool
+)
+
+func (t *Target) validate() error {
+	if t.Name == "" {
+		return errors.New("name is required")
+	}
+	if !strings.Contains(t.Remote, ":") || strings.HasPrefix(t.Remote, "-") {
+		return errors.New("remote must be an rclone remote like name:path")
+	}
+	if t.Direction != "push" && t.Direction != "pull" {
+		return errors.New("direction must be push or pull")
+	}
+	if len(t.Paths) == 0 {
+		return errors.New("at least one path is required")
+	}
+	for _, p := range t.Paths {
+		clean := filepath.ToSlash(filepath.Clean(p))
+		if p == "" || filepath.IsAbs(p) || clean == ".." || strings.HasPrefix(clean, "../") || strings.HasPrefix(p, "-") {
+			return fmt.Errorf("invalid path %q", p)
+		}
+	}
+	return nil
+}
+
+// load must be called with mu held.
+func load() error {
+	if loaded {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		loaded = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &targets); err != nil {
+		return err
+	}
+	loaded = true
+	return nil
+}
+
+// save must be called with mu held.
+func save() error {
+	data, err := json.MarshalIndent(targets, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func List() ([]Target, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := load(); err != nil {
+		return nil, err
+	}
+	list := make([]Target, len(targets))
+	for i, t := range targets {
+		list[i] = *t
+	}
+	return list, nil
+}
+
+func Add(t Target) (*Target, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := load(); err != nil {
+		return nil, err
+	}
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 6)
+	rand.Read(buf)
+	t.ID = hex.EncodeToString(buf)
+	t.LastRun = nil
+	t.LastError = ""
+
+	targets = append(targets, &t)
+	if err := save(); err != nil {
+		targets = targets[:len(targets)-1]
+		return nil, err
+	}
+	return &t, nil
+}
+
+func Delete(id string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := load(); err != nil {
+		return err
+	}
+	for i, t := range targets {
+		if t.ID == id {
+			targets = append(targets[:i], targets[i+1:]...)
+			return save()
+		}
+	}
+	return ErrNotFound
+}
+
+// Validate reports whether t could be added, without adding it.
+func Validate(t Target) error {
+	return t.validate()
+}
+
+func rclone() string {
+	if bin := os.Getenv("RCLONE_BIN"); bin != "" {
+		return bin
+	}
+	return "rclone"
+}
+
+// Run syncs target id with its remote, using dir as the local side, and
+// returns the tail of rclone's output. Files are only copied, never deleted
+// on the receiving side.
+func Run(id, dir string) (string, error) {
+	mu.Lock()
+	if err := load(); err != nil {
+		mu.Unlock()
+		return "", err
+	}
+	var target *Target
+	for _, t := range targets {
+		if t.ID == id {
+			target = t
+		}
+	}
+	if target == nil {
+		mu.Unlock()
+		return "", ErrNotFound
+	}
+	if running[id] {
+		mu.Unlock()
+		return "", ErrRunning
+	}
+	running[id] = true
+	t := *target
+	mu.Unlock()
+
+	args := []string{"copy"}
+	if t.Direction == "push" {
+		args = append(args, dir, t.Remote)
+	} else {
+		args = append(args, t.Remote, dir)
+	}
+	for _, p := range t.Paths {
+		args = append(args, "--include", filepath.ToSlash(filepath.Clean(p)))
+	}
+
+	log.Printf("[i] sync: %s %q (%s)", t.Direction, t.Name, t.Remote)
+	output, err := exec.Command(rclone(), args...).CombinedOutput()
+	tail := lastLines(string(output), 5)
+	if err != nil {
+		err = fmt.Errorf("rclone: %w: %s", err, tail)
+	}
+
+	mu.Lock()
+	delete(running, id)
+	now := time.Now()
+	target.LastRun = &now
+	target.LastError = ""
+	if err != nil {
+		target.LastError = err.Error()
+	}
+	if saveErr := save(); saveErr != nil {
+		log.Println("[e] sync: failed to save targets:", saveErr)
+	}
+	mu.Unlock()
+	return tail, err
+}
+
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "; ")
+}
@@ -0,0 +1,104 @@
+package scheduler
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"pkg.bijsven.nl/MiniMC/pkg/storage"
+)
+
+const (
+	runsPath = "schedule-runs.json"
+
+	// maxRuns is how many runs are kept per schedule.
+	maxRuns = 50
+
+	maxOutput = 2000
+)
+
+// Run is one execution of a schedule.
+type Run struct {
+	Schedule string    `json:"schedule"`
+	Name     string    `json:"name"`
+	Action   string    `json:"action"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Result   string    `json:"result"`
+	Error    string    `json:"error,omitempty"`
+	Output   string    `json:"output,omitempty"`
+}
+
+var runsMu sync.Mutex
+
+func loadRuns() (map[string][]Run, error) {
+	runs := map[string][]Run{}
+	err := storage.Get(runsPath, &runs)
+	if errors.Is(err, storage.ErrNotFound) {
+		return runs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+func saveRuns(runs map[string][]Run) error {
+	return storage.Put(runsPath, runs)
+}
+
+func recordRun(r Run) error {
+	if len(r.Output) > maxOutput {
+		r.Output = r.Output[len(r.Output)-maxOutput:]
+	}
+
+	runsMu.Lock()
+	defer runsMu.Unlock()
+
+	runs, err := loadRuns()
+	if err != nil {
+		return err
+	}
+	list := append(runs[r.Schedule], r)
+	if len(list) > maxRuns {
+		list = list[len(list)-maxRuns:]
+	}
+	runs[r.Schedule] = list
+	return saveRuns(runs)
+}
+
+func deleteRuns(id string) error {
+	runsMu.Lock()
+	defer runsMu.Unlock()
+
+	runs, err := loadRuns()
+	if err != nil {
+		return err
+	}
+	if _, ok := runs[id]; !ok {
+		return nil
+	}
+	delete(runs, id)
+	return saveRuns(runs)
+}
+
+// Runs returns the recorded runs of schedule id, newest first.
+func Runs(id string) ([]Run, error) {
+	if _, err := Get(id); err != nil {
+		return nil, err
+	}
+
+	runsMu.Lock()
+	defer runsMu.Unlock()
+
+	runs, err := loadRuns()
+	if err != nil {
+		return nil, err
+	}
+	list := runs[id]
+	result := make([]Run, 0, len(list))
+	for i := len(list) - 1; i >= 0; i-- {
+		result = append(result, list[i])
+	}
+	return result, nil
+}
@@ -0,0 +1,307 @@
+package scheduler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"pkg.bijsven.nl/MiniMC/pkg/storage"
+)
+
+const path = "schedules.json"
+
+var (
+	ErrNotFound      = errors.New("schedule not found")
+	ErrUnknownAction = errors.New("unknown action")
+)
+
+// Action performs a scheduled task. Params come straight from the schedule
+// definition. The returned output is kept in the run history.
+type Action func(params map[string]string) (string, error)
+
+// Schedule runs an action either every fixed interval or daily at a time,
+// optionally restricted to some weekdays.
+type Schedule struct {
+	ID      string            `json:"id"`
+	Name    string            `json:"name"`
+	Action  string            `json:"action"`
+	Params  map[string]string `json:"params,omitempty"`
+	Every   string            `json:"every,omitempty"`
+	At      string            `json:"at,omitempty"`
+	Days    []string          `json:"days,omitempty"`
+	Enabled bool              `json:"enabled"`
+	LastRun *time.Time        `json:"last_run,omitempty"`
+	NextRun *time.Time        `json:"next_run,omitempty"`
+}
+
+// Check validates the params of an action when a schedule is created.
+type Check func(params map[string]string) error
+
+var (
+	mu        sync.Mutex
+	actions   = map[string]Action{}
+	checks    = map[string]Check{}
+	schedules []*Schedule
+)
+
+func RegisterAction(name string, action Action) {
+	mu.Lock()
+	actions[name] = action
+	mu.Unlock()
+}
+
+// RegisterCheck makes schedules for action fail to save when check rejects
+// their params, instead of failing on every run.
+func RegisterCheck(name string, check Check) {
+	mu.Lock()
+	checks[name] = check
+	mu.Unlock()
+}
+
+// Actions lists the names of all registered actions.
+func Actions() []string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	names := make([]string, 0, len(actions))
+	for name := range actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+func (s *Schedule) validate() error {
+	if s.Action == "" {
+		return errors.New("action is required")
+	}
+	if _, ok := actions[s.Action]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownAction, s.Action)
+	}
+	if check, ok := checks[s.Action]; ok {
+		if err := check(s.Params); err != nil {
+			return fmt.Errorf("%s: %w", s.Action, err)
+		}
+	}
+	if (s.Every == "") == (s.At == "") {
+		return errors.New("exactly one of every or at is required")
+	}
+	if s.Every != "" {
+		d, err := time.ParseDuration(s.Every)
+		if err != nil || d < time.Minute {
+			return errors.New("every must be a duration of at least 1m")
+		}
+	}
+	if s.At != "" {
+		if _, err := time.Parse("15:04", s.At); err != nil {
+			return errors.New("at must be a time like 04:30")
+		}
+	}
+	for _, d := range s.Days {
+		if _, ok := weekdays[strings.ToLower(d)]; !ok {
+			return fmt.Errorf("unknown day %q", d)
+		}
+	}
+	return nil
+}
+
+// next returns the first run time after from.
+func (s *Schedule) next(from time.Time) time.Time {
+	if s.Every != "" {
+		d, _ := time.ParseDuration(s.Every)
+		if s.LastRun == nil {
+			return from.Add(d)
+		}
+		next := s.LastRun.Add(d)
+		if next.Before(from) {
+			return from
+		}
+		return next
+	}
+
+	at, _ := time.Parse("15:04", s.At)
+	candidate := time.Date(from.Year(), from.Month(), from.Day(), at.Hour(), at.Minute(), 0, 0, from.Location())
+	for i := 0; i < 8; i++ {
+		if candidate.After(from) && s.allowedOn(candidate.Weekday()) {
+			return candidate
+		}
+		candidate = candidate.AddDate(0, 0, 1)
+	}
+	return candidate
+}
+
+func (s *Schedule) allowedOn(day time.Weekday) bool {
+	if len(s.Days) == 0 {
+		return true
+	}
+	for _, d := range s.Days {
+		if weekdays[strings.ToLower(d)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+func load() error {
+	err := storage.Get(path, &schedules)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// save must be called with mu held.
+func save() error {
+	return storage.Put(path, schedules)
+}
+
+func List() []Schedule {
+	mu.Lock()
+	defer mu.Unlock()
+
+	list := make([]Schedule, len(schedules))
+	for i, s := range schedules {
+		list[i] = *s
+	}
+	return list
+}
+
+func Get(id string) (*Schedule, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, s := range schedules {
+		if s.ID == id {
+			copied := *s
+			return &copied, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func Add(s Schedule) (*Schedule, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 6)
+	rand.Read(buf)
+	s.ID = hex.EncodeToString(buf)
+	s.LastRun = nil
+	next := s.next(time.Now())
+	s.NextRun = &next
+
+	schedules = append(schedules, &s)
+	if err := save(); err != nil {
+		schedules = schedules[:len(schedules)-1]
+		return nil, err
+	}
+	return &s, nil
+}
+
+func Delete(id string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for i, s := range schedules {
+		if s.ID == id {
+			schedules = append(schedules[:i], schedules[i+1:]...)
+			if err := deleteRuns(id); err != nil {
+				log.Println("[w] scheduler: failed to delete run history:", err)
+			}
+			return save()
+		}
+	}
+	return ErrNotFound
+}
+
+// Start loads the saved schedules and runs due ones every minute. It returns
+// immediately.
+func Start() error {
+	mu.Lock()
+	err := load()
+	now := time.Now()
+	for _, s := range schedules {
+		next := s.next(now)
+		s.NextRun = &next
+	}
+	mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			time.Sleep(time.Until(time.Now().Truncate(time.Minute).Add(time.Minute)))
+			tick(time.Now())
+		}
+	}()
+	return nil
+}
+
+func tick(now time.Time) {
+	mu.Lock()
+	var due []Schedule
+	for _, s := range schedules {
+		if !s.Enabled || s.NextRun == nil || s.NextRun.After(now) {
+			continue
+		}
+		ran := now
+		s.LastRun = &ran
+		next := s.next(now)
+		s.NextRun = &next
+		due = append(due, *s)
+	}
+	if len(due) > 0 {
+		if err := save(); err != nil {
+			log.Println("[e] scheduler: failed to save schedules:", err)
+		}
+	}
+	mu.Unlock()
+
+	for _, s := range due {
+		go run(s)
+	}
+}
+
+func run(s Schedule) {
+	mu.Lock()
+	action := actions[s.Action]
+	mu.Unlock()
+
+	log.Printf("[i] scheduler: running %q (%s)", s.Name, s.Action)
+	r := Run{
+		Schedule: s.ID,
+		Name:     s.Name,
+		Action:   s.Action,
+		Started:  time.Now(),
+		Result:   "success",
+	}
+
+	output, err := action(s.Params)
+	r.Finished = time.Now()
+	r.Output = output
+	if err != nil {
+		log.Printf("[e] scheduler: %q failed: %v", s.Name, err)
+		r.Result = "failed"
+		r.Error = err.Error()
+	}
+
+	if err := recordRun(r); err != nil {
+		log.Println("[e] scheduler: failed to record run:", err)
+	}
+}
@@ -0,0 +1,163 @@
+package security
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const path = "security.log"
+
+const (
+	LoginFailed  = "login_failed"
+	Lockout      = "lockout"
+	LockedLogin  = "locked_login"
+	InvalidShare = "invalid_share"
+	// ExternalChange is a tracked server file that changed outside MiniMC.
+	ExternalChange = "external_change"
+
+	PermissionDenied = "permission_denied"
+)
+
+// suspicious lists the kinds that are sent to SECURITY_WEBHOOK_URL. A single
+// mistyped password isn't worth a notification, a lockout is.
+var suspicious = map[string]bool{
+	Lockout:        true,
+	LockedLogin:    true,
+	InvalidShare:   true,
+	ExternalChange: true,
+}
+
+type Event struct {
+	Time   time.Time `json:"time"`
+	Kind   string    `json:"kind"`
+	User   string    `json:"user,omitempty"`
+	IP     string    `json:"ip"`
+	Path   string    `json:"path,omitempty"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+var (
+	mu          sync.Mutex
+	subscribers []chan Event
+)
+
+// Emit persists e to security.log and passes it to all subscribers.
+// Subscribers that can't keep up miss events rather than block the request.
+func Emit(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		log.Println("[e] security:", err)
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		log.Println("[e] security:", err)
+	} else {
+		if _, err := f.Write(append(data, '\n')); err != nil {
+			log.Println("[e] security:", err)
+		}
+		f.Close()
+	}
+
+	for _, ch := range subscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+func Subscribe() <-chan Event {
+	ch := make(chan Event, 100)
+	mu.Lock()
+	subscribers = append(subscribers, ch)
+	mu.Unlock()
+	return ch
+}
+
+func Unsubscribe(ch <-chan Event) {
+	mu.Lock()
+	defer mu.Unlock()
+	for i, sub := range subscribers {
+		if sub == ch {
+			subscribers = append(subscribers[:i], subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// List returns the most recent events, newest first, optionally filtered by
+// kind.
+func List(limit int, kind string) ([]Event, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Event{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	events := []Event{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err == nil && (kind == "" || e.Kind == kind) {
+			events = append(events, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	if limit > 0 && len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+// RunWebhook posts every suspicious event as JSON to url. It blocks, so run
+// it in its own goroutine.
+func RunWebhook(url string) {
+	client := http.Client{Timeout: 10 * time.Second}
+	for e := range Subscribe() {
+		if !suspicious[e.Kind] {
+			continue
+		}
+
+		body, err := json.Marshal(e)
+		if err != nil {
+			continue
+		}
+		resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			log.Println("[w] security webhook failed:", err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Println("[w] security webhook returned", resp.Status)
+		}
+	}
+}
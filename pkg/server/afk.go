@@ -0,0 +1,88 @@
+package server
+
+import (
+	"os"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// activityPatterns match console lines that show a player doing something.
+// Movement itself isn't logged, advancements are the closest sign of it. Like
+// joins and leaves they start at the log prefix, so chat can't fake them.
+var activityPatterns = []*regexp.Regexp{
+	regexp.MustCompile(LogPrefix + `(?:\[Not Secure\] )?<(\w{1,16})> `),
+	regexp.MustCompile(LogPrefix + `(\w{1,16}) issued server command: `),
+	regexp.MustCompile(LogPrefix + `(\w{1,16}) has (?:made the advancement|completed the challenge|reached the goal) `),
+}
+
+const defaultAFKAfter = 10 * time.Minute
+
+// AFKAfter is how long a player has to be inactive to count as AFK, from
+// AFK_AFTER.
+func AFKAfter() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("AFK_AFTER")); err == nil && d > 0 {
+		return d
+	}
+	return defaultAFKAfter
+}
+
+// Activity describes how long an online player has been idle.
+type Activity struct {
+	Name       string    `json:"name"`
+	Joined     time.Time `json:"joined"`
+	LastActive time.Time `json:"last_active"`
+	IdleFor    int64     `json:"idle_seconds"`
+	AFK        bool      `json:"afk"`
+}
+
+// trackActivity must be called with playersMu held.
+func trackActivity(line string) {
+	for _, p := range activityPatterns {
+		if m := p.FindStringSubmatch(line); m != nil {
+			if _, ok := onlinePlayers[m[1]]; ok {
+				lastActive[m[1]] = time.Now()
+			}
+			return
+		}
+	}
+}
+
+// PlayerActivity returns the online players with their last activity seen in
+// the console, which is their join until they chat, run a command or earn an
+// advancement.
+func PlayerActivity() []Activity {
+	playersMu.Lock()
+	defer playersMu.Unlock()
+
+	after := AFKAfter()
+	list := make([]Activity, 0, len(onlinePlayers))
+	for name, joined := range onlinePlayers {
+		active := lastActive[name]
+		if active.IsZero() {
+			active = joined
+		}
+		idle := time.Since(active)
+		list = append(list, Activity{
+			Name:       name,
+			Joined:     joined,
+			LastActive: active,
+			IdleFor:    int64(idle.Seconds()),
+			AFK:        idle >= after,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// ActivePlayerCount returns the online players that aren't AFK, for logic
+// that should treat a server with only AFK players as empty.
+func ActivePlayerCount() int {
+	n := 0
+	for _, a := range PlayerActivity() {
+		if !a.AFK {
+			n++
+		}
+	}
+	return n
+}
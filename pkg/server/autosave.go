@@ -0,0 +1,170 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	saveMu       sync.Mutex
+	savePauses   int
+	autosaveOff  bool
+	saveInterval time.Duration
+	saveGen      int
+	saveWaiters  []chan struct{}
+)
+
+func init() {
+	OnLine(trackSaves)
+}
+
+func trackSaves(line string) {
+	if !strings.Contains(line, "Saved the game") {
+		return
+	}
+
+	saveMu.Lock()
+	for _, ch := range saveWaiters {
+		close(ch)
+	}
+	saveWaiters = nil
+	saveMu.Unlock()
+}
+
+func resetSaves() {
+	saveMu.Lock()
+	savePauses = 0
+	autosaveOff = false
+	saveMu.Unlock()
+}
+
+type AutosaveStatus struct {
+	Enabled  bool   `json:"enabled"`
+	Paused   bool   `json:"paused"`
+	Interval string `json:"interval,omitempty"`
+}
+
+func GetAutosave() AutosaveStatus {
+	saveMu.Lock()
+	defer saveMu.Unlock()
+
+	status := AutosaveStatus{
+		Enabled: !autosaveOff,
+		Paused:  savePauses > 0,
+	}
+	if saveInterval > 0 {
+		status.Interval = saveInterval.String()
+	}
+	return status
+}
+
+// SetAutosave turns the server's own autosave on or off. While a snapshot
+// holds saves paused the change is applied once it resumes.
+func SetAutosave(enabled bool) error {
+	if IsProxy() {
+		return ErrProxy
+	}
+	saveMu.Lock()
+	autosaveOff = !enabled
+	paused := savePauses > 0
+	saveMu.Unlock()
+
+	if paused {
+		return nil
+	}
+	if enabled {
+		return RunCommand("save-on")
+	}
+	return RunCommand("save-off")
+}
+
+// SetAutosaveInterval makes MiniMC issue `save-all` every d on top of the
+// server's own autosave. A zero duration stops the extra saves.
+func SetAutosaveInterval(d time.Duration) {
+	saveMu.Lock()
+	saveInterval = d
+	saveGen++
+	gen := saveGen
+	saveMu.Unlock()
+
+	if d <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(d)
+		defer ticker.Stop()
+		for range ticker.C {
+			saveMu.Lock()
+			current := gen == saveGen
+			skip := autosaveOff || savePauses > 0
+			saveMu.Unlock()
+
+			if !current {
+				return
+			}
+			if skip || !GetStatus() || IsProxy() {
+				continue
+			}
+			if err := RunCommand("save-all"); err != nil {
+				log.Println("[e] scheduled save failed:", err)
+			}
+		}
+	}()
+}
+
+// PauseSaves flushes the world to disk and turns saving off so files can be
+// copied consistently. The returned function turns saving back on, unless
+// another snapshot still holds it or autosave was disabled by the user.
+// When the server isn't running, or is a proxy, there is nothing to pause.
+func PauseSaves(timeout time.Duration) (func(), error) {
+	if !GetStatus() || IsProxy() {
+		return func() {}, nil
+	}
+
+	saveMu.Lock()
+	savePauses++
+	first := savePauses == 1
+	saved := make(chan struct{})
+	saveWaiters = append(saveWaiters, saved)
+	saveMu.Unlock()
+
+	var once sync.Once
+	resume := func() {
+		once.Do(func() {
+			saveMu.Lock()
+			savePauses--
+			last := savePauses == 0
+			off := autosaveOff
+			saveMu.Unlock()
+
+			if last && !off {
+				if err := RunCommand("save-on"); err != nil {
+					log.Println("[e] failed to re-enable saving:", err)
+				}
+			}
+		})
+	}
+
+	if first {
+		if err := RunCommand("save-off"); err != nil {
+			resume()
+			return nil, err
+		}
+	}
+	if err := RunCommand("save-all flush"); err != nil {
+		resume()
+		return nil, err
+	}
+
+	select {
+	case <-saved:
+		return resume, nil
+	case <-time.After(timeout):
+		resume()
+		return nil, errors.New("timed out waiting for the server to save")
+	}
+}
@@ -0,0 +1,336 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pkg.bijsven.nl/MiniMC/pkg/jobs"
+	"pkg.bijsven.nl/MiniMC/pkg/properties"
+)
+
+var ErrCanaryRunning = errors.New("a canary start is already running")
+
+// earlyInitPattern matches the line after which the configs have been read
+// and the server starts loading its world, which is as far as a canary start
+// goes.
+var earlyInitPattern = regexp.MustCompile(`Preparing level "[^"]*"|Done \([\d.,]+s\)`)
+
+// problemPattern matches warnings and errors worth showing with the result.
+var problemPattern = regexp.MustCompile(`(?i)\b(WARN|ERROR|FATAL|SEVERE)\]|exception|could not|failed to`)
+
+// canarySkip are not copied into a canary start: the folders the server
+// writes its data to, and the plugins, which could talk to the outside world
+// as if they were the live server.
+var canarySkip = map[string]bool{
+	"plugins":        true,
+	"mods":           true,
+	"logs":           true,
+	"crash-reports":  true,
+	"backups":        true,
+	"world":          true,
+	"world_nether":   true,
+	"world_the_end":  true,
+	"config-history": true,
+}
+
+// canaryCopy are the folders that are copied instead of linked, because they
+// hold configs.
+var canaryCopy = map[string]bool{
+	"config":         true,
+	"defaultconfigs": true,
+}
+
+const (
+	canaryOutputLines = 30
+	maxCanaryProblems = 50
+)
+
+// CanaryResult tells how far a canary start got.
+type CanaryResult struct {
+	OK      bool    `json:"ok"`
+	Reached string  `json:"reached,omitempty"`
+	Error   string  `json:"error,omitempty"`
+	Seconds float64 `json:"seconds"`
+	// Problems are the warnings and errors the server logged.
+	Problems []string `json:"problems"`
+	// Output is the end of what the server printed.
+	Output []string `json:"output"`
+}
+
+var canaryMu sync.Mutex
+
+// Canary starts the server in a temporary copy of its configs, with files
+// replaced by the given contents, keyed by their path relative to the
+// server's working directory. It succeeds once the server has read its
+// configs and starts preparing the world, and fails when the server exits
+// before that or doesn't get there within CANARY_TIMEOUT (default 3m).
+//
+// Worlds, plugins and mods are left out and the JVM heap is CANARY_HEAP
+// (default 1G), so the live server can keep running alongside. The copy
+// listens on a free port with RCON and query disabled.
+func Canary(files map[string][]byte, job *jobs.Job) (*CanaryResult, error) {
+	if installedProxy() {
+		return nil, errors.New("canary starts are only supported for Minecraft servers")
+	}
+	if !canaryMu.TryLock() {
+		return nil, ErrCanaryRunning
+	}
+	defer canaryMu.Unlock()
+
+	launch, err := LoadLaunchConfig()
+	if err == nil {
+		err = launch.Validate()
+	}
+	if err != nil {
+		launch = LaunchConfig{}
+	}
+	src := launch.Dir()
+	// A sibling of the working directory, so the relative paths to the jar
+	// and libraries in the java arguments stay the same.
+	dir := filepath.Join(filepath.Dir(src), fmt.Sprintf(".canary-%d", time.Now().UnixNano()))
+	defer os.RemoveAll(dir)
+
+	job.Log("preparing a copy of the configs")
+	if err := prepareCanary(src, dir, files); err != nil {
+		return nil, err
+	}
+
+	var cmd *exec.Cmd
+	if fake := fakeCommand(); fake != nil {
+		cmd = exec.Command(fake[0], append(fake[1:], "nogui")...)
+	} else {
+		cmd = exec.Command(JavaBin(), canaryArgs(javaArgs())...)
+	}
+	cmd.Dir = dir
+	cmd.Env = launch.environ(nil)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = cmd.Stdout
+
+	started := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	job.Log("canary server started")
+
+	result := &CanaryResult{Problems: []string{}, Output: []string{}}
+	reached := make(chan string, 1)
+	exited := make(chan struct{})
+	var output outputTail
+	go func() {
+		defer close(exited)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			line := scanner.Text()
+			output.add(line)
+			if problemPattern.MatchString(line) && len(result.Problems) < maxCanaryProblems {
+				result.Problems = append(result.Problems, line)
+				job.Log(line)
+			}
+			if earlyInitPattern.MatchString(line) {
+				select {
+				case reached <- line:
+				default:
+				}
+			}
+		}
+		io.Copy(io.Discard, stdout)
+	}()
+
+	timeout := envDuration("CANARY_TIMEOUT", 3*time.Minute)
+	select {
+	case line := <-reached:
+		result.OK = true
+		result.Reached = line
+		job.Log("configs loaded: " + line)
+	case <-exited:
+		// The line may have been the last one printed.
+		select {
+		case line := <-reached:
+			result.OK = true
+			result.Reached = line
+		default:
+			result.Error = "the server exited before it read its configs"
+		}
+	case <-time.After(timeout):
+		result.Error = fmt.Sprintf("the server didn't get to loading its world within %s", timeout)
+	}
+	result.Seconds = time.Since(started).Round(10 * time.Millisecond).Seconds()
+
+	cmd.Process.Kill()
+	<-exited
+	cmd.Wait()
+
+	lines := output.get()
+	result.Output = append(result.Output, lines[max(0, len(lines)-canaryOutputLines):]...)
+	if !result.OK {
+		log.Println("[w] canary start failed:", result.Error)
+	}
+	return result, nil
+}
+
+// prepareCanary fills dir with copies of the configs in src and links to
+// everything else the server needs, then applies files.
+func prepareCanary(src, dir string, files map[string][]byte) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	absSrc, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		name := e.Name()
+		from, to := filepath.Join(src, name), filepath.Join(dir, name)
+		switch {
+		case canarySkip[name] || strings.HasPrefix(name, ".canary-"):
+		case e.IsDir() && canaryCopy[name]:
+			if err := copyTree(from, to); err != nil {
+				return err
+			}
+		case e.IsDir():
+			if _, err := os.Stat(filepath.Join(from, "level.dat")); err == nil {
+				// Another world.
+				continue
+			}
+			if err := os.Symlink(filepath.Join(absSrc, name), to); err != nil {
+				return err
+			}
+		case strings.HasSuffix(name, ".jar"):
+			if err := os.Symlink(filepath.Join(absSrc, name), to); err != nil {
+				return err
+			}
+		case e.Type().IsRegular():
+			if err := copyFile(from, to); err != nil {
+				return err
+			}
+		}
+	}
+
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.Clean(name))
+		if rel, err := filepath.Rel(dir, path); err != nil || strings.HasPrefix(rel, "..") {
+			return fmt.Errorf("%s is outside the server directory", name)
+		}
+		if err := CanaryPath(name); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return err
+		}
+	}
+
+	// Run beside the live server without taking its ports.
+	props, err := properties.Load(filepath.Join(dir, "server.properties"))
+	if err != nil {
+		return err
+	}
+	port, err := freePort()
+	if err != nil {
+		return err
+	}
+	props.Set("server-port", strconv.Itoa(port))
+	props.Set("enable-rcon", "false")
+	props.Set("enable-query", "false")
+	return props.Save()
+}
+
+// CanaryPath returns an error when the file at name, relative to the server's
+// working directory, can't be changed in a canary start. Only top-level files
+// and the files in the config folders are copied.
+func CanaryPath(name string) error {
+	if top, _, nested := strings.Cut(filepath.ToSlash(filepath.Clean(name)), "/"); nested && !canaryCopy[top] {
+		return fmt.Errorf("%s can't be checked with a canary start, only top-level files and files in config/ can", name)
+	}
+	return nil
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// canaryArgs replaces the heap of the live server with CANARY_HEAP and drops
+// the GC log, which would write to the live server's logs.
+func canaryArgs(args []string) []string {
+	heap := os.Getenv("CANARY_HEAP")
+	if _, err := parseSize(heap); err != nil {
+		heap = "1G"
+	}
+	out := make([]string, 0, len(args))
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "-Xms"):
+			out = append(out, "-Xms"+heap)
+		case strings.HasPrefix(arg, "-Xmx"):
+			out = append(out, "-Xmx"+heap)
+		case strings.HasPrefix(arg, "-Xlog:gc"):
+		default:
+			out = append(out, arg)
+		}
+	}
+	return out
+}
+
+func copyTree(from, to string) error {
+	return filepath.Walk(from, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(to, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return copyFile(path, target)
+	})
+}
+
+func copyFile(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
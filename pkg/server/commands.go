@@ -0,0 +1,134 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	"pkg.bijsven.nl/MiniMC/pkg/sessions"
+)
+
+// commandWriteTimeout is how long a command may take to reach the server's
+// stdin. A server that stopped reading its console fills the pipe.
+const commandWriteTimeout = 10 * time.Second
+
+// maxQueuedCommands bounds the commands held back until the server is ready.
+const maxQueuedCommands = 100
+
+var (
+	ErrCommandQueueFull = errors.New("command queue full")
+	ErrCommandTimeout   = errors.New("the server didn't read the command in time")
+	// ErrServerExited is returned for commands that were still waiting to
+	// be written when the server process exited.
+	ErrServerExited = errors.New("the server exited before the command was sent")
+)
+
+// command is a console line on its way to the server's stdin. result gets
+// the outcome of the write.
+type command struct {
+	line   string
+	result chan error
+}
+
+// writeCommands writes the console commands to the server's stdin until the
+// server exits. A failed write is reported to the caller instead of dropping
+// the command.
+func (s *Server) writeCommands(stdin io.WriteCloser) {
+	defer stdin.Close()
+	for {
+		select {
+		case cmd := <-s.stdin:
+			_, err := io.WriteString(stdin, cmd.line+"\n")
+			if err != nil {
+				log.Println("[e] Failed to write command to the server:", err)
+			} else {
+				s.record(sessions.Command, cmd.line)
+			}
+			cmd.result <- err
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// send hands cmd to the stdin writer and waits until it has been written.
+func (s *Server) send(line string) error {
+	cmd := command{line: line, result: make(chan error, 1)}
+	select {
+	case s.stdin <- cmd:
+	case <-s.done:
+		return ErrServerExited
+	default:
+		return ErrCommandQueueFull
+	}
+
+	select {
+	case err := <-cmd.result:
+		return err
+	case <-s.done:
+		// The writer may have finished just before the exit.
+		select {
+		case err := <-cmd.result:
+			return err
+		default:
+			return ErrServerExited
+		}
+	case <-time.After(commandWriteTimeout):
+		return ErrCommandTimeout
+	}
+}
+
+// QueueCommand runs cmd, or holds it until the server is ready when it is
+// still starting. It reports whether cmd was queued.
+func QueueCommand(cmd string) (bool, error) {
+	serverMu.Lock()
+	s := activeServer
+	serverMu.Unlock()
+
+	if s == nil || !s.GetStatus() {
+		return false, errors.New("server is not running")
+	}
+
+	s.mu.Lock()
+	if !s.IsReady() && !s.stopping {
+		if len(s.queued) >= maxQueuedCommands {
+			s.mu.Unlock()
+			return false, ErrCommandQueueFull
+		}
+		s.queued = append(s.queued, cmd)
+		s.mu.Unlock()
+		return true, nil
+	}
+	s.mu.Unlock()
+	return false, s.RunCommand(cmd)
+}
+
+// flushQueued runs the commands queued while the server was starting.
+func (s *Server) flushQueued() {
+	s.mu.Lock()
+	queued := s.queued
+	s.queued = nil
+	s.mu.Unlock()
+
+	for _, cmd := range queued {
+		if err := s.RunCommand(cmd); err != nil {
+			log.Printf("[e] Failed to run queued command %q: %v", cmd, err)
+			continue
+		}
+		log.Printf("[i] Ran queued command %q", cmd)
+	}
+}
+
+// dropQueued discards the commands queued for a server that exited before it
+// was ready.
+func (s *Server) dropQueued() {
+	s.mu.Lock()
+	queued := s.queued
+	s.queued = nil
+	s.mu.Unlock()
+
+	if len(queued) > 0 {
+		log.Printf("[w] Dropped %d queued commands, the server exited before it was ready", len(queued))
+	}
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+func envInt(name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("[w] ignoring invalid %s=%q: %v", name, v, err)
+		return def
+	}
+	return n
+}
+
+func envFloat(name string, def float64) float64 {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Printf("[w] ignoring invalid %s=%q: %v", name, v, err)
+		return def
+	}
+	return f
+}
+
+func envDuration(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("[w] ignoring invalid %s=%q: %v", name, v, err)
+		return def
+	}
+	return d
+}
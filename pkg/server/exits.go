@@ -0,0 +1,119 @@
+package server
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	maxExits    = 10
+	maxTailSize = 50
+
+	// A crash loop is crashLoopCount crashes within crashLoopWindow;
+	// automatic restarts stop once one is detected.
+	crashLoopCount  = 3
+	crashLoopWindow = 5 * time.Minute
+)
+
+// Exit records how the server process ended.
+type Exit struct {
+	Time    time.Time `json:"time"`
+	Code    int       `json:"exit_code"`
+	Signal  string    `json:"signal,omitempty"`
+	Crashed bool      `json:"crashed"`
+	Uptime  string    `json:"uptime"`
+	Output  []string  `json:"output,omitempty"`
+}
+
+var (
+	exitMu sync.Mutex
+	exits  []Exit
+)
+
+// outputTail keeps the last lines the server process printed.
+type outputTail struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (t *outputTail) add(line string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lines = append(t.lines, line)
+	if len(t.lines) > maxTailSize {
+		t.lines = append(t.lines[:0], t.lines[1:]...)
+	}
+}
+
+func (t *outputTail) get() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.lines...)
+}
+
+func recordExit(s *Server, state *os.ProcessState, crashed bool) {
+	if state == nil {
+		return
+	}
+	e := Exit{
+		Time:    time.Now(),
+		Code:    state.ExitCode(),
+		Crashed: crashed,
+		Uptime:  s.uptime().Round(time.Second).String(),
+	}
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		e.Signal = ws.Signal().String()
+	}
+	if crashed {
+		// The output only matters for working out why it crashed.
+		e.Output = s.tail.get()
+	}
+
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	exits = append(exits, e)
+	if len(exits) > maxExits {
+		exits = exits[len(exits)-maxExits:]
+	}
+}
+
+// LastExit returns how the server process last ended.
+func LastExit() (*Exit, error) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	if len(exits) == 0 {
+		return nil, errors.New("the server hasn't exited since MiniMC started")
+	}
+	e := exits[len(exits)-1]
+	return &e, nil
+}
+
+// Exits returns the recent exits, newest first.
+func Exits() []Exit {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+
+	list := make([]Exit, len(exits))
+	for i, e := range exits {
+		list[len(exits)-1-i] = e
+	}
+	return list
+}
+
+// crashLooping reports whether the server crashed crashLoopCount times within
+// crashLoopWindow.
+func crashLooping() bool {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+
+	crashes := 0
+	for _, e := range exits {
+		if e.Crashed && time.Since(e.Time) <= crashLoopWindow {
+			crashes++
+		}
+	}
+	return crashes >= crashLoopCount
+}
@@ -0,0 +1,27 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fakeCommand returns the command line from FAKE_SERVER, a fake server
+// (cmd/fakeserver) run instead of java for testing, or nil.
+func fakeCommand() []string {
+	fields := strings.Fields(os.Getenv("FAKE_SERVER"))
+	if len(fields) == 0 {
+		return nil
+	}
+	// The server runs in the minecraft directory.
+	if abs, err := filepath.Abs(fields[0]); err == nil && strings.ContainsRune(fields[0], filepath.Separator) {
+		fields[0] = abs
+	}
+	return fields
+}
+
+// Fake reports whether FAKE_SERVER replaces the real server, which then
+// doesn't need to be downloaded.
+func Fake() bool {
+	return fakeCommand() != nil
+}
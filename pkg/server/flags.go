@@ -0,0 +1,184 @@
+package server
+
+import (
+	"log"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/mem"
+	"pkg.bijsven.nl/MiniMC/pkg"
+)
+
+const (
+	defaultXms = 2 << 30
+	defaultXmx = 4 << 30
+
+	// Heap below which G1 tuning flags meant for large heaps do more harm
+	// than good.
+	smallHeap = 2 << 30
+	// Practical heap ceiling of a 32-bit JVM.
+	maxHeap32 = 1536 << 20
+	// Memory left to metaspace, thread stacks and direct buffers when the
+	// heap is sized from the container limit.
+	heapOverhead = 1 << 30
+)
+
+var defaultJavaFlags = []string{
+	"-XX:+UseG1GC",
+	"-XX:+ParallelRefProcEnabled",
+	"-XX:+UnlockExperimentalVMOptions",
+	"-XX:+DisableExplicitGC",
+	"-XX:+AlwaysPreTouch",
+	"-XX:G1HeapWastePercent=5",
+	"-XX:G1MixedGCCountTarget=4",
+	"-XX:MaxGCPauseMillis=50",
+	"-XX:G1NewSizePercent=30",
+	"-XX:G1MaxNewSizePercent=40",
+	"-XX:G1HeapRegionSize=8M",
+	"-XX:+PerfDisableSharedMem",
+	"-XX:MaxDirectMemorySize=1G",
+}
+
+// availableMemory returns the container memory limit, or the host's total
+// memory when there is none.
+func availableMemory() uint64 {
+	if _, limit := pkg.CgroupMemory(); limit != 0 {
+		return limit
+	}
+	if vm, err := mem.VirtualMemory(); err == nil {
+		return vm.Total
+	}
+	return 0
+}
+
+// javaArgs returns the JVM arguments used to launch the server, adjusted to
+// the host architecture and available memory.
+func javaArgs() []string {
+	xms, xmx := uint64(defaultXms), uint64(defaultXmx)
+	defaults := defaultJavaFlags
+	proxy := installedProxy()
+	if proxy {
+		xms, xmx = proxyXms, proxyXmx
+		defaults = proxyJavaFlags
+	}
+	var extra []string
+	jar := "server.jar"
+
+	cfg, err := LoadLaunchConfig()
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		log.Println("[w] java flags: ignoring launch settings:", err)
+		cfg = LaunchConfig{}
+	} else {
+		if cfg.Xms != "" {
+			xms, _ = parseSize(cfg.Xms)
+		}
+		if cfg.Xmx != "" {
+			xmx, _ = parseSize(cfg.Xmx)
+			if cfg.Xms == "" {
+				xms = min(xms, xmx)
+			}
+		}
+		extra = cfg.ExtraFlags
+		if cfg.WorkDir != "" {
+			if rel, err := filepath.Rel(cfg.Dir(), filepath.Join("minecraft", "server.jar")); err == nil {
+				jar = rel
+			}
+		}
+
+		// A proxy doesn't get bigger with the container.
+		if cfg.Xmx == "" && !proxy {
+			if _, limit := pkg.CgroupMemory(); limit != 0 {
+				xmx = autoHeap(limit)
+				log.Printf("[i] java flags: -Xmx sized to %s from the %s container memory limit", formatSize(xmx), formatSize(limit))
+				if cfg.Xms == "" {
+					xms = min(xms, xmx)
+				}
+			}
+		}
+	}
+
+	xms, xmx, flags := adjustFlags(runtime.GOARCH, availableMemory(), xms, xmx, defaults)
+
+	args := []string{"-Xms" + formatSize(xms), "-Xmx" + formatSize(xmx)}
+	args = append(args, flags...)
+	args = append(args, gcLogArgs(cfg.Dir())...)
+	// Extra flags come last so they override the defaults.
+	args = append(args, extra...)
+	if m, err := pkg.LoadManifest(); err == nil && m.ArgsFile != "" {
+		// Forge and NeoForge put their classpath and main class in here.
+		if rel, err := filepath.Rel(cfg.Dir(), pkg.LaunchFile()); err == nil {
+			return append(args, "@"+filepath.ToSlash(rel), "nogui")
+		}
+	}
+	return append(args, "-jar", jar, "nogui")
+}
+
+// autoHeap derives -Xmx from a container memory limit, leaving heapOverhead
+// for the rest of the JVM. Small containers give the heap three quarters of
+// the limit instead.
+func autoHeap(limit uint64) uint64 {
+	if limit >= 4*heapOverhead {
+		return (limit - heapOverhead) &^ (1<<20 - 1)
+	}
+	return (limit * 3 / 4) &^ (1<<20 - 1)
+}
+
+// adjustFlags drops or rewrites flags that would keep the JVM from booting on
+// this host, logging every substitution.
+func adjustFlags(arch string, available, xms, xmx uint64, flags []string) (uint64, uint64, []string) {
+	is32 := arch == "386" || arch == "arm" || arch == "mips" || arch == "mipsle"
+
+	if is32 && xmx > maxHeap32 {
+		log.Printf("[w] java flags: lowering -Xmx from %s to %s on 32-bit %s", formatSize(xmx), formatSize(maxHeap32), arch)
+		xmx = maxHeap32
+	}
+	if available != 0 && xmx >= available {
+		// Leave room for metaspace, thread stacks and direct buffers.
+		lowered := (available * 3 / 4) &^ (1<<20 - 1)
+		log.Printf("[w] java flags: lowering -Xmx from %s to %s, only %s memory available",
+			formatSize(xmx), formatSize(lowered), formatSize(available))
+		xmx = lowered
+	}
+	if xms > xmx {
+		log.Printf("[w] java flags: lowering -Xms from %s to %s to match -Xmx", formatSize(xms), formatSize(xmx))
+		xms = xmx
+	}
+
+	adjusted := make([]string, 0, len(flags))
+	for _, flag := range flags {
+		switch {
+		case xmx < smallHeap && strings.HasPrefix(flag, "-XX:G1HeapRegionSize="):
+			log.Printf("[w] java flags: dropping %s, heap of %s is too small", flag, formatSize(xmx))
+			continue
+		case xmx < smallHeap && (strings.HasPrefix(flag, "-XX:G1NewSizePercent=") || strings.HasPrefix(flag, "-XX:G1MaxNewSizePercent=")):
+			log.Printf("[w] java flags: dropping %s, heap of %s is too small", flag, formatSize(xmx))
+			continue
+		case flag == "-XX:+AlwaysPreTouch" && available != 0 && xms > available/2:
+			log.Printf("[w] java flags: dropping %s, pre-touching %s of %s memory risks an OOM kill",
+				flag, formatSize(xms), formatSize(available))
+			continue
+		case is32 && strings.HasPrefix(flag, "-XX:MaxDirectMemorySize="):
+			log.Printf("[w] java flags: replacing %s with -XX:MaxDirectMemorySize=256M on 32-bit %s", flag, arch)
+			flag = "-XX:MaxDirectMemorySize=256M"
+		}
+		adjusted = append(adjusted, flag)
+	}
+	return xms, xmx, adjusted
+}
+
+// formatSize renders bytes in the largest whole JVM unit.
+func formatSize(b uint64) string {
+	switch {
+	case b%(1<<30) == 0:
+		return strconv.FormatUint(b>>30, 10) + "G"
+	case b%(1<<20) == 0:
+		return strconv.FormatUint(b>>20, 10) + "M"
+	default:
+		return strconv.FormatUint(b>>10, 10) + "K"
+	}
+}
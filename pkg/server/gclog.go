@@ -0,0 +1,47 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"pkg.bijsven.nl/MiniMC/pkg/gclog"
+)
+
+// GCLogDir is where the JVM writes its GC log when GC_LOG=true.
+func GCLogDir() string {
+	return filepath.Join("minecraft", "logs")
+}
+
+// GCLogEnabled reports whether GC_LOG=true.
+func GCLogEnabled() bool {
+	return os.Getenv("GC_LOG") == "true"
+}
+
+// gcLogArgs returns the flag that makes the JVM log its collections to
+// GCLogDir, relative to the server's working directory dir. The JVM rotates
+// the log itself after GC_LOG_SIZE, keeping GC_LOG_FILES files.
+func gcLogArgs(dir string) []string {
+	if !GCLogEnabled() {
+		return nil
+	}
+	path := filepath.Join(GCLogDir(), gclog.Name)
+	if rel, err := filepath.Rel(dir, path); err == nil {
+		path = rel
+	}
+	if err := os.MkdirAll(GCLogDir(), 0755); err != nil {
+		log.Println("[w] gc log:", err)
+		return nil
+	}
+	size := os.Getenv("GC_LOG_SIZE")
+	if size == "" {
+		size = "10M"
+	} else if _, err := parseSize(size); err != nil {
+		log.Printf("[w] ignoring invalid GC_LOG_SIZE=%q: %v", size, err)
+		size = "10M"
+	}
+	files := envInt("GC_LOG_FILES", 5)
+	return []string{fmt.Sprintf("-Xlog:gc*:file=%s:time,uptime,level,tags:filecount=%d,filesize=%s",
+		filepath.ToSlash(path), files, size)}
+}
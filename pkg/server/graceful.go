@@ -0,0 +1,169 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrAborted       = errors.New("power action aborted")
+	ErrActionPending = errors.New("another stop or restart is already counting down")
+)
+
+// PendingAction is a stop or restart counting down in-game.
+type PendingAction struct {
+	Action string    `json:"action"`
+	Reason string    `json:"reason,omitempty"`
+	At     time.Time `json:"at"`
+
+	warnings []time.Duration
+	abort    chan struct{}
+}
+
+var defaultWarnings = []time.Duration{5 * time.Minute, time.Minute, 30 * time.Second, 10 * time.Second, 5 * time.Second}
+
+var (
+	pendingMu sync.Mutex
+	pending   *PendingAction
+)
+
+// warnings returns how long before a stop or restart players are warned,
+// longest first, from POWER_WARNINGS like "5m,1m,30s,10s,5s". "0" disables
+// the countdown.
+func warnings() []time.Duration {
+	v := os.Getenv("POWER_WARNINGS")
+	if v == "" {
+		return defaultWarnings
+	}
+	if v == "0" {
+		return nil
+	}
+	var list []time.Duration
+	for _, f := range strings.Split(v, ",") {
+		d, err := time.ParseDuration(strings.TrimSpace(f))
+		if err != nil || d <= 0 {
+			log.Printf("[w] ignoring invalid POWER_WARNINGS=%q", v)
+			return defaultWarnings
+		}
+		list = append(list, d)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i] > list[j] })
+	return list
+}
+
+// Countdown warns the players before the server is stopped or restarted for
+// reason, and returns once it's time to do so. It takes as long as the
+// longest warning, and returns right away when no one is online or the
+// server is a proxy. It returns ErrAborted when the countdown is aborted
+// with AbortPending.
+func Countdown(action, reason string) error {
+	p, err := beginCountdown(action, reason)
+	if p == nil {
+		return err
+	}
+	return p.run()
+}
+
+// StartCountdown is Countdown without waiting for it: the countdown runs in
+// the background and then is called with its result. When there is nothing
+// to count down it returns nil and then isn't called.
+func StartCountdown(action, reason string, then func(error)) (*PendingAction, error) {
+	p, err := beginCountdown(action, reason)
+	if p == nil {
+		return nil, err
+	}
+	go func() { then(p.run()) }()
+	return p, nil
+}
+
+// beginCountdown registers the pending action, or returns nil when the
+// players don't need to be warned.
+func beginCountdown(action, reason string) (*PendingAction, error) {
+	list := warnings()
+	if len(list) == 0 || PlayerCount() == 0 || installedProxy() {
+		return nil, nil
+	}
+
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	if pending != nil {
+		return nil, ErrActionPending
+	}
+	pending = &PendingAction{Action: action, Reason: reason, At: time.Now().Add(list[0]), warnings: list, abort: make(chan struct{})}
+	return pending, nil
+}
+
+func (p *PendingAction) run() error {
+	defer func() {
+		pendingMu.Lock()
+		if pending == p {
+			pending = nil
+		}
+		pendingMu.Unlock()
+	}()
+
+	list := p.warnings
+	log.Printf("[i] Server %s in %s: %s", p.Action, list[0], p.Reason)
+	for i, left := range list {
+		announce(p.Action, p.Reason, left)
+		wait := left
+		if i+1 < len(list) {
+			wait = left - list[i+1]
+		}
+		select {
+		case <-time.After(wait):
+		case <-p.abort:
+			RunCommand("say The server " + p.Action + " was cancelled")
+			return ErrAborted
+		}
+	}
+	return nil
+}
+
+func announce(action, reason string, left time.Duration) {
+	msg := fmt.Sprintf("say The server will %s in %s", action, formatLeft(left))
+	if reason != "" {
+		msg += " (" + reason + ")"
+	}
+	if err := RunCommand(msg); err != nil {
+		log.Println("[w] could not warn players:", err)
+	}
+}
+
+// formatLeft writes a warning time the way players read it, "5 minutes" or
+// "30 seconds".
+func formatLeft(d time.Duration) string {
+	unit, n := "second", int(d.Round(time.Second)/time.Second)
+	if d >= time.Minute && d%time.Minute == 0 {
+		unit, n = "minute", int(d/time.Minute)
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", n, unit)
+}
+
+// Pending returns the stop or restart counting down, or nil.
+func Pending() *PendingAction {
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	return pending
+}
+
+// AbortPending cancels the countdown, and reports whether one was running.
+func AbortPending() bool {
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	if pending == nil {
+		return false
+	}
+	close(pending.abort)
+	pending = nil
+	return true
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"pkg.bijsven.nl/MiniMC/pkg"
+)
+
+const (
+	incidentsPath = "incidents.json"
+	maxIncidents  = 50
+)
+
+const (
+	IncidentOOMKilled = "oom_killed"
+	IncidentJavaOOM   = "java_oom"
+	IncidentKilled    = "killed"
+	IncidentCrash     = "crash"
+)
+
+// Incident describes an unexpected exit of the server process.
+type Incident struct {
+	Time           time.Time `json:"time"`
+	Kind           string    `json:"kind"`
+	ExitCode       int       `json:"exit_code"`
+	Signal         string    `json:"signal,omitempty"`
+	Uptime         string    `json:"uptime"`
+	Detail         string    `json:"detail"`
+	Recommendation string    `json:"recommendation,omitempty"`
+}
+
+var (
+	incidentMu        sync.Mutex
+	incidentListeners []func(Incident)
+
+	javaOOMMu   sync.Mutex
+	javaOOMSeen bool
+)
+
+func init() {
+	OnLine(func(line string) {
+		if strings.Contains(line, "java.lang.OutOfMemoryError") {
+			javaOOMMu.Lock()
+			javaOOMSeen = true
+			javaOOMMu.Unlock()
+		}
+	})
+}
+
+func resetJavaOOM() {
+	javaOOMMu.Lock()
+	javaOOMSeen = false
+	javaOOMMu.Unlock()
+}
+
+// classifyExit turns the exit of s into an incident, or returns nil when the
+// server was stopped on purpose or exited cleanly.
+func classifyExit(s *Server, state *os.ProcessState, oomKillsAtStart uint64, oomCounter bool) *Incident {
+	if state == nil {
+		return nil
+	}
+
+	javaOOMMu.Lock()
+	javaOOM := javaOOMSeen
+	javaOOMMu.Unlock()
+
+	var signaled bool
+	incident := &Incident{
+		Time:     time.Now(),
+		ExitCode: state.ExitCode(),
+		Uptime:   time.Since(s.started).Round(time.Second).String(),
+	}
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		signaled = true
+		incident.Signal = ws.Signal().String()
+	}
+
+	kills, _ := pkg.CgroupOOMKills()
+	_, limit := pkg.CgroupMemory()
+	xmx := heapArg(s.cmd.Args)
+
+	switch {
+	case oomCounter && kills > oomKillsAtStart:
+		incident.Kind = IncidentOOMKilled
+		incident.Detail = "The kernel OOM killer ended the server process."
+		incident.Recommendation = oomRecommendation(xmx, limit)
+	case javaOOM:
+		incident.Kind = IncidentJavaOOM
+		incident.Detail = "The JVM ran out of heap (java.lang.OutOfMemoryError)."
+		incident.Recommendation = fmt.Sprintf("Raise -Xmx (currently %s) or reduce view distance and loaded plugins.", xmx)
+	case s.Stopping() || (!signaled && state.ExitCode() == 0):
+		return nil
+	case signaled && incident.Signal == syscall.SIGKILL.String():
+		incident.Kind = IncidentKilled
+		incident.Detail = "The server process was killed with SIGKILL by something other than MiniMC."
+		if !oomCounter {
+			incident.Detail += " The OOM killer counter isn't readable, so an out-of-memory kill can't be ruled out."
+			incident.Recommendation = oomRecommendation(xmx, limit)
+		}
+	default:
+		incident.Kind = IncidentCrash
+		incident.Detail = "The server process exited unexpectedly: " + state.String()
+	}
+	return incident
+}
+
+func oomRecommendation(xmx string, limit uint64) string {
+	if limit == 0 {
+		return fmt.Sprintf("Lower -Xmx (currently %s) so heap and JVM overhead fit in the host's memory.", xmx)
+	}
+	suggested := (limit * 7 / 10) &^ (1<<20 - 1)
+	return fmt.Sprintf("Lower -Xmx (currently %s) to about %s, 70%% of the %s memory limit, or raise the container memory limit.",
+		xmx, formatSize(suggested), formatSize(limit))
+}
+
+func heapArg(args []string) string {
+	for _, a := range args {
+		if v, ok := strings.CutPrefix(a, "-Xmx"); ok {
+			return v
+		}
+	}
+	return "unknown"
+}
+
+// OnIncident registers fn to be called for every recorded incident.
+func OnIncident(fn func(Incident)) {
+	incidentMu.Lock()
+	incidentListeners = append(incidentListeners, fn)
+	incidentMu.Unlock()
+}
+
+func recordIncident(incident *Incident) {
+	log.Printf("[!] server incident (%s): %s %s\n", incident.Kind, incident.Detail, incident.Recommendation)
+
+	incidentMu.Lock()
+	defer incidentMu.Unlock()
+
+	for _, fn := range incidentListeners {
+		go fn(*incident)
+	}
+
+	incidents, err := loadIncidents()
+	if err != nil {
+		log.Println("[w] ignoring unreadable incidents:", err)
+	}
+	incidents = append(incidents, *incident)
+	if len(incidents) > maxIncidents {
+		incidents = incidents[len(incidents)-maxIncidents:]
+	}
+
+	data, err := json.MarshalIndent(incidents, "", "  ")
+	if err != nil {
+		log.Println("[e] could not save incident:", err)
+		return
+	}
+	if err := os.WriteFile(incidentsPath, data, 0644); err != nil {
+		log.Println("[e] could not save incident:", err)
+	}
+}
+
+func loadIncidents() ([]Incident, error) {
+	data, err := os.ReadFile(incidentsPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Incident{}, nil
+	}
+	if err != nil {
+		return []Incident{}, err
+	}
+	var incidents []Incident
+	if err := json.Unmarshal(data, &incidents); err != nil {
+		return []Incident{}, err
+	}
+	return incidents, nil
+}
+
+// Incidents returns the recorded incidents, newest first.
+func Incidents() ([]Incident, error) {
+	incidentMu.Lock()
+	defer incidentMu.Unlock()
+
+	incidents, err := loadIncidents()
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(incidents)-1; i < j; i, j = i+1, j-1 {
+		incidents[i], incidents[j] = incidents[j], incidents[i]
+	}
+	return incidents, nil
+}
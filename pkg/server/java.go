@@ -0,0 +1,93 @@
+package server
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+
+	"pkg.bijsven.nl/MiniMC/pkg"
+)
+
+var javaVersionPattern = regexp.MustCompile(`version "(\d+)(?:\.(\d+))?[^"]*"`)
+
+// JavaBin returns the java executable used to run the server, from JAVA_BIN
+// or "java" from PATH.
+func JavaBin() string {
+	return pkg.JavaBin()
+}
+
+// JavaVersion runs `java -version` and returns the major version along with
+// the raw version line.
+func JavaVersion() (int, string, error) {
+	out, err := exec.Command(JavaBin(), "-version").CombinedOutput()
+	if err != nil {
+		return 0, "", fmt.Errorf("%s -version failed: %w", JavaBin(), err)
+	}
+
+	m := javaVersionPattern.FindSubmatch(out)
+	if m == nil {
+		return 0, "", fmt.Errorf("unrecognized java -version output: %q", out)
+	}
+
+	major, _ := strconv.Atoi(string(m[1]))
+	if major == 1 && len(m[2]) > 0 {
+		// Java 8 and older report themselves as 1.x.
+		major, _ = strconv.Atoi(string(m[2]))
+	}
+	return major, string(m[0]), nil
+}
+
+// JavaError is returned by Start when java can't run the installed
+// Minecraft version.
+type JavaError struct {
+	Bin       string
+	Major     int
+	Required  int
+	Minecraft string
+	// Proxy is the proxy flavor when the installed server is one, Minecraft
+	// then holds the proxy's version.
+	Proxy string
+	Err   error
+}
+
+func (e *JavaError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	name := "Minecraft"
+	if e.Proxy != "" {
+		name = e.Proxy
+	}
+	return fmt.Sprintf("%s is Java %d, but %s %s requires Java %d or newer", e.Bin, e.Major, name, e.Minecraft, e.Required)
+}
+
+func (e *JavaError) Unwrap() error {
+	return e.Err
+}
+
+// checkJava makes sure the java binary runs and is new enough for the
+// installed server.
+func checkJava() error {
+	major, _, err := JavaVersion()
+	if err != nil {
+		return &JavaError{Bin: JavaBin(), Err: err}
+	}
+
+	m, err := pkg.LoadManifest()
+	if err != nil {
+		return nil
+	}
+	required := m.Java
+	if required == 0 {
+		required = pkg.RequiredJavaFor(m.Flavor, m.Version)
+	}
+	if major < required {
+		err := &JavaError{Bin: JavaBin(), Major: major, Required: required, Minecraft: m.Version}
+		if pkg.IsProxy(m.Flavor) {
+			err.Proxy = m.Flavor
+		}
+		return err
+	}
+	return nil
+}
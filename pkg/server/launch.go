@@ -0,0 +1,223 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	launchConfigPath = "launch.json"
+	// maskedValue replaces env values in API responses. Saving it keeps the
+	// value that was stored before.
+	maskedValue = "***"
+)
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// reservedEnv can't be overridden, MiniMC relies on them to launch java.
+var reservedEnv = map[string]bool{"PATH": true, "HOME": true, "JAVA_HOME": true}
+
+// LaunchConfig overrides the JVM heap and adds flags to the defaults. Saved
+// settings take precedence over JAVA_XMS, JAVA_XMX and JAVA_EXTRA_FLAGS.
+//
+// Env is added to the environment of the java process, for plugins that read
+// tokens from it. WorkDir runs java in a folder inside the server directory
+// instead of the server directory itself.
+type LaunchConfig struct {
+	Xms        string            `json:"xms,omitempty"`
+	Xmx        string            `json:"xmx,omitempty"`
+	ExtraFlags []string          `json:"extra_flags,omitempty"`
+	Env        map[string]string `json:"env,omitempty"`
+	WorkDir    string            `json:"work_dir,omitempty"`
+	// Source is "saved", "env" or "default".
+	Source string `json:"source"`
+}
+
+// parseSize parses a JVM memory size like "512M", "4G" or "1048576".
+func parseSize(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, errors.New("empty size")
+	}
+
+	shift := 0
+	switch s[len(s)-1] {
+	case 'k', 'K':
+		shift = 10
+	case 'm', 'M':
+		shift = 20
+	case 'g', 'G':
+		shift = 30
+	}
+	if shift != 0 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("invalid size %q, use e.g. 512M or 4G", s)
+	}
+	return n << shift, nil
+}
+
+func (c LaunchConfig) Validate() error {
+	var xms, xmx uint64
+	var err error
+	if c.Xms != "" {
+		if xms, err = parseSize(c.Xms); err != nil {
+			return fmt.Errorf("xms: %w", err)
+		}
+	}
+	if c.Xmx != "" {
+		if xmx, err = parseSize(c.Xmx); err != nil {
+			return fmt.Errorf("xmx: %w", err)
+		}
+		if xmx < 512<<20 {
+			return errors.New("xmx must be at least 512M")
+		}
+	}
+	if xms != 0 && xmx != 0 && xms > xmx {
+		return errors.New("xms can't be larger than xmx")
+	}
+
+	for _, flag := range c.ExtraFlags {
+		switch {
+		case !strings.HasPrefix(flag, "-"):
+			return fmt.Errorf("%q is not a JVM flag", flag)
+		case flag == "-jar":
+			return errors.New("-jar can't be set, MiniMC launches server.jar itself")
+		case strings.HasPrefix(flag, "-Xms") || strings.HasPrefix(flag, "-Xmx"):
+			return fmt.Errorf("set the heap with xms and xmx instead of %s", flag)
+		}
+	}
+
+	for name := range c.Env {
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("%q is not a valid environment variable name", name)
+		}
+		if reservedEnv[name] {
+			return fmt.Errorf("%s can't be overridden", name)
+		}
+	}
+
+	if c.WorkDir != "" {
+		dir := filepath.Clean(c.WorkDir)
+		if filepath.IsAbs(dir) || dir == "." || dir == ".." || strings.HasPrefix(dir, ".."+string(filepath.Separator)) {
+			return errors.New("work_dir must be a folder inside the server directory")
+		}
+	}
+	return nil
+}
+
+func (c LaunchConfig) empty() bool {
+	return c.Xms == "" && c.Xmx == "" && len(c.ExtraFlags) == 0 && len(c.Env) == 0 && c.WorkDir == ""
+}
+
+// Masked returns c with the env values hidden.
+func (c LaunchConfig) Masked() LaunchConfig {
+	if len(c.Env) == 0 {
+		return c
+	}
+	env := make(map[string]string, len(c.Env))
+	for name := range c.Env {
+		env[name] = maskedValue
+	}
+	c.Env = env
+	return c
+}
+
+// Dir returns the directory java runs in.
+func (c LaunchConfig) Dir() string {
+	if c.WorkDir == "" {
+		return "minecraft"
+	}
+	return filepath.Join("minecraft", filepath.Clean(c.WorkDir))
+}
+
+// environ returns the environment for the java process, adding c.Env to base
+// or, when base is nil, to MiniMC's own environment.
+func (c LaunchConfig) environ(base []string) []string {
+	if len(c.Env) == 0 {
+		return base
+	}
+	if base == nil {
+		base = os.Environ()
+	}
+	for name, value := range c.Env {
+		base = append(base, name+"="+value)
+	}
+	return base
+}
+
+// LoadLaunchConfig returns the saved launch settings, or those from the
+// environment when none were saved.
+func LoadLaunchConfig() (LaunchConfig, error) {
+	data, err := os.ReadFile(launchConfigPath)
+	if err == nil {
+		var c LaunchConfig
+		if err := json.Unmarshal(data, &c); err != nil {
+			return LaunchConfig{}, fmt.Errorf("invalid %s: %w", launchConfigPath, err)
+		}
+		c.Source = "saved"
+		return c, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return LaunchConfig{}, err
+	}
+
+	c := LaunchConfig{
+		Xms:        os.Getenv("JAVA_XMS"),
+		Xmx:        os.Getenv("JAVA_XMX"),
+		ExtraFlags: strings.Fields(os.Getenv("JAVA_EXTRA_FLAGS")),
+		Source:     "env",
+	}
+	if c.Xms == "" && c.Xmx == "" && len(c.ExtraFlags) == 0 {
+		c.Source = "default"
+	}
+	return c, nil
+}
+
+// SaveLaunchConfig stores launch settings for the next start. An empty
+// config removes the saved settings, falling back to the environment.
+func SaveLaunchConfig(c LaunchConfig) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	if c.empty() {
+		err := os.Remove(launchConfigPath)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	// Masked values were sent back unchanged.
+	if old, err := LoadLaunchConfig(); err == nil {
+		for name, value := range c.Env {
+			if value == maskedValue {
+				c.Env[name] = old.Env[name]
+			}
+		}
+	}
+
+	c.Source = ""
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := launchConfigPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, launchConfigPath)
+}
+
+// LaunchArgs returns the JVM arguments the next start will use.
+func LaunchArgs() []string {
+	return javaArgs()
+}
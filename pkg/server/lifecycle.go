@@ -0,0 +1,69 @@
+package server
+
+import "sync"
+
+var (
+	lifecycleMu   sync.Mutex
+	startHandlers []func() error
+	stopHandlers  []func(exitCode int)
+	readyHandlers []func()
+)
+
+// BeforeStart registers fn to run before the server process is launched. If
+// it returns an error the start is cancelled.
+func BeforeStart(fn func() error) {
+	lifecycleMu.Lock()
+	startHandlers = append(startHandlers, fn)
+	lifecycleMu.Unlock()
+}
+
+// AfterStop registers fn to run once the server process has exited and been
+// cleaned up, whether it was stopped or crashed.
+func AfterStop(fn func(exitCode int)) {
+	lifecycleMu.Lock()
+	stopHandlers = append(stopHandlers, fn)
+	lifecycleMu.Unlock()
+}
+
+// OnReady registers fn to run once the server printed its "Done" line.
+func OnReady(fn func()) {
+	lifecycleMu.Lock()
+	readyHandlers = append(readyHandlers, fn)
+	lifecycleMu.Unlock()
+}
+
+func runStartHandlers() error {
+	lifecycleMu.Lock()
+	handlers := make([]func() error, len(startHandlers))
+	copy(handlers, startHandlers)
+	lifecycleMu.Unlock()
+
+	for _, fn := range handlers {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runStopHandlers(exitCode int) {
+	lifecycleMu.Lock()
+	handlers := make([]func(int), len(stopHandlers))
+	copy(handlers, stopHandlers)
+	lifecycleMu.Unlock()
+
+	for _, fn := range handlers {
+		fn(exitCode)
+	}
+}
+
+func runReadyHandlers() {
+	lifecycleMu.Lock()
+	handlers := make([]func(), len(readyHandlers))
+	copy(handlers, readyHandlers)
+	lifecycleMu.Unlock()
+
+	for _, fn := range handlers {
+		fn()
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"errors"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// LogPrefix matches the time and level the server puts in front of what it
+// logs, "[12:00:00 INFO]: " on Paper and "[12:00:00] [Server thread/INFO]: "
+// on vanilla, Fabric and Forge. Patterns that start with it only match lines
+// the server wrote itself, not the same words in a player's chat message,
+// which follow "<name> " after the prefix.
+const LogPrefix = `^(?:\[[^\]]*\] ?)+: `
+
+var (
+	lineMu       sync.Mutex
+	lineHandlers []func(string)
+	lineWaiters  []*lineWaiter
+)
+
+type lineWaiter struct {
+	pattern *regexp.Regexp
+	result  chan []string
+}
+
+// OnLine registers fn to be called for every line the server process writes
+// to stdout or stderr.
+func OnLine(fn func(line string)) {
+	lineMu.Lock()
+	lineHandlers = append(lineHandlers, fn)
+	lineMu.Unlock()
+}
+
+func dispatchLine(line string) {
+	lineMu.Lock()
+	handlers := make([]func(string), len(lineHandlers))
+	copy(handlers, lineHandlers)
+
+	remaining := lineWaiters[:0]
+	for _, w := range lineWaiters {
+		if m := w.pattern.FindStringSubmatch(line); m != nil {
+			w.result <- m
+			continue
+		}
+		remaining = append(remaining, w)
+	}
+	lineWaiters = remaining
+	lineMu.Unlock()
+
+	for _, fn := range handlers {
+		fn(line)
+	}
+}
+
+// RunCommandWait sends cmd to the console and waits for the first output line
+// matching pattern, returning its submatches.
+func RunCommandWait(cmd string, pattern *regexp.Regexp, timeout time.Duration) ([]string, error) {
+	w := &lineWaiter{pattern: pattern, result: make(chan []string, 1)}
+
+	lineMu.Lock()
+	lineWaiters = append(lineWaiters, w)
+	lineMu.Unlock()
+
+	removeWaiter := func() {
+		lineMu.Lock()
+		for i, other := range lineWaiters {
+			if other == w {
+				lineWaiters = append(lineWaiters[:i], lineWaiters[i+1:]...)
+				break
+			}
+		}
+		lineMu.Unlock()
+	}
+
+	if err := RunCommand(cmd); err != nil {
+		removeWaiter()
+		return nil, err
+	}
+
+	select {
+	case m := <-w.result:
+		return m, nil
+	case <-time.After(timeout):
+		removeWaiter()
+		return nil, errors.New("no response from the server")
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	joinPattern  = regexp.MustCompile(LogPrefix + `(\w{1,16}) joined the game$`)
+	leavePattern = regexp.MustCompile(LogPrefix + `(\w{1,16}) left the game$`)
+
+	playersMu     sync.Mutex
+	onlinePlayers = map[string]time.Time{}
+	lastActive    = map[string]time.Time{}
+
+	playerHandlers []func(name string, joined bool)
+)
+
+// OnPlayer registers fn to be called when a player joins or leaves. When the
+// server exits, fn is called for every player still online.
+func OnPlayer(fn func(name string, joined bool)) {
+	playersMu.Lock()
+	playerHandlers = append(playerHandlers, fn)
+	playersMu.Unlock()
+}
+
+func dispatchPlayer(name string, joined bool) {
+	playersMu.Lock()
+	handlers := append([]func(string, bool){}, playerHandlers...)
+	playersMu.Unlock()
+	for _, fn := range handlers {
+		fn(name, joined)
+	}
+}
+
+func init() {
+	OnLine(trackPlayers)
+}
+
+func trackPlayers(line string) {
+	if m := joinPattern.FindStringSubmatch(line); m != nil {
+		playersMu.Lock()
+		onlinePlayers[m[1]] = time.Now()
+		playersMu.Unlock()
+		dispatchPlayer(m[1], true)
+		publishPlayers()
+		return
+	}
+	if m := leavePattern.FindStringSubmatch(line); m != nil {
+		playersMu.Lock()
+		delete(onlinePlayers, m[1])
+		delete(lastActive, m[1])
+		playersMu.Unlock()
+		dispatchPlayer(m[1], false)
+		publishPlayers()
+		return
+	}
+
+	playersMu.Lock()
+	trackActivity(line)
+	playersMu.Unlock()
+}
+
+func resetPlayers() {
+	playersMu.Lock()
+	online := onlinePlayers
+	onlinePlayers = map[string]time.Time{}
+	lastActive = map[string]time.Time{}
+	playersMu.Unlock()
+	for name := range online {
+		dispatchPlayer(name, false)
+	}
+}
+
+// OnlinePlayers returns the names of the players currently connected, as seen
+// in the console output.
+func OnlinePlayers() []string {
+	playersMu.Lock()
+	defer playersMu.Unlock()
+
+	names := make([]string, 0, len(onlinePlayers))
+	for name := range onlinePlayers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func PlayerCount() int {
+	playersMu.Lock()
+	defer playersMu.Unlock()
+	return len(onlinePlayers)
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// PriorityConfig sets how the java process competes for CPU and disk with
+// backups and the panel on shared hosts.
+type PriorityConfig struct {
+	CPUs    []int
+	Nice    int
+	SetNice bool
+	IOClass string
+	IOLevel int
+}
+
+var ioClasses = map[string]int{
+	"realtime":    1,
+	"best-effort": 2,
+	"idle":        3,
+}
+
+func priorityFromEnv() (PriorityConfig, error) {
+	var cfg PriorityConfig
+
+	if v := os.Getenv("SERVER_CPUS"); v != "" {
+		cpus, err := parseCPUList(v)
+		if err != nil {
+			return cfg, fmt.Errorf("SERVER_CPUS: %w", err)
+		}
+		cfg.CPUs = cpus
+	}
+
+	if v := os.Getenv("SERVER_NICE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < -20 || n > 19 {
+			return cfg, fmt.Errorf("SERVER_NICE must be between -20 and 19, got %q", v)
+		}
+		cfg.Nice, cfg.SetNice = n, true
+	}
+
+	if v := os.Getenv("SERVER_IONICE"); v != "" {
+		class, level, _ := strings.Cut(v, ":")
+		if _, ok := ioClasses[class]; !ok {
+			return cfg, fmt.Errorf("SERVER_IONICE class must be realtime, best-effort or idle, got %q", class)
+		}
+		cfg.IOClass = class
+		cfg.IOLevel = 4
+		if level != "" {
+			n, err := strconv.Atoi(level)
+			if err != nil || n < 0 || n > 7 {
+				return cfg, fmt.Errorf("SERVER_IONICE level must be between 0 and 7, got %q", level)
+			}
+			cfg.IOLevel = n
+		}
+	}
+
+	return cfg, nil
+}
+
+// parseCPUList parses a taskset style list such as "0-3,6".
+func parseCPUList(s string) ([]int, error) {
+	var cpus []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		lo, hi, isRange := strings.Cut(part, "-")
+
+		first, err := strconv.Atoi(lo)
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("invalid cpu %q", part)
+		}
+		last := first
+		if isRange {
+			last, err = strconv.Atoi(hi)
+			if err != nil || last < first {
+				return nil, fmt.Errorf("invalid cpu range %q", part)
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
+
+func (c PriorityConfig) empty() bool {
+	return len(c.CPUs) == 0 && !c.SetNice && c.IOClass == ""
+}
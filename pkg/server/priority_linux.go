@@ -0,0 +1,60 @@
+package server
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+const ioprioWhoProcess = 1
+
+// applyPriority sets affinity, niceness and I/O priority on every thread of
+// pid. On Linux these are per thread, and the JVM has already started some of
+// its threads by the time this runs; threads created later inherit them.
+func (c PriorityConfig) applyPriority(pid int) {
+	if c.empty() {
+		return
+	}
+
+	tids := []int{pid}
+	if entries, err := os.ReadDir(filepath.Join("/proc", strconv.Itoa(pid), "task")); err == nil {
+		tids = tids[:0]
+		for _, e := range entries {
+			if tid, err := strconv.Atoi(e.Name()); err == nil {
+				tids = append(tids, tid)
+			}
+		}
+	}
+
+	var set unix.CPUSet
+	for _, cpu := range c.CPUs {
+		set.Set(cpu)
+	}
+
+	for _, tid := range tids {
+		if len(c.CPUs) > 0 {
+			if err := unix.SchedSetaffinity(tid, &set); err != nil {
+				log.Println("[w] could not set cpu affinity:", err)
+				break
+			}
+		}
+		if c.SetNice {
+			if err := unix.Setpriority(unix.PRIO_PROCESS, tid, c.Nice); err != nil {
+				log.Println("[w] could not set nice level:", err)
+				break
+			}
+		}
+		if c.IOClass != "" {
+			prio := ioClasses[c.IOClass]<<13 | c.IOLevel
+			if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(prio)); errno != 0 {
+				log.Println("[w] could not set io priority:", errno)
+				break
+			}
+		}
+	}
+
+	log.Printf("[i] server process priority: cpus=%v nice=%d ionice=%s:%d", c.CPUs, c.Nice, c.IOClass, c.IOLevel)
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package server
+
+import "log"
+
+func (c PriorityConfig) applyPriority(pid int) {
+	if !c.empty() {
+		log.Println("[w] cpu affinity, nice and ionice are only supported on linux")
+	}
+}
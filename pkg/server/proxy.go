@@ -0,0 +1,52 @@
+package server
+
+import (
+	"errors"
+	"regexp"
+
+	"pkg.bijsven.nl/MiniMC/pkg"
+)
+
+const (
+	proxyXms = 512 << 20
+	proxyXmx = 1 << 30
+)
+
+// ErrProxy is returned for world commands when the server is a proxy.
+var ErrProxy = errors.New("the server is a proxy and has no worlds")
+
+// proxyJavaFlags are the flags Velocity recommends. Proxies keep little in
+// memory, so the G1 tuning for large Minecraft heaps doesn't apply.
+var proxyJavaFlags = []string{
+	"-XX:+UseG1GC",
+	"-XX:G1HeapRegionSize=4M",
+	"-XX:+UnlockExperimentalVMOptions",
+	"-XX:+ParallelRefProcEnabled",
+	"-XX:+AlwaysPreTouch",
+	"-XX:MaxInlineLevel=15",
+}
+
+// proxyReadyPattern matches the line Velocity and BungeeCord print once they
+// accept connections. They don't prepare a world, so there is no "Done" line
+// to wait for.
+var proxyReadyPattern = regexp.MustCompile(`Listening on /`)
+
+// installedProxy reports whether the installed server is a proxy.
+func installedProxy() bool {
+	return pkg.IsProxy(installedFlavor())
+}
+
+// IsProxy reports whether the running server is a proxy, which has no
+// worlds to save and stops with "end".
+func IsProxy() bool {
+	serverMu.Lock()
+	s := activeServer
+	serverMu.Unlock()
+	return s != nil && s.proxy
+}
+
+// isStopCommand reports whether cmd shuts the server down. BungeeCord only
+// knows "end", Velocity accepts both.
+func (s *Server) isStopCommand(cmd string) bool {
+	return cmd == "stop" || (s.proxy && cmd == "end")
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SandboxConfig describes how the java process is isolated from MiniMC.
+// Seccomp filtering isn't done here; it is left to the container runtime.
+type SandboxConfig struct {
+	UID          int
+	GID          int
+	RestrictEnv  bool
+	MaxOpenFiles uint64
+	MaxProcesses uint64
+}
+
+// keptEnv are the variables passed to java when SERVER_RESTRICT_ENV is set.
+// Everything else, including MiniMC's own credentials, is withheld.
+var keptEnv = []string{"PATH", "LANG", "LC_ALL", "TZ", "JAVA_HOME", "TERM"}
+
+func sandboxFromEnv() SandboxConfig {
+	return SandboxConfig{
+		UID:          envInt("SERVER_UID", -1),
+		GID:          envInt("SERVER_GID", -1),
+		RestrictEnv:  os.Getenv("SERVER_RESTRICT_ENV") == "true",
+		MaxOpenFiles: uint64(envInt("SERVER_MAX_OPEN_FILES", 0)),
+		MaxProcesses: uint64(envInt("SERVER_MAX_PROCESSES", 0)),
+	}
+}
+
+// processEnv returns the environment for the java process, or nil to inherit
+// MiniMC's.
+func (c SandboxConfig) processEnv() []string {
+	if !c.RestrictEnv {
+		return nil
+	}
+
+	env := []string{}
+	for _, name := range keptEnv {
+		if v, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+v)
+		}
+	}
+	if home, err := filepath.Abs("minecraft"); err == nil {
+		env = append(env, "HOME="+home)
+	}
+	return env
+}
+
+func (c SandboxConfig) String() string {
+	var parts []string
+	if c.UID >= 0 {
+		parts = append(parts, "uid")
+	}
+	if c.GID >= 0 {
+		parts = append(parts, "gid")
+	}
+	if c.RestrictEnv {
+		parts = append(parts, "restricted env")
+	}
+	if c.MaxOpenFiles > 0 || c.MaxProcesses > 0 {
+		parts = append(parts, "rlimits")
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// applySandbox configures cmd to run as the configured user and group. The
+// server directory is handed over to that user first, otherwise the server
+// couldn't write its worlds.
+func (c SandboxConfig) applySandbox(cmd *exec.Cmd) error {
+	cmd.Env = c.processEnv()
+
+	if c.UID < 0 && c.GID < 0 {
+		return nil
+	}
+
+	uid, gid := c.UID, c.GID
+	if uid < 0 {
+		uid = os.Getuid()
+	}
+	if gid < 0 {
+		gid = os.Getgid()
+	}
+
+	if err := chownTree(cmd.Dir, uid, gid); err != nil {
+		return err
+	}
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)},
+	}
+	return nil
+}
+
+func chownTree(root string, uid, gid int) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if st, ok := info.Sys().(*syscall.Stat_t); ok && int(st.Uid) == uid && int(st.Gid) == gid {
+			return nil
+		}
+		return os.Lchown(path, uid, gid)
+	})
+}
+
+// applyLimits sets the resource limits on the started process. They are
+// applied right after start, before the JVM gets to load any plugins.
+func (c SandboxConfig) applyLimits(pid int) {
+	if c.MaxOpenFiles > 0 {
+		limit := &unix.Rlimit{Cur: c.MaxOpenFiles, Max: c.MaxOpenFiles}
+		if err := unix.Prlimit(pid, unix.RLIMIT_NOFILE, limit, nil); err != nil {
+			log.Println("[w] could not limit open files:", err)
+		}
+	}
+	if c.MaxProcesses > 0 {
+		limit := &unix.Rlimit{Cur: c.MaxProcesses, Max: c.MaxProcesses}
+		if err := unix.Prlimit(pid, unix.RLIMIT_NPROC, limit, nil); err != nil {
+			log.Println("[w] could not limit processes:", err)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+
+package server
+
+import (
+	"errors"
+	"log"
+	"os/exec"
+)
+
+func (c SandboxConfig) applySandbox(cmd *exec.Cmd) error {
+	cmd.Env = c.processEnv()
+	if c.UID >= 0 || c.GID >= 0 {
+		return errors.New("SERVER_UID and SERVER_GID are only supported on linux")
+	}
+	return nil
+}
+
+func (c SandboxConfig) applyLimits(pid int) {
+	if c.MaxOpenFiles > 0 || c.MaxProcesses > 0 {
+		log.Println("[w] resource limits are only supported on linux")
+	}
+}
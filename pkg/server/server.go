@@ -8,26 +8,94 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
+	"regexp"
 	"sync"
 	"time"
+
+	"pkg.bijsven.nl/MiniMC/pkg"
+	"pkg.bijsven.nl/MiniMC/pkg/sessions"
 )
 
 var (
 	activeServer    *Server
 	serverMu        sync.Mutex
 	ErrServerExists = errors.New("a server is already running")
+	ErrStopTimeout  = errors.New("server did not stop in time")
 )
 
 type Server struct {
-	cmd       *exec.Cmd
-	stdin     chan string
+	cmd   *exec.Cmd
+	stdin chan command
+	// queued are the commands held back until the server is ready.
+	queued    []string
 	done      chan struct{}
+	ready     chan struct{}
+	readyOnce sync.Once
 	mu        sync.Mutex
 	isRunning bool
+	stopping  bool
+	trial     bool
+	proxy     bool
+	started   time.Time
+	// args are passed to the server after "nogui".
+	args []string
+	// readyPattern matches the line after which players can join.
+	readyPattern *regexp.Regexp
+	tail         outputTail
+	// session records the console, nil when recording is disabled.
+	session *sessions.Recorder
+}
+
+var (
+	donePattern = regexp.MustCompile(`Done \([\d.,]+s\)!`)
+	// Folia loads every world's regions on their own threads and doesn't
+	// log the server wide line on all builds, the first world is done when
+	// players can join.
+	foliaDonePattern = regexp.MustCompile(`Done (?:preparing level "[^"]+" )?\([\d.,]+s\)`)
+)
+
+// installedFlavor returns the flavor of the installed server, or "" if there
+// is no manifest.
+func installedFlavor() string {
+	m, err := pkg.LoadManifest()
+	if err != nil {
+		return ""
+	}
+	return m.Flavor
+}
+
+func readyPattern(flavor string) *regexp.Regexp {
+	switch {
+	case pkg.IsProxy(flavor):
+		return proxyReadyPattern
+	case flavor == "folia":
+		return foliaDonePattern
+	}
+	return donePattern
 }
 
 func Start() error {
+	return start(nil)
+}
+
+// StartWith starts the server with extra server arguments, like
+// --forceUpgrade.
+func StartWith(args ...string) error {
+	return start(args)
+}
+
+func start(args []string) error {
+	if GetStatus() {
+		return ErrServerExists
+	}
+	cancelPendingRestart()
+
+	// Run outside serverMu, hooks may take a while.
+	if err := runStartHandlers(); err != nil {
+		PublishStartup(StartupEvent{Stage: StageFailed, Percent: -1, Message: err.Error()})
+		return err
+	}
+
 	serverMu.Lock()
 	defer serverMu.Unlock()
 
@@ -35,6 +103,13 @@ func Start() error {
 		return ErrServerExists
 	}
 
+	PublishStartup(StartupEvent{Stage: StageVerifying, Percent: -1})
+	if Fake() {
+		log.Println("[w] starting the fake server from FAKE_SERVER instead of java")
+	} else if err := verifyLaunch(); err != nil {
+		return err
+	}
+
 	lockPath := filepath.Join("minecraft", "world", "session.lock")
 	if _, err := os.Stat(lockPath); err == nil {
 		log.Println("[i] Found stale session.lock, removing...")
@@ -42,15 +117,46 @@ func Start() error {
 	}
 
 	s := &Server{
-		stdin: make(chan string, 100),
+		stdin: make(chan command, 100),
 		done:  make(chan struct{}),
+		ready: make(chan struct{}),
+		args:  args,
 	}
+	flavor := installedFlavor()
+	s.proxy = pkg.IsProxy(flavor)
+	s.readyPattern = readyPattern(flavor)
 
+	PublishStartup(StartupEvent{Stage: StageLaunching, Percent: -1})
 	if err := s.startInternal(); err != nil {
+		PublishStartup(StartupEvent{Stage: StageFailed, Percent: -1, Message: err.Error()})
 		return err
 	}
 
 	activeServer = s
+	publishState(StateStarting, false)
+
+	if m, err := pkg.LoadManifest(); err == nil && m.Trial {
+		s.trial = true
+		go watchTrial(s)
+	}
+	return nil
+}
+
+// verifyLaunch checks that the installed server can be started with java.
+func verifyLaunch() error {
+	if _, err := os.Stat(pkg.LaunchFile()); err != nil {
+		PublishStartup(StartupEvent{Stage: StageFailed, Percent: -1, Message: "server jar missing: " + err.Error()})
+		return err
+	}
+	if err := pkg.PrepareFabric(); err != nil {
+		PublishStartup(StartupEvent{Stage: StageFailed, Percent: -1, Message: "fabric launcher: " + err.Error()})
+		return err
+	}
+	if err := checkJava(); err != nil {
+		log.Println("[e]", err)
+		PublishStartup(StartupEvent{Stage: StageFailed, Percent: -1, Message: err.Error()})
+		return err
+	}
 	return nil
 }
 
@@ -66,6 +172,36 @@ func Stop() error {
 	return s.RunCommand("stop")
 }
 
+// StopAndWait sends "stop" and waits up to timeout for the process to exit.
+func StopAndWait(timeout time.Duration) error {
+	serverMu.Lock()
+	s := activeServer
+	serverMu.Unlock()
+
+	if s == nil || !s.GetStatus() {
+		return errors.New("server is not running")
+	}
+	if err := s.RunCommand("stop"); err != nil {
+		return err
+	}
+
+	select {
+	case <-s.done:
+		return nil
+	case <-time.After(timeout):
+		return ErrStopTimeout
+	}
+}
+
+// Restart stops the server gracefully and starts it again.
+func Restart(timeout time.Duration) error {
+	if err := StopAndWait(timeout); err != nil {
+		return err
+	}
+	log.Println("[i] Server restarting")
+	return Start()
+}
+
 func Kill() error {
 	serverMu.Lock()
 	s := activeServer
@@ -101,65 +237,149 @@ func GetStatus() bool {
 	return s.GetStatus()
 }
 
+// Uptime returns how long the server process has been running, or zero if it
+// isn't.
+func Uptime() time.Duration {
+	serverMu.Lock()
+	s := activeServer
+	serverMu.Unlock()
+
+	if s == nil {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isRunning {
+		return 0
+	}
+	return time.Since(s.started)
+}
+
+func (s *Server) uptime() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Since(s.started)
+}
+
+const (
+	StateStopped  = "stopped"
+	StateStarting = "starting"
+	StateRunning  = "running"
+	StateStopping = "stopping"
+)
+
+// Process describes the server process for status reporting.
+type Process struct {
+	State  string        `json:"state"`
+	PID    int           `json:"pid,omitempty"`
+	Ready  bool          `json:"ready"`
+	Uptime time.Duration `json:"-"`
+}
+
+// ProcessInfo returns the state of the server process. A server is starting
+// until it printed its "Done" line.
+func ProcessInfo() Process {
+	serverMu.Lock()
+	s := activeServer
+	serverMu.Unlock()
+
+	if s == nil {
+		return Process{State: StateStopped}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isRunning {
+		return Process{State: StateStopped}
+	}
+
+	p := Process{State: StateRunning, PID: s.cmd.Process.Pid, Uptime: time.Since(s.started)}
+	select {
+	case <-s.ready:
+		p.Ready = true
+	default:
+		p.State = StateStarting
+	}
+	if s.stopping {
+		p.State = StateStopping
+	}
+	return p
+}
+
 func (s *Server) startInternal() error {
-	s.cmd = exec.Command("java",
-		"-Xms2G", "-Xmx4G",
-		"-XX:+UseG1GC",
-		"-XX:+ParallelRefProcEnabled",
-		"-XX:+UnlockExperimentalVMOptions",
-		"-XX:+DisableExplicitGC",
-		"-XX:+AlwaysPreTouch",
-		"-XX:G1HeapWastePercent=5",
-		"-XX:G1MixedGCCountTarget=4",
-		"-XX:MaxGCPauseMillis=50",
-		"-XX:G1NewSizePercent=30",
-		"-XX:G1MaxNewSizePercent=40",
-		"-XX:G1HeapRegionSize=8M",
-		"-XX:+PerfDisableSharedMem",
-		"-XX:MaxDirectMemorySize=1G",
-		"-jar", "server.jar",
-		"nogui",
-	)
+	if fake := fakeCommand(); fake != nil {
+		s.cmd = exec.Command(fake[0], append(append(fake[1:], "nogui"), s.args...)...)
+	} else {
+		s.cmd = exec.Command(JavaBin(), append(javaArgs(), s.args...)...)
+	}
 	s.cmd.Dir = "minecraft"
 
+	launch, err := LoadLaunchConfig()
+	if err == nil {
+		err = launch.Validate()
+	}
+	if err != nil {
+		launch = LaunchConfig{}
+	}
+	if launch.WorkDir != "" {
+		if err := os.MkdirAll(launch.Dir(), 0755); err != nil {
+			log.Println("[e] Failed to create server working directory:", err)
+			return err
+		}
+	}
+
+	sandbox := sandboxFromEnv()
+	if err := sandbox.applySandbox(s.cmd); err != nil {
+		log.Println("[e] Failed to sandbox server process:", err)
+		return err
+	}
+	// Set after the sandbox, which hands the whole server directory over.
+	s.cmd.Dir = launch.Dir()
+	s.cmd.Env = launch.environ(s.cmd.Env)
+	priority, err := priorityFromEnv()
+	if err != nil {
+		log.Println("[e] Invalid server priority:", err)
+		return err
+	}
+
 	stdoutPipe, _ := s.cmd.StdoutPipe()
 	stderrPipe, _ := s.cmd.StderrPipe()
 	stdinPipe, _ := s.cmd.StdinPipe()
 
+	resetJavaOOM()
+	oomKills, oomCounter := pkg.CgroupOOMKills()
+
 	if err := s.cmd.Start(); err != nil {
 		log.Println("[e] Failed to start server process:", err)
 		return err
 	}
+	sandbox.applyLimits(s.cmd.Process.Pid)
+	priority.applyPriority(s.cmd.Process.Pid)
+	if desc := sandbox.String(); desc != "" {
+		log.Println("[i] server process sandboxed:", desc)
+	}
 
 	s.mu.Lock()
 	s.isRunning = true
+	s.started = time.Now()
 	s.mu.Unlock()
+	s.session = startSession(s.started)
 
 	// WaitGroup om te zorgen dat alle output is gelezen voor we afsluiten
 	var wg sync.WaitGroup
 	wg.Add(2)
 
-	go s.pipeAndLog(stdoutPipe, "[g] ", &wg)
-	go s.pipeAndLog(stderrPipe, "[g] ", &wg)
+	go s.pipeAndLog(stdoutPipe, "[g] ", sessions.Stdout, &wg)
+	go s.pipeAndLog(stderrPipe, "[g] ", sessions.Stderr, &wg)
 
-	// Verbeterde STDIN handler
-	go func() {
-		defer stdinPipe.Close()
-		for {
-			select {
-			case cmd, ok := <-s.stdin:
-				if !ok {
-					return
-				}
-				io.WriteString(stdinPipe, cmd+"\n")
-			case <-s.done:
-				return
-			}
-		}
-	}()
+	go s.writeCommands(stdinPipe)
 
 	// Proces monitor
 	go func() {
+		// Wacht tot de pipes leeg zijn, Wait sluit ze en de laatste regels
+		// (zoals een OutOfMemoryError) gaan anders verloren.
+		wg.Wait()
 		err := s.cmd.Wait()
 		if err != nil {
 			log.Println("[e] Server exited with error:", err)
@@ -170,16 +390,36 @@ func (s *Server) startInternal() error {
 		close(s.done)
 		s.mu.Unlock()
 
-		// Wacht tot de pipes leeg zijn
-		wg.Wait()
+		resetPlayers()
+		resetSaves()
+		s.dropQueued()
+
+		if !s.IsReady() {
+			PublishStartup(StartupEvent{Stage: StageFailed, Percent: -1, Message: "server exited before it finished starting"})
+		}
+
+		if s.session != nil {
+			if err := s.session.Close(); err != nil {
+				log.Println("[w] could not finish the console session:", err)
+			}
+		}
+
+		incident := classifyExit(s, s.cmd.ProcessState, oomKills, oomCounter)
+		if incident != nil {
+			recordIncident(incident)
+		}
+		recordExit(s, s.cmd.ProcessState, incident != nil)
 
 		serverMu.Lock()
 		if activeServer == s {
 			activeServer = nil
 		}
 		serverMu.Unlock()
+		publishState(StateStopped, incident != nil)
 
 		log.Println("[i] Server process cleanup finished.")
+		runStopHandlers(s.cmd.ProcessState.ExitCode())
+		superviseExit(s, incident)
 	}()
 
 	return nil
@@ -193,6 +433,8 @@ func (s *Server) Kill() error {
 		return errors.New("server is not running")
 	}
 
+	s.stopping = true
+	publishState(StateStopping, false)
 	return s.cmd.Process.Kill()
 }
 
@@ -201,36 +443,61 @@ func (s *Server) RunCommand(cmd string) error {
 		return errors.New("server is not running")
 	}
 
-	select {
-	case s.stdin <- cmd:
-		// Als het command "stop" is, sluiten we de stdin kanaal na een korte delay
-		if cmd == "stop" {
-			go func() {
-				time.Sleep(2 * time.Second)
-				s.mu.Lock()
-				// We sluiten het kanaal niet handmatig hier,
-				// dat doet de done-goroutine
-				s.mu.Unlock()
-			}()
+	if s.isStopCommand(cmd) {
+		if s.proxy {
+			cmd = "end"
 		}
-		return nil
+		s.mu.Lock()
+		s.stopping = true
+		s.mu.Unlock()
+		publishState(StateStopping, false)
+	}
+
+	return s.send(cmd)
+}
+
+// IsReady reports whether the server has finished starting up and printed its
+// "Done" line.
+func (s *Server) IsReady() bool {
+	select {
+	case <-s.ready:
+		return true
 	default:
-		return errors.New("command queue full")
+		return false
 	}
 }
 
+// Stopping reports whether the server was asked to stop or was killed.
+func (s *Server) Stopping() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopping
+}
+
 func (s *Server) GetStatus() bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	return s.isRunning
 }
 
-func (s *Server) pipeAndLog(pipeReader io.ReadCloser, prefix string, wg *sync.WaitGroup) {
+func (s *Server) pipeAndLog(pipeReader io.ReadCloser, prefix, kind string, wg *sync.WaitGroup) {
 	defer wg.Done()
 	defer pipeReader.Close()
 	scanner := bufio.NewScanner(pipeReader)
 	for scanner.Scan() {
 		text := scanner.Text()
 		log.Println(prefix, text)
+		s.record(kind, text)
+		s.tail.add(text)
+		if s.readyPattern.MatchString(text) {
+			s.readyOnce.Do(func() {
+				close(s.ready)
+				PublishStartup(StartupEvent{Stage: StageDone, Percent: 100})
+				publishState(StateRunning, false)
+				go runReadyHandlers()
+				go s.flushQueued()
+			})
+		}
+		dispatchLine(text)
 	}
 }
@@ -0,0 +1,375 @@
+package server
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestMain builds cmd/fakeserver and runs the tests in a scratch directory
+// with FAKE_SERVER pointing at it, so the lifecycle can be tested without
+// java or a server jar.
+func TestMain(m *testing.M) {
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	flag.Parse()
+	dir, err := os.MkdirTemp("", "minimc-server-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer os.RemoveAll(dir)
+
+	bin := filepath.Join(dir, "fakeserver")
+	build := exec.Command("go", "build", "-o", bin, "pkg.bijsven.nl/MiniMC/cmd/fakeserver")
+	build.Stdout, build.Stderr = os.Stderr, os.Stderr
+	if err := build.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, "building the fake server:", err)
+		return 1
+	}
+
+	work := filepath.Join(dir, "work")
+	if err := os.MkdirAll(filepath.Join(work, "minecraft"), 0755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := os.Chdir(work); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	os.Setenv("FAKE_SERVER", bin+" -startup 100ms")
+	os.Setenv("CONSOLE_SESSIONS", "0")
+	os.Unsetenv("AUTO_RESTART")
+	if !testing.Verbose() {
+		log.SetOutput(io.Discard)
+	}
+	return m.Run()
+}
+
+const testTimeout = 10 * time.Second
+
+// waitFor polls cond until it holds or testTimeout passes.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// startReady starts the fake server and waits for its "Done" line.
+func startReady(t *testing.T) {
+	t.Helper()
+	if err := Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() {
+		if GetStatus() {
+			Kill()
+			waitStopped(t)
+		}
+	})
+	waitFor(t, "the server to be ready", func() bool { return ProcessInfo().Ready })
+}
+
+// waitStopped waits until the exit of the server has been recorded and it
+// can be started again.
+func waitStopped(t *testing.T) {
+	t.Helper()
+	waitFor(t, "the server to stop", func() bool {
+		serverMu.Lock()
+		defer serverMu.Unlock()
+		return activeServer == nil
+	})
+}
+
+func TestStartStop(t *testing.T) {
+	if err := Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if p := ProcessInfo(); p.State != StateStarting || p.Ready {
+		t.Errorf("right after Start got %+v, want a starting server", p)
+	}
+	if err := Start(); err != ErrServerExists {
+		t.Errorf("second Start returned %v, want ErrServerExists", err)
+	}
+	waitFor(t, "the server to be ready", func() bool { return ProcessInfo().Ready })
+	if p := ProcessInfo(); p.State != StateRunning || p.PID == 0 {
+		t.Errorf("once ready got %+v, want a running server with a pid", p)
+	}
+	if e := LastStartup(); e == nil || e.Stage != StageDone {
+		t.Errorf("last startup event is %+v, want %s", e, StageDone)
+	}
+
+	if err := StopAndWait(testTimeout); err != nil {
+		t.Fatalf("StopAndWait: %v", err)
+	}
+	waitStopped(t)
+	if GetStatus() {
+		t.Error("server still running after StopAndWait")
+	}
+	e, err := LastExit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Crashed || e.Code != 0 {
+		t.Errorf("stop recorded as %+v, want a clean exit", e)
+	}
+}
+
+func TestKill(t *testing.T) {
+	startReady(t)
+	if err := Kill(); err != nil {
+		t.Fatalf("Kill: %v", err)
+	}
+	waitStopped(t)
+	e, err := LastExit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Crashed || e.Signal == "" {
+		t.Errorf("kill recorded as %+v, want a signal and no crash", e)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	startReady(t)
+	pid := ProcessInfo().PID
+	if err := Restart(testTimeout); err != nil {
+		t.Fatalf("Restart: %v", err)
+	}
+	waitFor(t, "the restarted server to be ready", func() bool {
+		p := ProcessInfo()
+		return p.Ready && p.PID != pid
+	})
+}
+
+func TestStopWhenStopped(t *testing.T) {
+	if err := StopAndWait(time.Second); err == nil {
+		t.Error("StopAndWait without a server succeeded")
+	}
+	if err := Kill(); err == nil {
+		t.Error("Kill without a server succeeded")
+	}
+}
+
+func TestCrashDetection(t *testing.T) {
+	tests := []struct {
+		command string
+		kind    string
+	}{
+		{"fake crash", IncidentCrash},
+		{"fake oom", IncidentJavaOOM},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			startReady(t)
+			if err := RunCommand(tt.command); err != nil {
+				t.Fatal(err)
+			}
+			waitStopped(t)
+
+			e, err := LastExit()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !e.Crashed || e.Code != 1 || len(e.Output) == 0 {
+				t.Errorf("exit recorded as %+v, want a crash with its output", e)
+			}
+			incidents, err := Incidents()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(incidents) == 0 || incidents[0].Kind != tt.kind {
+				t.Errorf("latest incidents %+v, want a %s; exit %+v", incidents, tt.kind, e)
+			}
+		})
+	}
+}
+
+func TestLogParsing(t *testing.T) {
+	startReady(t)
+
+	var (
+		mu           sync.Mutex
+		joined, left []string
+	)
+	OnPlayer(func(name string, join bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if join {
+			joined = append(joined, name)
+		} else {
+			left = append(left, name)
+		}
+	})
+
+	if _, err := RunCommandWait("fake join Alex", regexp.MustCompile(`Alex joined the game`), testTimeout); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RunCommandWait("fake join Steve", regexp.MustCompile(`Steve joined the game`), testTimeout); err != nil {
+		t.Fatal(err)
+	}
+	if got := OnlinePlayers(); len(got) != 2 || PlayerCount() != 2 {
+		t.Errorf("online players %v, want Alex and Steve", got)
+	}
+	if _, err := RunCommandWait("fake leave Alex", regexp.MustCompile(`Alex left the game`), testTimeout); err != nil {
+		t.Fatal(err)
+	}
+	if got := OnlinePlayers(); len(got) != 1 || got[0] != "Steve" {
+		t.Errorf("online players %v, want Steve", got)
+	}
+
+	if err := RunCommand("fake tps 12.5"); err != nil {
+		t.Fatal(err)
+	}
+	m, err := RunCommandWait("tps", tpsPattern, testTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m[1] != "12.5" {
+		t.Errorf("parsed TPS %q, want 12.5", m[1])
+	}
+	if _, ok := AverageTPS(); !ok {
+		t.Error("no TPS sample recorded")
+	}
+
+	if err := SetWorldBorder(1000, 0); err != nil {
+		t.Fatal(err)
+	}
+	size, err := WorldBorderSize()
+	if err != nil || size != 1000 {
+		t.Errorf("world border %v (%v), want 1000", size, err)
+	}
+
+	// Players still online leave when the server stops.
+	if err := StopAndWait(testTimeout); err != nil {
+		t.Fatal(err)
+	}
+	waitStopped(t)
+	if PlayerCount() != 0 {
+		t.Errorf("%d players online after the server stopped", PlayerCount())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(joined) != 2 || len(left) != 2 {
+		t.Errorf("joined %v and left %v, want both players in each", joined, left)
+	}
+}
+
+func TestReadyPattern(t *testing.T) {
+	tests := []struct {
+		flavor string
+		line   string
+		ready  bool
+	}{
+		{"paper", `[12:00:00 INFO]: Done (3.512s)! For help, type "help"`, true},
+		{"paper", `[12:00:00 INFO]: Done (3,512s)! For help, type "help"`, true},
+		{"paper", `[12:00:00 INFO]: Preparing level "world"`, false},
+		{"folia", `[12:00:00 INFO]: Done preparing level "world" (3.512s)`, true},
+		{"velocity", `[12:00:00 INFO]: Listening on /0.0.0.0:25577`, true},
+		{"velocity", `[12:00:00 INFO]: Done (3.512s)! For help, type "help"`, false},
+	}
+	for _, tt := range tests {
+		if got := readyPattern(tt.flavor).MatchString(tt.line); got != tt.ready {
+			t.Errorf("%s: ready(%q) = %v, want %v", tt.flavor, tt.line, got, tt.ready)
+		}
+	}
+}
+
+func TestTPSPattern(t *testing.T) {
+	tests := []struct {
+		line string
+		tps  string
+	}{
+		{`[12:00:00 INFO]: TPS from last 1m, 5m, 15m: 19.5, 20.0, 20.0`, "19.5"},
+		{"[12:00:00 INFO]: §6TPS from last 1m, 5m, 15m: §a*20.0, §a20.0, §a20.0", "20.0"},
+		{"[12:00:00 INFO]: \x1b[0;33mTPS from last 1m, 5m, 15m: \x1b[0;32m18.2, 20.0, 20.0", "18.2"},
+		{`[12:00:00 INFO]: <Mallory> TPS from last 1m, 5m, 15m: 1.0, 1.0, 1.0`, ""},
+		{`[12:00:00 INFO]: [Not Secure] <Mallory> TPS from last 1m, 5m, 15m: 1.0`, ""},
+		{`[12:00:00 INFO]: <Mallory> hi [12:00:00 INFO]: TPS from last 1m, 5m, 15m: 1.0`, ""},
+	}
+	for _, tt := range tests {
+		var got string
+		if m := tpsPattern.FindStringSubmatch(tt.line); m != nil {
+			got = m[1]
+		}
+		if got != tt.tps {
+			t.Errorf("TPS in %q = %q, want %q", tt.line, got, tt.tps)
+		}
+	}
+}
+
+func TestPlayerLinesFromChat(t *testing.T) {
+	t.Cleanup(resetPlayers)
+	trackPlayers(`[12:00:00 INFO]: Steve joined the game`)
+	trackPlayers(`[12:00:00] [Server thread/INFO]: Alex joined the game`)
+
+	for _, line := range []string{
+		`[12:00:00 INFO]: <Alex> Mallory joined the game`,
+		`[12:00:00 INFO]: <Alex> hi ]: Mallory joined the game`,
+		`[12:00:00 INFO]: [Not Secure] <Alex> x: Steve left the game`,
+		`[12:00:00 INFO]: <Alex> [12:00:00 INFO]: Steve left the game`,
+		`[12:00:00 INFO]: <Alex> hi ]: Steve issued server command: /help`,
+		`[12:00:00 INFO]: <Alex> x: Steve has made the advancement [Stone Age]`,
+		`[12:00:00 INFO]: [Alex] ]: <Steve> hi`,
+	} {
+		trackPlayers(line)
+	}
+	if got := OnlinePlayers(); len(got) != 2 || got[0] != "Alex" || got[1] != "Steve" {
+		t.Errorf("online players %v after chat, want Alex and Steve", got)
+	}
+	playersMu.Lock()
+	_, steveActive := lastActive["Steve"]
+	_, alexActive := lastActive["Alex"]
+	playersMu.Unlock()
+	if steveActive {
+		t.Error("chat from Alex counted as activity of Steve")
+	}
+	if !alexActive {
+		t.Error("chat from Alex didn't count as activity of Alex")
+	}
+
+	trackPlayers(`[12:00:00] [Server thread/INFO]: Steve issued server command: /help`)
+	trackPlayers(`[12:00:00 INFO]: Alex left the game`)
+	playersMu.Lock()
+	_, steveActive = lastActive["Steve"]
+	playersMu.Unlock()
+	if got := OnlinePlayers(); !steveActive || len(got) != 1 || got[0] != "Steve" {
+		t.Errorf("online players %v (Steve active %v), want only an active Steve", got, steveActive)
+	}
+}
+
+func TestBorderSizePattern(t *testing.T) {
+	tests := []struct {
+		line string
+		size string
+	}{
+		{`[12:00:00 INFO]: The world border is currently 1000 block(s) wide`, "1000"},
+		{`[12:00:00] [Server thread/INFO]: The world border is currently 59999968 blocks wide`, "59999968"},
+		{`[12:00:00 INFO]: <Mallory> The world border is currently 1 block(s) wide`, ""},
+		{`[12:00:00 INFO]: <Mallory> hi ]: The world border is currently 1 block(s) wide`, ""},
+	}
+	for _, tt := range tests {
+		var got string
+		if m := borderSizePattern.FindStringSubmatch(tt.line); m != nil {
+			got = m[1]
+		}
+		if got != tt.size {
+			t.Errorf("border size in %q = %q, want %q", tt.line, got, tt.size)
+		}
+	}
+}
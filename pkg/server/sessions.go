@@ -0,0 +1,35 @@
+package server
+
+import (
+	"log"
+	"time"
+
+	"pkg.bijsven.nl/MiniMC/pkg"
+	"pkg.bijsven.nl/MiniMC/pkg/sessions"
+)
+
+// startSession starts recording the console of a server run, keeping the
+// last CONSOLE_SESSIONS runs (default 20, 0 disables recording). It returns
+// nil when the console isn't recorded.
+func startSession(started time.Time) *sessions.Recorder {
+	keep := envInt("CONSOLE_SESSIONS", 20)
+	if keep <= 0 {
+		return nil
+	}
+	h := sessions.Header{Started: started}
+	if m, err := pkg.LoadManifest(); err == nil {
+		h.Flavor, h.Version = m.Flavor, m.Version
+	}
+	r, err := sessions.Start(h, keep)
+	if err != nil {
+		log.Println("[w] could not record the console session:", err)
+		return nil
+	}
+	return r
+}
+
+func (s *Server) record(kind, line string) {
+	if s.session != nil {
+		s.session.Record(kind, line)
+	}
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Startup stages, in the order they normally happen.
+const (
+	StageDownloading   = "downloading"
+	StageVerifying     = "verifying"
+	StageLaunching     = "launching"
+	StageLoadingWorlds = "loading_worlds"
+	StageDone          = "done"
+	StageFailed        = "failed"
+)
+
+// StartupEvent reports startup progress. Percent is the progress within the
+// stage, or -1 when it isn't known.
+type StartupEvent struct {
+	Stage   string    `json:"stage"`
+	Percent int       `json:"percent"`
+	World   string    `json:"world,omitempty"`
+	Message string    `json:"message,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+var (
+	levelPattern = regexp.MustCompile(`Preparing level "([^"]+)"`)
+	spawnPattern = regexp.MustCompile(`Preparing spawn area: (\d+)%`)
+
+	startupMu     sync.Mutex
+	lastStartup   *StartupEvent
+	startupSubs   []chan StartupEvent
+	startupActive bool
+	startupWorld  string
+)
+
+func init() {
+	OnLine(trackStartup)
+}
+
+// PublishStartup records e as the current startup progress and passes it to
+// all subscribers. Slow subscribers miss events rather than block startup.
+func PublishStartup(e StartupEvent) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	startupMu.Lock()
+	defer startupMu.Unlock()
+
+	switch e.Stage {
+	case StageDone, StageFailed:
+		startupActive = false
+	default:
+		startupActive = true
+	}
+	if e.Stage == StageLaunching {
+		startupWorld = ""
+	}
+
+	lastStartup = &e
+	for _, ch := range startupSubs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// LastStartup returns the most recent startup event, or nil if the server
+// was never started.
+func LastStartup() *StartupEvent {
+	startupMu.Lock()
+	defer startupMu.Unlock()
+	if lastStartup == nil {
+		return nil
+	}
+	e := *lastStartup
+	return &e
+}
+
+func SubscribeStartup() <-chan StartupEvent {
+	ch := make(chan StartupEvent, 100)
+	startupMu.Lock()
+	startupSubs = append(startupSubs, ch)
+	startupMu.Unlock()
+	return ch
+}
+
+func UnsubscribeStartup(ch <-chan StartupEvent) {
+	startupMu.Lock()
+	defer startupMu.Unlock()
+	for i, sub := range startupSubs {
+		if sub == ch {
+			startupSubs = append(startupSubs[:i], startupSubs[i+1:]...)
+			return
+		}
+	}
+}
+
+func trackStartup(line string) {
+	startupMu.Lock()
+	active := startupActive
+	startupMu.Unlock()
+	if !active {
+		return
+	}
+
+	if m := levelPattern.FindStringSubmatch(line); m != nil {
+		startupMu.Lock()
+		startupWorld = m[1]
+		startupMu.Unlock()
+		PublishStartup(StartupEvent{Stage: StageLoadingWorlds, Percent: 0, World: m[1]})
+		return
+	}
+	if m := spawnPattern.FindStringSubmatch(line); m != nil {
+		percent, _ := strconv.Atoi(m[1])
+		startupMu.Lock()
+		world := startupWorld
+		startupMu.Unlock()
+		PublishStartup(StartupEvent{Stage: StageLoadingWorlds, Percent: percent, World: world})
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"pkg.bijsven.nl/MiniMC/pkg"
+)
+
+// Kinds of status events.
+const (
+	EventState   = "state"
+	EventPlayers = "players"
+	EventUpdate  = "update"
+)
+
+// StatusEvent reports a change of the process state or the player count. A
+// state event to StateStopped with Crashed set means the server crashed. An
+// update event announces a newer build in Update.
+type StatusEvent struct {
+	Kind     string               `json:"kind"`
+	State    string               `json:"state,omitempty"`
+	Previous string               `json:"previous,omitempty"`
+	Crashed  bool                 `json:"crashed,omitempty"`
+	Players  int                  `json:"players"`
+	Update   *pkg.AvailableUpdate `json:"update,omitempty"`
+	Time     time.Time            `json:"time"`
+}
+
+var (
+	statusMu   sync.Mutex
+	statusSubs []chan StatusEvent
+	lastState  = StateStopped
+)
+
+func publishStatus(e StatusEvent) {
+	e.Time = time.Now()
+	e.Players = PlayerCount()
+
+	statusMu.Lock()
+	defer statusMu.Unlock()
+
+	if e.Kind == EventState {
+		if e.State == lastState && !e.Crashed {
+			return
+		}
+		e.Previous, lastState = lastState, e.State
+	}
+	for _, ch := range statusSubs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+func publishState(state string, crashed bool) {
+	publishStatus(StatusEvent{Kind: EventState, State: state, Crashed: crashed})
+}
+
+func publishPlayers() {
+	publishStatus(StatusEvent{Kind: EventPlayers})
+}
+
+// PublishUpdate announces a newer server build to status subscribers.
+func PublishUpdate(u *pkg.AvailableUpdate) {
+	publishStatus(StatusEvent{Kind: EventUpdate, Update: u})
+}
+
+// SubscribeStatus returns a channel receiving every status change. Slow
+// subscribers miss events rather than block the server.
+func SubscribeStatus() <-chan StatusEvent {
+	ch := make(chan StatusEvent, 100)
+	statusMu.Lock()
+	statusSubs = append(statusSubs, ch)
+	statusMu.Unlock()
+	return ch
+}
+
+func UnsubscribeStatus(ch <-chan StatusEvent) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	for i, sub := range statusSubs {
+		if sub == ch {
+			statusSubs = append(statusSubs[:i], statusSubs[i+1:]...)
+			return
+		}
+	}
+}
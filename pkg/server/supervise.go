@@ -0,0 +1,80 @@
+package server
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// stableUptime is how long the server must run before earlier crashes no
+// longer count towards AUTO_RESTART_MAX_RETRIES.
+const stableUptime = 10 * time.Minute
+
+var (
+	superviseMu    sync.Mutex
+	crashFailures  int
+	pendingRestart *time.Timer
+)
+
+// superviseExit restarts the server after a crash when AUTO_RESTART=true. The
+// delay doubles with every consecutive crash, starting at
+// AUTO_RESTART_BACKOFF and capped at 10 minutes, and retries stop after
+// AUTO_RESTART_MAX_RETRIES crashes in a row.
+func superviseExit(s *Server, incident *Incident) {
+	if incident == nil || os.Getenv("AUTO_RESTART") != "true" {
+		return
+	}
+	if s.trial {
+		// watchTrial reverts the jar and restarts on its own.
+		return
+	}
+
+	superviseMu.Lock()
+	defer superviseMu.Unlock()
+
+	if s.uptime() >= stableUptime {
+		crashFailures = 0
+	}
+	crashFailures++
+
+	if crashLooping() {
+		log.Printf("[!] server crashed %d times within %s, not restarting it automatically", crashLoopCount, crashLoopWindow)
+		return
+	}
+
+	maxRetries := envInt("AUTO_RESTART_MAX_RETRIES", 5)
+	if crashFailures > maxRetries {
+		log.Printf("[!] server crashed %d times in a row, giving up on automatic restarts", crashFailures)
+		return
+	}
+
+	delay := envDuration("AUTO_RESTART_BACKOFF", 10*time.Second)
+	for i := 1; i < crashFailures && delay < 10*time.Minute; i++ {
+		delay *= 2
+	}
+	delay = min(delay, 10*time.Minute)
+
+	log.Printf("[!] server crashed (%s), restarting in %s (attempt %d of %d)", incident.Kind, delay, crashFailures, maxRetries)
+	pendingRestart = time.AfterFunc(delay, func() {
+		superviseMu.Lock()
+		pendingRestart = nil
+		superviseMu.Unlock()
+
+		if err := Start(); err != nil {
+			log.Println("[e] automatic restart failed:", err)
+		}
+	})
+}
+
+// cancelPendingRestart drops a scheduled crash restart, used when the server
+// is started by hand in the meantime.
+func cancelPendingRestart() {
+	superviseMu.Lock()
+	defer superviseMu.Unlock()
+
+	if pendingRestart != nil {
+		pendingRestart.Stop()
+		pendingRestart = nil
+	}
+}
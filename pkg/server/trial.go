@@ -0,0 +1,56 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"pkg.bijsven.nl/MiniMC/pkg"
+)
+
+// watchTrial waits for the first start of a freshly updated jar. If the server
+// doesn't print its "Done" line within JAR_TRIAL_TIMEOUT, or exits before
+// that, the previous jar is restored and the server started again.
+func watchTrial(s *Server) {
+	timeout := envDuration("JAR_TRIAL_TIMEOUT", 5*time.Minute)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var reason string
+	select {
+	case <-s.ready:
+		if err := pkg.ConfirmJar(); err != nil {
+			log.Println("[e] failed to confirm new server jar:", err)
+			return
+		}
+		log.Println("[i] new server jar started successfully")
+		return
+	case <-s.done:
+		if s.Stopping() {
+			// Stopped by hand, try again on the next start.
+			return
+		}
+		reason = "server exited before it finished starting"
+	case <-timer.C:
+		reason = fmt.Sprintf("server did not finish starting within %s", timeout)
+		log.Println("[!] " + reason + ", killing it")
+		s.Kill()
+		<-s.done
+	}
+
+	// Wait for the monitor goroutine to release the active server.
+	for GetStatus() {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	m, err := pkg.RevertJar(reason)
+	if err != nil {
+		log.Println("[e] failed to revert to the previous server jar:", err)
+		return
+	}
+	log.Printf("[!] reverted to %s build %d: %s", m.Version, m.Build, reason)
+
+	if err := Start(); err != nil {
+		log.Println("[e] failed to start the server after reverting:", err)
+	}
+}
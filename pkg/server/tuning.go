@@ -0,0 +1,155 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// tpsPattern matches the reply to /tps, which Paper may color, and not a
+// player saying the same thing in chat.
+var tpsPattern = regexp.MustCompile(LogPrefix + `(?:\x1b\[[\d;]*m|§.)*TPS from last 1m, 5m, 15m: (?:\x1b\[[\d;]*m|[^\d\x1b])*([\d.]+)`)
+
+const tpsSampleCount = 5
+
+var (
+	tpsMu      sync.Mutex
+	tpsSamples []float64
+)
+
+func init() {
+	OnLine(trackTPS)
+}
+
+func trackTPS(line string) {
+	m := tpsPattern.FindStringSubmatch(line)
+	if m == nil {
+		return
+	}
+	tps, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return
+	}
+
+	tpsMu.Lock()
+	tpsSamples = append(tpsSamples, tps)
+	if len(tpsSamples) > tpsSampleCount {
+		tpsSamples = tpsSamples[len(tpsSamples)-tpsSampleCount:]
+	}
+	tpsMu.Unlock()
+}
+
+// AverageTPS returns the mean of the most recent TPS samples, or false when
+// no samples have been collected yet.
+func AverageTPS() (float64, bool) {
+	tpsMu.Lock()
+	defer tpsMu.Unlock()
+
+	if len(tpsSamples) == 0 {
+		return 0, false
+	}
+	var sum float64
+	for _, s := range tpsSamples {
+		sum += s
+	}
+	return sum / float64(len(tpsSamples)), true
+}
+
+type TuningConfig struct {
+	Interval          time.Duration
+	MinView, MaxView  int
+	MinSim, MaxSim    int
+	PlayersPerStep    int
+	LowTPS, HighTPS   float64
+	ViewCommand       string
+	SimulationCommand string
+}
+
+// TuningConfigFromEnv reads the tuner settings. The tuner is only enabled when
+// TUNING_VIEW_COMMAND is set, since neither vanilla nor Paper ship a console
+// command to change the view distance at runtime.
+func TuningConfigFromEnv() (TuningConfig, bool) {
+	cfg := TuningConfig{
+		Interval:          envDuration("TUNING_INTERVAL", time.Minute),
+		MinView:           envInt("TUNING_MIN_VIEW", 4),
+		MaxView:           envInt("TUNING_MAX_VIEW", 10),
+		MinSim:            envInt("TUNING_MIN_SIMULATION", 4),
+		MaxSim:            envInt("TUNING_MAX_SIMULATION", 10),
+		PlayersPerStep:    envInt("TUNING_PLAYERS_PER_STEP", 4),
+		LowTPS:            envFloat("TUNING_LOW_TPS", 18),
+		HighTPS:           envFloat("TUNING_HIGH_TPS", 19.5),
+		ViewCommand:       os.Getenv("TUNING_VIEW_COMMAND"),
+		SimulationCommand: os.Getenv("TUNING_SIMULATION_COMMAND"),
+	}
+	if cfg.PlayersPerStep < 1 {
+		cfg.PlayersPerStep = 1
+	}
+	return cfg, cfg.ViewCommand != ""
+}
+
+// RunTuner periodically samples TPS and the online player count and lowers
+// or raises the view and simulation distance accordingly. Distances are only
+// raised again once TPS is back above HighTPS, so the server doesn't flap
+// between two values.
+func RunTuner(cfg TuningConfig) {
+	log.Printf("[i] dynamic tuning enabled (view %d-%d, simulation %d-%d)",
+		cfg.MinView, cfg.MaxView, cfg.MinSim, cfg.MaxSim)
+
+	current := -1
+	for range time.Tick(cfg.Interval) {
+		if !GetStatus() {
+			current = -1
+			continue
+		}
+
+		RunCommand("tps")
+		time.Sleep(2 * time.Second)
+
+		tps, ok := AverageTPS()
+		if !ok {
+			continue
+		}
+
+		target := clamp(cfg.MaxView-PlayerCount()/cfg.PlayersPerStep, cfg.MinView, cfg.MaxView)
+		if current != -1 {
+			if tps < cfg.LowTPS && target >= current {
+				target = clamp(current-1, cfg.MinView, cfg.MaxView)
+			}
+			if target > current && tps < cfg.HighTPS {
+				target = current
+			}
+		}
+		if target == current {
+			continue
+		}
+
+		sim := clamp(cfg.MaxSim-(cfg.MaxView-target), cfg.MinSim, cfg.MaxSim)
+		if err := RunCommand(fmt.Sprintf(cfg.ViewCommand, target)); err != nil {
+			log.Println("[e] tuning: failed to set view distance:", err)
+			continue
+		}
+		if cfg.SimulationCommand != "" {
+			if err := RunCommand(fmt.Sprintf(cfg.SimulationCommand, sim)); err != nil {
+				log.Println("[e] tuning: failed to set simulation distance:", err)
+			}
+		}
+
+		log.Printf("[i] tuning: %d players at %.1f TPS, view distance %d, simulation distance %d",
+			PlayerCount(), tps, target, sim)
+		current = target
+	}
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+)
+
+var (
+	Difficulties = []string{"peaceful", "easy", "normal", "hard"}
+	Weathers     = []string{"clear", "rain", "thunder"}
+	// TimesOfDay are the names time set accepts.
+	TimesOfDay = []string{"day", "noon", "night", "midnight"}
+)
+
+func oneOf(value string, list []string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func CheckDifficulty(difficulty string) error {
+	if !oneOf(difficulty, Difficulties) {
+		return fmt.Errorf("difficulty must be one of %v, got %q", Difficulties, difficulty)
+	}
+	return nil
+}
+
+func CheckTime(time string) error {
+	if !oneOf(time, TimesOfDay) {
+		if ticks, err := strconv.Atoi(time); err != nil || ticks < 0 {
+			return fmt.Errorf("time must be one of %v or a tick count, got %q", TimesOfDay, time)
+		}
+	}
+	return nil
+}
+
+func CheckWeather(weather string, seconds int) error {
+	if !oneOf(weather, Weathers) {
+		return fmt.Errorf("weather must be one of %v, got %q", Weathers, weather)
+	}
+	if seconds < 0 || seconds > 1000000 {
+		return fmt.Errorf("duration must be between 0 and 1000000 seconds, got %d", seconds)
+	}
+	return nil
+}
+
+func SetDifficulty(difficulty string) error {
+	if err := CheckDifficulty(difficulty); err != nil {
+		return err
+	}
+	if IsProxy() {
+		return ErrProxy
+	}
+	return RunCommand("difficulty " + difficulty)
+}
+
+// SetTime sets the time of day to a name from TimesOfDay or a tick count.
+// With freeze the daylight cycle stops there, without it the cycle is
+// resumed.
+func SetTime(time string, freeze bool) error {
+	if err := CheckTime(time); err != nil {
+		return err
+	}
+	if IsProxy() {
+		return ErrProxy
+	}
+	if err := RunCommand("time set " + time); err != nil {
+		return err
+	}
+	return RunCommand("gamerule doDaylightCycle " + strconv.FormatBool(!freeze))
+}
+
+// SetWeather changes the weather for seconds, or a random duration when zero.
+// With lock the weather cycle stops, without it the cycle is resumed.
+func SetWeather(weather string, seconds int, lock bool) error {
+	if err := CheckWeather(weather, seconds); err != nil {
+		return err
+	}
+	if IsProxy() {
+		return ErrProxy
+	}
+	cmd := "weather " + weather
+	if seconds > 0 {
+		cmd += " " + strconv.Itoa(seconds)
+	}
+	if err := RunCommand(cmd); err != nil {
+		return err
+	}
+	return RunCommand("gamerule doWeatherCycle " + strconv.FormatBool(!lock))
+}
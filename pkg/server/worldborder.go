@@ -0,0 +1,38 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// borderSizePattern matches the reply to "worldborder get", and not a player
+// typing it in chat.
+var borderSizePattern = regexp.MustCompile(LogPrefix + `The world border is currently ([\d.,]+) block`)
+
+// WorldBorderSize asks the server for the current world border diameter.
+func WorldBorderSize() (float64, error) {
+	m, err := RunCommandWait("worldborder get", borderSizePattern, 5*time.Second)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(m[1], 64)
+}
+
+// SetWorldBorder resizes the world border, growing or shrinking over seconds
+// when it is above zero.
+func SetWorldBorder(size float64, seconds int) error {
+	if size < 1 || size > 59999968 {
+		return fmt.Errorf("world border size must be between 1 and 59999968, got %g", size)
+	}
+	cmd := fmt.Sprintf("worldborder set %g", size)
+	if seconds > 0 {
+		cmd += " " + strconv.Itoa(seconds)
+	}
+	return RunCommand(cmd)
+}
+
+func SetWorldBorderCenter(x, z float64) error {
+	return RunCommand(fmt.Sprintf("worldborder center %g %g", x, z))
+}
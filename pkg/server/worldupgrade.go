@@ -0,0 +1,68 @@
+package server
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"time"
+
+	"pkg.bijsven.nl/MiniMC/pkg/jobs"
+)
+
+// upgradeProgressPattern matches the progress the server logs while
+// upgrading worlds with --forceUpgrade, e.g.
+// "45% completed (1234 / 2742 chunks)...".
+var upgradeProgressPattern = regexp.MustCompile(`(\d+)% completed \((\d+) / (\d+) chunks\)`)
+
+// upgradeLinePattern matches the other lines worth keeping in the job log.
+var upgradeLinePattern = regexp.MustCompile(`(?i)forcing world upgrade|upgrading|erasing cache`)
+
+// UpgradeWorlds starts the server once with --forceUpgrade, and
+// --eraseCache if set, which converts every chunk to the installed version
+// before the server finishes starting. The server is stopped again once it
+// is done, so the worlds can be backed up before players join. Progress is
+// reported on job, counting chunks.
+func UpgradeWorlds(eraseCache bool, job *jobs.Job) error {
+	if installedProxy() {
+		return errors.New("a proxy has no worlds to upgrade")
+	}
+
+	OnLine(func(line string) {
+		if job.Snapshot().Status != jobs.Running {
+			return
+		}
+		if m := upgradeProgressPattern.FindStringSubmatch(line); m != nil {
+			done, _ := strconv.ParseInt(m[2], 10, 64)
+			total, _ := strconv.ParseInt(m[3], 10, 64)
+			job.Update("chunks", done, total)
+			return
+		}
+		if upgradeLinePattern.MatchString(line) {
+			job.Log(line)
+		}
+	})
+
+	args := []string{"--forceUpgrade"}
+	if eraseCache {
+		args = append(args, "--eraseCache")
+	}
+	if err := StartWith(args...); err != nil {
+		return err
+	}
+
+	serverMu.Lock()
+	s := activeServer
+	serverMu.Unlock()
+	if s == nil {
+		return errors.New("server exited before the upgrade finished")
+	}
+
+	select {
+	case <-s.ready:
+	case <-s.done:
+		return errors.New("server exited before the upgrade finished")
+	}
+	job.Log("worlds upgraded, stopping the server")
+	// The upgrade is done by now, stopping only saves the spawn chunks.
+	return StopAndWait(5 * time.Minute)
+}
@@ -0,0 +1,267 @@
+// Package sessions records the console of every server run to a file that
+// can be replayed later.
+package sessions
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Dir holds the session files, next to MiniMC.
+const Dir = "sessions"
+
+const idLayout = "20060102-150405"
+
+// Kinds of entries: output on stdout or stderr, and commands sent to stdin.
+const (
+	Stdout  = "out"
+	Stderr  = "err"
+	Command = "cmd"
+)
+
+var ErrNotFound = errors.New("session not found")
+
+// Header is the first line of a session file.
+type Header struct {
+	Started time.Time `json:"started"`
+	Flavor  string    `json:"flavor,omitempty"`
+	Version string    `json:"version,omitempty"`
+}
+
+// Entry is a console line, At milliseconds after the session started.
+type Entry struct {
+	At   int64  `json:"t"`
+	Kind string `json:"k"`
+	Line string `json:"l"`
+}
+
+// Info describes a recorded session.
+type Info struct {
+	ID string `json:"id"`
+	Header
+	Duration float64 `json:"duration_seconds"`
+	Lines    int     `json:"lines"`
+	Commands int     `json:"commands"`
+	Size     int64   `json:"size"`
+	Active   bool    `json:"active"`
+}
+
+// Recorder writes the console of one server run.
+type Recorder struct {
+	mu      sync.Mutex
+	id      string
+	file    *os.File
+	w       *bufio.Writer
+	enc     *json.Encoder
+	started time.Time
+	flush   *time.Timer
+}
+
+var (
+	activeMu sync.Mutex
+	active   string
+)
+
+// Start creates a new session file and removes the oldest ones beyond keep.
+func Start(h Header, keep int) (*Recorder, error) {
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		return nil, err
+	}
+	id := h.Started.Format(idLayout)
+	f, err := os.OpenFile(path(id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	// A server restarted within the same second.
+	for n := 2; errors.Is(err, os.ErrExist) && n < 10; n++ {
+		id = h.Started.Format(idLayout) + "-" + strconv.Itoa(n)
+		f, err = os.OpenFile(path(id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	}
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(f)
+	r := &Recorder{id: id, file: f, w: w, enc: json.NewEncoder(w), started: h.Started}
+	err = r.enc.Encode(h)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	activeMu.Lock()
+	active = id
+	activeMu.Unlock()
+	prune(keep)
+	return r, nil
+}
+
+// ID returns the session's id.
+func (r *Recorder) ID() string {
+	return r.id
+}
+
+// Record appends a line. Lines are written out within a second, so a replay
+// of the running session is never far behind.
+func (r *Recorder) Record(kind, line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return
+	}
+	r.enc.Encode(Entry{At: time.Since(r.started).Milliseconds(), Kind: kind, Line: line})
+	if r.flush == nil {
+		r.flush = time.AfterFunc(time.Second, func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.flush = nil
+			if r.file != nil {
+				r.w.Flush()
+			}
+		})
+	}
+}
+
+// Close writes the remaining lines and closes the file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	if r.flush != nil {
+		r.flush.Stop()
+	}
+	err := r.w.Flush()
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	r.file = nil
+
+	activeMu.Lock()
+	if active == r.id {
+		active = ""
+	}
+	activeMu.Unlock()
+	return err
+}
+
+func path(id string) string {
+	return filepath.Join(Dir, id+".jsonl")
+}
+
+// ids returns the recorded sessions, oldest first.
+func ids() []string {
+	paths, _ := filepath.Glob(filepath.Join(Dir, "*.jsonl"))
+	list := make([]string, 0, len(paths))
+	for _, p := range paths {
+		list = append(list, strings.TrimSuffix(filepath.Base(p), ".jsonl"))
+	}
+	sort.Strings(list)
+	return list
+}
+
+func prune(keep int) {
+	list := ids()
+	for len(list) > keep {
+		os.Remove(path(list[0]))
+		list = list[1:]
+	}
+}
+
+// List describes the recorded sessions, newest first.
+func List() []Info {
+	list := ids()
+	infos := make([]Info, 0, len(list))
+	for i := len(list) - 1; i >= 0; i-- {
+		info, err := Stat(list[i])
+		if err != nil {
+			continue
+		}
+		infos = append(infos, *info)
+	}
+	return infos
+}
+
+// Stat describes the session id.
+func Stat(id string) (*Info, error) {
+	info := &Info{ID: id}
+	err := Read(id, func(h Header) {
+		info.Header = h
+	}, func(e Entry) error {
+		info.Lines++
+		if e.Kind == Command {
+			info.Commands++
+		}
+		info.Duration = float64(e.At) / 1000
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := os.Stat(path(id)); err == nil {
+		info.Size = fi.Size()
+	}
+	activeMu.Lock()
+	info.Active = active == id
+	activeMu.Unlock()
+	return info, nil
+}
+
+// Path returns the file of session id.
+func Path(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return "", ErrNotFound
+	}
+	p := path(id)
+	if _, err := os.Stat(p); err != nil {
+		return "", ErrNotFound
+	}
+	return p, nil
+}
+
+// Read calls header with the session's header and fn with every entry in
+// order, stopping at the first error fn returns.
+func Read(id string, header func(Header), fn func(Entry) error) error {
+	p, err := Path(id)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	first := true
+	for scanner.Scan() {
+		if first {
+			first = false
+			var h Header
+			if err := json.Unmarshal(scanner.Bytes(), &h); err != nil {
+				return err
+			}
+			header(h)
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// The last line of a session that is still being
+			// written may be incomplete.
+			continue
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
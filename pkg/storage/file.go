@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// fileStore keeps documents as indented JSON files and logs as JSON lines,
+// written the way MiniMC always did: documents through a temporary file and
+// a rename, so a crash never leaves half a file behind.
+type fileStore struct {
+	mu sync.Mutex
+}
+
+func NewFileStore() Store {
+	return &fileStore{}
+}
+
+func (f *fileStore) Get(name string, v interface{}) error {
+	data, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (f *fileStore) Put(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(name); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+func (f *fileStore) Append(name string, record interface{}) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (f *fileStore) Scan(name string, fn func(data []byte) error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.scan(name, fn)
+}
+
+func (f *fileStore) scan(name string, fn func(data []byte) error) error {
+	file, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		if err := fn(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (f *fileStore) Prune(name string, keep func(data []byte) bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var buf bytes.Buffer
+	err := f.scan(name, func(data []byte) error {
+		if keep(data) {
+			buf.Write(data)
+			buf.WriteByte('\n')
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+func (f *fileStore) Close() error {
+	return nil
+}
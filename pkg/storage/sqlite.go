@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteStore keeps documents and log records in a single database file.
+// Values are the same JSON the file store writes, so the schema stays the
+// same for every subsystem and another SQL database could use it as is.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS documents (
+	name    TEXT PRIMARY KEY,
+	data    BLOB NOT NULL,
+	updated TIMESTAMP NOT NULL
+);
+CREATE TABLE IF NOT EXISTS records (
+	id      INTEGER PRIMARY KEY AUTOINCREMENT,
+	log     TEXT NOT NULL,
+	data    BLOB NOT NULL,
+	created TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS records_log ON records (log, id);
+`
+
+func OpenSQLite(path string) (Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, more connections only wait on locks.
+	db.SetMaxOpenConns(1)
+	for _, stmt := range []string{"PRAGMA journal_mode=WAL", "PRAGMA busy_timeout=5000", sqliteSchema} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+func (s *sqliteStore) Get(name string, v interface{}) error {
+	var data []byte
+	err := s.db.QueryRow(`SELECT data FROM documents WHERE name = ?`, name).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (s *sqliteStore) Put(name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO documents (name, data, updated) VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET data = excluded.data, updated = excluded.updated`,
+		name, data, time.Now())
+	return err
+}
+
+func (s *sqliteStore) Append(name string, record interface{}) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO records (log, data, created) VALUES (?, ?, ?)`, name, data, time.Now())
+	return err
+}
+
+func (s *sqliteStore) Scan(name string, fn func(data []byte) error) error {
+	rows, err := s.db.Query(`SELECT data FROM records WHERE log = ? ORDER BY id`, name)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return err
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *sqliteStore) Prune(name string, keep func(data []byte) bool) error {
+	rows, err := s.db.Query(`SELECT id, data FROM records WHERE log = ? ORDER BY id`, name)
+	if err != nil {
+		return err
+	}
+	var drop []int64
+	for rows.Next() {
+		var id int64
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			rows.Close()
+			return err
+		}
+		if !keep(data) {
+			drop = append(drop, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, id := range drop {
+		if _, err := tx.Exec(`DELETE FROM records WHERE id = ?`, id); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+// ErrNotFound is returned by Get for documents that were never stored.
+var ErrNotFound = errors.New("not found")
+
+// Store keeps MiniMC's own state: documents that are replaced as a whole,
+// like the schedules, and append-only logs, like the audit log. Names are
+// paths relative to MiniMC's working directory, such as "schedules.json" or
+// "audit.log", so the file store keeps the files where they always were.
+type Store interface {
+	// Get decodes the JSON document name into v.
+	Get(name string, v interface{}) error
+	// Put replaces document name with v encoded as JSON.
+	Put(name string, v interface{}) error
+	// Append adds record, encoded as JSON, to log name.
+	Append(name string, record interface{}) error
+	// Scan calls fn with every record of log name, oldest first. A log that
+	// doesn't exist has no records.
+	Scan(name string, fn func(data []byte) error) error
+	// Prune rewrites log name with only the records keep returns true for.
+	Prune(name string, keep func(data []byte) bool) error
+	Close() error
+}
+
+var (
+	mu      sync.Mutex
+	current Store
+)
+
+// Open selects the store from STORAGE: "file" (default) or "sqlite", with
+// STORAGE_PATH as the database (default minimc.db).
+func Open() error {
+	var s Store
+	switch kind := os.Getenv("STORAGE"); kind {
+	case "", "file":
+		s = NewFileStore()
+	case "sqlite":
+		path := os.Getenv("STORAGE_PATH")
+		if path == "" {
+			path = "minimc.db"
+		}
+		var err error
+		if s, err = OpenSQLite(path); err != nil {
+			return err
+		}
+		log.Println("[i] storing panel state in", path)
+	default:
+		return fmt.Errorf("unknown STORAGE %q, use file or sqlite", kind)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if current != nil {
+		current.Close()
+	}
+	current = s
+	return nil
+}
+
+// Default returns the selected store, the file store until Open is called.
+func Default() Store {
+	mu.Lock()
+	defer mu.Unlock()
+	if current == nil {
+		current = NewFileStore()
+	}
+	return current
+}
+
+func Get(name string, v interface{}) error {
+	return Default().Get(name, v)
+}
+
+func Put(name string, v interface{}) error {
+	return Default().Put(name, v)
+}
+
+func Append(name string, record interface{}) error {
+	return Default().Append(name, record)
+}
+
+func Scan(name string, fn func(data []byte) error) error {
+	return Default().Scan(name, fn)
+}
+
+func Prune(name string, keep func(data []byte) bool) error {
+	return Default().Prune(name, keep)
+}
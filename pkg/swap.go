@@ -0,0 +1,110 @@
+package pkg
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+func JarPath() string {
+	return filepath.Join(mcDir, jarName)
+}
+
+// LaunchFile returns the file the server is started from: the argument file
+// of an installed Forge or NeoForge server, server.jar otherwise.
+func LaunchFile() string {
+	if m, err := LoadManifest(); err == nil && m.ArgsFile != "" {
+		return filepath.Join(mcDir, argsFileForOS(m.ArgsFile))
+	}
+	return JarPath()
+}
+
+// argsFileForOS swaps the unix argument file for the one the installer wrote
+// for Windows.
+func argsFileForOS(path string) string {
+	if runtime.GOOS == "windows" {
+		return strings.Replace(path, "unix_args.txt", "win_args.txt", 1)
+	}
+	return path
+}
+
+func PreviousJarPath() string {
+	return JarPath() + ".previous"
+}
+
+// swapJar moves the freshly downloaded jar at partPath into place, keeping the
+// current jar around so it can be restored if the new one doesn't start.
+// It reports whether a previous jar was kept.
+func swapJar(partPath string) (bool, error) {
+	kept := false
+	if _, err := os.Stat(JarPath()); err == nil {
+		if err := os.Rename(JarPath(), PreviousJarPath()); err != nil {
+			return false, err
+		}
+		kept = true
+	}
+	if err := os.Rename(partPath, JarPath()); err != nil {
+		if kept {
+			os.Rename(PreviousJarPath(), JarPath())
+		}
+		return false, err
+	}
+	return kept, nil
+}
+
+// ConfirmJar ends the trial of a swapped-in jar after it started
+// successfully.
+func ConfirmJar() error {
+	m, err := LoadManifest()
+	if err != nil {
+		return err
+	}
+	if !m.Trial {
+		return nil
+	}
+	m.Trial = false
+	return m.Save()
+}
+
+// RevertJar puts the previous jar back after the new one failed its first
+// start, and records the failed build so it isn't installed again.
+func RevertJar(reason string) (*Manifest, error) {
+	m, err := LoadManifest()
+	if err != nil {
+		return nil, err
+	}
+	if len(m.History) < 2 {
+		return nil, errors.New("no previous install recorded")
+	}
+	if _, err := os.Stat(PreviousJarPath()); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(PreviousJarPath(), JarPath()); err != nil {
+		return nil, err
+	}
+
+	m.Failed = append(m.Failed, FailedInstall{
+		Version: m.Version,
+		Build:   m.Build,
+		Reason:  reason,
+		Date:    time.Now().Format(time.RFC3339),
+	})
+
+	prev := m.History[len(m.History)-2]
+	m.History = m.History[:len(m.History)-1]
+	m.Flavor = prev.Flavor
+	m.Filename = prev.Filename
+	m.Version = prev.Version
+	m.Build = prev.Build
+	m.Size = prev.Size
+	m.SHA256 = prev.SHA256
+	m.Java = RequiredJavaFor(prev.Flavor, prev.Version)
+	m.Download = prev.Download
+	m.Date = prev.Date
+	m.Trial = false
+
+	return m, m.Save()
+}
@@ -0,0 +1,195 @@
+package tellraw
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"pkg.bijsven.nl/MiniMC/pkg"
+)
+
+// Colors are the named text colors; hex colors like "#ff8800" work as well.
+var Colors = []string{
+	"black", "dark_blue", "dark_green", "dark_aqua", "dark_red", "dark_purple",
+	"gold", "gray", "dark_gray", "blue", "green", "aqua", "red",
+	"light_purple", "yellow", "white",
+}
+
+const (
+	OpenURL         = "open_url"
+	RunCommand      = "run_command"
+	SuggestCommand  = "suggest_command"
+	CopyToClipboard = "copy_to_clipboard"
+	ChangePage      = "change_page"
+)
+
+var (
+	hexColor        = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+	playerName      = regexp.MustCompile(`^\w{1,16}$`)
+	selectorPattern = regexp.MustCompile(`^@[aeprs](\[[^\s\[\]]*\])?$`)
+)
+
+// Click runs an action when the segment is clicked.
+type Click struct {
+	Action string `json:"action"`
+	Value  string `json:"value"`
+}
+
+// Segment is a run of text with one style.
+type Segment struct {
+	Text          string `json:"text"`
+	Color         string `json:"color,omitempty"`
+	Bold          bool   `json:"bold,omitempty"`
+	Italic        bool   `json:"italic,omitempty"`
+	Underlined    bool   `json:"underlined,omitempty"`
+	Strikethrough bool   `json:"strikethrough,omitempty"`
+	Obfuscated    bool   `json:"obfuscated,omitempty"`
+	// Hover is plain text shown when hovering the segment.
+	Hover string `json:"hover,omitempty"`
+	Click *Click `json:"click,omitempty"`
+}
+
+// Message is sent to Target, a player name or a selector like "@a" or
+// "@a[team=red]".
+type Message struct {
+	Target   string    `json:"target"`
+	Segments []Segment `json:"segments"`
+}
+
+func validColor(color string) bool {
+	if hexColor.MatchString(color) {
+		return true
+	}
+	for _, c := range Colors {
+		if c == color {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Click) validate() error {
+	switch c.Action {
+	case OpenURL:
+		u, err := url.Parse(c.Value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("open_url needs an http or https URL")
+		}
+	case RunCommand, SuggestCommand:
+		if !strings.HasPrefix(c.Value, "/") {
+			return fmt.Errorf("%s needs a command starting with /", c.Action)
+		}
+	case CopyToClipboard:
+	case ChangePage:
+		if n, err := strconv.Atoi(c.Value); err != nil || n < 1 {
+			return errors.New("change_page needs a page number")
+		}
+	default:
+		return fmt.Errorf("unknown click action %q", c.Action)
+	}
+	return nil
+}
+
+func (m Message) Validate() error {
+	if !playerName.MatchString(m.Target) && !selectorPattern.MatchString(m.Target) {
+		return fmt.Errorf("target %q must be a player name or selector", m.Target)
+	}
+	if len(m.Segments) == 0 {
+		return errors.New("message has no segments")
+	}
+	for i, s := range m.Segments {
+		if s.Color != "" && !validColor(s.Color) {
+			return fmt.Errorf("segment %d: unknown color %q", i+1, s.Color)
+		}
+		if s.Click != nil {
+			if err := s.Click.validate(); err != nil {
+				return fmt.Errorf("segment %d: %w", i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// snakeCaseEvents reports whether version uses the text component format of
+// 1.21.5 and later, with click_event and hover_event.
+func snakeCaseEvents(version string) bool {
+	return version != "" && pkg.CompareVersions(version, "1.21.5") >= 0
+}
+
+func (s Segment) component(version string) map[string]interface{} {
+	c := map[string]interface{}{"text": s.Text}
+	if s.Color != "" {
+		c["color"] = strings.ToLower(s.Color)
+	}
+	for key, set := range map[string]bool{
+		"bold":          s.Bold,
+		"italic":        s.Italic,
+		"underlined":    s.Underlined,
+		"strikethrough": s.Strikethrough,
+		"obfuscated":    s.Obfuscated,
+	} {
+		if set {
+			c[key] = true
+		}
+	}
+
+	modern := snakeCaseEvents(version)
+	if s.Hover != "" {
+		if modern {
+			c["hover_event"] = map[string]interface{}{"action": "show_text", "value": s.Hover}
+		} else {
+			c["hoverEvent"] = map[string]interface{}{"action": "show_text", "contents": s.Hover}
+		}
+	}
+	if s.Click != nil {
+		if modern {
+			event := map[string]interface{}{"action": s.Click.Action}
+			switch s.Click.Action {
+			case OpenURL:
+				event["url"] = s.Click.Value
+			case RunCommand, SuggestCommand:
+				event["command"] = s.Click.Value
+			case ChangePage:
+				page, _ := strconv.Atoi(s.Click.Value)
+				event["page"] = page
+			default:
+				event["value"] = s.Click.Value
+			}
+			c["click_event"] = event
+		} else {
+			c["clickEvent"] = map[string]interface{}{"action": s.Click.Action, "value": s.Click.Value}
+		}
+	}
+	return c
+}
+
+// Command returns the tellraw command for m in the text component format of
+// the given Minecraft version. Segments are children of an empty root, so
+// their styles don't leak into each other.
+func (m Message) Command(version string) (string, error) {
+	if err := m.Validate(); err != nil {
+		return "", err
+	}
+
+	components := make([]interface{}, 0, len(m.Segments)+1)
+	components = append(components, "")
+	for _, s := range m.Segments {
+		components = append(components, s.component(version))
+	}
+	var data strings.Builder
+	enc := json.NewEncoder(&data)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(components); err != nil {
+		return "", err
+	}
+	return "tellraw " + m.Target + " " + strings.TrimSpace(data.String()), nil
+}
+
+// Text returns a message of a single colored segment.
+func Text(target, color, text string) Message {
+	return Message{Target: target, Segments: []Segment{{Text: text, Color: color}}}
+}
@@ -0,0 +1,75 @@
+package textenc
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const (
+	UTF8        = "utf-8"
+	UTF8BOM     = "utf-8-bom"
+	UTF16LE     = "utf-16le"
+	UTF16BE     = "utf-16be"
+	Windows1252 = "windows-1252"
+	Binary      = "binary"
+)
+
+var encodings = map[string]encoding.Encoding{
+	UTF8BOM:      unicode.UTF8BOM,
+	UTF16LE:      unicode.UTF16(unicode.LittleEndian, unicode.UseBOM),
+	UTF16BE:      unicode.UTF16(unicode.BigEndian, unicode.UseBOM),
+	Windows1252:  charmap.Windows1252,
+	"iso-8859-1": charmap.ISO8859_1,
+}
+
+// Detect guesses the encoding of data. Files with a byte order mark are
+// taken at their word, valid UTF-8 is UTF-8, and anything else that isn't
+// binary is assumed to be Windows-1252, which is what older config files
+// written on Windows hosts tend to be.
+func Detect(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return UTF8BOM
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return UTF16LE
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return UTF16BE
+	case bytes.IndexByte(data, 0) >= 0:
+		return Binary
+	case utf8.Valid(data):
+		return UTF8
+	}
+	return Windows1252
+}
+
+// Decode converts data in enc to a UTF-8 string. UTF-8 and binary data are
+// returned unchanged.
+func Decode(data []byte, enc string) (string, error) {
+	if enc == UTF8 || enc == Binary {
+		return string(data), nil
+	}
+	e, ok := encodings[enc]
+	if !ok {
+		return "", fmt.Errorf("unsupported encoding %q", enc)
+	}
+	out, err := e.NewDecoder().Bytes(data)
+	return string(out), err
+}
+
+// Encode converts s to enc. Characters that don't exist in enc are an error
+// rather than silently replaced.
+func Encode(s string, enc string) ([]byte, error) {
+	if enc == "" || enc == UTF8 {
+		return []byte(s), nil
+	}
+	e, ok := encodings[enc]
+	if !ok {
+		return nil, fmt.Errorf("unsupported encoding %q", enc)
+	}
+	return e.NewEncoder().Bytes([]byte(s))
+}
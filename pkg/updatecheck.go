@@ -0,0 +1,86 @@
+package pkg
+
+import (
+	"sync"
+	"time"
+)
+
+// AvailableUpdate is a newer build of the installed version.
+type AvailableUpdate struct {
+	Flavor    string    `json:"flavor"`
+	Version   string    `json:"version"`
+	Installed int       `json:"installed_build"`
+	Build     int       `json:"build"`
+	Channel   string    `json:"channel"`
+	Found     time.Time `json:"found"`
+}
+
+var (
+	updateMu        sync.Mutex
+	availableUpdate *AvailableUpdate
+	updateChecked   time.Time
+)
+
+// CheckUpdate asks the download API for a newer build of the installed
+// version in MC_CHANNEL. It returns nil when the server is up to date, the
+// build is pinned or the newest build failed to start before. Nothing is
+// installed.
+func CheckUpdate() (*AvailableUpdate, error) {
+	m, err := LoadManifest()
+	if err != nil {
+		return nil, err
+	}
+	provider, err := GetProvider(m.Flavor)
+	if err != nil {
+		return nil, err
+	}
+
+	pinned, err := PinnedBuild(m.Version)
+	if err != nil {
+		return nil, err
+	}
+	var update *AvailableUpdate
+	if pinned == 0 {
+		channel := Channel()
+		builds, err := channelBuilds(provider, m.Version, channel)
+		if err != nil {
+			return nil, err
+		}
+		if len(builds) > 0 {
+			latest := builds[len(builds)-1]
+			if latest > m.Build && !m.HasFailed(m.Version, latest) {
+				update = &AvailableUpdate{
+					Flavor:    m.Flavor,
+					Version:   m.Version,
+					Installed: m.Build,
+					Build:     latest,
+					Channel:   channel,
+					Found:     time.Now(),
+				}
+			}
+		}
+	}
+
+	updateMu.Lock()
+	defer updateMu.Unlock()
+	if update != nil && availableUpdate != nil &&
+		update.Version == availableUpdate.Version && update.Build == availableUpdate.Build {
+		update.Found = availableUpdate.Found
+	}
+	availableUpdate = update
+	updateChecked = time.Now()
+	return update, nil
+}
+
+// LatestUpdate returns the update found by the last CheckUpdate, if it is
+// still newer than the installed build, and when that check ran.
+func LatestUpdate() (*AvailableUpdate, time.Time) {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+	if availableUpdate != nil {
+		if m, err := LoadManifest(); err != nil || m.Version != availableUpdate.Version || m.Build >= availableUpdate.Build {
+			return nil, updateChecked
+		}
+	}
+	return availableUpdate, updateChecked
+}
@@ -0,0 +1,75 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UpgradeAck records that an operator reviewed the pre-flight checks for a
+// Minecraft version upgrade.
+type UpgradeAck struct {
+	From    string    `json:"from"`
+	Version string    `json:"version"`
+	User    string    `json:"user"`
+	Time    time.Time `json:"time"`
+}
+
+func upgradeAckPath() string {
+	return filepath.Join(mcDir, "upgrade-ack.json")
+}
+
+// CompareVersions compares two Minecraft versions like "1.20.4" numerically,
+// returning -1, 0 or 1. Suffixes such as "-pre1" are ignored.
+func CompareVersions(a, b string) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var na, nb int
+		if i < len(pa) {
+			na, _ = strconv.Atoi(strings.SplitN(pa[i], "-", 2)[0])
+		}
+		if i < len(pb) {
+			nb, _ = strconv.Atoi(strings.SplitN(pb[i], "-", 2)[0])
+		}
+		switch {
+		case na < nb:
+			return -1
+		case na > nb:
+			return 1
+		}
+	}
+	return 0
+}
+
+// UpgradeAcknowledged reports whether moving from the installed version to
+// version was acknowledged, which is required for any upgrade.
+func UpgradeAcknowledged(from, version string) bool {
+	data, err := os.ReadFile(upgradeAckPath())
+	if err != nil {
+		return false
+	}
+	var ack UpgradeAck
+	if err := json.Unmarshal(data, &ack); err != nil {
+		return false
+	}
+	return ack.From == from && ack.Version == version
+}
+
+func AcknowledgeUpgrade(ack UpgradeAck) error {
+	if ack.Version == "" {
+		return errors.New("version is required")
+	}
+	data, err := json.MarshalIndent(ack, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := upgradeAckPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, upgradeAckPath())
+}
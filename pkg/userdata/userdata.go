@@ -0,0 +1,47 @@
+package userdata
+
+import (
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"pkg.bijsven.nl/MiniMC/pkg/storage"
+)
+
+const Dir = "userdata"
+
+var (
+	mu       sync.Mutex
+	safeName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+)
+
+// userDir maps a username to its directory. Names that aren't safe to use as
+// a path are hex encoded.
+func userDir(user string) string {
+	if !safeName.MatchString(user) || user == "." || user == ".." {
+		user = "x-" + hex.EncodeToString([]byte(user))
+	}
+	return filepath.Join(Dir, user)
+}
+
+// Load reads the named document of user into v. A missing document leaves v
+// untouched, so callers can pre-fill defaults.
+func Load(user, name string, v interface{}) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	err := storage.Get(filepath.Join(userDir(user), name+".json"), v)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func Save(user, name string, v interface{}) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return storage.Put(filepath.Join(userDir(user), name+".json"), v)
+}
@@ -0,0 +1,77 @@
+package pkg
+
+import (
+	"log"
+	"os"
+	"strings"
+)
+
+// BuildInfo is one build of a version. Channel is the release channel the
+// download API puts the build in, like "default" or "experimental" on the
+// Paper API, and empty for flavors without channels.
+type BuildInfo struct {
+	Build   int    `json:"build"`
+	Channel string `json:"channel,omitempty"`
+}
+
+// channelProvider is implemented by providers that publish build channels.
+type channelProvider interface {
+	BuildInfos(version string) ([]BuildInfo, error)
+}
+
+// ListBuilds returns the builds of version, oldest first, with their channel
+// when the provider has one.
+func ListBuilds(p Provider, version string) ([]BuildInfo, error) {
+	if cp, ok := p.(channelProvider); ok {
+		return cp.BuildInfos(version)
+	}
+	builds, err := p.Builds(version)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]BuildInfo, len(builds))
+	for i, b := range builds {
+		list[i] = BuildInfo{Build: b}
+	}
+	return list, nil
+}
+
+// Channel returns the build channel to install from MC_CHANNEL: "default",
+// the stable builds, unless "experimental" builds are opted in to.
+func Channel() string {
+	switch c := strings.ToLower(os.Getenv("MC_CHANNEL")); c {
+	case "", "default":
+		return "default"
+	case "experimental":
+		return c
+	default:
+		log.Printf("[w] ignoring invalid MC_CHANNEL=%q, use default or experimental", c)
+		return "default"
+	}
+}
+
+// stable reports whether a build is in the default channel. Flavors without
+// channels only publish stable builds.
+func (b BuildInfo) stable() bool {
+	return b.Channel == "" || strings.EqualFold(b.Channel, "default") || strings.EqualFold(b.Channel, "stable")
+}
+
+// channelBuilds returns the builds of version that may be installed from
+// channel, oldest first.
+func channelBuilds(p Provider, version, channel string) ([]int, error) {
+	cp, ok := p.(channelProvider)
+	if !ok || channel == "experimental" {
+		return p.Builds(version)
+	}
+	infos, err := cp.BuildInfos(version)
+	if err != nil {
+		return nil, err
+	}
+	var builds []int
+	for _, b := range infos {
+		if b.stable() {
+			builds = append(builds, b.Build)
+		}
+	}
+	return builds, nil
+}
@@ -0,0 +1,91 @@
+package world
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+const maxPreviewSize = 2048
+
+var background = color.RGBA{0x24, 0x24, 0x24, 0xff}
+
+// RenderPreview draws a top-down heatmap of a world dimension with one cell
+// per region file, colored by how many of its 1024 chunks are generated.
+// dim is the dimension folder relative to the world, "" for the overworld.
+func RenderPreview(w io.Writer, name, dim string, cell int) error {
+	root, err := Path(name)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(dim, "..") {
+		return ErrNotFound
+	}
+
+	files := regionFiles(filepath.Join(root, dim, "region"))
+	if len(files) == 0 {
+		return errors.New("no region files found")
+	}
+
+	type cellData struct{ x, z, chunks int }
+	var cells []cellData
+	minX, minZ, maxX, maxZ := 0, 0, 0, 0
+	for _, file := range files {
+		r, err := ReadRegionHeader(file)
+		if err != nil {
+			continue
+		}
+		if len(cells) == 0 {
+			minX, maxX, minZ, maxZ = r.X, r.X, r.Z, r.Z
+		}
+		minX, maxX = min(minX, r.X), max(maxX, r.X)
+		minZ, maxZ = min(minZ, r.Z), max(maxZ, r.Z)
+		cells = append(cells, cellData{r.X, r.Z, len(r.Chunks)})
+	}
+	if len(cells) == 0 {
+		return errors.New("no readable region files found")
+	}
+
+	width, height := maxX-minX+1, maxZ-minZ+1
+	if cell < 1 {
+		cell = 8
+	}
+	for cell > 1 && (width*cell > maxPreviewSize || height*cell > maxPreviewSize) {
+		cell--
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width*cell, height*cell))
+	draw.Draw(img, img.Bounds(), &image.Uniform{background}, image.Point{}, draw.Src)
+
+	for _, c := range cells {
+		col := heat(float64(c.chunks) / chunksPerFile)
+		ox, oz := (c.x-minX)*cell, (c.z-minZ)*cell
+		for y := 0; y < cell; y++ {
+			for x := 0; x < cell; x++ {
+				img.SetRGBA(ox+x, oz+y, col)
+			}
+		}
+	}
+	return png.Encode(w, img)
+}
+
+// heat maps 0..1 onto a blue to yellow ramp.
+func heat(v float64) color.RGBA {
+	if v < 0 {
+		v = 0
+	}
+	if v > 1 {
+		v = 1
+	}
+	return color.RGBA{
+		R: uint8(40 + 215*v),
+		G: uint8(60 + 160*v),
+		B: uint8(160 - 120*v),
+		A: 0xff,
+	}
+}
@@ -0,0 +1,87 @@
+package world
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+const (
+	sectorSize    = 4096
+	chunksPerFile = 1024
+)
+
+var regionName = regexp.MustCompile(`^r\.(-?\d+)\.(-?\d+)\.mca$`)
+
+// ChunkEntry describes one chunk slot from a region file header.
+type ChunkEntry struct {
+	X, Z      int
+	Sectors   int
+	Timestamp int64
+}
+
+// Region is the parsed header of an Anvil .mca file.
+type Region struct {
+	Path   string
+	X, Z   int
+	Chunks []ChunkEntry
+}
+
+// ParseRegionCoords returns the region coordinates encoded in a file name
+// such as r.-1.2.mca.
+func ParseRegionCoords(name string) (int, int, bool) {
+	m := regionName.FindStringSubmatch(name)
+	if m == nil {
+		return 0, 0, false
+	}
+	x, _ := strconv.Atoi(m[1])
+	z, _ := strconv.Atoi(m[2])
+	return x, z, true
+}
+
+// ReadRegionHeader reads the location and timestamp tables of a region file.
+// Only the 8 KiB header is read, chunk data itself is never decompressed.
+func ReadRegionHeader(path string) (*Region, error) {
+	rx, rz, ok := ParseRegionCoords(filepath.Base(path))
+	if !ok {
+		return nil, fmt.Errorf("not a region file: %s", path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	header := make([]byte, 2*sectorSize)
+	n, err := io.ReadFull(f, header)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		// Empty or truncated region files are written by the server for
+		// regions without chunks.
+		return &Region{Path: path, X: rx, Z: rz}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	header = header[:n]
+
+	r := &Region{Path: path, X: rx, Z: rz}
+	for i := 0; i < chunksPerFile; i++ {
+		loc := binary.BigEndian.Uint32(header[i*4:])
+		sectors := int(loc & 0xff)
+		if loc == 0 || sectors == 0 {
+			continue
+		}
+		r.Chunks = append(r.Chunks, ChunkEntry{
+			X:         rx*32 + i%32,
+			Z:         rz*32 + i/32,
+			Sectors:   sectors,
+			Timestamp: int64(binary.BigEndian.Uint32(header[sectorSize+i*4:])),
+		})
+	}
+	return r, nil
+}
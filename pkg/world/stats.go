@@ -0,0 +1,158 @@
+package world
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	Dir         = "minecraft"
+	heavyChunks = 10
+)
+
+var ErrNotFound = errors.New("world not found")
+
+type DimensionStats struct {
+	Path         string    `json:"path"`
+	RegionFiles  int       `json:"region_files"`
+	Size         int64     `json:"size"`
+	Chunks       int       `json:"chunks"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+type HeavyChunk struct {
+	File  string `json:"file"`
+	X     int    `json:"x"`
+	Z     int    `json:"z"`
+	Bytes int    `json:"bytes"`
+}
+
+type Stats struct {
+	Name         string           `json:"name"`
+	Size         int64            `json:"size"`
+	RegionFiles  int              `json:"region_files"`
+	Chunks       int              `json:"chunks"`
+	LastModified time.Time        `json:"last_modified"`
+	Dimensions   []DimensionStats `json:"dimensions"`
+	HeavyChunks  []HeavyChunk     `json:"heavy_chunks"`
+	HeavySource  string           `json:"heavy_source,omitempty"`
+}
+
+// Path resolves a world name to its directory, rejecting anything that isn't
+// a direct child of the server directory containing a level.dat.
+func Path(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", fmt.Errorf("%w: %q", ErrNotFound, name)
+	}
+	dir := filepath.Join(Dir, name)
+	if _, err := os.Stat(filepath.Join(dir, "level.dat")); err != nil {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return dir, nil
+}
+
+// regionDirs finds every directory named kind ("region" or "entities") in a
+// world, which covers the overworld as well as DIM-1/DIM1 layouts.
+func regionDirs(root, kind string) []string {
+	var dirs []string
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() && d.Name() == kind {
+			dirs = append(dirs, path)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return dirs
+}
+
+func regionFiles(dir string) []string {
+	matches, _ := filepath.Glob(filepath.Join(dir, "r.*.mca"))
+	return matches
+}
+
+// GetStats collects region statistics for a world. Entity heavy chunks are
+// estimated from the size of their entry in the entities/ region files, or
+// from the terrain region files on worlds from before 1.17.
+func GetStats(name string) (*Stats, error) {
+	root, err := Path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{Name: name, Dimensions: []DimensionStats{}, HeavyChunks: []HeavyChunk{}}
+
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				stats.Size += info.Size()
+			}
+		}
+		return nil
+	})
+
+	for _, dir := range regionDirs(root, "region") {
+		rel, _ := filepath.Rel(root, dir)
+		dim := DimensionStats{Path: filepath.ToSlash(rel)}
+		for _, file := range regionFiles(dir) {
+			info, err := os.Stat(file)
+			if err != nil {
+				continue
+			}
+			dim.RegionFiles++
+			dim.Size += info.Size()
+			if info.ModTime().After(dim.LastModified) {
+				dim.LastModified = info.ModTime()
+			}
+			if r, err := ReadRegionHeader(file); err == nil {
+				dim.Chunks += len(r.Chunks)
+			}
+		}
+
+		stats.RegionFiles += dim.RegionFiles
+		stats.Chunks += dim.Chunks
+		if dim.LastModified.After(stats.LastModified) {
+			stats.LastModified = dim.LastModified
+		}
+		stats.Dimensions = append(stats.Dimensions, dim)
+	}
+
+	source := "entities"
+	dirs := regionDirs(root, source)
+	if len(dirs) == 0 {
+		source = "region"
+		dirs = regionDirs(root, source)
+	}
+	stats.HeavySource = source
+
+	for _, dir := range dirs {
+		for _, file := range regionFiles(dir) {
+			r, err := ReadRegionHeader(file)
+			if err != nil {
+				continue
+			}
+			rel, _ := filepath.Rel(root, file)
+			for _, c := range r.Chunks {
+				stats.HeavyChunks = append(stats.HeavyChunks, HeavyChunk{
+					File:  filepath.ToSlash(rel),
+					X:     c.X,
+					Z:     c.Z,
+					Bytes: c.Sectors * sectorSize,
+				})
+			}
+		}
+	}
+
+	sort.Slice(stats.HeavyChunks, func(a, b int) bool {
+		return stats.HeavyChunks[a].Bytes > stats.HeavyChunks[b].Bytes
+	})
+	if len(stats.HeavyChunks) > heavyChunks {
+		stats.HeavyChunks = stats.HeavyChunks[:heavyChunks]
+	}
+	return stats, nil
+}
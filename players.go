@@ -0,0 +1,91 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/geoip"
+	"pkg.bijsven.nl/MiniMC/pkg/playerstats"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+)
+
+const (
+	defaultPlayerStatsDays = 90
+	maxAnalyticsHours      = 7 * 24
+)
+
+func playersGeoHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, geoip.GetSummary())
+}
+
+// playersActivity lists the online players with how long they have been idle.
+func playersActivity(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"players":     server.PlayerActivity(),
+		"afk_seconds": int64(server.AFKAfter().Seconds()),
+	})
+}
+
+// openPlayerStats starts keeping join and leave events for the last
+// PLAYER_STATS_DAYS days (default 90, 0 disables them).
+func openPlayerStats() {
+	days := defaultPlayerStatsDays
+	if v := os.Getenv("PLAYER_STATS_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Printf("[w] ignoring invalid PLAYER_STATS_DAYS=%q", v)
+		} else {
+			days = n
+		}
+	}
+	if days == 0 {
+		return
+	}
+	if err := playerstats.Open(time.Duration(days) * 24 * time.Hour); err != nil {
+		log.Println("[e] could not load player statistics:", err)
+		return
+	}
+	server.OnPlayer(func(name string, joined bool) {
+		playerstats.Add(playerstats.Event{Time: time.Now(), Player: name, Join: joined})
+	})
+}
+
+// playerAnalytics returns the daily concurrency, new and returning players of
+// the last ?days= (default 30) and the hourly concurrency of the last
+// ?hours= (default 48), in the server's time zone.
+func playerAnalytics(c echo.Context) error {
+	keep := int(playerstats.Retention() / (24 * time.Hour))
+	if keep == 0 {
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "player_stats_disabled",
+			Message: "Player statistics are disabled, set PLAYER_STATS_DAYS to keep them",
+		})
+	}
+
+	days, hours := min(30, keep), 48
+	if v := c.QueryParam("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > keep {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_days",
+				Message: "days must be between 1 and " + strconv.Itoa(keep),
+			})
+		}
+		days = n
+	}
+	if v := c.QueryParam("hours"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAnalyticsHours {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_hours",
+				Message: "hours must be between 1 and " + strconv.Itoa(maxAnalyticsHours),
+			})
+		}
+		hours = n
+	}
+	return c.JSON(http.StatusOK, playerstats.Summarize(days, hours, time.Local))
+}
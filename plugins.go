@@ -0,0 +1,307 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg"
+	"pkg.bijsven.nl/MiniMC/pkg/audit"
+	"pkg.bijsven.nl/MiniMC/pkg/jobs"
+	"pkg.bijsven.nl/MiniMC/pkg/plugins"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+)
+
+// listPlugins lists the plugin jars with what their plugin.yml or
+// paper-plugin.yml says about them.
+func listPlugins(c echo.Context) error {
+	list, err := plugins.List()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "read_error",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, list)
+}
+
+func pluginUsage(c echo.Context) error {
+	usage, err := plugins.DiskUsage()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "read_error",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, usage)
+}
+
+func cleanupPlugins(c echo.Context) error {
+	var request struct {
+		Folders []string `json:"folders"`
+	}
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+
+	if len(request.Folders) == 0 {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "missing_folders",
+			Message: "List the orphaned folders to remove",
+		})
+	}
+
+	removed, err := plugins.RemoveOrphaned(request.Folders)
+	if len(removed) > 0 {
+		user, _ := c.Get("user").(string)
+		audit.Record(user, "plugin_cleanup", strings.Join(removed, ", "))
+		log.Printf("[i] Removed orphaned plugin folders: %s", strings.Join(removed, ", "))
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "cleanup_failed",
+			Message: err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Orphaned plugin folders removed",
+		"removed": removed,
+	})
+}
+
+// pluginDependencies reports dependencies of installed plugins that are
+// missing, with where each could be installed from. Installers show this plan
+// before downloading anything.
+func pluginDependencies(c echo.Context) error {
+	installed, err := plugins.Installed()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "read_error",
+			Message: err.Error(),
+		})
+	}
+
+	missing, err := plugins.MissingDependencies(installed)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "read_error",
+			Message: err.Error(),
+		})
+	}
+	if c.QueryParam("resolve") != "false" {
+		plugins.Resolve(missing)
+	}
+	return c.JSON(http.StatusOK, missing)
+}
+
+// installPlugin downloads a plugin from Modrinth or Hangar into plugins/, picking
+// the newest version for the installed Minecraft version and flavor unless a
+// version is given, together with the plugins it depends on. The whole plan
+// is worked out before anything is installed; with dry_run it's only
+// returned. Plans with conflicts, or required dependencies that can't be
+// found, aren't applied unless skip_missing is set for the latter.
+func installPlugin(c echo.Context) error {
+	var request struct {
+		Repository  string `json:"repository"`
+		Project     string `json:"project"`
+		Version     string `json:"version"`
+		DryRun      bool   `json:"dry_run"`
+		SkipMissing bool   `json:"skip_missing"`
+	}
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+	if request.Project == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "missing_project",
+			Message: "project is required, e.g. the plugin's slug",
+		})
+	}
+	if request.Repository == "" {
+		request.Repository = "modrinth"
+	}
+
+	flavor, gameVersion := pkg.Flavor(), installedVersion()
+	if m, err := pkg.LoadManifest(); err == nil {
+		flavor = m.Flavor
+	}
+	loaders, err := plugins.Loaders(flavor)
+	if err != nil {
+		return c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "plugins_unsupported",
+			Message: err.Error(),
+		})
+	}
+	// Proxy versions aren't Minecraft versions, their plugins work across
+	// Minecraft versions anyway.
+	if pkg.IsProxy(flavor) {
+		gameVersion = ""
+	}
+
+	rel, err := plugins.FindRelease(request.Repository, request.Project, request.Version, gameVersion, loaders)
+	if err != nil {
+		return pluginInstallError(c, err)
+	}
+
+	job := jobs.New("plugin_install")
+	plan, err := plugins.PlanInstall(rel, gameVersion, loaders, job)
+	if err != nil {
+		job.Finish(err)
+		log.Println("[e] Plugin install failed:", err)
+		return c.JSON(http.StatusBadGateway, ErrorResponse{
+			Error:   "install_failed",
+			Message: err.Error(),
+		})
+	}
+	defer plan.Close()
+
+	if request.DryRun {
+		job.Finish(nil)
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"release": rel,
+			"plan":    plan,
+			"ready":   plan.Ready(),
+			"job":     job.ID(),
+		})
+	}
+	if len(plan.Conflicts) > 0 {
+		err := errors.New(plan.Conflicts[0].Reason)
+		job.Finish(err)
+		return c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "dependency_conflict",
+			Message: err.Error() + ", see the plan with dry_run",
+		})
+	}
+	if len(plan.Missing) > 0 && !request.SkipMissing {
+		names := make([]string, len(plan.Missing))
+		for i, dep := range plan.Missing {
+			names[i] = dep.Name
+		}
+		err := errors.New("required dependencies can't be installed: " + strings.Join(names, ", "))
+		job.Finish(err)
+		return c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "unresolved_dependencies",
+			Message: err.Error() + ", see the plan with dry_run or resend with skip_missing",
+		})
+	}
+
+	records, err := plan.Apply(job)
+	job.Finish(err)
+	if err != nil {
+		log.Println("[e] Plugin install failed:", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "install_failed",
+			Message: err.Error(),
+		})
+	}
+
+	installed := make([]string, len(records))
+	for i, r := range records {
+		installed[i] = r.Name + " " + r.Version
+	}
+	audit.Record(currentUser(c), "plugin_install", rel.Repository+" "+rel.Project+" "+rel.Version)
+	log.Printf("[i] Installed plugins from %s: %s", rel.Repository, strings.Join(installed, ", "))
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":   "Plugin installed, restart the server to load it",
+		"installed": records,
+		"plan":      plan,
+		"job":       job.ID(),
+	})
+}
+
+// disablePlugin renames the jar of a plugin to .jar.disabled, so it isn't
+// loaded the next time the server starts. With restart a running server is
+// restarted right away, after warning the players.
+func disablePlugin(c echo.Context) error {
+	return togglePlugin(c, false)
+}
+
+// enablePlugin renames a disabled jar back to .jar.
+func enablePlugin(c echo.Context) error {
+	return togglePlugin(c, true)
+}
+
+func togglePlugin(c echo.Context, enable bool) error {
+	var request struct {
+		Restart bool `json:"restart"`
+	}
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+
+	action, toggle := "disable", plugins.Disable
+	if enable {
+		action, toggle = "enable", plugins.Enable
+	}
+	p, err := toggle(c.Param("name"))
+	if err != nil {
+		status, code := http.StatusInternalServerError, "rename_failed"
+		switch {
+		case errors.Is(err, plugins.ErrNotInstalled):
+			status, code = http.StatusNotFound, "plugin_not_installed"
+		case errors.Is(err, plugins.ErrFileExists):
+			status, code = http.StatusConflict, "file_exists"
+		}
+		return c.JSON(status, ErrorResponse{
+			Error:   code,
+			Message: err.Error(),
+		})
+	}
+
+	audit.Record(currentUser(c), "plugin_"+action, p.Name)
+	log.Printf("[i] Plugin %s %sd by %s", p.Name, action, currentUser(c))
+
+	message := "Plugin " + action + "d, restart the server to apply"
+	restarting := request.Restart && server.GetStatus()
+	if restarting {
+		message = "Plugin " + action + "d, the server is restarting"
+		go restartFor(fmt.Sprintf("plugin %s %sd", p.Name, action))
+	} else if !server.GetStatus() {
+		message = "Plugin " + action + "d"
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":    message,
+		"plugin":     p,
+		"restarting": restarting,
+	})
+}
+
+// restartFor restarts the server after counting down in-game.
+func restartFor(reason string) {
+	if err := server.Countdown("restart", reason); err != nil {
+		log.Println("[w] Restart skipped:", err)
+		return
+	}
+	if err := server.Restart(stopTimeout); err != nil {
+		log.Println("[e] Restart failed:", err)
+	}
+}
+
+func pluginInstallError(c echo.Context, err error) error {
+	status, code := http.StatusBadGateway, "repository_unavailable"
+	switch {
+	case errors.Is(err, plugins.ErrUnknownRepository):
+		status, code = http.StatusBadRequest, "unknown_repository"
+	case errors.Is(err, plugins.ErrProjectNotFound):
+		status, code = http.StatusNotFound, "plugin_not_found"
+	case errors.Is(err, plugins.ErrNoCompatible):
+		status, code = http.StatusNotFound, "no_compatible_version"
+	}
+	return c.JSON(status, ErrorResponse{
+		Error:   code,
+		Message: err.Error(),
+	})
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/ratelimit"
+)
+
+// rateClass is a group of routes that share a rate limit, configured with
+// env and counted per user.
+type rateClass struct {
+	name    string
+	env     string
+	def     string
+	matches func(method, path string) bool
+	limiter *ratelimit.Limiter
+}
+
+var rateClasses = []*rateClass{
+	{
+		name: "login",
+		env:  "RATE_LIMIT_LOGIN",
+		def:  "10/m",
+		matches: func(method, path string) bool {
+			return method == http.MethodPost && path == "/api/auth/session"
+		},
+	},
+	{
+		name: "commands",
+		env:  "RATE_LIMIT_COMMANDS",
+		def:  "10/s",
+		matches: func(method, path string) bool {
+			return method == http.MethodPost && (path == "/api/command" || path == "/api/messages/send")
+		},
+	},
+	{
+		name: "file_writes",
+		env:  "RATE_LIMIT_FILE_WRITES",
+		def:  "120/m",
+		matches: func(method, path string) bool {
+			return method != http.MethodGet && method != http.MethodHead && strings.HasPrefix(path, "/api/files")
+		},
+	},
+}
+
+// setupRateLimits reads the limits from the environment. An invalid limit
+// falls back to the default.
+func setupRateLimits() {
+	for _, class := range rateClasses {
+		v, ok := os.LookupEnv(class.env)
+		if !ok {
+			v = class.def
+		}
+		limit, err := ratelimit.ParseLimit(v)
+		if err != nil {
+			log.Printf("[w] ignoring invalid %s=%q: %v", class.env, v, err)
+			limit, _ = ratelimit.ParseLimit(class.def)
+		}
+		if limit == nil {
+			log.Printf("[i] rate limit for %s disabled", class.name)
+			continue
+		}
+		class.limiter = ratelimit.New(*limit)
+	}
+}
+
+// rateLimitMiddleware answers 429 once a user used up the limit of a route
+// class. It runs after authMiddleware, requests without a user (logins) are
+// counted per address, as decided by ipExtractor.
+func rateLimitMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		method, path := c.Request().Method, c.Request().URL.Path
+		for _, class := range rateClasses {
+			if class.limiter == nil || !class.matches(method, path) {
+				continue
+			}
+			key := "ip:" + c.RealIP()
+			if user := currentUser(c); user != "" {
+				key = "user:" + user
+			}
+			allowed, wait := class.limiter.Allow(key)
+			if allowed {
+				break
+			}
+			c.Response().Header().Set("Retry-After", fmt.Sprint(int(wait.Seconds())+1))
+			return c.JSON(http.StatusTooManyRequests, ErrorResponse{
+				Error:   "rate_limited",
+				Message: fmt.Sprintf("The %s limit of %s was reached", strings.ReplaceAll(class.name, "_", " "), class.limiter.Limit()),
+			})
+		}
+		return next(c)
+	}
+}
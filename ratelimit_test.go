@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/ratelimit"
+)
+
+func TestRateLimitIgnoresForgedForwardedFor(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "")
+	login := rateClasses[0]
+	saved := login.limiter
+	defer func() { login.limiter = saved }()
+	limit, err := ratelimit.ParseLimit("2/m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	login.limiter = ratelimit.New(*limit)
+
+	e := echo.New()
+	e.IPExtractor = ipExtractor()
+	e.Use(rateLimitMiddleware)
+	e.POST("/api/auth/session", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/session", nil)
+		req.RemoteAddr = "203.0.113.7:4000"
+		req.Header.Set(echo.HeaderXForwardedFor, "198.51.100."+strconv.Itoa(i))
+		req.Header.Set(echo.HeaderXRealIP, "198.51.100."+strconv.Itoa(i))
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		want := http.StatusNoContent
+		if i == 2 {
+			want = http.StatusTooManyRequests
+		}
+		if rec.Code != want {
+			t.Fatalf("request %d: got %d, want %d", i+1, rec.Code, want)
+		}
+	}
+}
+
+func TestRateLimitTrustedProxy(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8")
+	e := echo.New()
+	e.IPExtractor = ipExtractor()
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.RemoteAddr = "10.1.2.3:4000"
+	req.Header.Set(echo.HeaderXForwardedFor, "198.51.100.1")
+	if ip := e.NewContext(req, nil).RealIP(); ip != "198.51.100.1" {
+		t.Errorf("behind a trusted proxy got %s, want the forwarded address", ip)
+	}
+
+	req.RemoteAddr = "203.0.113.7:4000"
+	if ip := e.NewContext(req, nil).RealIP(); ip != "203.0.113.7" {
+		t.Errorf("from an untrusted address got %s, want the connection address", ip)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"log"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ipExtractor decides the client address behind c.RealIP(), which lockouts,
+// rate limits and the audit log are keyed on. By default it's the address of
+// the connection, as X-Forwarded-For and X-Real-IP can be set by anyone.
+// Behind a reverse proxy, TRUSTED_PROXIES lists the proxy addresses or CIDR
+// ranges whose X-Forwarded-For is believed.
+func ipExtractor() echo.IPExtractor {
+	v := strings.TrimSpace(os.Getenv("TRUSTED_PROXIES"))
+	if v == "" {
+		return echo.ExtractIPDirect()
+	}
+
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, s := range strings.Split(v, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			log.Printf("[w] ignoring invalid TRUSTED_PROXIES entry %q: %v", s, err)
+			continue
+		}
+		options = append(options, echo.TrustIPRange(ipNet))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
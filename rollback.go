@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg"
+	"pkg.bijsven.nl/MiniMC/pkg/audit"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+)
+
+// listJars returns the kept jars a rollback can switch to.
+func listJars(c echo.Context) error {
+	jars, err := pkg.KeptJars()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "read_error",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, jars)
+}
+
+// rollbackJar switches back to an earlier kept jar, the previous install by
+// default. Going back to another Minecraft version needs "force", since the
+// worlds may already be converted.
+func rollbackJar(c echo.Context) error {
+	var request struct {
+		Version string `json:"version"`
+		Build   int    `json:"build"`
+		Stop    bool   `json:"stop"`
+		Force   bool   `json:"force"`
+	}
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+
+	current, err := pkg.LoadManifest()
+	if err != nil {
+		return upgradeError(c, err)
+	}
+	if request.Build != 0 && request.Version == "" {
+		request.Version = current.Version
+	}
+	target, err := pkg.RollbackTarget(request.Version, request.Build)
+	if errors.Is(err, pkg.ErrNoRollback) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "no_rollback",
+			Message: err.Error(),
+		})
+	}
+	if err != nil {
+		return upgradeError(c, err)
+	}
+	if target.Version != current.Version && !request.Force {
+		return c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "version_change",
+			Message: "Rolling back to " + target.Version + " changes the Minecraft version, worlds opened on " + current.Version + " may not load. Resend with \"force\": true to continue",
+		})
+	}
+
+	running, status, stopErr := stopForSwap(request.Stop, "rolling back")
+	if stopErr != nil {
+		return c.JSON(status, stopErr)
+	}
+	user := currentUser(c)
+	audit.Record(user, "rollback", current.Version+" -> "+target.Version)
+
+	m, err := pkg.Rollback(target, "rolled back by "+user)
+	if err != nil {
+		log.Println("[e] Rollback failed:", err)
+		if running {
+			if err := server.Start(); err != nil {
+				log.Println("[e] Failed to start the server after the rollback:", err)
+			}
+		}
+		status := http.StatusInternalServerError
+		if errors.Is(err, pkg.ErrChecksumMismatch) || errors.Is(err, os.ErrNotExist) {
+			status = http.StatusConflict
+		}
+		return c.JSON(status, ErrorResponse{
+			Error:   "rollback_failed",
+			Message: err.Error(),
+		})
+	}
+	log.Printf("[!] Rolled back from %s build %d to %s build %d by %s",
+		current.Version, current.Build, m.Version, m.Build, user)
+
+	result := UpdateResult{Status: "rolled_back", Flavor: m.Flavor, Version: m.Version, Build: m.Build}
+	if running {
+		if err := server.Start(); err != nil {
+			result.Message = "Rolled back, but the server failed to start: " + err.Error()
+		} else {
+			result.Restarted = true
+		}
+	}
+	return c.JSON(http.StatusOK, result)
+}
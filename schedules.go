@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/scheduler"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+)
+
+func registerScheduleActions() {
+	scheduler.RegisterAction("worldborder", worldBorderAction)
+	scheduler.RegisterAction("sync", syncAction)
+	scheduler.RegisterAction("stop", powerAction("stop"))
+	scheduler.RegisterAction("restart", powerAction("restart"))
+	registerWorldActions()
+}
+
+// powerAction stops or restarts the server after warning the players, with
+// the "reason" param shown in the warnings.
+func powerAction(action string) scheduler.Action {
+	return func(params map[string]string) (string, error) {
+		if !server.GetStatus() {
+			return "server not running, nothing to " + action, nil
+		}
+		reason := params["reason"]
+		if reason == "" {
+			reason = "scheduled " + action
+		}
+		if err := server.Countdown(action, reason); err != nil {
+			return "", err
+		}
+		if action == "restart" {
+			if err := server.Restart(stopTimeout); err != nil {
+				return "", err
+			}
+			return "server restarted", nil
+		}
+		if err := server.StopAndWait(stopTimeout); err != nil {
+			return "", err
+		}
+		return "server stopped", nil
+	}
+}
+
+func listSchedules(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"schedules": scheduler.List(),
+		"actions":   scheduler.Actions(),
+	})
+}
+
+func createSchedule(c echo.Context) error {
+	var request scheduler.Schedule
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+
+	s, err := scheduler.Add(request)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_schedule",
+			Message: err.Error(),
+		})
+	}
+
+	log.Printf("[i] Schedule %q created (%s)", s.Name, s.Action)
+	return c.JSON(http.StatusCreated, s)
+}
+
+func deleteSchedule(c echo.Context) error {
+	if err := scheduler.Delete(c.Param("id")); err != nil {
+		if errors.Is(err, scheduler.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "schedule_not_found",
+				Message: err.Error(),
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "schedule_error",
+			Message: err.Error(),
+		})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func listScheduleRuns(c echo.Context) error {
+	runs, err := scheduler.Runs(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, scheduler.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "schedule_not_found",
+				Message: err.Error(),
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "schedule_error",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, runs)
+}
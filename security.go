@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/security"
+)
+
+func listSecurityEvents(c echo.Context) error {
+	limit := 100
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_limit",
+				Message: "limit must be a positive number",
+			})
+		}
+		limit = n
+	}
+
+	events, err := security.List(limit, c.QueryParam("kind"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "read_error",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, events)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/sessions"
+)
+
+const maxReplaySpeed = 1000
+
+var errReplayClosed = errors.New("replay closed")
+
+func listSessions(c echo.Context) error {
+	return c.JSON(http.StatusOK, sessions.List())
+}
+
+func sessionNotFound(c echo.Context) error {
+	return c.JSON(http.StatusNotFound, ErrorResponse{
+		Error:   "session_not_found",
+		Message: "No console session with id " + c.Param("id"),
+	})
+}
+
+// downloadSession sends the session file as recorded: a JSON header line
+// followed by one JSON line per console line.
+func downloadSession(c echo.Context) error {
+	p, err := sessions.Path(c.Param("id"))
+	if err != nil {
+		return sessionNotFound(c)
+	}
+	return c.Attachment(p, "session-"+c.Param("id")+".jsonl")
+}
+
+// replaySession streams a recorded session as server-sent events, waiting
+// between lines as long as the server did divided by speed. speed=0 sends
+// everything at once; max_gap caps the wait in seconds, to skip over quiet
+// stretches.
+func replaySession(c echo.Context) error {
+	speed := 1.0
+	if v := c.QueryParam("speed"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 || f > maxReplaySpeed {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_speed",
+				Message: "speed must be between 0 and " + strconv.Itoa(maxReplaySpeed),
+			})
+		}
+		speed = f
+	}
+	var maxGap time.Duration
+	if v := c.QueryParam("max_gap"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_max_gap",
+				Message: "max_gap must be a number of seconds",
+			})
+		}
+		maxGap = time.Duration(f * float64(time.Second))
+	}
+
+	info, err := sessions.Stat(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, sessions.ErrNotFound) {
+			return sessionNotFound(c)
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "read_error",
+			Message: err.Error(),
+		})
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, "text/event-stream")
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-cache")
+	c.Response().Header().Set(echo.HeaderConnection, "keep-alive")
+
+	flusher, ok := c.Response().Writer.(http.Flusher)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Streaming unsupported")
+	}
+
+	send := func(event string, v interface{}) {
+		data, _ := json.Marshal(v)
+		c.Response().Write([]byte("event: " + event + "\ndata: " + string(data) + "\n\n"))
+		flusher.Flush()
+	}
+
+	send("session", info)
+	ctx := c.Request().Context()
+	var last int64
+	err = sessions.Read(info.ID, func(sessions.Header) {}, func(e sessions.Entry) error {
+		if speed > 0 && e.At > last {
+			wait := time.Duration(float64(time.Duration(e.At-last)*time.Millisecond) / speed)
+			if maxGap > 0 && wait > maxGap {
+				wait = maxGap
+			}
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return errReplayClosed
+			}
+		}
+		last = e.At
+		send("line", e)
+		return nil
+	})
+	if errors.Is(err, errReplayClosed) {
+		return nil
+	}
+	if err != nil {
+		send("error", ErrorResponse{Error: "read_error", Message: err.Error()})
+		return nil
+	}
+	send("end", map[string]int64{"t": last})
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/audit"
+	"pkg.bijsven.nl/MiniMC/pkg/auth"
+	"pkg.bijsven.nl/MiniMC/pkg/security"
+)
+
+const maxShareMinutes = 24 * 60
+
+// shareScopes maps the routes that can be opened with a share token to the
+// scope the token must have.
+var shareScopes = map[string]string{
+	"/api/logs":          "logs",
+	"/api/files":         "files",
+	"/api/files/content": "files",
+}
+
+// shareEnvs holds the variable that enables sharing for each scope.
+var shareEnvs = map[string]string{
+	"logs":  "LOG_SHARES",
+	"files": "FILE_SHARES",
+}
+
+func sharesEnabled(scope string) bool {
+	env, ok := shareEnvs[scope]
+	return ok && os.Getenv(env) == "true"
+}
+
+func createShare(c echo.Context) error {
+	var request struct {
+		Minutes int      `json:"minutes"`
+		Scope   string   `json:"scope"`
+		Paths   []string `json:"paths"`
+	}
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+
+	if request.Scope == "" {
+		request.Scope = "logs"
+	}
+	if _, ok := shareEnvs[request.Scope]; !ok {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_scope",
+			Message: "scope must be logs or files",
+		})
+	}
+	if !sharesEnabled(request.Scope) {
+		return c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "shares_disabled",
+			Message: "Sharing " + request.Scope + " is disabled, set " + shareEnvs[request.Scope] + "=true to enable it",
+		})
+	}
+
+	if request.Minutes <= 0 {
+		request.Minutes = 30
+	}
+	if request.Minutes > maxShareMinutes {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_duration",
+			Message: "Shares can be valid for at most 24 hours",
+		})
+	}
+
+	user, _ := c.Get("user").(string)
+	ttl := time.Duration(request.Minutes) * time.Minute
+	var share *auth.Share
+	var err error
+	if request.Scope == "files" {
+		if share, err = auth.NewFileShare(user, request.Paths, ttl); err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_share_paths",
+				Message: err.Error(),
+			})
+		}
+		audit.Record(user, "file_share", strings.Join(request.Paths, ", "))
+	} else if share, err = auth.NewShare("logs", user, ttl); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "share_error",
+			Message: err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"token":   share.Token,
+		"scope":   share.Scope,
+		"paths":   share.Paths,
+		"url":     "/?share=" + share.Token,
+		"expires": share.Expires,
+	})
+}
+
+// shareDenied returns a 403 response when the request came in through a file
+// share that doesn't cover p, and nil otherwise.
+func shareDenied(c echo.Context, p string, dir bool) *ErrorResponse {
+	share, ok := c.Get("share").(*auth.Share)
+	if !ok || share.Scope != "files" {
+		return nil
+	}
+	p = strings.TrimPrefix(cleanFilePath(p), "/")
+	if (dir && share.AllowsDir(p)) || (!dir && share.AllowsFile(p)) {
+		return nil
+	}
+	security.Emit(security.Event{
+		Kind:   security.PermissionDenied,
+		User:   share.CreatedBy + " (share)",
+		IP:     c.RealIP(),
+		Path:   c.Request().URL.Path,
+		Detail: p,
+	})
+	return &ErrorResponse{
+		Error:   "forbidden",
+		Message: "This share doesn't include " + p,
+	}
+}
+
+func listShares(c echo.Context) error {
+	return c.JSON(http.StatusOK, auth.ListShares())
+}
+
+func revokeShare(c echo.Context) error {
+	if !auth.RevokeShare(c.Param("token")) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "share_not_found",
+			Message: "No share with this token",
+		})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
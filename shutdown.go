@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+)
+
+// shutdownTimeout is how long the server gets to save and stop when MiniMC is
+// asked to quit, from SHUTDOWN_TIMEOUT. Docker sends SIGKILL after its own
+// stop timeout (10s by default), so docker-compose should allow more.
+func shutdownTimeout() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT")); err == nil && d > 0 {
+		return d
+	}
+	return stopTimeout
+}
+
+// handleShutdown stops the server gracefully on SIGINT or SIGTERM before
+// shutting down the web interface, so the world isn't killed mid-save. A
+// second signal exits right away. The returned channel is closed once
+// everything is shut down.
+func handleShutdown(e *echo.Echo) <-chan struct{} {
+	done := make(chan struct{})
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		sig := <-signals
+		log.Printf("[i] Received %s, shutting down\n", sig)
+
+		go func() {
+			<-signals
+			log.Println("[w] Received second signal, exiting immediately")
+			os.Exit(1)
+		}()
+
+		if server.GetStatus() {
+			timeout := shutdownTimeout()
+			log.Printf("[i] Stopping server (up to %s)\n", timeout)
+			err := server.StopAndWait(timeout)
+			if errors.Is(err, server.ErrStopTimeout) {
+				log.Println("[w] Server did not stop in time, killing it")
+				server.Kill()
+			} else if err != nil {
+				log.Println("[e] Failed to stop server:", err)
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := e.Shutdown(ctx); err != nil {
+			log.Println("[e] Failed to shut down web interface:", err)
+		}
+		close(done)
+	}()
+	return done
+}
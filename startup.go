@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+)
+
+// startupEvents streams the startup progress of the server, starting with the
+// most recent event.
+func startupEvents(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderContentType, "text/event-stream")
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-cache")
+	c.Response().Header().Set(echo.HeaderConnection, "keep-alive")
+
+	flusher, ok := c.Response().Writer.(http.Flusher)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Streaming unsupported")
+	}
+
+	ch := server.SubscribeStartup()
+	defer server.UnsubscribeStartup(ch)
+
+	send := func(e server.StartupEvent) {
+		data, _ := json.Marshal(e)
+		c.Response().Write([]byte("data: " + string(data) + "\n\n"))
+		flusher.Flush()
+	}
+
+	if e := server.LastStartup(); e != nil {
+		send(*e)
+	} else {
+		flusher.Flush()
+	}
+	for {
+		select {
+		case e := <-ch:
+			send(e)
+		case <-c.Request().Context().Done():
+			return nil
+		}
+	}
+}
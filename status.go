@@ -0,0 +1,139 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+	"pkg.bijsven.nl/MiniMC/pkg"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+)
+
+const publicStatusPath = "/api/public/status"
+
+type PublicStatus struct {
+	Online     bool     `json:"online"`
+	MOTD       string   `json:"motd"`
+	Version    string   `json:"version,omitempty"`
+	Players    []string `json:"players"`
+	MaxPlayers int      `json:"max_players"`
+	Uptime     int64    `json:"uptime_seconds"`
+}
+
+func publicStatusEnabled() bool {
+	return os.Getenv("STATUS_PAGE") == "true"
+}
+
+// publicStatus is served without a login so communities can embed it on their
+// website. When STATUS_PAGE_PASSWORD is set the caller has to pass it as
+// ?key=.
+func publicStatus(c echo.Context) error {
+	if !publicStatusEnabled() {
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "status_page_disabled",
+			Message: "The public status page is disabled",
+		})
+	}
+
+	if password := os.Getenv("STATUS_PAGE_PASSWORD"); password != "" {
+		if subtle.ConstantTimeCompare([]byte(c.QueryParam("key")), []byte(password)) != 1 {
+			return c.JSON(http.StatusUnauthorized, ErrorResponse{
+				Error:   "unauthorized",
+				Message: "Invalid status page key",
+			})
+		}
+	}
+
+	c.Response().Header().Set("Access-Control-Allow-Origin", "*")
+
+	maxPlayers, _ := strconv.Atoi(serverProperty("max-players", "20"))
+	status := PublicStatus{
+		Online:     server.GetStatus(),
+		MOTD:       serverProperty("motd", "A Minecraft Server"),
+		Players:    server.OnlinePlayers(),
+		MaxPlayers: maxPlayers,
+		Uptime:     int64(server.Uptime().Seconds()),
+	}
+	if m, err := pkg.LoadManifest(); err == nil {
+		status.Version = m.Version
+	}
+	return c.JSON(http.StatusOK, status)
+}
+
+type ServerStatus struct {
+	State    string `json:"state"`
+	PID      int    `json:"pid,omitempty"`
+	Ready    bool   `json:"ready"`
+	Uptime   int64  `json:"uptime_seconds"`
+	Flavor   string `json:"flavor,omitempty"`
+	Version  string `json:"version,omitempty"`
+	Build    int    `json:"build,omitempty"`
+	Joinable bool   `json:"joinable"`
+	Players  int    `json:"players"`
+	// Update is set when a newer build of the installed version was found.
+	Update *pkg.AvailableUpdate `json:"update,omitempty"`
+}
+
+// serverStatus reports the process state in detail. ready turns true once the
+// server printed "Done (Xs)!", which is when players can join.
+func serverStatus(c echo.Context) error {
+	return c.JSON(http.StatusOK, currentStatus())
+}
+
+func currentStatus() ServerStatus {
+	p := server.ProcessInfo()
+	status := ServerStatus{
+		State:    p.State,
+		PID:      p.PID,
+		Ready:    p.Ready,
+		Uptime:   int64(p.Uptime.Seconds()),
+		Joinable: p.State == server.StateRunning,
+	}
+	if m, err := pkg.LoadManifest(); err == nil {
+		status.Flavor = m.Flavor
+		status.Version = m.Version
+		status.Build = m.Build
+	}
+	status.Players = server.PlayerCount()
+	status.Update, _ = pkg.LatestUpdate()
+	return status
+}
+
+// statusStream pushes state transitions and player count changes over a
+// WebSocket as they happen. The first message is the full status, every
+// message after it a server.StatusEvent.
+func statusStream(c echo.Context) error {
+	websocket.Handler(func(ws *websocket.Conn) {
+		defer ws.Close()
+
+		ch := server.SubscribeStatus()
+		defer server.UnsubscribeStatus(ch)
+
+		// The client doesn't send anything, reading only notices it left.
+		closed := make(chan struct{})
+		go func() {
+			var discard []byte
+			for websocket.Message.Receive(ws, &discard) == nil {
+			}
+			close(closed)
+		}()
+
+		if err := websocket.JSON.Send(ws, currentStatus()); err != nil {
+			return
+		}
+		for {
+			select {
+			case e := <-ch:
+				if err := websocket.JSON.Send(ws, e); err != nil {
+					return
+				}
+			case <-closed:
+				return
+			}
+		}
+	}).ServeHTTP(c.Response(), c.Request())
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/remotesync"
+)
+
+func syncError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, remotesync.ErrNotFound):
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "sync_not_found",
+			Message: err.Error(),
+		})
+	case errors.Is(err, remotesync.ErrRunning):
+		return c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "sync_running",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(http.StatusInternalServerError, ErrorResponse{
+		Error:   "sync_error",
+		Message: err.Error(),
+	})
+}
+
+// syncAction runs the sync target given by the "target" param, so syncs can
+// be scheduled separately from backups.
+func syncAction(params map[string]string) (string, error) {
+	if params["target"] == "" {
+		return "", errors.New("target is required")
+	}
+	return remotesync.Run(params["target"], MinecraftDir)
+}
+
+func listSyncTargets(c echo.Context) error {
+	targets, err := remotesync.List()
+	if err != nil {
+		return syncError(c, err)
+	}
+	return c.JSON(http.StatusOK, targets)
+}
+
+func createSyncTarget(c echo.Context) error {
+	var request remotesync.Target
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+	if err := remotesync.Validate(request); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_sync_target",
+			Message: err.Error(),
+		})
+	}
+
+	t, err := remotesync.Add(request)
+	if err != nil {
+		return syncError(c, err)
+	}
+
+	log.Printf("[i] Sync target %q created (%s %s)", t.Name, t.Direction, t.Remote)
+	return c.JSON(http.StatusCreated, t)
+}
+
+func deleteSyncTarget(c echo.Context) error {
+	if err := remotesync.Delete(c.Param("id")); err != nil {
+		return syncError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func runSyncTarget(c echo.Context) error {
+	output, err := remotesync.Run(c.Param("id"), MinecraftDir)
+	if err != nil {
+		return syncError(c, err)
+	}
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Sync finished",
+		"output":  output,
+	})
+}
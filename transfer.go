@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+)
+
+// Frames sent by the client over the transfer socket are binary messages:
+//
+//	data:   'D' | seq (uint64, big endian) | sha256(payload) | payload
+//	finish: 'F' | frame count (uint64)     | sha256(file)
+//
+// Every frame is acknowledged with a TransferAck. A data frame with a bad
+// checksum is rejected and should be resent with the same sequence number.
+const (
+	frameData   = 'D'
+	frameFinish = 'F'
+
+	frameHeaderSize = 1 + 8 + sha256.Size
+	maxFrameSize    = 4 << 20
+)
+
+var errTransferTooLarge = errors.New("transfer exceeds TRANSFER_MAX_MB")
+
+// transferMaxSize is how many bytes one transfer may write, from
+// TRANSFER_MAX_MB (default 4096).
+func transferMaxSize() int64 {
+	return int64(envFloat("TRANSFER_MAX_MB", 4096) * 1024 * 1024)
+}
+
+type TransferAck struct {
+	Seq      uint64 `json:"seq"`
+	OK       bool   `json:"ok"`
+	Done     bool   `json:"done,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Received int64  `json:"received"`
+}
+
+func transferHandler(c echo.Context) error {
+	fullPath, err := sanitizePath(c.QueryParam("path"))
+	if err != nil || fullPath == MinecraftDir {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_path",
+			Message: "A valid file path is required",
+		})
+	}
+	if status, denied := protectedDenied(c, c.QueryParam("path"), false, confirmedParam(c)); denied != nil {
+		return c.JSON(status, denied)
+	}
+
+	websocket.Handler(func(ws *websocket.Conn) {
+		defer ws.Close()
+		ws.PayloadType = websocket.BinaryFrame
+		ws.MaxPayloadBytes = frameHeaderSize + maxFrameSize
+
+		if err := receiveTransfer(c, ws, fullPath); err != nil {
+			log.Printf("[e] Transfer of %s failed: %v", c.QueryParam("path"), err)
+			return
+		}
+		log.Printf("[i] Uploaded file: %s", c.QueryParam("path"))
+	}).ServeHTTP(c.Response(), c.Request())
+	return nil
+}
+
+// receiveTransfer writes the frames sent over ws to fullPath, and records the
+// finished file like the other file writes do.
+func receiveTransfer(c echo.Context, ws *websocket.Conn, fullPath string) error {
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		websocket.JSON.Send(ws, TransferAck{Error: err.Error()})
+		return err
+	}
+
+	// Every transfer gets its own part file, so two uploads to the same path
+	// don't write into each other.
+	out, err := os.CreateTemp(filepath.Dir(fullPath), "."+filepath.Base(fullPath)+".*.part")
+	if err != nil {
+		websocket.JSON.Send(ws, TransferAck{Error: err.Error()})
+		return err
+	}
+	partPath := out.Name()
+	if err := out.Chmod(0644); err != nil {
+		out.Close()
+		os.Remove(partPath)
+		websocket.JSON.Send(ws, TransferAck{Error: err.Error()})
+		return err
+	}
+	defer func() {
+		out.Close()
+		os.Remove(partPath)
+	}()
+
+	var (
+		next     uint64
+		received int64
+		maxSize  = transferMaxSize()
+	)
+	fileHash := sha256.New()
+
+	for {
+		var frame []byte
+		if err := websocket.Message.Receive(ws, &frame); err != nil {
+			return err
+		}
+		if len(frame) < frameHeaderSize {
+			websocket.JSON.Send(ws, TransferAck{Error: "frame too short", Received: received})
+			continue
+		}
+
+		seq := binary.BigEndian.Uint64(frame[1:9])
+		sum := frame[9:frameHeaderSize]
+		payload := frame[frameHeaderSize:]
+
+		switch frame[0] {
+		case frameData:
+			if seq < next {
+				// Duplicate of a frame whose ack got lost.
+				websocket.JSON.Send(ws, TransferAck{Seq: seq, OK: true, Received: received})
+				continue
+			}
+			if seq > next {
+				websocket.JSON.Send(ws, TransferAck{Seq: seq, Error: "unexpected frame, expected " + strconv.FormatUint(next, 10), Received: received})
+				continue
+			}
+			if got := sha256.Sum256(payload); !bytes.Equal(got[:], sum) {
+				websocket.JSON.Send(ws, TransferAck{Seq: seq, Error: "checksum mismatch", Received: received})
+				continue
+			}
+			if received+int64(len(payload)) > maxSize {
+				websocket.JSON.Send(ws, TransferAck{Seq: seq, Error: fmt.Sprintf("file exceeds the %d MB transfer limit", maxSize/1024/1024), Received: received})
+				return errTransferTooLarge
+			}
+			if _, err := out.Write(payload); err != nil {
+				websocket.JSON.Send(ws, TransferAck{Seq: seq, Error: err.Error(), Received: received})
+				return err
+			}
+			fileHash.Write(payload)
+			received += int64(len(payload))
+			next++
+			websocket.JSON.Send(ws, TransferAck{Seq: seq, OK: true, Received: received})
+
+		case frameFinish:
+			if seq != next {
+				websocket.JSON.Send(ws, TransferAck{Seq: seq, Error: "missing frames, received " + strconv.FormatUint(next, 10), Received: received})
+				continue
+			}
+			if got := fileHash.Sum(nil); !bytes.Equal(got, sum) {
+				websocket.JSON.Send(ws, TransferAck{Seq: seq, Error: "file checksum mismatch: " + hex.EncodeToString(got), Received: received})
+				return os.ErrInvalid
+			}
+			if err := out.Close(); err != nil {
+				websocket.JSON.Send(ws, TransferAck{Seq: seq, Error: err.Error(), Received: received})
+				return err
+			}
+			if err := os.Rename(partPath, fullPath); err != nil {
+				websocket.JSON.Send(ws, TransferAck{Seq: seq, Error: err.Error(), Received: received})
+				return err
+			}
+			path := c.QueryParam("path")
+			recordRecentFile(currentUser(c), path)
+			recordConfigChange(c, "Upload "+cleanFilePath(path), path)
+			return websocket.JSON.Send(ws, TransferAck{Seq: seq, OK: true, Done: true, Received: received})
+
+		default:
+			websocket.JSON.Send(ws, TransferAck{Seq: seq, Error: "unknown frame type", Received: received})
+		}
+	}
+}
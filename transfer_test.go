@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+)
+
+// dialTransfer opens a transfer of path against a test server.
+func dialTransfer(t *testing.T, path string) *websocket.Conn {
+	t.Helper()
+	e := echo.New()
+	e.GET("/api/files/transfer", transferHandler)
+	srv := httptest.NewServer(e)
+	t.Cleanup(srv.Close)
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/files/transfer?path=" + path
+	ws, err := websocket.Dial(url, "", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
+
+func sendFrame(t *testing.T, ws *websocket.Conn, kind byte, seq uint64, sum []byte, payload []byte) TransferAck {
+	t.Helper()
+	frame := make([]byte, frameHeaderSize, frameHeaderSize+len(payload))
+	frame[0] = kind
+	binary.BigEndian.PutUint64(frame[1:9], seq)
+	copy(frame[9:], sum)
+	if err := websocket.Message.Send(ws, append(frame, payload...)); err != nil {
+		t.Fatal(err)
+	}
+	var ack TransferAck
+	if err := websocket.JSON.Receive(ws, &ack); err != nil {
+		t.Fatal(err)
+	}
+	return ack
+}
+
+// partFiles returns the part files left in the server directory.
+func partFiles(t *testing.T) []string {
+	t.Helper()
+	parts, err := filepath.Glob(filepath.Join(MinecraftDir, ".*.part"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parts
+}
+
+func TestTransfer(t *testing.T) {
+	ws := dialTransfer(t, "transfer-test.txt")
+	defer os.Remove(filepath.Join(MinecraftDir, "transfer-test.txt"))
+
+	data := []byte("hello over the socket")
+	sum := sha256.Sum256(data)
+	if ack := sendFrame(t, ws, frameData, 0, sum[:], data); !ack.OK {
+		t.Fatalf("data frame: %+v", ack)
+	}
+	if ack := sendFrame(t, ws, frameFinish, 1, sum[:], nil); !ack.OK || !ack.Done {
+		t.Fatalf("finish frame: %+v", ack)
+	}
+	got, err := os.ReadFile(filepath.Join(MinecraftDir, "transfer-test.txt"))
+	if err != nil || string(got) != string(data) {
+		t.Errorf("transferred file holds %q (%v), want %q", got, err, data)
+	}
+	if parts := partFiles(t); len(parts) != 0 {
+		t.Errorf("part files left behind: %v", parts)
+	}
+}
+
+func TestTransferMaxSize(t *testing.T) {
+	t.Setenv("TRANSFER_MAX_MB", "0.0001")
+	ws := dialTransfer(t, "transfer-large.txt")
+
+	data := make([]byte, 200)
+	sum := sha256.Sum256(data)
+	ack := sendFrame(t, ws, frameData, 0, sum[:], data)
+	if ack.OK || ack.Error == "" {
+		t.Fatalf("frame past the limit: %+v, want an error", ack)
+	}
+	// The server hangs up, after which the part file is gone.
+	var rest []byte
+	if err := websocket.Message.Receive(ws, &rest); err == nil {
+		t.Error("connection still open after the limit was passed")
+	}
+	waitFor(t, "the part file to be removed", func() bool { return len(partFiles(t)) == 0 })
+	if _, err := os.Stat(filepath.Join(MinecraftDir, "transfer-large.txt")); !os.IsNotExist(err) {
+		t.Error("transfer past the limit left a file")
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg"
+	"pkg.bijsven.nl/MiniMC/pkg/audit"
+	"pkg.bijsven.nl/MiniMC/pkg/jobs"
+	"pkg.bijsven.nl/MiniMC/pkg/notify"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+)
+
+type UpdateResult struct {
+	Status  string `json:"status"`
+	Flavor  string `json:"flavor"`
+	Version string `json:"version"`
+	Build   int    `json:"build"`
+	Message string `json:"message,omitempty"`
+	Job     string `json:"job,omitempty"`
+	// Restarted is set when the server was stopped for the update and
+	// started again afterwards.
+	Restarted bool `json:"restarted,omitempty"`
+}
+
+// updateJar installs another version or build of the current flavor without
+// restarting MiniMC. The server must be stopped, or is stopped first and
+// started again afterwards with "stop".
+func updateJar(c echo.Context) error {
+	var request struct {
+		Version string `json:"version"`
+		Build   int    `json:"build"`
+		Stop    bool   `json:"stop"`
+	}
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+	if request.Build < 0 {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_build",
+			Message: "build must be a positive number",
+		})
+	}
+	if request.Version == "" {
+		request.Version = os.Getenv("MC_VERSION")
+	}
+	if request.Version == "" {
+		request.Version = "no_version"
+	}
+
+	if job := downloadJob(); job != nil && job.Snapshot().Status == jobs.Running {
+		return c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "download_running",
+			Message: "Another server jar download is still running",
+		})
+	}
+
+	running, status, stopErr := stopForSwap(request.Stop, "updating")
+	if stopErr != nil {
+		return c.JSON(status, stopErr)
+	}
+	user := currentUser(c)
+	audit.Record(user, "update", pkg.Flavor()+" "+request.Version)
+
+	job := jobs.New("download")
+	err := pkg.Update(pkg.Flavor(), request.Version, request.Build, job)
+	if errors.Is(err, pkg.ErrUpToDate) {
+		job.Finish(nil)
+	} else {
+		job.Finish(err)
+	}
+
+	result := UpdateResult{Status: "updated", Flavor: pkg.Flavor(), Job: job.ID()}
+	switch {
+	case err == nil:
+		log.Printf("[i] Server jar updated by %s", user)
+	case errors.Is(err, pkg.ErrUpToDate):
+		result.Status, result.Message = "up_to_date", err.Error()
+	default:
+		log.Println("[e] Update failed:", err)
+		status, code := http.StatusBadGateway, "download_failed"
+		switch {
+		case errors.Is(err, pkg.ErrUpdateRejected):
+			code, status = "update_rejected", http.StatusConflict
+		case errors.Is(err, pkg.ErrVersionNotFound), errors.Is(err, pkg.ErrNoBuilds):
+			code, status = "version_not_found", http.StatusNotFound
+		}
+		// The old jar is still in place, so a stopped server comes back
+		// on it.
+		if running {
+			if err := server.Start(); err != nil {
+				log.Println("[e] Failed to start the server after the update:", err)
+			}
+		}
+		return c.JSON(status, map[string]interface{}{
+			"error":   code,
+			"message": err.Error(),
+			"job":     job.ID(),
+		})
+	}
+
+	if m, err := pkg.LoadManifest(); err == nil {
+		result.Version, result.Build = m.Version, m.Build
+	}
+	if running {
+		if err := server.Start(); err != nil {
+			result.Message = "Updated, but the server failed to start: " + err.Error()
+		} else {
+			result.Restarted = true
+		}
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// stopForSwap stops a running server before its jar is replaced, if stop
+// allows it, after warning the players. It reports whether the server was
+// running, or the response to send when it wasn't stopped.
+func stopForSwap(stop bool, action string) (bool, int, *ErrorResponse) {
+	if !server.GetStatus() {
+		return false, 0, nil
+	}
+	if !stop {
+		return true, http.StatusConflict, &ErrorResponse{
+			Error:   "server_running",
+			Message: "Stop the server before " + action + ", or resend with \"stop\": true",
+		}
+	}
+	if err := server.Countdown("restart", action); err != nil {
+		return true, http.StatusConflict, powerError(err)
+	}
+	if err := server.StopAndWait(stopTimeout); err != nil {
+		status, code := http.StatusInternalServerError, "stop_failed"
+		if errors.Is(err, server.ErrStopTimeout) {
+			status, code = http.StatusGatewayTimeout, "stop_timeout"
+		}
+		return true, status, &ErrorResponse{
+			Error:   code,
+			Message: err.Error(),
+		}
+	}
+	return true, 0, nil
+}
+
+// runUpdateChecks looks for a newer build of the installed version every
+// UPDATE_CHECK_INTERVAL (default 6h, "0" to disable). A new build is shown in
+// /api/status, pushed to the status stream and sent to notifications. It is
+// only installed with UPDATE_AUTO_INSTALL=true, by the first check that finds
+// the server stopped.
+func runUpdateChecks() {
+	interval := 6 * time.Hour
+	if v := os.Getenv("UPDATE_CHECK_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Printf("[w] ignoring invalid UPDATE_CHECK_INTERVAL=%q", v)
+		} else {
+			interval = d
+		}
+	}
+	if interval <= 0 || server.Fake() {
+		return
+	}
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+
+	var announced pkg.AvailableUpdate
+	for {
+		update, err := pkg.CheckUpdate()
+		switch {
+		case err != nil:
+			if !errors.Is(err, os.ErrNotExist) {
+				log.Println("[w] update check failed:", err)
+			}
+		case update != nil && (update.Version != announced.Version || update.Build != announced.Build):
+			announced = *update
+			announceUpdate(update)
+		}
+		if update != nil && autoInstall() && !server.GetStatus() {
+			installUpdate(update)
+		}
+		time.Sleep(interval)
+	}
+}
+
+func announceUpdate(u *pkg.AvailableUpdate) {
+	text := fmt.Sprintf("%s %s build %d is available, build %d is installed", u.Flavor, u.Version, u.Build, u.Installed)
+	log.Println("[i]", text)
+	server.PublishUpdate(u)
+	notify.Send(notify.Message{
+		Event: "update_available",
+		Title: "Server update available",
+		Text:  text,
+	})
+
+	if autoInstall() && server.GetStatus() {
+		log.Println("[i] the update is installed once the server is stopped, or now with POST /api/update")
+	}
+}
+
+func autoInstall() bool {
+	return os.Getenv("UPDATE_AUTO_INSTALL") == "true"
+}
+
+func installUpdate(u *pkg.AvailableUpdate) {
+	job := jobs.New("download")
+	err := pkg.Update(u.Flavor, u.Version, u.Build, job)
+	if errors.Is(err, pkg.ErrUpToDate) {
+		err = nil
+	}
+	job.Finish(err)
+	if err != nil {
+		log.Println("[e] Automatic update failed:", err)
+		return
+	}
+	log.Printf("[i] Installed %s build %d automatically", u.Version, u.Build)
+}
+
+// updateStatus reports the update found by the last check, checking again
+// first with ?refresh=true.
+func updateStatus(c echo.Context) error {
+	if c.QueryParam("refresh") == "true" {
+		if _, err := pkg.CheckUpdate(); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return upgradeError(c, err)
+			}
+			return c.JSON(http.StatusBadGateway, ErrorResponse{
+				Error:   "versions_unavailable",
+				Message: err.Error(),
+			})
+		}
+	}
+	update, checked := pkg.LatestUpdate()
+	result := map[string]interface{}{
+		"available": update != nil,
+		"update":    update,
+	}
+	if !checked.IsZero() {
+		result["checked"] = checked
+	}
+	return c.JSON(http.StatusOK, result)
+}
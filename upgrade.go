@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg"
+	"pkg.bijsven.nl/MiniMC/pkg/audit"
+	"pkg.bijsven.nl/MiniMC/pkg/backup"
+	"pkg.bijsven.nl/MiniMC/pkg/plugins"
+)
+
+// recentBackupAge is how old the newest world backup may be for an upgrade
+// to go ahead without forcing it.
+const recentBackupAge = 24 * time.Hour
+
+type PluginCompatibility struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	APIVersion string `json:"api_version,omitempty"`
+	// Status is "declared" when the plugin targets the new version,
+	// "unverified" when it targets an older API, "legacy" without an
+	// api-version and "incompatible" when it needs a newer server.
+	Status string `json:"status"`
+}
+
+type UpgradeCheck struct {
+	From     string                `json:"from"`
+	To       string                `json:"to"`
+	Upgrade  bool                  `json:"upgrade"`
+	Plugins  []PluginCompatibility `json:"plugins"`
+	Backup   *backup.Backup        `json:"latest_backup"`
+	Warnings []string              `json:"warnings"`
+	Blocking []string              `json:"blocking"`
+	Acked    bool                  `json:"acknowledged"`
+	Ready    bool                  `json:"ready"`
+}
+
+// majorMinor cuts a version like "1.20.4" down to "1.20", which is the
+// granularity plugins declare in api-version.
+func majorMinor(version string) string {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return version
+	}
+	return parts[0] + "." + parts[1]
+}
+
+func checkUpgrade(to string) (*UpgradeCheck, error) {
+	m, err := pkg.LoadManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	check := &UpgradeCheck{
+		From:     m.Version,
+		To:       to,
+		Upgrade:  pkg.CompareVersions(to, m.Version) > 0,
+		Plugins:  []PluginCompatibility{},
+		Warnings: []string{},
+		Blocking: []string{},
+		Acked:    pkg.UpgradeAcknowledged(m.Version, to),
+	}
+	if pkg.CompareVersions(to, m.Version) < 0 {
+		check.Blocking = append(check.Blocking, "Minecraft worlds can't be downgraded, restore a backup made on "+to+" instead")
+	}
+	if check.Upgrade {
+		check.Warnings = append(check.Warnings, "Worlds are converted on the first start on "+to+" and can't be opened with "+m.Version+" afterwards")
+	}
+
+	installed, err := plugins.Installed()
+	if err != nil {
+		return nil, err
+	}
+	target := majorMinor(to)
+	for _, d := range installed {
+		p := PluginCompatibility{Name: d.Name, Version: d.Version, APIVersion: d.APIVersion}
+		switch {
+		case d.APIVersion == "":
+			p.Status = "legacy"
+			check.Warnings = append(check.Warnings, d.Name+" declares no api-version and runs in legacy mode")
+		case pkg.CompareVersions(d.APIVersion, target) > 0:
+			p.Status = "incompatible"
+			check.Blocking = append(check.Blocking, fmt.Sprintf("%s requires API %s, newer than %s", d.Name, d.APIVersion, to))
+		case majorMinor(d.APIVersion) == target:
+			p.Status = "declared"
+		default:
+			p.Status = "unverified"
+			check.Warnings = append(check.Warnings, fmt.Sprintf("%s targets API %s, check for an update for %s", d.Name, d.APIVersion, target))
+		}
+		check.Plugins = append(check.Plugins, p)
+	}
+
+	backups, err := backup.List()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for i, b := range backups {
+		if !b.Corrupt && (containsPath(b.Paths, ".") || containsPath(b.Paths, "world")) {
+			check.Backup = &backups[i]
+			break
+		}
+	}
+	switch {
+	case check.Backup == nil:
+		check.Blocking = append(check.Blocking, "No backup of the worlds exists")
+	case time.Since(check.Backup.Created) > recentBackupAge:
+		check.Blocking = append(check.Blocking, "The newest world backup is older than "+recentBackupAge.String())
+	}
+
+	check.Ready = len(check.Blocking) == 0
+	return check, nil
+}
+
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+func upgradeCheck(c echo.Context) error {
+	version := c.QueryParam("version")
+	if version == "" {
+		version = os.Getenv("MC_VERSION")
+	}
+	if version == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "missing_version",
+			Message: "Pass the target version as ?version=",
+		})
+	}
+
+	check, err := checkUpgrade(version)
+	if err != nil {
+		return upgradeError(c, err)
+	}
+	return c.JSON(http.StatusOK, check)
+}
+
+// acknowledgeUpgrade lets the downloader install the target version on the
+// next start. Blocking problems must be overridden with "force".
+func acknowledgeUpgrade(c echo.Context) error {
+	var request struct {
+		Version string `json:"version"`
+		Force   bool   `json:"force"`
+	}
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+	if request.Version == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "missing_version",
+			Message: "version is required",
+		})
+	}
+
+	check, err := checkUpgrade(request.Version)
+	if err != nil {
+		return upgradeError(c, err)
+	}
+	if !check.Upgrade {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "not_an_upgrade",
+			Message: request.Version + " is not newer than " + check.From,
+		})
+	}
+	if !check.Ready && !request.Force {
+		return c.JSON(http.StatusConflict, map[string]interface{}{
+			"error":   "preflight_failed",
+			"message": strings.Join(check.Blocking, "; "),
+			"check":   check,
+		})
+	}
+
+	user, _ := c.Get("user").(string)
+	err = pkg.AcknowledgeUpgrade(pkg.UpgradeAck{
+		From:    check.From,
+		Version: request.Version,
+		User:    user,
+		Time:    time.Now(),
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "write_error",
+			Message: err.Error(),
+		})
+	}
+
+	audit.Record(user, "upgrade_ack", check.From+" -> "+request.Version)
+	log.Printf("[i] Upgrade from %s to %s acknowledged by %s", check.From, request.Version, user)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Upgrade acknowledged, install it with POST /api/update or set MC_VERSION=" + request.Version + " and restart MiniMC",
+		"check":   check,
+	})
+}
+
+func upgradeError(c echo.Context, err error) error {
+	if errors.Is(err, os.ErrNotExist) {
+		return c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "unknown_version",
+			Message: "No installed version found",
+		})
+	}
+	return c.JSON(http.StatusInternalServerError, ErrorResponse{
+		Error:   "read_error",
+		Message: err.Error(),
+	})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg"
+)
+
+type VersionList struct {
+	Flavor   string   `json:"flavor"`
+	Versions []string `json:"versions"`
+	// Version and Builds are only set when a version was asked for.
+	Version string          `json:"version,omitempty"`
+	Builds  []pkg.BuildInfo `json:"builds,omitempty"`
+}
+
+// listVersions returns the versions a flavor offers, MC_FLAVOR by default,
+// oldest first. With ?version= it also lists that version's builds, so a
+// version can be picked before anything is downloaded.
+func listVersions(c echo.Context) error {
+	flavor := c.QueryParam("flavor")
+	if flavor == "" {
+		flavor = pkg.Flavor()
+	}
+	provider, err := pkg.GetProvider(flavor)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "unknown_flavor",
+			Message: err.Error(),
+		})
+	}
+
+	versions, err := provider.Versions()
+	if err != nil {
+		return c.JSON(http.StatusBadGateway, ErrorResponse{
+			Error:   "versions_unavailable",
+			Message: err.Error(),
+		})
+	}
+	list := VersionList{Flavor: flavor, Versions: versions}
+
+	if version := c.QueryParam("version"); version != "" {
+		builds, err := pkg.ListBuilds(provider, version)
+		if errors.Is(err, pkg.ErrVersionNotFound) {
+			return c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "version_not_found",
+				Message: err.Error(),
+			})
+		}
+		if err != nil {
+			return c.JSON(http.StatusBadGateway, ErrorResponse{
+				Error:   "versions_unavailable",
+				Message: err.Error(),
+			})
+		}
+		list.Version, list.Builds = version, builds
+	}
+	return c.JSON(http.StatusOK, list)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+
+	"pkg.bijsven.nl/MiniMC/pkg/scheduler"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+)
+
+// registerWorldActions adds schedule actions for difficulty, time and
+// weather, e.g. hard difficulty on weekends or always day on a creative
+// server.
+func registerWorldActions() {
+	scheduler.RegisterAction("difficulty", difficultyAction)
+	scheduler.RegisterCheck("difficulty", func(params map[string]string) error {
+		return server.CheckDifficulty(params["difficulty"])
+	})
+	scheduler.RegisterAction("time", timeAction)
+	scheduler.RegisterCheck("time", func(params map[string]string) error {
+		return server.CheckTime(params["time"])
+	})
+	scheduler.RegisterAction("weather", weatherAction)
+	scheduler.RegisterCheck("weather", func(params map[string]string) error {
+		_, err := weatherParams(params)
+		return err
+	})
+}
+
+// difficultyAction sets the difficulty, e.g. "hard" for the weekend and back
+// to "normal" on Monday.
+func difficultyAction(params map[string]string) (string, error) {
+	if err := server.SetDifficulty(params["difficulty"]); err != nil {
+		return "", err
+	}
+	return "difficulty set to " + params["difficulty"], nil
+}
+
+// timeAction sets the time of day, with freeze=true keeping it there.
+func timeAction(params map[string]string) (string, error) {
+	freeze := params["freeze"] == "true"
+	if err := server.SetTime(params["time"], freeze); err != nil {
+		return "", err
+	}
+	if freeze {
+		return "time set to " + params["time"] + " and frozen", nil
+	}
+	return "time set to " + params["time"], nil
+}
+
+func weatherParams(params map[string]string) (int, error) {
+	seconds := 0
+	if v, ok := params["duration"]; ok {
+		var err error
+		if seconds, err = strconv.Atoi(v); err != nil {
+			return 0, errors.New("invalid duration")
+		}
+	}
+	return seconds, server.CheckWeather(params["weather"], seconds)
+}
+
+// weatherAction changes the weather for duration seconds, with lock=true
+// keeping it that way.
+func weatherAction(params map[string]string) (string, error) {
+	seconds, err := weatherParams(params)
+	if err != nil {
+		return "", err
+	}
+	lock := params["lock"] == "true"
+	if err := server.SetWeather(params["weather"], seconds, lock); err != nil {
+		return "", err
+	}
+	if lock {
+		return "weather set to " + params["weather"] + " and locked", nil
+	}
+	return "weather set to " + params["weather"], nil
+}
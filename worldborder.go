@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+)
+
+type WorldBorderRequest struct {
+	Size    *float64 `json:"size"`
+	Seconds int      `json:"seconds"`
+	CenterX *float64 `json:"center_x"`
+	CenterZ *float64 `json:"center_z"`
+}
+
+func (r WorldBorderRequest) apply() error {
+	if (r.CenterX == nil) != (r.CenterZ == nil) {
+		return errors.New("center_x and center_z must be set together")
+	}
+	if r.Size == nil && r.CenterX == nil {
+		return errors.New("nothing to change, set size and/or center_x and center_z")
+	}
+
+	if r.CenterX != nil {
+		if err := server.SetWorldBorderCenter(*r.CenterX, *r.CenterZ); err != nil {
+			return err
+		}
+	}
+	if r.Size != nil {
+		return server.SetWorldBorder(*r.Size, r.Seconds)
+	}
+	return nil
+}
+
+// worldBorderAction lets schedules resize or move the border, e.g. shrinking
+// an event arena at a set time.
+func worldBorderAction(params map[string]string) (string, error) {
+	var r WorldBorderRequest
+	parse := func(key string) (*float64, error) {
+		v, ok := params[key]
+		if !ok {
+			return nil, nil
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, errors.New("invalid " + key)
+		}
+		return &f, nil
+	}
+
+	var err error
+	if r.Size, err = parse("size"); err != nil {
+		return "", err
+	}
+	if r.CenterX, err = parse("center_x"); err != nil {
+		return "", err
+	}
+	if r.CenterZ, err = parse("center_z"); err != nil {
+		return "", err
+	}
+	if v, ok := params["seconds"]; ok {
+		if r.Seconds, err = strconv.Atoi(v); err != nil {
+			return "", errors.New("invalid seconds")
+		}
+	}
+	if err := r.apply(); err != nil {
+		return "", err
+	}
+	return "world border updated", nil
+}
+
+func getWorldBorder(c echo.Context) error {
+	size, err := server.WorldBorderSize()
+	if err != nil {
+		return c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "worldborder_unavailable",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]float64{"size": size})
+}
+
+func setWorldBorder(c echo.Context) error {
+	var request WorldBorderRequest
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+
+	if err := request.apply(); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "worldborder_error",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "World border updated"})
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"pkg.bijsven.nl/MiniMC/pkg/audit"
+	"pkg.bijsven.nl/MiniMC/pkg/jobs"
+	"pkg.bijsven.nl/MiniMC/pkg/server"
+	"pkg.bijsven.nl/MiniMC/pkg/world"
+)
+
+func worldError(c echo.Context, err error) error {
+	if errors.Is(err, world.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "world_not_found",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(http.StatusInternalServerError, ErrorResponse{
+		Error:   "world_error",
+		Message: err.Error(),
+	})
+}
+
+func worldStats(c echo.Context) error {
+	stats, err := world.GetStats(c.Param("name"))
+	if err != nil {
+		return worldError(c, err)
+	}
+	return c.JSON(http.StatusOK, stats)
+}
+
+func worldPreview(c echo.Context) error {
+	cell, _ := strconv.Atoi(c.QueryParam("scale"))
+
+	var buf bytes.Buffer
+	if err := world.RenderPreview(&buf, c.Param("name"), c.QueryParam("dim"), cell); err != nil {
+		return worldError(c, err)
+	}
+	return c.Blob(http.StatusOK, "image/png", buf.Bytes())
+}
+
+// upgradeWorlds runs the server once with --forceUpgrade to convert every
+// chunk after a large version jump, and stops it again when done. Progress
+// is followed through the returned job.
+func upgradeWorlds(c echo.Context) error {
+	var request struct {
+		EraseCache bool `json:"erase_cache"`
+	}
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_json",
+			Message: err.Error(),
+		})
+	}
+	if server.GetStatus() {
+		return c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "server_running",
+			Message: "Stop the server before upgrading the worlds",
+		})
+	}
+	if job := jobs.Latest("world-upgrade"); job != nil && job.Snapshot().Status == jobs.Running {
+		return c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "upgrade_running",
+			Message: "The worlds are already being upgraded",
+		})
+	}
+
+	detail := "--forceUpgrade"
+	if request.EraseCache {
+		detail += " --eraseCache"
+	}
+	audit.Record(currentUser(c), "world_upgrade", detail)
+
+	job := jobs.New("world-upgrade")
+	go func() {
+		log.Println("[i] Upgrading worlds with", detail)
+		err := server.UpgradeWorlds(request.EraseCache, job)
+		job.Finish(err)
+		if err != nil {
+			log.Println("[e] World upgrade failed:", err)
+			return
+		}
+		log.Println("[i] Worlds upgraded, the server is stopped")
+	}()
+
+	return c.JSON(http.StatusAccepted, map[string]interface{}{
+		"message": "World upgrade started",
+		"job":     job.ID(),
+	})
+}